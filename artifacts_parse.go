@@ -0,0 +1,158 @@
+package neco
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+)
+
+// ReleaseArtifactsFile is the name of the source file that declares
+// the artifacts of a release in the tag of the release.
+const ReleaseArtifactsFile = "artifacts_release.go"
+
+// ParseArtifactSet parses the Go source code generated by generate-artifacts
+// and returns the declared CurrentArtifacts.
+func ParseArtifactSet(src []byte) (*ArtifactSet, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), "", src, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, decl := range f.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.VAR {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			vs := spec.(*ast.ValueSpec)
+			for i, name := range vs.Names {
+				if name.Name != "CurrentArtifacts" || i >= len(vs.Values) {
+					continue
+				}
+				lit, ok := vs.Values[i].(*ast.CompositeLit)
+				if !ok {
+					return nil, errors.New("CurrentArtifacts is not a composite literal")
+				}
+				return parseArtifactSet(lit)
+			}
+		}
+	}
+	return nil, errors.New("CurrentArtifacts is not found")
+}
+
+func parseArtifactSet(lit *ast.CompositeLit) (*ArtifactSet, error) {
+	a := new(ArtifactSet)
+	for _, elt := range lit.Elts {
+		key, value, err := keyValue(elt)
+		if err != nil {
+			return nil, err
+		}
+		switch key {
+		case "Images":
+			elts, err := compositeElts(value)
+			if err != nil {
+				return nil, err
+			}
+			for _, e := range elts {
+				var img ContainerImage
+				err := parseFields(e, map[string]interface{}{
+					"Name":       &img.Name,
+					"Repository": &img.Repository,
+					"Tag":        &img.Tag,
+					"Private":    &img.Private,
+				})
+				if err != nil {
+					return nil, err
+				}
+				a.Images = append(a.Images, img)
+			}
+		case "Debs":
+			elts, err := compositeElts(value)
+			if err != nil {
+				return nil, err
+			}
+			for _, e := range elts {
+				var deb DebianPackage
+				err := parseFields(e, map[string]interface{}{
+					"Name":       &deb.Name,
+					"Owner":      &deb.Owner,
+					"Repository": &deb.Repository,
+					"Release":    &deb.Release,
+				})
+				if err != nil {
+					return nil, err
+				}
+				a.Debs = append(a.Debs, deb)
+			}
+		case "OSImage":
+			err := parseFields(value, map[string]interface{}{
+				"Channel": &a.OSImage.Channel,
+				"Version": &a.OSImage.Version,
+			})
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unknown field of ArtifactSet: %s", key)
+		}
+	}
+	return a, nil
+}
+
+func keyValue(expr ast.Expr) (string, ast.Expr, error) {
+	kv, ok := expr.(*ast.KeyValueExpr)
+	if !ok {
+		return "", nil, errors.New("field name is missing")
+	}
+	key, ok := kv.Key.(*ast.Ident)
+	if !ok {
+		return "", nil, errors.New("invalid field name")
+	}
+	return key.Name, kv.Value, nil
+}
+
+func compositeElts(expr ast.Expr) ([]ast.Expr, error) {
+	lit, ok := expr.(*ast.CompositeLit)
+	if !ok {
+		return nil, errors.New("not a composite literal")
+	}
+	return lit.Elts, nil
+}
+
+// parseFields sets the fields of a struct literal to fields.
+// Each value of fields must be a *string or a *bool.
+func parseFields(expr ast.Expr, fields map[string]interface{}) error {
+	elts, err := compositeElts(expr)
+	if err != nil {
+		return err
+	}
+	for _, elt := range elts {
+		key, value, err := keyValue(elt)
+		if err != nil {
+			return err
+		}
+		switch p := fields[key].(type) {
+		case *string:
+			bl, ok := value.(*ast.BasicLit)
+			if !ok || bl.Kind != token.STRING {
+				return fmt.Errorf("%s is not a string", key)
+			}
+			*p, err = strconv.Unquote(bl.Value)
+			if err != nil {
+				return err
+			}
+		case *bool:
+			id, ok := value.(*ast.Ident)
+			if !ok || (id.Name != "true" && id.Name != "false") {
+				return fmt.Errorf("%s is not a bool", key)
+			}
+			*p = id.Name == "true"
+		default:
+			return fmt.Errorf("unknown field: %s", key)
+		}
+	}
+	return nil
+}
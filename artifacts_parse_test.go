@@ -0,0 +1,37 @@
+package neco
+
+import (
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseArtifactSet(t *testing.T) {
+	t.Parallel()
+
+	src, err := os.ReadFile("artifacts.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := ParseArtifactSet(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(*a, CurrentArtifacts) {
+		t.Error("unexpected artifacts:", cmp.Diff(CurrentArtifacts, *a))
+	}
+
+	invalid := map[string]string{
+		"syntax":    "package neco\nvar CurrentArtifacts = ArtifactSet{",
+		"not-found": "package neco\nvar OtherArtifacts = ArtifactSet{}\n",
+		"field":     "package neco\nvar CurrentArtifacts = ArtifactSet{Foo: 1}\n",
+		"type":      "package neco\nvar CurrentArtifacts = ArtifactSet{OSImage: OSImage{Version: 1}}\n",
+		"bool":      "package neco\nvar CurrentArtifacts = ArtifactSet{Images: []ContainerImage{{Private: \"yes\"}}}\n",
+	}
+	for name, src := range invalid {
+		if _, err := ParseArtifactSet([]byte(src)); err == nil {
+			t.Error("should fail:", name)
+		}
+	}
+}
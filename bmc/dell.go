@@ -0,0 +1,116 @@
+package bmc
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+	"strings"
+
+	"github.com/stmcginnis/gofish"
+	"github.com/stmcginnis/gofish/redfish"
+)
+
+// DellIDRACDriverName is the name of the driver for Dell iDRAC.
+const DellIDRACDriverName = "dell-idrac"
+
+// Redfish API endpoints of Dell iDRAC.
+// These values will probably not be changed. So define as constants.
+// If you want to get these values dynamically, you can get them as follows.
+//
+//	$ curl --insecure -sS -X GET -u $BMC_USER:$BMC_PASS \
+//	       https://$BMC_ADDR/redfish/v1/Managers/iDRAC.Embedded.1 | jq .Links.Oem.Dell.Jobs
+//	{
+//	  "@odata.id": "/redfish/v1/Managers/iDRAC.Embedded.1/Jobs"
+//	}
+//
+//	$ curl --insecure -sS -X GET -u $BMC_USER:$BMC_PASS \
+//	       https://$BMC_ADDR/redfish/v1/Systems/System.Embedded.1/Bios | jq '."@Redfish.Settings".SettingsObject'
+//	{
+//	  "@odata.id": "/redfish/v1/Systems/System.Embedded.1/Bios/Settings"
+//	}
+const (
+	dellRedfishJobURI          = "/redfish/v1/Managers/iDRAC.Embedded.1/Jobs"
+	dellRedfishBiosSettingsURI = "/redfish/v1/Systems/System.Embedded.1/Bios/Settings"
+)
+
+var dellTPMClearAttributes = redfish.BiosAttributes{
+	"Tpm2Hierarchy": "Clear",
+}
+
+// dellIDRACDriver implements Driver for Dell iDRAC.
+//
+// iDRAC applies pending BIOS settings only when a BIOS configuration job
+// is queued in its job queue.
+type dellIDRACDriver struct {
+	redfishDriver
+}
+
+func init() {
+	Register(dellIDRACDriver{})
+}
+
+func (dellIDRACDriver) Name() string {
+	return DellIDRACDriverName
+}
+
+func (d dellIDRACDriver) ClearTPM(ctx context.Context, client *gofish.APIClient, mt MachineType) (string, error) {
+	attrs := mt.TPMClearAttributes
+	if len(attrs) == 0 {
+		attrs = dellTPMClearAttributes
+	}
+	return d.SetBIOSAttributes(ctx, client, attrs)
+}
+
+func (d dellIDRACDriver) SetBIOSAttributes(ctx context.Context, client *gofish.APIClient, attrs redfish.BiosAttributes) (string, error) {
+	_, err := d.redfishDriver.SetBIOSAttributes(ctx, client, attrs)
+	if err != nil {
+		return "", err
+	}
+
+	payload := map[string]string{
+		"TargetSettingsURI": dellRedfishBiosSettingsURI,
+	}
+	resp, err := client.Post(dellRedfishJobURI, payload)
+	if err != nil {
+		if strings.Contains(err.Error(), "SYS011") {
+			// When a job has already been registered, "SYS011" will be returned.
+			// We face this error when we re-execute "neco tpm clear" command due to the failure of the machine restart.
+			// In order to succeed the re-executed command, ignore the "SYS011" error.
+			return "", nil
+		}
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	jobURL, err := resp.Location()
+	if err != nil {
+		return "", err
+	}
+	return path.Base(jobURL.Path), nil
+}
+
+func (dellIDRACDriver) GetJob(ctx context.Context, client *gofish.APIClient, id string) (*Job, error) {
+	resp, err := client.Get(dellRedfishJobURI + "/" + id)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var dellJob struct {
+		JobState string
+		Message  string
+	}
+	err = json.NewDecoder(resp.Body).Decode(&dellJob)
+	if err != nil {
+		return nil, err
+	}
+
+	job := &Job{ID: id, State: JobRunning, Message: dellJob.Message}
+	switch dellJob.JobState {
+	case "Completed":
+		job.State = JobCompleted
+	case "Failed", "CompletedWithErrors":
+		job.State = JobFailed
+	}
+	return job, nil
+}
@@ -0,0 +1,131 @@
+package bmc
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/cybozu-go/neco/bmc/redfishtest"
+	"github.com/stmcginnis/gofish"
+	"github.com/stmcginnis/gofish/redfish"
+)
+
+func connect(t *testing.T, s *redfishtest.Server) *gofish.APIClient {
+	t.Helper()
+	client, err := gofish.Connect(gofish.ClientConfig{
+		Endpoint:  s.URL,
+		Username:  "user",
+		Password:  "pass",
+		BasicAuth: true,
+		Insecure:  true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestDellIDRACClearTPM(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	s := redfishtest.NewServer(redfishtest.Config{
+		Username:       "user",
+		Password:       "pass",
+		BiosAttributes: map[string]interface{}{"Tpm2Hierarchy": "Enabled"},
+	})
+	defer s.Close()
+	client := connect(t, s)
+	defer client.Logout()
+
+	d, err := GetDriver(DellIDRACDriverName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jobID, err := d.ClearTPM(ctx, client, MachineType{Driver: DellIDRACDriverName})
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs := s.Jobs()
+	if len(jobs) != 1 || jobs[0].ID != jobID || jobs[0].JobState != redfishtest.JobScheduled {
+		t.Fatal("a BIOS configuration job should be scheduled:", jobID, jobs)
+	}
+	if s.PendingBiosAttributes()["Tpm2Hierarchy"] != "Clear" {
+		t.Error("Tpm2Hierarchy should be pending:", s.PendingBiosAttributes())
+	}
+
+	job, err := d.GetJob(ctx, client, jobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.State != JobRunning {
+		t.Error("scheduled job should be running:", job)
+	}
+
+	// Re-execution before the reboot is tolerated.
+	jobID2, err := d.ClearTPM(ctx, client, MachineType{Driver: DellIDRACDriverName})
+	if err != nil {
+		t.Fatal("SYS011 should be ignored:", err)
+	}
+	if jobID2 != "" || len(s.Jobs()) != 1 {
+		t.Error("another job should not be created:", jobID2, s.Jobs())
+	}
+
+	system, err := getComputerSystem(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = system.Reset(redfish.ForceRestartResetType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.BiosAttributes()["Tpm2Hierarchy"] != "Clear" {
+		t.Error("Tpm2Hierarchy should be applied on reboot:", s.BiosAttributes())
+	}
+	job, err = d.GetJob(ctx, client, jobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.State != JobCompleted {
+		t.Error("job should be completed:", job)
+	}
+
+	s.SetJobState(jobID, redfishtest.JobFailed, "BIOS configuration failed.")
+	job, err = d.GetJob(ctx, client, jobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.State != JobFailed || job.Message != "BIOS configuration failed." {
+		t.Error("job should be failed:", job)
+	}
+}
+
+func TestDellIDRACSetBIOSAttributesFailure(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	s := redfishtest.NewServer(redfishtest.Config{Username: "user", Password: "pass"})
+	defer s.Close()
+	client := connect(t, s)
+	defer client.Logout()
+
+	d, err := GetDriver(DellIDRACDriverName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Fail(http.MethodPost, redfishtest.JobsURI, http.StatusInternalServerError, "job queue is full", 1)
+	_, err = d.SetBIOSAttributes(ctx, client, redfish.BiosAttributes{"BootMode": "Uefi"})
+	if err == nil {
+		t.Error("failure of job creation should be returned")
+	}
+
+	jobID, err := d.SetBIOSAttributes(ctx, client, redfish.BiosAttributes{"BootMode": "Uefi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jobID == "" {
+		t.Error("job should be created after the failure is cleared")
+	}
+}
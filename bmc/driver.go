@@ -0,0 +1,141 @@
+// Package bmc implements vendor specific operations of BMCs as drivers.
+package bmc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/stmcginnis/gofish"
+	"github.com/stmcginnis/gofish/redfish"
+)
+
+// ErrNotSupported is returned when a driver does not support the operation.
+var ErrNotSupported = errors.New("not supported")
+
+// TPMSettings represents TPM devices and TPM related BIOS attributes.
+type TPMSettings struct {
+	Devices    []redfish.TrustedModules
+	Attributes redfish.BiosAttributes
+}
+
+// JobState is the state of a BMC job.
+type JobState string
+
+// Job states.
+const (
+	JobRunning   = JobState("running")
+	JobCompleted = JobState("completed")
+	JobFailed    = JobState("failed")
+)
+
+// Job represents a job queued in a BMC.
+type Job struct {
+	ID      string
+	State   JobState
+	Message string
+}
+
+// Driver is the interface of vendor specific BMC operations.
+type Driver interface {
+	// Name returns the name of the driver.
+	Name() string
+
+	// ClearTPM requests BMC to clear TPM devices on the next reset.
+	// This returns the ID of the job that clears TPM devices, or an empty
+	// string if the BMC does not create a job.
+	// If the machine cannot clear TPM devices, this returns ErrNotSupported.
+	ClearTPM(ctx context.Context, client *gofish.APIClient, mt MachineType) (string, error)
+
+	// ShowTPM returns TPM devices and TPM related BIOS attributes.
+	ShowTPM(ctx context.Context, client *gofish.APIClient) (*TPMSettings, error)
+
+	// SetBIOSAttributes requests BMC to change BIOS attributes on the next reset.
+	// This returns the ID of the job that applies the attributes, or an empty
+	// string if the BMC does not create a job.
+	SetBIOSAttributes(ctx context.Context, client *gofish.APIClient, attrs redfish.BiosAttributes) (string, error)
+
+	// GetJob returns the job of the ID.
+	GetJob(ctx context.Context, client *gofish.APIClient, id string) (*Job, error)
+}
+
+var (
+	driversMu sync.RWMutex
+	drivers   = make(map[string]Driver)
+)
+
+// Register registers a driver.  This panics if a driver of the same name
+// is already registered.
+func Register(d Driver) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+
+	if _, ok := drivers[d.Name()]; ok {
+		panic("bmc: driver registered twice: " + d.Name())
+	}
+	drivers[d.Name()] = d
+}
+
+// GetDriver returns the registered driver of the name.
+func GetDriver(name string) (Driver, error) {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+
+	d, ok := drivers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown BMC driver: %s", name)
+	}
+	return d, nil
+}
+
+// Drivers returns the names of the registered drivers.
+func Drivers() []string {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+
+	names := make([]string, 0, len(drivers))
+	for name := range drivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WaitJob polls the job until it completes or fails.
+func WaitJob(ctx context.Context, d Driver, client *gofish.APIClient, id string, interval time.Duration) (*Job, error) {
+	for {
+		job, err := d.GetJob(ctx, client, id)
+		if err != nil {
+			return nil, err
+		}
+		switch job.State {
+		case JobCompleted:
+			return job, nil
+		case JobFailed:
+			return job, fmt.Errorf("job %s failed: %s", id, job.Message)
+		}
+
+		select {
+		case <-ctx.Done():
+			return job, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+func getComputerSystem(client *gofish.APIClient) (*redfish.ComputerSystem, error) {
+	systems, err := client.Service.Systems()
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if the collection contains 1 computer system
+	if len(systems) != 1 {
+		return nil, fmt.Errorf("computer Systems length should be 1, actual: %d", len(systems))
+	}
+
+	return systems[0], nil
+}
@@ -0,0 +1,130 @@
+package bmc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stmcginnis/gofish"
+	"github.com/stmcginnis/gofish/redfish"
+)
+
+type fakeDriver struct {
+	redfishDriver
+	states []JobState
+}
+
+func (d *fakeDriver) Name() string {
+	return "fake"
+}
+
+func (d *fakeDriver) GetJob(ctx context.Context, client *gofish.APIClient, id string) (*Job, error) {
+	state := d.states[0]
+	if len(d.states) > 1 {
+		d.states = d.states[1:]
+	}
+	return &Job{ID: id, State: state, Message: string(state)}, nil
+}
+
+func TestMachineTypes(t *testing.T) {
+	mts := MachineTypes(map[string]MachineType{
+		"r640-cs-2": {Driver: RedfishDriverName, TPMClearAttributes: redfish.BiosAttributes{"TpmClear": "Enabled"}},
+		"new-type":  {Driver: DellIDRACDriverName},
+		"bad-type":  {Driver: "no-such-driver"},
+	})
+
+	mt, d, err := Lookup(mts, "r640-cs-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Name() != RedfishDriverName || mt.TPMClearAttributes["TpmClear"] != "Enabled" {
+		t.Error("configured machine type should override the built-in one:", mt)
+	}
+
+	_, d, err = Lookup(mts, "r640-cs-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Name() != PowerOffDriverName {
+		t.Error("unexpected driver for built-in machine type:", d.Name())
+	}
+
+	_, d, err = Lookup(mts, "new-type")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Name() != DellIDRACDriverName {
+		t.Error("unexpected driver for new machine type:", d.Name())
+	}
+
+	_, _, err = Lookup(mts, "unknown")
+	if err != ErrUnknownMachineType {
+		t.Error("unknown machine type should not be found:", err)
+	}
+	_, _, err = Lookup(mts, "bad-type")
+	if err == nil || err == ErrUnknownMachineType {
+		t.Error("unknown driver should be an error:", err)
+	}
+
+	if _, ok := DefaultMachineTypes["new-type"]; ok {
+		t.Error("DefaultMachineTypes should not be modified")
+	}
+}
+
+func TestDrivers(t *testing.T) {
+	names := Drivers()
+	expected := []string{DellIDRACDriverName, PowerOffDriverName, RedfishDriverName}
+	if len(names) != len(expected) {
+		t.Fatal("unexpected drivers:", names)
+	}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Error("unexpected drivers:", names)
+		}
+	}
+
+	d, err := GetDriver(PowerOffDriverName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = d.ClearTPM(context.Background(), nil, MachineType{})
+	if err != ErrNotSupported {
+		t.Error("power-off driver should not support clearing TPM:", err)
+	}
+	d, err = GetDriver(RedfishDriverName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = d.ClearTPM(context.Background(), nil, MachineType{})
+	if err != ErrNotSupported {
+		t.Error("redfish driver without attributes should not support clearing TPM:", err)
+	}
+}
+
+func TestWaitJob(t *testing.T) {
+	ctx := context.Background()
+
+	d := &fakeDriver{states: []JobState{JobRunning, JobRunning, JobCompleted}}
+	job, err := WaitJob(ctx, d, nil, "JID_1", time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.State != JobCompleted {
+		t.Error("job should be completed:", job)
+	}
+
+	d = &fakeDriver{states: []JobState{JobRunning, JobFailed}}
+	_, err = WaitJob(ctx, d, nil, "JID_2", time.Millisecond)
+	if err == nil {
+		t.Error("failed job should be an error")
+	}
+
+	d = &fakeDriver{states: []JobState{JobRunning}}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = WaitJob(ctx, d, nil, "JID_3", time.Millisecond)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("WaitJob should be cancelled:", err)
+	}
+}
@@ -0,0 +1,61 @@
+package bmc
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/stmcginnis/gofish/redfish"
+)
+
+// MachineType is the BMC configuration of a machine type, that is
+// the value of "machine-type" label of sabakan machines.
+type MachineType struct {
+	// Driver is the name of the BMC driver.
+	Driver string `json:"driver"`
+
+	// TPMClearAttributes are the BIOS attributes to clear TPM devices.
+	// If empty, the default attributes of the driver are used.
+	TPMClearAttributes redfish.BiosAttributes `json:"tpm_clear_attributes,omitempty"`
+}
+
+// DefaultMachineTypes are the machine types known without configuration.
+var DefaultMachineTypes = map[string]MachineType{
+	"qemu":         {Driver: PowerOffDriverName},  // Placemat VM. Clear logic is not implemented on placemat.
+	"r640-boot-1":  {Driver: PowerOffDriverName},  // Dell, TPM 1.2
+	"r640-boot-2":  {Driver: DellIDRACDriverName}, // Dell, TPM 2.0
+	"r640-cs-1":    {Driver: PowerOffDriverName},  // Dell, TPM 1.2
+	"r640-cs-2":    {Driver: DellIDRACDriverName}, // Dell, TPM 2.0
+	"r740xd-ss-1":  {Driver: PowerOffDriverName},  // Dell, TPM 1.2
+	"r740xd-ss-2":  {Driver: DellIDRACDriverName}, // Dell, TPM 2.0
+	"r6525-boot-1": {Driver: DellIDRACDriverName}, // Dell, TPM 2.0
+	"r6525-cs-1":   {Driver: DellIDRACDriverName}, // Dell, TPM 2.0
+	"r7525-ss-1":   {Driver: DellIDRACDriverName}, // Dell, TPM 2.0
+}
+
+// MachineTypes returns DefaultMachineTypes overridden by configured.
+func MachineTypes(configured map[string]MachineType) map[string]MachineType {
+	mts := make(map[string]MachineType, len(DefaultMachineTypes)+len(configured))
+	for name, mt := range DefaultMachineTypes {
+		mts[name] = mt
+	}
+	for name, mt := range configured {
+		mts[name] = mt
+	}
+	return mts
+}
+
+// ErrUnknownMachineType is returned by Lookup for unknown machine types.
+var ErrUnknownMachineType = errors.New("unknown machine type")
+
+// Lookup returns the configuration and the driver of a machine type.
+func Lookup(mts map[string]MachineType, machineType string) (MachineType, Driver, error) {
+	mt, ok := mts[machineType]
+	if !ok {
+		return mt, nil, ErrUnknownMachineType
+	}
+	d, err := GetDriver(mt.Driver)
+	if err != nil {
+		return mt, nil, fmt.Errorf("machine type %s: %w", machineType, err)
+	}
+	return mt, d, nil
+}
@@ -0,0 +1,28 @@
+package bmc
+
+import (
+	"context"
+
+	"github.com/stmcginnis/gofish"
+)
+
+// PowerOffDriverName is the name of the driver for machines whose TPM
+// devices cannot be cleared through BMC.  Such machines are shut down
+// instead of clearing TPM devices.
+const PowerOffDriverName = "power-off"
+
+type powerOffDriver struct {
+	redfishDriver
+}
+
+func init() {
+	Register(powerOffDriver{})
+}
+
+func (powerOffDriver) Name() string {
+	return PowerOffDriverName
+}
+
+func (powerOffDriver) ClearTPM(ctx context.Context, client *gofish.APIClient, mt MachineType) (string, error) {
+	return "", ErrNotSupported
+}
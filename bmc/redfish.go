@@ -0,0 +1,100 @@
+package bmc
+
+import (
+	"context"
+	"strings"
+
+	"github.com/stmcginnis/gofish"
+	"github.com/stmcginnis/gofish/redfish"
+)
+
+// RedfishDriverName is the name of the driver for DMTF standard Redfish.
+const RedfishDriverName = "redfish"
+
+// redfishDriver implements Driver with DMTF standard Redfish API.
+//
+// BIOS attributes are written to the settings object of the Bios resource
+// and take effect on the next reset.  Jobs are tasks of TaskService,
+// and their IDs are the URIs of the tasks.
+type redfishDriver struct{}
+
+func init() {
+	Register(redfishDriver{})
+}
+
+func (redfishDriver) Name() string {
+	return RedfishDriverName
+}
+
+func (d redfishDriver) ClearTPM(ctx context.Context, client *gofish.APIClient, mt MachineType) (string, error) {
+	// DMTF does not define a standard BIOS attribute to clear TPM devices.
+	if len(mt.TPMClearAttributes) == 0 {
+		return "", ErrNotSupported
+	}
+	return d.SetBIOSAttributes(ctx, client, mt.TPMClearAttributes)
+}
+
+func (redfishDriver) ShowTPM(ctx context.Context, client *gofish.APIClient) (*TPMSettings, error) {
+	system, err := getComputerSystem(client)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get BIOS attributes related to TPM.
+	tpmAttrs := redfish.BiosAttributes{}
+	bios, err := system.Bios()
+	if err != nil && !strings.Contains(err.Error(), "404") {
+		// Ignore error if return 404. It means bios endpoint is not implemented.
+		return nil, err
+	}
+	// When 404 error is returned, bios will be nil.
+	// And in some cases, bios will be nil even if ComputerSystem.BIOS() doesn't return an error.
+	// https://github.com/stmcginnis/gofish/blob/v0.7.0/redfish/computersystem.go#L635
+	if bios != nil {
+		for name, val := range bios.Attributes {
+			if !strings.HasPrefix(strings.ToLower(name), "tpm") {
+				continue
+			}
+			tpmAttrs[name] = val
+		}
+	}
+
+	return &TPMSettings{
+		Devices:    system.TrustedModules,
+		Attributes: tpmAttrs,
+	}, nil
+}
+
+func (redfishDriver) SetBIOSAttributes(ctx context.Context, client *gofish.APIClient, attrs redfish.BiosAttributes) (string, error) {
+	system, err := getComputerSystem(client)
+	if err != nil {
+		return "", err
+	}
+	bios, err := system.Bios()
+	if err != nil {
+		return "", err
+	}
+	if bios == nil {
+		return "", ErrNotSupported
+	}
+	return "", bios.UpdateBiosAttributes(attrs)
+}
+
+func (redfishDriver) GetJob(ctx context.Context, client *gofish.APIClient, id string) (*Job, error) {
+	task, err := redfish.GetTask(client, id)
+	if err != nil {
+		return nil, err
+	}
+
+	job := &Job{ID: id, State: JobRunning}
+	switch task.TaskState {
+	case redfish.CompletedTaskState:
+		job.State = JobCompleted
+	case redfish.ExceptionTaskState, redfish.KilledTaskState, redfish.CancelledTaskState:
+		job.State = JobFailed
+	}
+	if job.State == JobFailed {
+		job.Message = string(task.TaskState)
+	}
+	return job, nil
+}
@@ -0,0 +1,71 @@
+package bmc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cybozu-go/neco/bmc/redfishtest"
+	"github.com/stmcginnis/gofish/redfish"
+)
+
+func TestRedfishDriver(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	s := redfishtest.NewServer(redfishtest.Config{
+		Username: "user",
+		Password: "pass",
+		BiosAttributes: map[string]interface{}{
+			"TpmOperation":  "NoAction",
+			"TpmVisibility": "Visible",
+			"BootMode":      "Uefi",
+		},
+		TrustedModules: []redfish.TrustedModules{
+			{InterfaceType: redfish.TPM2_0InterfaceType, FirmwareVersion: "7.2.2.0"},
+		},
+		ApplySettingsOnReset: true,
+	})
+	defer s.Close()
+	client := connect(t, s)
+	defer client.Logout()
+
+	d, err := GetDriver(RedfishDriverName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tpm, err := d.ShowTPM(ctx, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tpm.Devices) != 1 || tpm.Devices[0].InterfaceType != redfish.TPM2_0InterfaceType {
+		t.Error("unexpected TPM devices:", tpm.Devices)
+	}
+	if len(tpm.Attributes) != 2 || tpm.Attributes["TpmOperation"] != "NoAction" {
+		t.Error("unexpected TPM attributes:", tpm.Attributes)
+	}
+
+	mt := MachineType{
+		Driver:             RedfishDriverName,
+		TPMClearAttributes: redfish.BiosAttributes{"TpmOperation": "Clear"},
+	}
+	jobID, err := d.ClearTPM(ctx, client, mt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jobID != "" || len(s.Jobs()) != 0 {
+		t.Error("redfish driver should not create jobs:", jobID, s.Jobs())
+	}
+
+	system, err := getComputerSystem(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = system.Reset(redfish.ForceRestartResetType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.BiosAttributes()["TpmOperation"] != "Clear" {
+		t.Error("TpmOperation should be applied on reboot:", s.BiosAttributes())
+	}
+}
@@ -0,0 +1,127 @@
+package redfishtest
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/stmcginnis/gofish/redfish"
+)
+
+// URIs of the log services served by Server.
+const (
+	ManagerLogServicesURI = "/redfish/v1/Managers/iDRAC.Embedded.1/LogServices"
+	SelURI                = "/redfish/v1/Managers/iDRAC.Embedded.1/LogServices/Sel"
+	SelEntriesURI         = "/redfish/v1/Managers/iDRAC.Embedded.1/LogServices/Sel/Entries"
+	SystemLogServicesURI  = "/redfish/v1/Systems/System.Embedded.1/LogServices"
+	PostCodesURI          = "/redfish/v1/Systems/System.Embedded.1/LogServices/PostCodes"
+	PostCodesEntriesURI   = "/redfish/v1/Systems/System.Embedded.1/LogServices/PostCodes/Entries"
+)
+
+// LogEntry is an entry of SEL or POST codes.
+type LogEntry struct {
+	Created  time.Time
+	Severity redfish.EventSeverity
+	Message  string
+}
+
+// AddSELEntry adds an entry to the System Event Log.
+func (s *Server) AddSELEntry(e LogEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sel = append(s.sel, e)
+}
+
+// AddPOSTCode adds a POST code to the PostCodes log.
+func (s *Server) AddPOSTCode(created time.Time, code string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.postCodes = append(s.postCodes, LogEntry{
+		Created:  created,
+		Severity: redfish.OKEventSeverity,
+		Message:  "POST Code: " + code,
+	})
+}
+
+// SetBootProgress sets BootProgress.LastState of the system.
+func (s *Server) SetBootProgress(lastState string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bootProgress = lastState
+	s.bootProgressTime = time.Now().UTC()
+}
+
+func logService(uri, id string, entryType redfish.LogEntryTypes, entriesURI string) map[string]interface{} {
+	return map[string]interface{}{
+		"@odata.id":    uri,
+		"Id":           id,
+		"Name":         id,
+		"LogEntryType": entryType,
+		"Entries":      link(entriesURI),
+	}
+}
+
+// getLogEntries writes the entries with their contents embedded
+// in the collection as iDRAC does.  If LogPageSize is set, this writes
+// a page starting from the $skip query parameter.
+func (s *Server) getLogEntries(w http.ResponseWriter, r *http.Request, uri string, entries []LogEntry) {
+	skip, _ := strconv.Atoi(r.URL.Query().Get("$skip"))
+	if skip < 0 || skip > len(entries) {
+		skip = len(entries)
+	}
+	end := len(entries)
+	if s.cfg.LogPageSize > 0 && skip+s.cfg.LogPageSize < end {
+		end = skip + s.cfg.LogPageSize
+	}
+
+	members := make([]map[string]interface{}, 0, end-skip)
+	for i := skip; i < end; i++ {
+		e := entries[i]
+		id := fmt.Sprint(i + 1)
+		members = append(members, map[string]interface{}{
+			"@odata.id": uri + "/" + id,
+			"Id":        id,
+			"Created":   e.Created.Format(time.RFC3339),
+			"EntryType": "SEL",
+			"Severity":  e.Severity,
+			"Message":   e.Message,
+		})
+	}
+	resp := map[string]interface{}{
+		"@odata.id":           uri,
+		"Members":             members,
+		"Members@odata.count": len(entries),
+	}
+	if end < len(entries) {
+		resp["Members@odata.nextLink"] = fmt.Sprintf("%s?$skip=%d", uri, end)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) serveLogs(w http.ResponseWriter, r *http.Request, path string) bool {
+	if r.Method != http.MethodGet {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch path {
+	case ManagerLogServicesURI:
+		writeJSON(w, http.StatusOK, collection(SelURI))
+	case SelURI:
+		writeJSON(w, http.StatusOK, logService(SelURI, "Sel", redfish.SELLogEntryTypes, SelEntriesURI))
+	case SelEntriesURI:
+		s.getLogEntries(w, r, SelEntriesURI, s.sel)
+	case SystemLogServicesURI:
+		writeJSON(w, http.StatusOK, collection(PostCodesURI))
+	case PostCodesURI:
+		writeJSON(w, http.StatusOK, logService(PostCodesURI, "PostCodes", redfish.MultipleLogEntryTypes, PostCodesEntriesURI))
+	case PostCodesEntriesURI:
+		s.getLogEntries(w, r, PostCodesEntriesURI, s.postCodes)
+	default:
+		return false
+	}
+	return true
+}
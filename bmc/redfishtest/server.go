@@ -0,0 +1,573 @@
+// Package redfishtest provides a Redfish service that simulates a BMC
+// for testing BMC operations without hardware.
+//
+// The service serves a ComputerSystem with Reset action, Bios and its
+// settings object, a Manager, and the job queue of Dell iDRAC.
+// It also serves the system event log, POST codes and BootProgress
+// to simulate a machine that fails to boot.
+package redfishtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stmcginnis/gofish/redfish"
+)
+
+// URIs of the resources served by Server.
+const (
+	ServiceRootURI  = "/redfish/v1/"
+	SystemsURI      = "/redfish/v1/Systems"
+	SystemURI       = "/redfish/v1/Systems/System.Embedded.1"
+	ResetURI        = "/redfish/v1/Systems/System.Embedded.1/Actions/ComputerSystem.Reset"
+	BiosURI         = "/redfish/v1/Systems/System.Embedded.1/Bios"
+	BiosSettingsURI = "/redfish/v1/Systems/System.Embedded.1/Bios/Settings"
+	ManagersURI     = "/redfish/v1/Managers"
+	ManagerURI      = "/redfish/v1/Managers/iDRAC.Embedded.1"
+	JobsURI         = "/redfish/v1/Managers/iDRAC.Embedded.1/Jobs"
+	ChassisURI      = "/redfish/v1/Chassis"
+	SessionsURI     = "/redfish/v1/SessionService/Sessions"
+)
+
+// DellSYS011 is the message ID returned when a BIOS configuration job
+// is requested while another one is scheduled.
+const DellSYS011 = "SYS011"
+
+const dellSYS011Message = "Pending configuration values are already committed, unable to perform another set operation."
+
+// Dell iDRAC job states.
+const (
+	JobScheduled = "Scheduled"
+	JobCompleted = "Completed"
+	JobFailed    = "Failed"
+)
+
+// Config is the initial state and behavior of Server.
+type Config struct {
+	// Username and Password are the credentials for basic authentication.
+	// If Username is empty, requests are not authenticated.
+	Username string
+	Password string
+
+	// PowerState is the initial power state.  The default is On.
+	PowerState redfish.PowerState
+
+	// BiosVersion is the version of BIOS.
+	BiosVersion string
+
+	// BiosAttributes are the initial BIOS attributes.
+	BiosAttributes map[string]interface{}
+
+	// TrustedModules are the TPM devices of the system.
+	TrustedModules []redfish.TrustedModules
+
+	// ApplySettingsOnReset makes pending BIOS settings take effect on the
+	// next boot without iDRAC jobs, as DMTF standard Redfish services do.
+	ApplySettingsOnReset bool
+
+	// LogPageSize is the maximum number of entries in a page of log entries.
+	// The rest are linked by Members@odata.nextLink as iDRAC does.
+	// 0 means no limit.
+	LogPageSize int
+}
+
+// Job is a job in the job queue of Dell iDRAC.
+type Job struct {
+	ID                string
+	JobState          string
+	Message           string
+	TargetSettingsURI string
+}
+
+type failure struct {
+	status  int
+	message string
+	count   int
+}
+
+// Server is a Redfish service simulating a BMC.
+type Server struct {
+	*httptest.Server
+
+	cfg Config
+
+	mu         sync.Mutex
+	powerState redfish.PowerState
+	attributes map[string]interface{}
+	pending    map[string]interface{}
+	jobs       []*Job
+	resets     []redfish.ResetType
+	failures   map[string]*failure
+
+	sel              []LogEntry
+	postCodes        []LogEntry
+	bootProgress     string
+	bootProgressTime time.Time
+}
+
+// NewServer starts a Redfish service over TLS.
+// The caller should call Close when finished.
+func NewServer(cfg Config) *Server {
+	s := NewUnstartedServer(cfg)
+	s.StartTLS()
+	return s
+}
+
+// NewUnstartedServer returns a new Server but doesn't start it.
+// The caller should call StartTLS and Close.
+func NewUnstartedServer(cfg Config) *Server {
+	s := &Server{
+		cfg:        cfg,
+		powerState: cfg.PowerState,
+		attributes: make(map[string]interface{}),
+		pending:    make(map[string]interface{}),
+		failures:   make(map[string]*failure),
+	}
+	if s.powerState == "" {
+		s.powerState = redfish.OnPowerState
+	}
+	for k, v := range cfg.BiosAttributes {
+		s.attributes[k] = v
+	}
+	s.Server = httptest.NewUnstartedServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Addr returns the address of the service in host:port form.
+func (s *Server) Addr() string {
+	return s.Listener.Addr().String()
+}
+
+// PowerState returns the current power state.
+func (s *Server) PowerState() redfish.PowerState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.powerState
+}
+
+// SetPowerState changes the power state without resetting the system.
+func (s *Server) SetPowerState(state redfish.PowerState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.powerState = state
+}
+
+// BiosAttributes returns the current BIOS attributes.
+func (s *Server) BiosAttributes() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return copyAttributes(s.attributes)
+}
+
+// PendingBiosAttributes returns the BIOS attributes that take effect on the next boot.
+func (s *Server) PendingBiosAttributes() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return copyAttributes(s.pending)
+}
+
+// Jobs returns the jobs of the iDRAC job queue.
+func (s *Server) Jobs() []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := make([]Job, len(s.jobs))
+	for i, j := range s.jobs {
+		jobs[i] = *j
+	}
+	return jobs
+}
+
+// SetJobState changes the state of a job.
+func (s *Server) SetJobState(id, state, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		if j.ID == id {
+			j.JobState = state
+			j.Message = message
+		}
+	}
+}
+
+// Resets returns the reset types requested so far.
+func (s *Server) Resets() []redfish.ResetType {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]redfish.ResetType(nil), s.resets...)
+}
+
+// Fail makes the next count requests of method to path fail with status
+// and an error message.  If count is 0, the requests keep failing until
+// ClearFailures is called.
+func (s *Server) Fail(method, path string, status int, message string, count int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[method+" "+path] = &failure{status: status, message: message, count: count}
+}
+
+// ClearFailures removes the failures injected by Fail.
+func (s *Server) ClearFailures() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = make(map[string]*failure)
+}
+
+func copyAttributes(attrs map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(attrs))
+	for k, v := range attrs {
+		c[k] = v
+	}
+	return c
+}
+
+func link(uri string) map[string]string {
+	return map[string]string{"@odata.id": uri}
+}
+
+func collection(members ...string) map[string]interface{} {
+	links := make([]map[string]string, len(members))
+	for i, m := range members {
+		links[i] = link(m)
+	}
+	return map[string]interface{}{
+		"Members":             links,
+		"Members@odata.count": len(links),
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, messageID, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"error": map[string]interface{}{
+			"code":    "Base.1.0.GeneralError",
+			"message": message,
+			"@Message.ExtendedInfo": []map[string]string{
+				{"MessageId": messageID, "Message": message},
+			},
+		},
+	})
+}
+
+func (s *Server) injectedFailure(method, path string) *failure {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := method + " " + path
+	f, ok := s.failures[key]
+	if !ok {
+		return nil
+	}
+	if f.count > 0 {
+		f.count--
+		if f.count == 0 {
+			delete(s.failures, key)
+		}
+	}
+	return f
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+	if path != ServiceRootURI {
+		path = strings.TrimSuffix(path, "/")
+	}
+
+	// The service root is accessible without authentication.
+	if s.cfg.Username != "" && path != ServiceRootURI {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != s.cfg.Username || password != s.cfg.Password {
+			writeError(w, http.StatusUnauthorized, "Base.1.0.NoValidSession", "authentication failed")
+			return
+		}
+	}
+	if f := s.injectedFailure(r.Method, path); f != nil {
+		writeError(w, f.status, "Base.1.0.InternalError", f.message)
+		return
+	}
+
+	if s.serveLogs(w, r, path) {
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && path == ServiceRootURI:
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"@odata.id":      ServiceRootURI,
+			"Id":             "RootService",
+			"Name":           "Root Service",
+			"RedfishVersion": "1.6.0",
+			"Systems":        link(SystemsURI),
+			"Managers":       link(ManagersURI),
+			"Chassis":        link(ChassisURI),
+			"Links": map[string]interface{}{
+				"Sessions": link(SessionsURI),
+			},
+		})
+	case r.Method == http.MethodGet && path == SystemsURI:
+		writeJSON(w, http.StatusOK, collection(SystemURI))
+	case r.Method == http.MethodGet && path == SystemURI:
+		s.getSystem(w)
+	case r.Method == http.MethodPost && path == ResetURI:
+		s.reset(w, r)
+	case r.Method == http.MethodGet && path == BiosURI:
+		s.getBios(w)
+	case r.Method == http.MethodGet && path == BiosSettingsURI:
+		s.getBiosSettings(w)
+	case r.Method == http.MethodPatch && path == BiosSettingsURI:
+		s.patchBiosSettings(w, r)
+	case r.Method == http.MethodGet && path == ManagersURI:
+		writeJSON(w, http.StatusOK, collection(ManagerURI))
+	case r.Method == http.MethodGet && path == ManagerURI:
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"@odata.id":   ManagerURI,
+			"Id":          "iDRAC.Embedded.1",
+			"Name":        "Manager",
+			"LogServices": link(ManagerLogServicesURI),
+		})
+	case r.Method == http.MethodGet && path == ChassisURI:
+		writeJSON(w, http.StatusOK, collection())
+	case r.Method == http.MethodGet && path == JobsURI:
+		s.getJobs(w)
+	case r.Method == http.MethodPost && path == JobsURI:
+		s.createJob(w, r)
+	case r.Method == http.MethodGet && strings.HasPrefix(path, JobsURI+"/"):
+		s.getJob(w, strings.TrimPrefix(path, JobsURI+"/"))
+	case r.Method == http.MethodDelete && strings.HasPrefix(path, SessionsURI):
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusNotFound, "Base.1.0.ResourceMissingAtURI", "resource not found: "+r.Method+" "+path)
+	}
+}
+
+func (s *Server) getSystem(w http.ResponseWriter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	trustedModules := s.cfg.TrustedModules
+	if trustedModules == nil {
+		trustedModules = []redfish.TrustedModules{}
+	}
+	system := map[string]interface{}{
+		"@odata.id":      SystemURI,
+		"Id":             "System.Embedded.1",
+		"Name":           "System",
+		"PowerState":     s.powerState,
+		"BiosVersion":    s.cfg.BiosVersion,
+		"Bios":           link(BiosURI),
+		"LogServices":    link(SystemLogServicesURI),
+		"TrustedModules": trustedModules,
+		"Actions": map[string]interface{}{
+			"#ComputerSystem.Reset": map[string]interface{}{
+				"Target": ResetURI,
+				"ResetType@Redfish.AllowableValues": []redfish.ResetType{
+					redfish.OnResetType,
+					redfish.ForceOffResetType,
+					redfish.ForceRestartResetType,
+					redfish.GracefulRestartResetType,
+					redfish.GracefulShutdownResetType,
+					redfish.PushPowerButtonResetType,
+					redfish.NmiResetType,
+					redfish.PowerCycleResetType,
+				},
+			},
+		},
+	}
+	if s.bootProgress != "" {
+		system["BootProgress"] = map[string]interface{}{
+			"LastState":     s.bootProgress,
+			"LastStateTime": s.bootProgressTime.Format(time.RFC3339),
+		}
+	}
+	writeJSON(w, http.StatusOK, system)
+}
+
+func (s *Server) reset(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ResetType redfish.ResetType
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Base.1.0.MalformedJSON", err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	on := s.powerState == redfish.OnPowerState
+	switch req.ResetType {
+	case redfish.OnResetType:
+		if on {
+			writeError(w, http.StatusConflict, "IDRAC.2.1.RAC0508", "Server is already powered ON.")
+			return
+		}
+		s.boot()
+	case redfish.ForceOffResetType, redfish.GracefulShutdownResetType:
+		if !on {
+			writeError(w, http.StatusConflict, "IDRAC.2.1.RAC0509", "Server is already powered OFF.")
+			return
+		}
+		s.powerState = redfish.OffPowerState
+	case redfish.ForceRestartResetType, redfish.GracefulRestartResetType:
+		if !on {
+			writeError(w, http.StatusConflict, "IDRAC.2.1.RAC0509", "Server is already powered OFF.")
+			return
+		}
+		s.boot()
+	case redfish.PowerCycleResetType, redfish.PushPowerButtonResetType:
+		if on && req.ResetType == redfish.PushPowerButtonResetType {
+			s.powerState = redfish.OffPowerState
+			break
+		}
+		s.boot()
+	case redfish.NmiResetType:
+	default:
+		writeError(w, http.StatusBadRequest, "Base.1.0.ActionParameterValueError", fmt.Sprintf("invalid reset type: %s", req.ResetType))
+		return
+	}
+	s.resets = append(s.resets, req.ResetType)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// boot powers on the system and applies pending BIOS settings.
+// s.mu must be locked.
+func (s *Server) boot() {
+	s.powerState = redfish.OnPowerState
+
+	apply := s.cfg.ApplySettingsOnReset
+	for _, j := range s.jobs {
+		if j.JobState != JobScheduled {
+			continue
+		}
+		j.JobState = JobCompleted
+		j.Message = "Job completed successfully."
+		apply = true
+	}
+	if !apply {
+		return
+	}
+	for k, v := range s.pending {
+		s.attributes[k] = v
+	}
+	s.pending = make(map[string]interface{})
+}
+
+func (s *Server) getBios(w http.ResponseWriter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"@odata.id":  BiosURI,
+		"Id":         "Bios",
+		"Name":       "BIOS Configuration Current Settings",
+		"Attributes": s.attributes,
+		"@Redfish.Settings": map[string]interface{}{
+			"SettingsObject": link(BiosSettingsURI),
+		},
+	})
+}
+
+func (s *Server) getBiosSettings(w http.ResponseWriter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"@odata.id":  BiosSettingsURI,
+		"Id":         "Settings",
+		"Name":       "BIOS Configuration Pending Settings",
+		"Attributes": s.pending,
+	})
+}
+
+func (s *Server) patchBiosSettings(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Attributes map[string]interface{}
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Base.1.0.MalformedJSON", err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for k, v := range req.Attributes {
+		s.pending[k] = v
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) getJobs(w http.ResponseWriter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	uris := make([]string, len(s.jobs))
+	for i, j := range s.jobs {
+		uris[i] = JobsURI + "/" + j.ID
+	}
+	writeJSON(w, http.StatusOK, collection(uris...))
+}
+
+func (s *Server) createJob(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		TargetSettingsURI string
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Base.1.0.MalformedJSON", err.Error())
+		return
+	}
+	if req.TargetSettingsURI != BiosSettingsURI {
+		writeError(w, http.StatusBadRequest, "Base.1.0.PropertyValueNotInList", "invalid TargetSettingsURI: "+req.TargetSettingsURI)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, j := range s.jobs {
+		if j.JobState == JobScheduled {
+			writeError(w, http.StatusBadRequest, "IDRAC.2.1."+DellSYS011, dellSYS011Message)
+			return
+		}
+	}
+
+	job := &Job{
+		ID:                fmt.Sprintf("JID_%012d", len(s.jobs)+1),
+		JobState:          JobScheduled,
+		Message:           "Task successfully scheduled.",
+		TargetSettingsURI: req.TargetSettingsURI,
+	}
+	s.jobs = append(s.jobs, job)
+
+	w.Header().Set("Location", JobsURI+"/"+job.ID)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) getJob(w http.ResponseWriter, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, j := range s.jobs {
+		if j.ID != id {
+			continue
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"@odata.id": JobsURI + "/" + j.ID,
+			"Id":        j.ID,
+			"Name":      "Configure: BIOS.Setup.1-1",
+			"JobState":  j.JobState,
+			"Message":   j.Message,
+		})
+		return
+	}
+	writeError(w, http.StatusNotFound, "Base.1.0.ResourceMissingAtURI", "job not found: "+id)
+}
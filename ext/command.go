@@ -0,0 +1,62 @@
+package ext
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/storage"
+)
+
+const commandNotifierTimeout = 30 * time.Second
+
+// CommandNotifier runs a local command for each notification.
+// The command receives WebhookPayload in JSON from stdin.
+// The event type is also given as NECO_EVENT environment variable.
+type CommandNotifier struct {
+	Path    string
+	Cluster string
+}
+
+func newCommandNotifier(ctx context.Context, st storage.Storage) (Notifier, error) {
+	path, err := st.GetCommandNotification(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	me, err := neco.MyCluster()
+	if err != nil {
+		return nil, err
+	}
+
+	return &CommandNotifier{Path: path, Cluster: me}, nil
+}
+
+func (c CommandNotifier) run(payload WebhookPayload) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), commandNotifierTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, c.Path)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Env = append(os.Environ(), "NECO_EVENT="+string(payload.Event))
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("notification command failed: %w: %s", err, string(out))
+	}
+	return nil
+}
+
+// Notify sends a notification about an event of the update process
+func (c CommandNotifier) Notify(ev Event) error {
+	return c.run(newWebhookPayload(c.Cluster, ev))
+}
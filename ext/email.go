@@ -0,0 +1,164 @@
+package ext
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/storage"
+)
+
+const defaultSMTPPort = "25"
+
+// EmailClient sends notifications by email through a SMTP relay.
+type EmailClient struct {
+	// Addr is the address of the SMTP server in "host:port" form.
+	Addr    string
+	From    string
+	To      []string
+	Cluster string
+}
+
+// ParseEmailURL parses the email notification config such as
+// "smtp://mail.example.com:25/?from=neco@example.com&to=a@example.com,b@example.com".
+func ParseEmailURL(s string) (*EmailClient, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "smtp" {
+		return nil, errors.New("scheme must be smtp: " + s)
+	}
+	if u.Hostname() == "" {
+		return nil, errors.New("no SMTP server: " + s)
+	}
+	port := u.Port()
+	if port == "" {
+		port = defaultSMTPPort
+	}
+
+	q := u.Query()
+	from, err := mail.ParseAddress(q.Get("from"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid from address: %w", err)
+	}
+	if q.Get("to") == "" {
+		return nil, errors.New("no to address: " + s)
+	}
+	var to []string
+	for _, a := range strings.Split(q.Get("to"), ",") {
+		addr, err := mail.ParseAddress(a)
+		if err != nil {
+			return nil, fmt.Errorf("invalid to address: %w", err)
+		}
+		to = append(to, addr.Address)
+	}
+
+	return &EmailClient{
+		Addr: net.JoinHostPort(u.Hostname(), port),
+		From: from.Address,
+		To:   to,
+	}, nil
+}
+
+func newEmailNotifier(ctx context.Context, st storage.Storage) (Notifier, error) {
+	emailURL, err := st.GetEmailNotification(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := ParseEmailURL(emailURL)
+	if err != nil {
+		return nil, err
+	}
+
+	me, err := neco.MyCluster()
+	if err != nil {
+		return nil, err
+	}
+	c.Cluster = me
+
+	return c, nil
+}
+
+func (c EmailClient) message(ev Event) []byte {
+	req := ev.Request
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", c.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(c.To, ", "))
+	fmt.Fprintf(&b, "Subject: [neco] [%s] %s\r\n", c.Cluster, ev.Title())
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Content-Type: text/plain; charset=UTF-8\r\n")
+	fmt.Fprintf(&b, "\r\n")
+	if text := ev.Text(); text != "" {
+		fmt.Fprintf(&b, "%s\r\n\r\n", text)
+	}
+	fmt.Fprintf(&b, "Cluster:    %s\r\n", c.Cluster)
+	fmt.Fprintf(&b, "Version:    %s\r\n", req.Version)
+	fmt.Fprintf(&b, "Servers:    %v\r\n", req.Servers)
+	fmt.Fprintf(&b, "Started at: %s\r\n", req.StartedAt.Format(time.RFC3339))
+	if ev.Message != "" {
+		if ev.Level() == LevelDanger {
+			fmt.Fprintf(&b, "Reason:     %s\r\n", ev.Message)
+		} else {
+			fmt.Fprintf(&b, "Detail:     %s\r\n", ev.Message)
+		}
+	}
+	if progress := ev.Progress(); progress != "" {
+		fmt.Fprintf(&b, "Progress:   %s\r\n", progress)
+	}
+	return b.Bytes()
+}
+
+// Send sends a message via the SMTP server.
+func (c EmailClient) Send(msg []byte) error {
+	conn, err := net.DialTimeout("tcp", c.Addr, 10*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+
+	host, _, err := net.SplitHostPort(c.Addr)
+	if err != nil {
+		return err
+	}
+	sc, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer sc.Close()
+
+	if err := sc.Mail(c.From); err != nil {
+		return err
+	}
+	for _, to := range c.To {
+		if err := sc.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := sc.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return sc.Quit()
+}
+
+// Notify sends a notification about an event of the update process
+func (c EmailClient) Notify(ev Event) error {
+	return c.Send(c.message(ev))
+}
@@ -0,0 +1,155 @@
+package ext
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cybozu-go/neco"
+)
+
+// EventType is the type of Event.
+type EventType string
+
+// Event types.
+const (
+	EventRequestCreated   EventType = "request-created"
+	EventReconfigured     EventType = "reconfigured"
+	EventStepStarted      EventType = "step-started"
+	EventStepFinished     EventType = "step-finished"
+	EventAborted          EventType = "aborted"
+	EventTimedOut         EventType = "timed-out"
+	EventCompleted        EventType = "completed"
+	EventRecovered        EventType = "recovered"
+	EventCanaryCompleted  EventType = "canary-completed"
+	EventRolloutExtended  EventType = "rollout-extended"
+	EventHealthGateFailed EventType = "health-gate-failed"
+	EventRolledBack       EventType = "rolled-back"
+)
+
+// Level is the severity of Event.
+type Level int
+
+// Levels.
+const (
+	LevelInfo Level = iota
+	LevelGood
+	LevelWarning
+	LevelDanger
+)
+
+// Event represents an event of the update process.
+type Event struct {
+	Type    EventType
+	Request neco.UpdateRequest
+
+	// LRN and Step are the boot server and the step where the event happened.
+	// They are valid only for EventStepStarted, EventStepFinished and EventAborted.
+	LRN      int
+	Step     int
+	StepName string
+
+	Message string
+
+	// Statuses are the statuses of the workers when the event happened.
+	// This may be nil.
+	Statuses map[int]*neco.UpdateStatus
+}
+
+// HasStep returns true if LRN and Step of ev are valid.
+func (ev Event) HasStep() bool {
+	switch ev.Type {
+	case EventStepStarted, EventStepFinished, EventAborted:
+		return true
+	}
+	return false
+}
+
+// Level returns the severity of ev.
+func (ev Event) Level() Level {
+	switch ev.Type {
+	case EventCompleted, EventRecovered:
+		return LevelGood
+	case EventAborted, EventTimedOut, EventHealthGateFailed:
+		return LevelDanger
+	case EventRolledBack:
+		return LevelWarning
+	}
+	return LevelInfo
+}
+
+// Title returns a short summary of ev.
+func (ev Event) Title() string {
+	switch ev.Type {
+	case EventRequestCreated:
+		return "Update begins"
+	case EventReconfigured:
+		return "Reconfiguration begins"
+	case EventStepStarted:
+		return fmt.Sprintf("Step %s started on boot server %d", neco.StepLabel(ev.Step, ev.StepName), ev.LRN)
+	case EventStepFinished:
+		return fmt.Sprintf("Step %s finished on boot server %d", neco.StepLabel(ev.Step, ev.StepName), ev.LRN)
+	case EventAborted:
+		return fmt.Sprintf("Update failed at step %s on boot server %d", neco.StepLabel(ev.Step, ev.StepName), ev.LRN)
+	case EventTimedOut:
+		return "Update timed out"
+	case EventCompleted:
+		return "Update completed successfully"
+	case EventRecovered:
+		return "Update recovered"
+	case EventCanaryCompleted:
+		return "Canary boot servers updated"
+	case EventRolloutExtended:
+		return "Update extended to all boot servers"
+	case EventHealthGateFailed:
+		return "Canary boot servers are unhealthy"
+	case EventRolledBack:
+		return "Update rolled back"
+	}
+	return string(ev.Type)
+}
+
+// Text returns a description of ev.
+func (ev Event) Text() string {
+	switch ev.Type {
+	case EventRequestCreated:
+		return "neco-worker has started the updating process."
+	case EventReconfigured:
+		return "neco-worker has started reconfiguring the boot servers."
+	case EventAborted, EventTimedOut:
+		return "there were some errors.  Please fix it manually."
+	case EventCompleted:
+		return "boot servers were updated successfully."
+	case EventRecovered:
+		return "the failed update request was cleared."
+	case EventCanaryCompleted:
+		return "neco-updater is waiting for the soak period before updating the remaining boot servers."
+	case EventRolloutExtended:
+		return "neco-worker has started updating all boot servers."
+	case EventHealthGateFailed:
+		return "the update was stopped before updating the remaining boot servers.  Please check the canary boot servers."
+	case EventRolledBack:
+		return "neco-worker has started rolling back to the last successful release.  The failed release will not be retried until `neco update unblock` is run."
+	}
+	return ""
+}
+
+// Progress returns a summary of Statuses such as "0: step 3 running, 1: step 2 aborted".
+// It returns an empty string if Statuses is empty.
+func (ev Event) Progress() string {
+	lrns := make([]int, 0, len(ev.Statuses))
+	for lrn, st := range ev.Statuses {
+		if st == nil || st.Version != ev.Request.Version {
+			continue
+		}
+		lrns = append(lrns, lrn)
+	}
+	sort.Ints(lrns)
+
+	progress := make([]string, len(lrns))
+	for i, lrn := range lrns {
+		st := ev.Statuses[lrn]
+		progress[i] = fmt.Sprintf("%d: step %s %s", lrn, neco.StepLabel(st.Step, st.StepName), st.Cond.String())
+	}
+	return strings.Join(progress, ", ")
+}
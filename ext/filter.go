@@ -0,0 +1,106 @@
+package ext
+
+import (
+	"fmt"
+	"strings"
+)
+
+var levelNames = map[string]Level{
+	"info":    LevelInfo,
+	"good":    LevelGood,
+	"warning": LevelWarning,
+	"danger":  LevelDanger,
+}
+
+var eventTypes = []EventType{
+	EventRequestCreated,
+	EventReconfigured,
+	EventStepStarted,
+	EventStepFinished,
+	EventAborted,
+	EventTimedOut,
+	EventCompleted,
+	EventRecovered,
+	EventCanaryCompleted,
+	EventRolloutExtended,
+	EventHealthGateFailed,
+	EventRolledBack,
+}
+
+// EventFilter selects events to be notified by a backend.
+// A nil EventFilter selects all events.
+type EventFilter struct {
+	types  map[EventType]bool
+	levels map[Level]bool
+}
+
+// ParseEventFilter parses a comma-separated list of event types and levels.
+// An event matches the filter if its type or level is in the list.
+// "all" matches every event.
+func ParseEventFilter(s string) (*EventFilter, error) {
+	f := &EventFilter{
+		types:  make(map[EventType]bool),
+		levels: make(map[Level]bool),
+	}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if item == "all" {
+			return nil, nil
+		}
+		if l, ok := levelNames[item]; ok {
+			f.levels[l] = true
+			continue
+		}
+		if !isEventType(EventType(item)) {
+			return nil, fmt.Errorf("unknown event type or level: %s", item)
+		}
+		f.types[EventType(item)] = true
+	}
+	return f, nil
+}
+
+func isEventType(t EventType) bool {
+	for _, et := range eventTypes {
+		if et == t {
+			return true
+		}
+	}
+	return false
+}
+
+// withoutStepEvents returns a filter that selects events other than step events.
+func withoutStepEvents() *EventFilter {
+	f := &EventFilter{types: make(map[EventType]bool)}
+	for _, t := range eventTypes {
+		if t == EventStepStarted || t == EventStepFinished {
+			continue
+		}
+		f.types[t] = true
+	}
+	return f
+}
+
+// Match returns true if ev should be notified.
+func (f *EventFilter) Match(ev Event) bool {
+	if f == nil {
+		return true
+	}
+	return f.types[ev.Type] || f.levels[ev.Level()]
+}
+
+// filteredNotifier notifies only the events selected by filter.
+type filteredNotifier struct {
+	notifier Notifier
+	filter   *EventFilter
+}
+
+// Notify implements Notifier.
+func (n filteredNotifier) Notify(ev Event) error {
+	if !n.filter.Match(ev) {
+		return nil
+	}
+	return n.notifier.Notify(ev)
+}
@@ -1,12 +1,17 @@
 package ext
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"net/url"
 	"time"
 
+	"github.com/cybozu-go/log"
 	"github.com/cybozu-go/neco/storage"
 	"golang.org/x/oauth2"
 )
@@ -92,3 +97,42 @@ func GitHubHTTPClient(ctx context.Context, st storage.Storage) (*http.Client, er
 	// Create access token and proxy configuration included *http.Client
 	return oauth2.NewClient(ctx, ts), nil
 }
+
+// postJSON posts payload encoded in JSON to url.
+func postJSON(hc *http.Client, url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+
+	resp, err := hc.Do(req)
+	if err != nil {
+		log.Warn("failed to send notification", map[string]interface{}{
+			"url_host":       req.URL.Host,
+			"content-length": len(body),
+			"error":          err,
+		})
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to send notification to %s: %s", req.URL.Host, resp.Status)
+	}
+	return nil
+}
@@ -2,51 +2,107 @@ package ext
 
 import (
 	"context"
+	"fmt"
 
-	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/log"
 	"github.com/cybozu-go/neco/storage"
 )
 
-// Notifier notifies the result of update to the outside.
+// Notifier notifies events of update to the outside.
 type Notifier interface {
-	NotifyInfo(req neco.UpdateRequest, message string) error
-	NotifySucceeded(req neco.UpdateRequest) error
-	NotifyFailure(req neco.UpdateRequest, message string) error
+	Notify(ev Event) error
 }
 
 type nopNotifier struct {
 }
 
-func (n nopNotifier) NotifyInfo(req neco.UpdateRequest, message string) error {
+func (n nopNotifier) Notify(ev Event) error {
 	return nil
 }
-func (n nopNotifier) NotifySucceeded(req neco.UpdateRequest) error {
-	return nil
-}
-func (n nopNotifier) NotifyFailure(req neco.UpdateRequest, message string) error {
-	return nil
+
+// notifierBackend creates a Notifier from the configurations in storage.
+// It returns storage.ErrNotFound if the backend is not configured.
+type notifierBackend func(ctx context.Context, st storage.Storage) (Notifier, error)
+
+// notifierBackends is the registry of Notifier implementations.
+// defaultFilter selects events to be notified unless a filter is configured
+// for the backend.  Step events are too noisy for human readers.
+var notifierBackends = []struct {
+	name          string
+	backend       notifierBackend
+	defaultFilter *EventFilter
+}{
+	{"slack", newSlackNotifier, withoutStepEvents()},
+	{"webhook", newWebhookNotifier, nil},
+	{"email", newEmailNotifier, withoutStepEvents()},
+	{"teams", newTeamsNotifier, nil},
+	{"command", newCommandNotifier, nil},
 }
 
 // NewNotifier creates a new Notifier.
+// The returned Notifier sends notifications to all configured backends.
+// Each backend receives the events selected by its filter in the background
+// until ctx is canceled.
 func NewNotifier(ctx context.Context, st storage.Storage) (Notifier, error) {
-	slackURL, err := st.GetSlackNotification(ctx)
-	if err == storage.ErrNotFound {
-		return nopNotifier{}, nil
-	}
+	var notifiers MultiNotifier
+	for _, b := range notifierBackends {
+		n, err := b.backend(ctx, st)
+		if err == storage.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
 
-	if err != nil {
-		return nil, err
-	}
+		filter := b.defaultFilter
+		spec, err := st.GetNotificationFilter(ctx, b.name)
+		switch err {
+		case nil:
+			filter, err = ParseEventFilter(spec)
+			if err != nil {
+				return nil, fmt.Errorf("invalid notification filter for %s: %w", b.name, err)
+			}
+		case storage.ErrNotFound:
+		default:
+			return nil, err
+		}
 
-	hc, err := ProxyHTTPClient(ctx, st)
-	if err != nil {
-		return nil, err
+		log.Info("notifier is configured", map[string]interface{}{
+			"backend": b.name,
+			"filter":  spec,
+		})
+		q := newQueuedNotifier(ctx, b.name, n, notifyQueueSize)
+		notifiers = append(notifiers, filteredNotifier{notifier: q, filter: filter})
 	}
 
-	me, err := neco.MyCluster()
-	if err != nil {
-		return nil, err
+	switch len(notifiers) {
+	case 0:
+		return nopNotifier{}, nil
+	case 1:
+		return notifiers[0], nil
 	}
+	return notifiers, nil
+}
+
+// MultiNotifier sends every notification to all of its Notifiers.
+// It returns the first error, if any, after trying all Notifiers.
+type MultiNotifier []Notifier
 
-	return &SlackClient{URL: slackURL, HTTP: hc, Cluster: me}, nil
+// Notify implements Notifier.
+func (m MultiNotifier) Notify(ev Event) error {
+	var firstErr error
+	for _, n := range m {
+		err := n.Notify(ev)
+		if err == nil {
+			continue
+		}
+		log.Warn("failed to notify", map[string]interface{}{
+			log.FnError: err,
+			"event":     string(ev.Type),
+		})
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
 }
@@ -0,0 +1,263 @@
+package ext
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cybozu-go/neco"
+)
+
+type recordNotifier struct {
+	events []EventType
+	err    error
+}
+
+func (n *recordNotifier) Notify(ev Event) error {
+	n.events = append(n.events, ev.Type)
+	return n.err
+}
+
+func testMultiNotifier(t *testing.T) {
+	t.Parallel()
+
+	errFail := errors.New("fail")
+	n1 := &recordNotifier{err: errFail}
+	n2 := &recordNotifier{}
+	m := MultiNotifier{n1, n2}
+
+	req := neco.UpdateRequest{Version: "1.0.0"}
+	err := m.Notify(Event{Type: EventAborted, Request: req, Message: "msg"})
+	if err != errFail {
+		t.Error("unexpected error:", err)
+	}
+	err = m.Notify(Event{Type: EventCompleted, Request: req})
+	if err != errFail {
+		t.Error("unexpected error:", err)
+	}
+
+	expected := []EventType{EventAborted, EventCompleted}
+	if !reflect.DeepEqual(n1.events, expected) {
+		t.Error("unexpected events for n1:", n1.events)
+	}
+	if !reflect.DeepEqual(n2.events, expected) {
+		t.Error("unexpected events for n2:", n2.events)
+	}
+}
+
+func testWebhookClient(t *testing.T) {
+	t.Parallel()
+
+	var received WebhookPayload
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if received.Event == EventAborted {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer ts.Close()
+
+	c := WebhookClient{URL: ts.URL, HTTP: ts.Client(), Cluster: "stage0"}
+	req := neco.UpdateRequest{
+		Version:   "1.0.0",
+		Servers:   []int{0, 1, 2},
+		StartedAt: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	err := c.Notify(Event{Type: EventRequestCreated, Request: req, Message: "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := WebhookPayload{
+		Cluster:   "stage0",
+		Event:     EventRequestCreated,
+		Title:     "Update begins",
+		Version:   "1.0.0",
+		Servers:   []int{0, 1, 2},
+		StartedAt: req.StartedAt,
+		Message:   "hello",
+	}
+	if !reflect.DeepEqual(received, expected) {
+		t.Errorf("unexpected payload: %#v", received)
+	}
+
+	err = c.Notify(Event{Type: EventStepStarted, Request: req, LRN: 1, Step: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if received.LRN == nil || *received.LRN != 1 || received.Step == nil || *received.Step != 3 {
+		t.Errorf("unexpected payload: %#v", received)
+	}
+
+	err = c.Notify(Event{Type: EventAborted, Request: req, LRN: 1, Step: 3, Message: "bye"})
+	if err == nil {
+		t.Error("error should be returned for 5xx response")
+	}
+}
+
+func testEventProgress(t *testing.T) {
+	t.Parallel()
+
+	ev := Event{
+		Type:    EventAborted,
+		Request: neco.UpdateRequest{Version: "1.0.0", Servers: []int{0, 1, 2}},
+		Statuses: map[int]*neco.UpdateStatus{
+			2: {Version: "1.0.0", Step: 3, Cond: neco.CondAbort},
+			0: {Version: "1.0.0", Step: 4, Cond: neco.CondRunning},
+			1: {Version: "0.9.0", Step: 9, Cond: neco.CondComplete},
+		},
+	}
+	expected := "0: step 4 running, 2: step 3 aborted"
+	if p := ev.Progress(); p != expected {
+		t.Error("unexpected progress:", p)
+	}
+	if ev.Level() != LevelDanger {
+		t.Error("aborted event should be danger")
+	}
+}
+
+func testParseEmailURL(t *testing.T) {
+	t.Parallel()
+
+	c, err := ParseEmailURL("smtp://mail.example.com/?from=neco@example.com&to=a@example.com,b@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Addr != "mail.example.com:25" {
+		t.Error("unexpected addr:", c.Addr)
+	}
+	if c.From != "neco@example.com" {
+		t.Error("unexpected from:", c.From)
+	}
+	if !reflect.DeepEqual(c.To, []string{"a@example.com", "b@example.com"}) {
+		t.Error("unexpected to:", c.To)
+	}
+
+	invalid := []string{
+		"http://mail.example.com/?from=neco@example.com&to=a@example.com",
+		"smtp:///?from=neco@example.com&to=a@example.com",
+		"smtp://mail.example.com/?to=a@example.com",
+		"smtp://mail.example.com/?from=neco@example.com",
+		"smtp://mail.example.com/?from=neco@example.com&to=foo",
+	}
+	for _, s := range invalid {
+		if _, err := ParseEmailURL(s); err == nil {
+			t.Error("should fail:", s)
+		}
+	}
+}
+
+func testEventFilter(t *testing.T) {
+	t.Parallel()
+
+	req := neco.UpdateRequest{Version: "1.0.0"}
+	started := Event{Type: EventStepStarted, Request: req}
+	aborted := Event{Type: EventAborted, Request: req}
+	completed := Event{Type: EventCompleted, Request: req}
+
+	f, err := ParseEventFilter("all")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.Match(started) || !f.Match(aborted) {
+		t.Error("all should match every event")
+	}
+
+	f, err = ParseEventFilter("danger, completed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Match(started) || !f.Match(aborted) || !f.Match(completed) {
+		t.Error("unexpected match for danger and completed")
+	}
+
+	f = withoutStepEvents()
+	if f.Match(started) || !f.Match(aborted) || !f.Match(completed) {
+		t.Error("step events should not match")
+	}
+
+	f, err = ParseEventFilter("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Match(aborted) {
+		t.Error("empty filter should match nothing")
+	}
+
+	if _, err := ParseEventFilter("step-started,unknown"); err == nil {
+		t.Error("unknown event type should be rejected")
+	}
+}
+
+type blockingNotifier struct {
+	unblock chan struct{}
+	events  chan EventType
+}
+
+func (n blockingNotifier) Notify(ev Event) error {
+	<-n.unblock
+	n.events <- ev.Type
+	return nil
+}
+
+func testQueuedNotifier(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	n := blockingNotifier{unblock: make(chan struct{}), events: make(chan EventType, 10)}
+	q := newQueuedNotifier(ctx, "test", n, 2)
+	req := neco.UpdateRequest{Version: "1.0.0"}
+	statuses := map[int]*neco.UpdateStatus{0: {Version: "1.0.0", Step: 1}}
+
+	// The first event is being delivered and the next two are queued.
+	types := []EventType{EventRequestCreated, EventStepStarted, EventStepFinished}
+	for i, et := range types {
+		err := q.Notify(Event{Type: et, Request: req, Statuses: statuses})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			// wait for the first event to be taken out of the queue
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+	statuses[1] = &neco.UpdateStatus{Version: "1.0.0", Step: 1}
+	if err := q.Notify(Event{Type: EventCompleted, Request: req}); err == nil {
+		t.Error("event should be dropped when the queue is full")
+	}
+
+	close(n.unblock)
+	for _, et := range types {
+		select {
+		case got := <-n.events:
+			if got != et {
+				t.Error("unexpected order of events:", got, et)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("events are not delivered")
+		}
+	}
+}
+
+func TestNotifier(t *testing.T) {
+	t.Run("MultiNotifier", testMultiNotifier)
+	t.Run("EventFilter", testEventFilter)
+	t.Run("QueuedNotifier", testQueuedNotifier)
+	t.Run("WebhookClient", testWebhookClient)
+	t.Run("EventProgress", testEventProgress)
+	t.Run("ParseEmailURL", testParseEmailURL)
+}
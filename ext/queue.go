@@ -0,0 +1,70 @@
+package ext
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/neco"
+)
+
+// notifyQueueSize is the maximum number of events waiting for a backend.
+const notifyQueueSize = 100
+
+// queuedNotifier delivers events to a backend in the background so that
+// a slow backend does not delay the caller.  Events are delivered in order.
+// If the queue is full, new events are dropped.
+type queuedNotifier struct {
+	name     string
+	notifier Notifier
+	queue    chan Event
+}
+
+// newQueuedNotifier creates a queuedNotifier and starts delivering events
+// until ctx is canceled.
+func newQueuedNotifier(ctx context.Context, name string, n Notifier, size int) *queuedNotifier {
+	q := &queuedNotifier{
+		name:     name,
+		notifier: n,
+		queue:    make(chan Event, size),
+	}
+	go q.run(ctx)
+	return q
+}
+
+func (q *queuedNotifier) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-q.queue:
+			err := q.notifier.Notify(ev)
+			if err != nil {
+				log.Warn("failed to notify", map[string]interface{}{
+					log.FnError: err,
+					"backend":   q.name,
+					"event":     string(ev.Type),
+				})
+			}
+		}
+	}
+}
+
+// Notify implements Notifier.
+func (q *queuedNotifier) Notify(ev Event) error {
+	// The caller may update Statuses after this returns.
+	if ev.Statuses != nil {
+		statuses := make(map[int]*neco.UpdateStatus, len(ev.Statuses))
+		for lrn, st := range ev.Statuses {
+			statuses[lrn] = st
+		}
+		ev.Statuses = statuses
+	}
+
+	select {
+	case q.queue <- ev:
+		return nil
+	default:
+		return fmt.Errorf("notification queue of %s is full; dropped the event", q.name)
+	}
+}
@@ -1,15 +1,13 @@
 package ext
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"time"
 
-	"github.com/cybozu-go/log"
 	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/storage"
 )
 
 // Reserved colors in Slack API
@@ -57,90 +55,70 @@ type SlackClient struct {
 	Cluster string
 }
 
-// PostWebHook posts a payload to slack
-func (c SlackClient) PostWebHook(payload Payload) error {
-	body, err := json.Marshal(payload)
+func newSlackNotifier(ctx context.Context, st storage.Storage) (Notifier, error) {
+	slackURL, err := st.GetSlackNotification(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	req, err := http.NewRequest("POST", c.URL, bytes.NewReader(body))
-	if err != nil {
-		return err
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
 
-	req = req.WithContext(ctx)
-	req.Header.Set("Content-Type", "application/json")
-
-	httpc := c.HTTP
-	if httpc == nil {
-		httpc = http.DefaultClient
+	hc, err := ProxyHTTPClient(ctx, st)
+	if err != nil {
+		return nil, err
 	}
 
-	_, err = httpc.Do(req)
+	me, err := neco.MyCluster()
 	if err != nil {
-		log.Warn("Failed to send slack notification", map[string]interface{}{
-			"content-length": len(body),
-			"error":          err,
-		})
+		return nil, err
 	}
-	return err
+
+	return &SlackClient{URL: slackURL, HTTP: hc, Cluster: me}, nil
 }
 
-// NotifyInfo sends a notification about the beginning of the update process
-func (c SlackClient) NotifyInfo(req neco.UpdateRequest, message string) error {
-	att := Attachment{
-		Color:      ColorInfo,
-		AuthorName: "Boot server updater",
-		Title:      "Update begins",
-		Text:       "neco-worker has started the updating process.",
-		Fields: []AttachmentField{
-			{Title: "Cluster", Value: c.Cluster, Short: true},
-			{Title: "Version", Value: req.Version, Short: true},
-			{Title: "Servers", Value: fmt.Sprintf("%v", req.Servers), Short: true},
-			{Title: "Started at", Value: req.StartedAt.Format(time.RFC3339), Short: true},
-			{Title: "Detail", Value: message, Short: false},
-		},
-	}
-	payload := Payload{Attachments: []Attachment{att}}
-	return c.PostWebHook(payload)
+// PostWebHook posts a payload to slack
+func (c SlackClient) PostWebHook(payload Payload) error {
+	return postJSON(c.HTTP, c.URL, payload)
 }
 
-// NotifySucceeded sends a successful notification about the update process
-func (c SlackClient) NotifySucceeded(req neco.UpdateRequest) error {
-	att := Attachment{
-		Color:      ColorGood,
-		AuthorName: "Boot server updater",
-		Title:      "Update completed successfully",
-		Text:       "boot servers were updated successfully :tada: :tada: :tada:",
-		Fields: []AttachmentField{
-			{Title: "Cluster", Value: c.Cluster, Short: true},
-			{Title: "Version", Value: req.Version, Short: true},
-			{Title: "Servers", Value: fmt.Sprintf("%v", req.Servers), Short: true},
-			{Title: "Started at", Value: req.StartedAt.Format(time.RFC3339), Short: true},
-		},
-	}
-	payload := Payload{Attachments: []Attachment{att}}
-	return c.PostWebHook(payload)
+var slackColors = map[Level]string{
+	LevelInfo:    ColorInfo,
+	LevelGood:    ColorGood,
+	LevelWarning: ColorWarning,
+	LevelDanger:  ColorDanger,
 }
 
-// NotifyFailure sends a failure notification about the update process
-func (c SlackClient) NotifyFailure(req neco.UpdateRequest, message string) error {
+// Notify sends a notification about an event of the update process
+func (c SlackClient) Notify(ev Event) error {
+	req := ev.Request
+	text := ev.Text()
+	switch ev.Type {
+	case EventCompleted:
+		text = "boot servers were updated successfully :tada: :tada: :tada:"
+	case EventAborted, EventTimedOut:
+		text = "there were some errors :crying_cat_face:.  Please fix it manually."
+	}
+
 	att := Attachment{
-		Color:      ColorDanger,
+		Color:      slackColors[ev.Level()],
 		AuthorName: "Boot server updater",
-		Title:      "Update failed",
-		Text:       "there were some errors :crying_cat_face:.  Please fix it manually.",
+		Title:      ev.Title(),
+		Text:       text,
 		Fields: []AttachmentField{
 			{Title: "Cluster", Value: c.Cluster, Short: true},
 			{Title: "Version", Value: req.Version, Short: true},
 			{Title: "Servers", Value: fmt.Sprintf("%v", req.Servers), Short: true},
 			{Title: "Started at", Value: req.StartedAt.Format(time.RFC3339), Short: true},
-			{Title: "Reason", Value: message, Short: false},
 		},
 	}
+	if ev.Message != "" {
+		title := "Detail"
+		if ev.Level() == LevelDanger {
+			title = "Reason"
+		}
+		att.Fields = append(att.Fields, AttachmentField{Title: title, Value: ev.Message, Short: false})
+	}
+	if progress := ev.Progress(); progress != "" {
+		att.Fields = append(att.Fields, AttachmentField{Title: "Progress", Value: progress, Short: false})
+	}
 	payload := Payload{Attachments: []Attachment{att}}
 	return c.PostWebHook(payload)
 }
@@ -0,0 +1,106 @@
+package ext
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/storage"
+)
+
+// Theme colors for Microsoft Teams message cards
+const (
+	TeamsColorInfo    = "439FE0"
+	TeamsColorGood    = "2EB886"
+	TeamsColorDanger  = "A30200"
+	TeamsColorWarning = "DAA038"
+)
+
+// TeamsMessageCard represents a legacy actionable message card for Microsoft Teams.
+type TeamsMessageCard struct {
+	Type       string         `json:"@type"`
+	Context    string         `json:"@context"`
+	ThemeColor string         `json:"themeColor,omitempty"`
+	Summary    string         `json:"summary"`
+	Title      string         `json:"title,omitempty"`
+	Text       string         `json:"text,omitempty"`
+	Sections   []TeamsSection `json:"sections,omitempty"`
+}
+
+// TeamsSection represents a section in TeamsMessageCard
+type TeamsSection struct {
+	Facts []TeamsFact `json:"facts,omitempty"`
+}
+
+// TeamsFact represents a name-value pair in TeamsSection
+type TeamsFact struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// TeamsClient is a Microsoft Teams incoming webhook client
+type TeamsClient struct {
+	URL     string
+	HTTP    *http.Client
+	Cluster string
+}
+
+func newTeamsNotifier(ctx context.Context, st storage.Storage) (Notifier, error) {
+	teamsURL, err := st.GetTeamsNotification(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	hc, err := ProxyHTTPClient(ctx, st)
+	if err != nil {
+		return nil, err
+	}
+
+	me, err := neco.MyCluster()
+	if err != nil {
+		return nil, err
+	}
+
+	return &TeamsClient{URL: teamsURL, HTTP: hc, Cluster: me}, nil
+}
+
+var teamsColors = map[Level]string{
+	LevelInfo:    TeamsColorInfo,
+	LevelGood:    TeamsColorGood,
+	LevelWarning: TeamsColorWarning,
+	LevelDanger:  TeamsColorDanger,
+}
+
+// Notify sends a notification about an event of the update process
+func (c TeamsClient) Notify(ev Event) error {
+	req := ev.Request
+	facts := []TeamsFact{
+		{Name: "Cluster", Value: c.Cluster},
+		{Name: "Version", Value: req.Version},
+		{Name: "Servers", Value: fmt.Sprintf("%v", req.Servers)},
+		{Name: "Started at", Value: req.StartedAt.Format(time.RFC3339)},
+	}
+	if ev.Message != "" {
+		name := "Detail"
+		if ev.Level() == LevelDanger {
+			name = "Reason"
+		}
+		facts = append(facts, TeamsFact{Name: name, Value: ev.Message})
+	}
+	if progress := ev.Progress(); progress != "" {
+		facts = append(facts, TeamsFact{Name: "Progress", Value: progress})
+	}
+
+	card := TeamsMessageCard{
+		Type:       "MessageCard",
+		Context:    "https://schema.org/extensions",
+		ThemeColor: teamsColors[ev.Level()],
+		Summary:    ev.Title(),
+		Title:      ev.Title(),
+		Text:       ev.Text(),
+		Sections:   []TeamsSection{{Facts: facts}},
+	}
+	return postJSON(c.HTTP, c.URL, card)
+}
@@ -0,0 +1,78 @@
+package ext
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/storage"
+)
+
+// WebhookPayload is the JSON object sent by WebhookClient and CommandNotifier.
+type WebhookPayload struct {
+	Cluster   string                     `json:"cluster"`
+	Event     EventType                  `json:"event"`
+	Title     string                     `json:"title"`
+	Version   string                     `json:"version"`
+	Servers   []int                      `json:"servers"`
+	Stage     string                     `json:"stage,omitempty"`
+	Targets   []int                      `json:"targets,omitempty"`
+	StartedAt time.Time                  `json:"started_at"`
+	LRN       *int                       `json:"lrn,omitempty"`
+	Step      *int                       `json:"step,omitempty"`
+	StepName  string                     `json:"step_name,omitempty"`
+	Message   string                     `json:"message,omitempty"`
+	Statuses  map[int]*neco.UpdateStatus `json:"statuses,omitempty"`
+}
+
+func newWebhookPayload(cluster string, ev Event) WebhookPayload {
+	p := WebhookPayload{
+		Cluster:   cluster,
+		Event:     ev.Type,
+		Title:     ev.Title(),
+		Version:   ev.Request.Version,
+		Servers:   ev.Request.Servers,
+		StartedAt: ev.Request.StartedAt,
+		Message:   ev.Message,
+		Statuses:  ev.Statuses,
+	}
+	if ev.Request.Strategy != nil {
+		p.Stage = ev.Request.Strategy.Stage
+		p.Targets = ev.Request.Targets()
+	}
+	if ev.HasStep() {
+		lrn, step := ev.LRN, ev.Step
+		p.LRN = &lrn
+		p.Step = &step
+		p.StepName = ev.StepName
+	}
+	return p
+}
+
+// WebhookClient posts notifications as JSON to a generic webhook.
+type WebhookClient struct {
+	URL     string
+	HTTP    *http.Client
+	Cluster string
+}
+
+func newWebhookNotifier(ctx context.Context, st storage.Storage) (Notifier, error) {
+	webhookURL, err := st.GetWebhookNotification(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	me, err := neco.MyCluster()
+	if err != nil {
+		return nil, err
+	}
+
+	// The webhook is supposed to be an intranet service.
+	return &WebhookClient{URL: webhookURL, HTTP: LocalHTTPClient(), Cluster: me}, nil
+}
+
+// Notify sends a notification about an event of the update process
+func (c WebhookClient) Notify(ev Event) error {
+	return postJSON(c.HTTP, c.URL, newWebhookPayload(c.Cluster, ev))
+}
@@ -0,0 +1,129 @@
+package neco
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FirmwareCampaign represents an application of firmware updaters
+// to machines by "neco apply-firmware".
+type FirmwareCampaign struct {
+	Name string `json:"name"`
+
+	// Assets are the names of sabakan assets of the firmware updaters.
+	Assets []string `json:"assets"`
+
+	// Expect maps names of firmware components to their versions expected
+	// after the application.  If empty, the application is verified by
+	// changes of the firmware inventory.
+	Expect map[string]string `json:"expect,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ValidateFirmwareCampaignName validates the name of a campaign.
+func ValidateFirmwareCampaignName(name string) error {
+	if name == "" {
+		return errors.New("campaign name is empty")
+	}
+	if strings.Contains(name, "/") {
+		return fmt.Errorf("campaign name must not contain '/': %s", name)
+	}
+	return nil
+}
+
+// FirmwareMachineState is the state of a machine in a firmware campaign.
+type FirmwareMachineState string
+
+// Possible states of a machine in a firmware campaign.
+const (
+	FirmwarePending   = FirmwareMachineState("pending")
+	FirmwareUploaded  = FirmwareMachineState("uploaded")
+	FirmwareScheduled = FirmwareMachineState("scheduled")
+	FirmwareRebooted  = FirmwareMachineState("rebooted")
+	FirmwareVerified  = FirmwareMachineState("verified")
+	FirmwareUnchanged = FirmwareMachineState("unchanged")
+	FirmwareFailed    = FirmwareMachineState("failed")
+)
+
+// FirmwareMachine represents a machine in a firmware campaign.
+type FirmwareMachine struct {
+	Serial string               `json:"serial"`
+	Node   string               `json:"node"`
+	BMC    string               `json:"bmc"`
+	State  FirmwareMachineState `json:"state"`
+
+	// Before maps IDs of firmware components to their versions
+	// before the application.
+	Before map[string]string `json:"before,omitempty"`
+
+	UploadedAt time.Time `json:"uploaded_at,omitempty"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	Message    string    `json:"message,omitempty"`
+}
+
+// FirmwareComponent is an item of the firmware inventory of a machine.
+type FirmwareComponent struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// FirmwareVersions returns a map from IDs of components to their versions.
+func FirmwareVersions(inventory []FirmwareComponent) map[string]string {
+	versions := make(map[string]string, len(inventory))
+	for _, c := range inventory {
+		versions[c.ID] = c.Version
+	}
+	return versions
+}
+
+// ErrFirmwareUnchanged is returned by VerifyFirmware if no component has
+// changed its version.
+var ErrFirmwareUnchanged = errors.New("firmware inventory is unchanged")
+
+// VerifyFirmware verifies the firmware inventory after an application.
+//
+// If expect is not empty, each component named in expect must have
+// the expected version.  Otherwise, some component must have a version
+// different from before, or this returns ErrFirmwareUnchanged.
+func VerifyFirmware(expect, before map[string]string, after []FirmwareComponent) error {
+	if len(expect) == 0 {
+		if len(before) == 0 {
+			return errors.New("firmware inventory before the application is unknown")
+		}
+		for _, c := range after {
+			if v, ok := before[c.ID]; !ok || v != c.Version {
+				return nil
+			}
+		}
+		return ErrFirmwareUnchanged
+	}
+
+	var mismatched []string
+	for name, version := range expect {
+		found := false
+		var actual []string
+		for _, c := range after {
+			if c.Name != name {
+				continue
+			}
+			if c.Version == version {
+				found = true
+				break
+			}
+			actual = append(actual, c.Version)
+		}
+		if !found {
+			mismatched = append(mismatched, fmt.Sprintf("%s: expected %s, actual %v", name, version, actual))
+		}
+	}
+	if len(mismatched) > 0 {
+		sort.Strings(mismatched)
+		return errors.New("unexpected firmware versions: " + strings.Join(mismatched, ", "))
+	}
+	return nil
+}
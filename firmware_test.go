@@ -0,0 +1,61 @@
+package neco
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVerifyFirmware(t *testing.T) {
+	before := map[string]string{
+		"Installed-BIOS": "1.0.0",
+		"Installed-BMC":  "5.0.0",
+	}
+	unchanged := []FirmwareComponent{
+		{ID: "Installed-BIOS", Name: "BIOS", Version: "1.0.0"},
+		{ID: "Installed-BMC", Name: "BMC", Version: "5.0.0"},
+	}
+	updated := []FirmwareComponent{
+		{ID: "Previous-BIOS", Name: "BIOS", Version: "1.0.0"},
+		{ID: "Installed-BIOS", Name: "BIOS", Version: "1.1.0"},
+		{ID: "Installed-BMC", Name: "BMC", Version: "5.0.0"},
+	}
+
+	if err := VerifyFirmware(nil, before, unchanged); err != ErrFirmwareUnchanged {
+		t.Error("unchanged inventory should be reported as unchanged:", err)
+	}
+	if err := VerifyFirmware(nil, nil, updated); err == nil || err == ErrFirmwareUnchanged {
+		t.Error("inventory should not be verified without the one before the application:", err)
+	}
+	if err := VerifyFirmware(nil, before, updated); err != nil {
+		t.Error("updated inventory should be verified:", err)
+	}
+
+	expect := map[string]string{"BIOS": "1.1.0"}
+	if err := VerifyFirmware(expect, before, updated); err != nil {
+		t.Error("expected versions should be verified:", err)
+	}
+	if err := VerifyFirmware(expect, nil, updated); err != nil {
+		t.Error("expected versions should be verified without the inventory before the application:", err)
+	}
+	expect["BMC"] = "5.1.0"
+	err := VerifyFirmware(expect, before, updated)
+	if err == nil || !strings.Contains(err.Error(), "BMC") {
+		t.Error("unexpected versions should be reported:", err)
+	}
+
+	if v := FirmwareVersions(updated); v["Installed-BIOS"] != "1.1.0" || len(v) != 3 {
+		t.Error("unexpected versions:", v)
+	}
+}
+
+func TestValidateFirmwareCampaignName(t *testing.T) {
+	if err := ValidateFirmwareCampaignName("bios-2023"); err != nil {
+		t.Error(err)
+	}
+	if err := ValidateFirmwareCampaignName(""); err == nil {
+		t.Error("empty name should be invalid")
+	}
+	if err := ValidateFirmwareCampaignName("a/b"); err == nil {
+		t.Error("name with a slash should be invalid")
+	}
+}
@@ -0,0 +1,65 @@
+package neco
+
+import "time"
+
+// MachineHealth is the latest health evaluation of a machine by sabakan-state-setter.
+type MachineHealth struct {
+	Serial      string `json:"serial"`
+	IPv4        string `json:"ipv4"`
+	MachineType string `json:"machine_type"`
+
+	// State is the sabakan state of the machine when evaluated.
+	State string `json:"state"`
+
+	// Candidate is the state decided by the evaluation.
+	// Empty means that the state is not changed.
+	Candidate string `json:"candidate"`
+
+	// Reason explains why the candidate is decided.
+	Reason string `json:"reason"`
+
+	SerfStatus         string  `json:"serf_status"`
+	SystemdUnitsFailed *string `json:"systemd_units_failed,omitempty"`
+
+	Checks   []MachineHealthCheck `json:"checks,omitempty"`
+	Warnings []string             `json:"warnings,omitempty"`
+
+	// UnhealthySince is when the machine was first judged as unhealthy.
+	// TransitionAt is when the grace period ends and the machine becomes unhealthy.
+	UnhealthySince *time.Time    `json:"unhealthy_since,omitempty"`
+	GracePeriod    time.Duration `json:"grace_period,omitempty"`
+	TransitionAt   *time.Time    `json:"transition_at,omitempty"`
+
+	EvaluatedAt time.Time `json:"evaluated_at"`
+}
+
+// MachineHealthCheck is the result of a health rule for a metric.
+type MachineHealthCheck struct {
+	Metric              string `json:"metric"`
+	Rule                string `json:"rule"`
+	Severity            string `json:"severity"`
+	Healthy             bool   `json:"healthy"`
+	Matched             int    `json:"matched"`
+	HealthyCount        int    `json:"healthy_count"`
+	MinimumHealthyCount *int   `json:"minimum_healthy_count,omitempty"`
+	Reason              string `json:"reason,omitempty"`
+
+	// Failures are the metrics that do not satisfy the rule.
+	Failures []MachineHealthFailure `json:"failures,omitempty"`
+}
+
+// MachineHealthFailure is a metric that does not satisfy a health rule.
+type MachineHealthFailure struct {
+	Labels map[string]string `json:"labels,omitempty"`
+	Value  *float64          `json:"value,omitempty"`
+	Reason string            `json:"reason"`
+}
+
+// UnhealthyMachine is a machine in the grace period of setting unhealthy state.
+// It is stored in etcd so that a new leader of sabakan-state-setter resumes the grace period.
+type UnhealthyMachine struct {
+	Serial string `json:"serial"`
+
+	// Since is when the machine was first judged as unhealthy.
+	Since time.Time `json:"since"`
+}
@@ -0,0 +1,130 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const sabakanStateSetterSubsystem = "sabakan_state_setter"
+
+var (
+	// SabakanStateSetterLeader is 1 while this sabakan-state-setter holds the leadership.
+	SabakanStateSetterLeader = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: sabakanStateSetterSubsystem,
+		Name:      "leader",
+		Help:      "1 if this sabakan-state-setter is the leader, 0 otherwise.",
+	})
+
+	// SabakanStateSetterTransitionsTotal counts state transitions of machines by from/to state.
+	SabakanStateSetterTransitionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: sabakanStateSetterSubsystem,
+		Name:      "transitions_total",
+		Help:      "The number of state transitions of machines made by sabakan-state-setter.",
+	}, []string{"from", "to"})
+
+	// SabakanStateSetterScrapeFailuresTotal counts failures to get metrics of machines.
+	SabakanStateSetterScrapeFailuresTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: sabakanStateSetterSubsystem,
+		Name:      "scrape_failures_total",
+		Help:      "The number of failures to get metrics of machines.",
+	}, []string{"address"})
+
+	// SabakanStateSetterUnhealthyGraceMachines is the number of machines in the grace period.
+	SabakanStateSetterUnhealthyGraceMachines = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: sabakanStateSetterSubsystem,
+		Name:      "unhealthy_grace_machines",
+		Help:      "The number of machines waiting for the grace period before being set unhealthy.",
+	})
+
+	// SabakanStateSetterRunDuration observes the time to run a round of state management.
+	SabakanStateSetterRunDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: sabakanStateSetterSubsystem,
+		Name:      "run_duration_seconds",
+		Help:      "The time taken to run a round of state management.",
+		Buckets:   []float64{0.1, 0.5, 1, 2.5, 5, 10, 20, 30, 60, 120},
+	})
+
+	// SabakanStateSetterRetirementsTotal counts the results of retirement of machines.
+	SabakanStateSetterRetirementsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: sabakanStateSetterSubsystem,
+		Name:      "retirements_total",
+		Help:      "The number of retirements of machines by result (succeeded or failed).",
+	}, []string{"result"})
+
+	// SabakanStateSetterShutdownsTotal counts the results of shutdown of retired machines.
+	SabakanStateSetterShutdownsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: sabakanStateSetterSubsystem,
+		Name:      "shutdowns_total",
+		Help:      "The number of shutdowns of retired machines by result (succeeded, skipped or failed).",
+	}, []string{"result"})
+
+	// SabakanStateSetterLastShutdown is the time of the last run of the shutdown cron job.
+	SabakanStateSetterLastShutdown = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: sabakanStateSetterSubsystem,
+		Name:      "last_shutdown_timestamp_seconds",
+		Help:      "The last time when the shutdown cron job ran.",
+	})
+
+	// SabakanStateSetterBreakerTripped is 1 while the circuit breaker is tripped.
+	SabakanStateSetterBreakerTripped = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: sabakanStateSetterSubsystem,
+		Name:      "breaker_tripped",
+		Help:      "1 if the circuit breaker of state transitions is tripped, 0 otherwise.",
+	})
+
+	// SabakanStateSetterBreakerBlockedMachines is the number of machines whose transitions are stopped by the circuit breaker.
+	SabakanStateSetterBreakerBlockedMachines = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: sabakanStateSetterSubsystem,
+		Name:      "breaker_blocked_machines",
+		Help:      "The number of machines whose state transitions are stopped by the circuit breaker.",
+	})
+
+	sabakanStateSetterMachines = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: sabakanStateSetterSubsystem,
+		Name:      "machines",
+		Help:      "The number of machines by state and machine type.",
+	}, []string{"state", "machine_type"})
+)
+
+// SabakanStateSetterHandler returns a http.Handler that exposes sabakan-state-setter metrics.
+func SabakanStateSetterHandler() http.Handler {
+	return newHandler(
+		SabakanStateSetterLeader,
+		SabakanStateSetterTransitionsTotal,
+		SabakanStateSetterScrapeFailuresTotal,
+		SabakanStateSetterUnhealthyGraceMachines,
+		SabakanStateSetterRunDuration,
+		SabakanStateSetterRetirementsTotal,
+		SabakanStateSetterShutdownsTotal,
+		SabakanStateSetterLastShutdown,
+		SabakanStateSetterBreakerTripped,
+		SabakanStateSetterBreakerBlockedMachines,
+		sabakanStateSetterMachines,
+	)
+}
+
+// SabakanStateSetterMachineCount is the key of the number of machines.
+type SabakanStateSetterMachineCount struct {
+	State       string
+	MachineType string
+}
+
+// SetSabakanStateSetterMachines replaces the numbers of machines by state and machine type.
+func SetSabakanStateSetterMachines(counts map[SabakanStateSetterMachineCount]int) {
+	sabakanStateSetterMachines.Reset()
+	for k, n := range counts {
+		sabakanStateSetterMachines.WithLabelValues(k.State, k.MachineType).Set(float64(n))
+	}
+}
@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSabakanStateSetterMachines(t *testing.T) {
+	SetSabakanStateSetterMachines(map[SabakanStateSetterMachineCount]int{
+		{State: "healthy", MachineType: "qemu"}:   3,
+		{State: "unhealthy", MachineType: "qemu"}: 1,
+		{State: "healthy", MachineType: "boot"}:   2,
+	})
+
+	expected := `
+# HELP neco_sabakan_state_setter_machines The number of machines by state and machine type.
+# TYPE neco_sabakan_state_setter_machines gauge
+neco_sabakan_state_setter_machines{machine_type="boot",state="healthy"} 2
+neco_sabakan_state_setter_machines{machine_type="qemu",state="healthy"} 3
+neco_sabakan_state_setter_machines{machine_type="qemu",state="unhealthy"} 1
+`
+	err := testutil.CollectAndCompare(sabakanStateSetterMachines, strings.NewReader(expected))
+	if err != nil {
+		t.Error(err)
+	}
+
+	SetSabakanStateSetterMachines(map[SabakanStateSetterMachineCount]int{
+		{State: "unhealthy", MachineType: "qemu"}: 4,
+	})
+	if n := testutil.CollectAndCount(sabakanStateSetterMachines); n != 1 {
+		t.Error("stale machine counts should be removed:", n)
+	}
+}
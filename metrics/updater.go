@@ -0,0 +1,145 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cybozu-go/neco"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const updaterSubsystem = "updater"
+
+var (
+	// UpdaterLeader is 1 while this neco-updater holds the leadership.
+	UpdaterLeader = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: updaterSubsystem,
+		Name:      "leader",
+		Help:      "1 if this neco-updater is the leader, 0 otherwise.",
+	})
+
+	// UpdaterAbortsTotal counts update requests stopped by neco-updater.
+	UpdaterAbortsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: updaterSubsystem,
+		Name:      "aborts_total",
+		Help:      "The number of update requests stopped due to abort or timeout.",
+	})
+
+	// UpdaterRollbacksTotal counts automatic rollbacks by neco-updater.
+	UpdaterRollbacksTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: updaterSubsystem,
+		Name:      "rollbacks_total",
+		Help:      "The number of failed updates rolled back automatically.",
+	})
+
+	// UpdaterLastReleaseCheck is the time of the last successful release check.
+	UpdaterLastReleaseCheck = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: updaterSubsystem,
+		Name:      "last_release_check_timestamp_seconds",
+		Help:      "The last time when neco-updater successfully checked the neco release.",
+	})
+
+	updaterRequestInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: updaterSubsystem,
+		Name:      "request_info",
+		Help:      "The version of the current update request.  The value is always 1.",
+	}, []string{"version"})
+
+	updaterRequestStopped = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: updaterSubsystem,
+		Name:      "request_stopped",
+		Help:      "1 if the current update request is stopped, 0 otherwise.",
+	})
+
+	updaterRequestStarted = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: updaterSubsystem,
+		Name:      "request_started_timestamp_seconds",
+		Help:      "The time when the current update request was started.",
+	})
+
+	updaterWorkerStep = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: updaterSubsystem,
+		Name:      "worker_step",
+		Help:      "The current update step of each boot server.",
+	}, []string{"lrn"})
+
+	updaterWorkerCondition = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: updaterSubsystem,
+		Name:      "worker_condition",
+		Help:      "The current update condition of each boot server (0: not running, 1: running, 2: aborted, 3: completed).",
+	}, []string{"lrn"})
+)
+
+// UpdaterHandler returns a http.Handler that exposes neco-updater metrics.
+func UpdaterHandler() http.Handler {
+	return newHandler(
+		UpdaterLeader,
+		UpdaterAbortsTotal,
+		UpdaterRollbacksTotal,
+		UpdaterLastReleaseCheck,
+		updaterRequestInfo,
+		updaterRequestStopped,
+		updaterRequestStarted,
+		updaterWorkerStep,
+		updaterWorkerCondition,
+	)
+}
+
+// SetUpdaterRequest records the current update request.
+// req may be nil if there is no request.
+func SetUpdaterRequest(req *neco.UpdateRequest) {
+	updaterRequestInfo.Reset()
+	if req == nil {
+		updaterRequestStopped.Set(0)
+		updaterRequestStarted.Set(0)
+		return
+	}
+
+	updaterRequestInfo.WithLabelValues(req.Version).Set(1)
+	if req.Stop {
+		updaterRequestStopped.Set(1)
+	} else {
+		updaterRequestStopped.Set(0)
+	}
+	updaterRequestStarted.Set(timestamp(req.StartedAt))
+}
+
+// SetUpdaterStatuses replaces the recorded statuses of boot servers.
+// Statuses for versions other than the current request are ignored.
+func SetUpdaterStatuses(req *neco.UpdateRequest, statuses map[int]*neco.UpdateStatus) {
+	updaterWorkerStep.Reset()
+	updaterWorkerCondition.Reset()
+	if req == nil {
+		return
+	}
+	for lrn, st := range statuses {
+		SetUpdaterStatus(req, lrn, st)
+	}
+}
+
+// SetUpdaterStatus records the status of a boot server.
+func SetUpdaterStatus(req *neco.UpdateRequest, lrn int, st *neco.UpdateStatus) {
+	if st.Version != req.Version {
+		return
+	}
+	l := strconv.Itoa(lrn)
+	updaterWorkerStep.WithLabelValues(l).Set(float64(st.Step))
+	updaterWorkerCondition.WithLabelValues(l).Set(float64(st.Cond))
+}
+
+func timestamp(t time.Time) float64 {
+	if t.IsZero() {
+		return 0
+	}
+	return float64(t.UnixNano()) / 1e9
+}
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cybozu-go/neco"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestUpdaterStatuses(t *testing.T) {
+	req := &neco.UpdateRequest{
+		Version:   "2022.12.01-1",
+		Servers:   []int{0, 1},
+		StartedAt: time.Unix(1000, 0),
+	}
+	statuses := map[int]*neco.UpdateStatus{
+		0: {Version: "2022.12.01-1", Step: 5, Cond: neco.CondRunning},
+		1: {Version: "2022.12.01-1", Step: 4, Cond: neco.CondAbort},
+		2: {Version: "2022.11.01-1", Step: 18, Cond: neco.CondComplete},
+	}
+
+	SetUpdaterRequest(req)
+	SetUpdaterStatuses(req, statuses)
+
+	expected := `
+# HELP neco_updater_worker_step The current update step of each boot server.
+# TYPE neco_updater_worker_step gauge
+neco_updater_worker_step{lrn="0"} 5
+neco_updater_worker_step{lrn="1"} 4
+`
+	err := testutil.CollectAndCompare(updaterWorkerStep, strings.NewReader(expected))
+	if err != nil {
+		t.Error(err)
+	}
+
+	expected = `
+# HELP neco_updater_request_info The version of the current update request.  The value is always 1.
+# TYPE neco_updater_request_info gauge
+neco_updater_request_info{version="2022.12.01-1"} 1
+`
+	err = testutil.CollectAndCompare(updaterRequestInfo, strings.NewReader(expected))
+	if err != nil {
+		t.Error(err)
+	}
+	if v := testutil.ToFloat64(updaterRequestStarted); v != 1000 {
+		t.Error("unexpected started timestamp:", v)
+	}
+
+	SetUpdaterRequest(nil)
+	SetUpdaterStatuses(nil, statuses)
+	if n := testutil.CollectAndCount(updaterWorkerStep); n != 0 {
+		t.Error("worker steps should be cleared:", n)
+	}
+	if n := testutil.CollectAndCount(updaterRequestInfo); n != 0 {
+		t.Error("request info should be cleared:", n)
+	}
+}
@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const workerSubsystem = "worker"
+
+var (
+	// WorkerAbortsTotal counts aborted update processes on this boot server.
+	WorkerAbortsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: workerSubsystem,
+		Name:      "aborts_total",
+		Help:      "The number of update processes aborted on this boot server.",
+	})
+
+	workerRequestInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: workerSubsystem,
+		Name:      "request_info",
+		Help:      "The version of the update request being processed.  The value is always 1.",
+	}, []string{"version"})
+
+	workerStep = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: workerSubsystem,
+		Name:      "step",
+		Help:      "The current update step of this boot server.",
+	})
+
+	workerStepDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: workerSubsystem,
+		Name:      "step_duration_seconds",
+		Help:      "The time taken to run each update step.",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 13),
+	}, []string{"step"})
+)
+
+// WorkerHandler returns a http.Handler that exposes neco-worker metrics.
+func WorkerHandler() http.Handler {
+	return newHandler(
+		WorkerAbortsTotal,
+		workerRequestInfo,
+		workerStep,
+		workerStepDuration,
+	)
+}
+
+// SetWorkerRequest records the version of the update request being processed.
+func SetWorkerRequest(version string) {
+	workerRequestInfo.Reset()
+	workerRequestInfo.WithLabelValues(version).Set(1)
+}
+
+// SetWorkerStep records the current update step.
+func SetWorkerStep(step int) {
+	workerStep.Set(float64(step))
+}
+
+// ObserveWorkerStep records the time taken to run an update step.
+func ObserveWorkerStep(step int, d time.Duration) {
+	workerStepDuration.WithLabelValues(strconv.Itoa(step)).Observe(d.Seconds())
+}
@@ -3,11 +3,13 @@ package main
 import (
 	"context"
 	"flag"
+	"net/http"
 	"time"
 
 	"github.com/cybozu-go/log"
 	"github.com/cybozu-go/neco"
 	"github.com/cybozu-go/neco/ext"
+	"github.com/cybozu-go/neco/metrics"
 	"github.com/cybozu-go/neco/storage"
 	"github.com/cybozu-go/neco/updater"
 	"github.com/cybozu-go/well"
@@ -15,7 +17,8 @@ import (
 )
 
 var (
-	flgSessionTTL = flag.String("session-ttl", "60s", "leader session's TTL")
+	flgSessionTTL  = flag.String("session-ttl", "60s", "leader session's TTL")
+	flgMetricsAddr = flag.String("metrics-addr", ":10190", "listen address of the metrics endpoint")
 )
 
 func main() {
@@ -50,6 +53,20 @@ func main() {
 		return server.Run(ctx)
 	})
 	well.Go(st.WaitConfigChange)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.UpdaterHandler())
+	ms := &well.HTTPServer{
+		Server: &http.Server{
+			Addr:    *flgMetricsAddr,
+			Handler: mux,
+		},
+	}
+	err = ms.ListenAndServe()
+	if err != nil {
+		log.ErrorExit(err)
+	}
+
 	well.Stop()
 	err = well.Wait()
 	if err != nil && !well.IsSignaled(err) {
@@ -6,6 +6,7 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -13,11 +14,16 @@ import (
 
 	"github.com/cybozu-go/log"
 	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/metrics"
 	"github.com/cybozu-go/neco/storage"
 	"github.com/cybozu-go/neco/worker"
 	"github.com/cybozu-go/well"
 )
 
+var (
+	flgMetricsAddr = flag.String("metrics-addr", ":10191", "listen address of the metrics endpoint")
+)
+
 func main() {
 	flag.Parse()
 	well.LogConfig{}.Apply()
@@ -63,6 +69,20 @@ func main() {
 		return w.Run(ctx)
 	})
 	well.Go(storage.NewStorage(ec).WaitConfigChange)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.WorkerHandler())
+	ms := &well.HTTPServer{
+		Server: &http.Server{
+			Addr:    *flgMetricsAddr,
+			Handler: mux,
+		},
+	}
+	err = ms.ListenAndServe()
+	if err != nil {
+		log.ErrorExit(err)
+	}
+
 	well.Stop()
 	err = well.Wait()
 	if err != nil && !well.IsSignaled(err) {
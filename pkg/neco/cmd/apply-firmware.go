@@ -2,14 +2,17 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"path/filepath"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/cybozu-go/log"
 	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/storage"
 	"github.com/cybozu-go/sabakan/v2"
 	"github.com/cybozu-go/sabakan/v2/client"
 	"github.com/cybozu-go/well"
@@ -25,25 +28,85 @@ var applyFirmwareCmd = &cobra.Command{
 
 This uses CKE's function of graceful reboot for the nodes used by CKE.
 As for the other nodes, this reboots them immediately.
-If some nodes are already powered off, this command does not do anything to those nodes.`,
+If some nodes are already powered off, this command does not do anything to those nodes.
+
+The application is recorded in etcd as a firmware campaign named by
+--campaign.  Use "neco firmware campaign refresh" to verify the firmware
+of the machines after they are rebooted.  If --expect is given, the
+versions of the named firmware components are verified.  Otherwise,
+any change of the firmware inventory is accepted, and machines without
+changes are reported as unchanged.`,
 
 	Args: cobra.MinimumNArgs(1),
 	Run:  applyFirmwareRun,
 }
 
-var applyFirmwareGetOpts sabakanMachinesGetOpts
-var applyFirmwareRebootOption bool
+var (
+	applyFirmwareGetOpts      sabakanMachinesGetOpts
+	applyFirmwareRebootOption bool
+	applyFirmwareCampaignName string
+	applyFirmwareExpect       map[string]string
+)
+
+var applyFirmwareCmdline = []string{"docker", "exec", "setup-hw", "setup-apply-firmware"}
 
 func applyFirmwareRun(cmd *cobra.Command, args []string) {
+	name := applyFirmwareCampaignName
+	if name == "" {
+		name = "firmware-" + time.Now().UTC().Format("20060102-150405")
+	}
+	if err := neco.ValidateFirmwareCampaignName(name); err != nil {
+		log.ErrorExit(err)
+	}
+
+	etcd, err := neco.EtcdClient()
+	if err != nil {
+		log.ErrorExit(err)
+	}
+	defer etcd.Close()
+	st := storage.NewStorage(etcd)
+
 	ctx := context.Background()
+	machines, err := selectWorkers(ctx, &applyFirmwareGetOpts)
+	if err != nil {
+		log.ErrorExit(err)
+	}
+	fmt.Printf("Applying to %d machines in campaign %s.\n", len(machines), name)
 
-	uploadAssetsAndRunCommandOnWorkers(ctx, &applyFirmwareGetOpts, args, []string{"docker", "exec", "setup-hw", "setup-apply-firmware"}, applyFirmwareRebootOption)
+	assets, err := uploadAssets(ctx, args)
+	if err != nil {
+		log.ErrorExit(err)
+	}
+
+	campaign := &neco.FirmwareCampaign{
+		Name:      name,
+		Assets:    assets,
+		Expect:    applyFirmwareExpect,
+		CreatedAt: time.Now().UTC(),
+	}
+	fms := make([]*neco.FirmwareMachine, len(machines))
+	for i, m := range machines {
+		fms[i] = newFirmwareMachine(m)
+	}
+	err = st.CreateFirmwareCampaign(ctx, campaign, fms)
+	if err == storage.ErrConflict {
+		log.ErrorExit(errors.New("campaign already exists: " + name))
+	}
+	if err != nil {
+		log.ErrorExit(err)
+	}
+
+	err = applyFirmwareCampaign(ctx, st, campaign, machines, fms, applyFirmwareRebootOption)
+	if err != nil {
+		log.ErrorExit(err)
+	}
 }
 
-func uploadAssetsAndRunCommandOnWorkers(ctx context.Context, getOpts *sabakanMachinesGetOpts, filenames []string, cmdline []string, needReboot bool) {
+// selectWorkers returns the machines selected by getOpts except for boot servers.
+func selectWorkers(ctx context.Context, getOpts *sabakanMachinesGetOpts) ([]sabakan.Machine, error) {
 	machines, err := sabakanMachinesGet(ctx, getOpts)
 	if err != nil {
-		log.ErrorExit(err)
+		return nil, err
 	}
 	machinesWithoutBootServers := []sabakan.Machine{}
 	for _, m := range machines {
@@ -51,34 +114,97 @@ func uploadAssetsAndRunCommandOnWorkers(ctx context.Context, getOpts *sabakanMac
 			machinesWithoutBootServers = append(machinesWithoutBootServers, m)
 		}
 	}
-	machines = machinesWithoutBootServers
-	fmt.Printf("Applying to %d machines.\n", len(machines))
+	return machinesWithoutBootServers, nil
+}
 
+// uploadAssets uploads files to sabakan and returns the names of the assets.
+func uploadAssets(ctx context.Context, filenames []string) ([]string, error) {
 	sabakanClient, err := client.NewClient(neco.SabakanLocalEndpoint, httpClient.Client)
 	if err != nil {
-		log.ErrorExit(err)
+		return nil, err
 	}
 
-	hostAddr, err := getInterfaceAddress("node0")
-	if err != nil {
-		log.ErrorExit(err)
-	}
-	assetUrlRoot := "http://" + hostAddr.String() + ":10080/api/v1/assets/"
-	assetUrls := []string{}
+	names := []string{}
 	for _, filename := range filenames {
 		name := filepath.Base(filename)
 		_, err := sabakanClient.AssetsUpload(ctx, name, filename, nil)
 		if err != nil {
-			log.ErrorExit(err)
+			return nil, err
 		}
-		assetUrl := assetUrlRoot + name
 		log.Info("asset uploaded", map[string]interface{}{
-			"file":      filename,
-			"name":      name,
-			"asset_url": assetUrl,
+			"file": filename,
+			"name": name,
 		})
-		assetUrls = append(assetUrls, assetUrl)
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// assetURLs returns the URLs of sabakan assets served by this boot server.
+func assetURLs(names []string) ([]string, error) {
+	hostAddr, err := getInterfaceAddress("node0")
+	if err != nil {
+		return nil, err
+	}
+	assetUrlRoot := "http://" + hostAddr.String() + ":10080/api/v1/assets/"
+	assetUrls := []string{}
+	for _, name := range names {
+		assetUrls = append(assetUrls, assetUrlRoot+name)
+	}
+	return assetUrls, nil
+}
+
+func runCommandOnWorker(ctx context.Context, machine sabakan.Machine, cmdline []string) ([]byte, error) {
+	addr := machine.Spec.IPv4[0]
+	cmdArgs := []string{"ssh", addr}
+	cmdArgs = append(cmdArgs, cmdline...)
+	return well.CommandContext(ctx, neco.CKECLIBin, cmdArgs...).Output()
+}
+
+func printApplySummary(total int, succeededAddrs, failedAddrs []string) {
+	sort.Strings(succeededAddrs)
+	sort.Strings(failedAddrs)
+
+	fmt.Printf(`
+Total:     %d
+Succeeded: %d %v
+Failed:    %d %v
+
+`, total, len(succeededAddrs), succeededAddrs, len(failedAddrs), failedAddrs)
+}
+
+func askReboot() bool {
+	ans, err := input.DefaultUI().Ask("reboot succeeded machines? ([y]es/[N]o)", &input.Options{
+		Default:     "N",
+		HideDefault: true,
+		HideOrder:   true,
+	})
+	if err != nil {
+		log.ErrorExit(err)
+	}
+	switch ans {
+	case "y", "Y", "yes":
+		return true
+	}
+	return false
+}
+
+func uploadAssetsAndRunCommandOnWorkers(ctx context.Context, getOpts *sabakanMachinesGetOpts, filenames []string, cmdline []string, needReboot bool) {
+	machines, err := selectWorkers(ctx, getOpts)
+	if err != nil {
+		log.ErrorExit(err)
 	}
+	fmt.Printf("Applying to %d machines.\n", len(machines))
+
+	names, err := uploadAssets(ctx, filenames)
+	if err != nil {
+		log.ErrorExit(err)
+	}
+	assetUrls, err := assetURLs(names)
+	if err != nil {
+		log.ErrorExit(err)
+	}
+	cmdline = append(append([]string{}, cmdline...), assetUrls...)
 
 	var wg sync.WaitGroup
 	var mtx sync.Mutex
@@ -90,10 +216,7 @@ func uploadAssetsAndRunCommandOnWorkers(ctx context.Context, getOpts *sabakanMac
 		go func(machine sabakan.Machine) {
 			defer wg.Done()
 			addr := machine.Spec.IPv4[0]
-			cmdArgs := []string{"ssh", addr}
-			cmdArgs = append(cmdArgs, cmdline...)
-			cmdArgs = append(cmdArgs, assetUrls...)
-			output, err := well.CommandContext(ctx, neco.CKECLIBin, cmdArgs...).Output()
+			output, err := runCommandOnWorker(ctx, machine, cmdline)
 
 			mtx.Lock()
 			defer mtx.Unlock()
@@ -108,31 +231,9 @@ func uploadAssetsAndRunCommandOnWorkers(ctx context.Context, getOpts *sabakanMac
 	}
 	wg.Wait()
 
-	sort.Strings(succeededAddrs)
-	sort.Strings(failedAddrs)
-
-	fmt.Printf(`
-Total:     %d
-Succeeded: %d %v
-Failed:    %d %v
-
-`, len(machines), len(succeededAddrs), succeededAddrs, len(failedAddrs), failedAddrs)
+	printApplySummary(len(machines), succeededAddrs, failedAddrs)
 
-	if !needReboot {
-		return
-	}
-
-	ans, err := input.DefaultUI().Ask("reboot succeeded machines? ([y]es/[N]o)", &input.Options{
-		Default:     "N",
-		HideDefault: true,
-		HideOrder:   true,
-	})
-	if err != nil {
-		log.ErrorExit(err)
-	}
-	switch ans {
-	case "y", "Y", "yes":
-	default:
+	if !needReboot || !askReboot() {
 		return
 	}
 
@@ -158,4 +259,6 @@ func init() {
 	rootCmd.AddCommand(applyFirmwareCmd)
 	addSabakanMachinesGetOpts(applyFirmwareCmd, &applyFirmwareGetOpts)
 	applyFirmwareCmd.Flags().BoolVar(&applyFirmwareRebootOption, "reboot", false, "Schedule reboot")
+	applyFirmwareCmd.Flags().StringVar(&applyFirmwareCampaignName, "campaign", "", "name of the firmware campaign (default: firmware-<UTC time>)")
+	applyFirmwareCmd.Flags().StringToStringVar(&applyFirmwareExpect, "expect", nil, "expected versions of firmware components after the application (--expect BIOS=1.2.3,...)")
 }
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/bmc"
+	"github.com/cybozu-go/neco/storage"
+	"github.com/cybozu-go/sabakan/v2"
+	"github.com/spf13/cobra"
+)
+
+var bmcMachineTypeCmd = &cobra.Command{
+	Use:   "machine-type",
+	Short: "BMC drivers of machine types",
+	Long: `Manage BMC drivers of machine types.
+
+The BMC driver of a machine is chosen by its "machine-type" label.
+Machine types configured in etcd override the built-in ones.`,
+}
+
+// getBMCMachineTypes returns the built-in machine types overridden by
+// the ones configured in etcd.
+func getBMCMachineTypes(ctx context.Context) (map[string]bmc.MachineType, error) {
+	etcd, err := neco.EtcdClient()
+	if err != nil {
+		return nil, err
+	}
+	defer etcd.Close()
+	st := storage.NewStorage(etcd)
+
+	configured, err := st.GetBMCMachineTypes(ctx)
+	if err != nil && err != storage.ErrNotFound {
+		return nil, err
+	}
+	return bmc.MachineTypes(configured), nil
+}
+
+// lookupBMCDriver returns the BMC configuration and the driver of the machine.
+// If the machine type is unknown, this returns bmc.ErrUnknownMachineType.
+func lookupBMCDriver(ctx context.Context, machine *sabakan.Machine) (bmc.MachineType, bmc.Driver, error) {
+	mts, err := getBMCMachineTypes(ctx)
+	if err != nil {
+		return bmc.MachineType{}, nil, err
+	}
+	return bmc.Lookup(mts, machine.Spec.Labels[machineTypeLabelName])
+}
+
+func init() {
+	bmcCmd.AddCommand(bmcMachineTypeCmd)
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+)
+
+var bmcMachineTypeListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "list BMC drivers of machine types",
+	Long:  `List BMC drivers of machine types including the built-in ones.`,
+	Args:  cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		well.Go(func(ctx context.Context) error {
+			mts, err := getBMCMachineTypes(ctx)
+			if err != nil {
+				return err
+			}
+
+			names := make([]string, 0, len(mts))
+			for name := range mts {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+			fmt.Fprintln(w, "MACHINE-TYPE\tDRIVER\tTPM-CLEAR-ATTRIBUTES")
+			for _, name := range names {
+				mt := mts[name]
+				attrs := ""
+				if len(mt.TPMClearAttributes) > 0 {
+					attrs = fmt.Sprint(mt.TPMClearAttributes)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\n", name, mt.Driver, attrs)
+			}
+			return w.Flush()
+		})
+		well.Stop()
+		err := well.Wait()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+	},
+}
+
+func init() {
+	bmcMachineTypeCmd.AddCommand(bmcMachineTypeListCmd)
+}
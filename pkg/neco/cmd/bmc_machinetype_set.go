@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/bmc"
+	"github.com/cybozu-go/neco/storage"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+	"github.com/stmcginnis/gofish/redfish"
+)
+
+var bmcMachineTypeSetTPMClearAttributes map[string]string
+
+var bmcMachineTypeSetCmd = &cobra.Command{
+	Use:   "set MACHINE_TYPE DRIVER",
+	Short: "set the BMC driver of a machine type",
+	Long: `Set the BMC driver of a machine type.
+
+Available drivers are:
+    ` + strings.Join(bmc.Drivers(), "\n    ") + `
+
+--tpm-clear-attribute specifies BIOS attributes to clear TPM devices.
+It is required for "redfish" driver to clear TPM devices.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		_, err := bmc.GetDriver(args[1])
+		if err != nil {
+			log.ErrorExit(err)
+		}
+
+		etcd, err := neco.EtcdClient()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+		defer etcd.Close()
+		st := storage.NewStorage(etcd)
+		well.Go(func(ctx context.Context) error {
+			mts, err := st.GetBMCMachineTypes(ctx)
+			if err != nil && err != storage.ErrNotFound {
+				return err
+			}
+			if err == storage.ErrNotFound {
+				mts = map[string]bmc.MachineType{}
+			}
+
+			mt := bmc.MachineType{Driver: args[1]}
+			if len(bmcMachineTypeSetTPMClearAttributes) > 0 {
+				mt.TPMClearAttributes = redfish.BiosAttributes{}
+				for k, v := range bmcMachineTypeSetTPMClearAttributes {
+					mt.TPMClearAttributes[k] = v
+				}
+			}
+			mts[args[0]] = mt
+
+			return st.PutBMCMachineTypes(ctx, mts)
+		})
+		well.Stop()
+		err = well.Wait()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+	},
+}
+
+func init() {
+	bmcMachineTypeSetCmd.Flags().StringToStringVar(&bmcMachineTypeSetTPMClearAttributes, "tpm-clear-attribute", nil, "BIOS attributes to clear TPM devices (--tpm-clear-attribute NAME=VALUE,...)")
+	bmcMachineTypeCmd.AddCommand(bmcMachineTypeSetCmd)
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/storage"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+)
+
+var bmcMachineTypeUnsetCmd = &cobra.Command{
+	Use:   "unset MACHINE_TYPE",
+	Short: "remove the configured BMC driver of a machine type",
+	Long: `Remove the BMC driver of a machine type configured in etcd.
+
+If the machine type is built-in, the built-in driver is used again.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		etcd, err := neco.EtcdClient()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+		defer etcd.Close()
+		st := storage.NewStorage(etcd)
+		well.Go(func(ctx context.Context) error {
+			mts, err := st.GetBMCMachineTypes(ctx)
+			if err != nil && err != storage.ErrNotFound {
+				return err
+			}
+			if _, ok := mts[args[0]]; !ok {
+				return errors.New("machine type is not configured: " + args[0])
+			}
+			delete(mts, args[0])
+
+			return st.PutBMCMachineTypes(ctx, mts)
+		})
+		well.Stop()
+		err = well.Wait()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+	},
+}
+
+func init() {
+	bmcMachineTypeCmd.AddCommand(bmcMachineTypeUnsetCmd)
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/cybozu-go/log"
 	"github.com/cybozu-go/neco"
@@ -21,10 +23,19 @@ var configGetCmd = &cobra.Command{
 Possible keys are:
     env                       - "staging" or "prod".  Default is "staging".
     slack                     - Slack WebHook URL.
+    webhook                   - Generic WebHook URL to receive notifications in JSON.
+    email                     - SMTP URL to send notifications by email.
+    teams                     - Microsoft Teams incoming WebHook URL.
+    command-hook              - Absolute path of a command to be run for notifications.
+    slack-filter, webhook-filter, email-filter, teams-filter, command-hook-filter
+                              - Event types and levels to be notified by the backend.
     proxy                     - HTTP proxy server URL to access Internet for boot servers.
     quay-username             - Username to authenticate to quay.io.
     check-update-interval     - Polling interval for checking new neco release.
     worker-timeout            - Timeout value to wait for workers.
+    rollout-canary            - Comma-separated LRNs of the boot servers to be updated first.
+    rollout-soak-period       - Duration to wait after the canary servers are updated.
+    auto-rollback             - "true" if a failed update is rolled back automatically.
     github-token              - GitHub personal access token for checking GitHub release.
     node-proxy                - HTTP proxy server URL to access Internet for worker nodes.
     external-ip-address-block - IP address block to be assigned to Nodes by LoadBalancer controllers.
@@ -36,10 +47,22 @@ Possible keys are:
 	ValidArgs: []string{
 		"env",
 		"slack",
+		"webhook",
+		"email",
+		"teams",
+		"command-hook",
+		"slack-filter",
+		"webhook-filter",
+		"email-filter",
+		"teams-filter",
+		"command-hook-filter",
 		"proxy",
 		"quay-username",
 		"check-update-interval",
 		"worker-timeout",
+		"rollout-canary",
+		"rollout-soak-period",
+		"auto-rollback",
 		"github-token",
 		"node-proxy",
 		"external-ip-address-block",
@@ -66,6 +89,36 @@ Possible keys are:
 					return err
 				}
 				fmt.Println(slack)
+			case "webhook":
+				webhook, err := st.GetWebhookNotification(ctx)
+				if err != nil {
+					return err
+				}
+				fmt.Println(webhook)
+			case "email":
+				email, err := st.GetEmailNotification(ctx)
+				if err != nil {
+					return err
+				}
+				fmt.Println(email)
+			case "teams":
+				teams, err := st.GetTeamsNotification(ctx)
+				if err != nil {
+					return err
+				}
+				fmt.Println(teams)
+			case "command-hook":
+				command, err := st.GetCommandNotification(ctx)
+				if err != nil {
+					return err
+				}
+				fmt.Println(command)
+			case "slack-filter", "webhook-filter", "email-filter", "teams-filter", "command-hook-filter":
+				filter, err := st.GetNotificationFilter(ctx, notificationFilterBackend(key))
+				if err != nil {
+					return err
+				}
+				fmt.Println(filter)
 			case "proxy":
 				proxy, err := st.GetProxyConfig(ctx)
 				if err != nil {
@@ -90,6 +143,28 @@ Possible keys are:
 					return err
 				}
 				fmt.Println(timeout.String())
+			case "rollout-canary":
+				lrns, err := st.GetRolloutCanary(ctx)
+				if err != nil {
+					return err
+				}
+				strs := make([]string, len(lrns))
+				for i, lrn := range lrns {
+					strs[i] = strconv.Itoa(lrn)
+				}
+				fmt.Println(strings.Join(strs, ","))
+			case "rollout-soak-period":
+				period, err := st.GetRolloutSoakPeriod(ctx)
+				if err != nil {
+					return err
+				}
+				fmt.Println(period.String())
+			case "auto-rollback":
+				enabled, err := st.GetAutoRollback(ctx)
+				if err != nil {
+					return err
+				}
+				fmt.Println(enabled)
 			case "github-token":
 				token, err := st.GetGitHubToken(ctx)
 				if err != nil {
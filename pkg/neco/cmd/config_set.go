@@ -7,10 +7,14 @@ import (
 	"net"
 	"net/url"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/cybozu-go/log"
 	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/ext"
 	"github.com/cybozu-go/neco/storage"
 	"github.com/cybozu-go/well"
 	"github.com/spf13/cobra"
@@ -25,11 +29,24 @@ var configSetCmd = &cobra.Command{
 Possible keys are:
     env                       - "staging" or "prod".
     slack                     - Slack WebHook URL.
+    webhook                   - Generic WebHook URL to receive notifications in JSON.
+    email                     - SMTP URL to send notifications by email.
+                                e.g. smtp://HOST[:PORT]/?from=ADDR&to=ADDR[,ADDR...]
+    teams                     - Microsoft Teams incoming WebHook URL.
+    command-hook              - Absolute path of a command to be run for notifications.
+    slack-filter, webhook-filter, email-filter, teams-filter, command-hook-filter
+                              - Comma-separated event types and levels to be notified by the backend.
+                                "all" notifies every event.  By default, slack and email do not
+                                notify step events, and the others notify every event.
     proxy                     - HTTP proxy server URL to access Internet for boot servers.
     quay-username             - Username to authenticate to quay.io from QUAY_USER.  This does not take VALUE.
     quay-password             - Password to authenticate to quay.io from QUAY_PASSWORD.  This does not take VALUE.
     check-update-interval     - Polling interval for checking new neco release.
     worker-timeout            - Timeout value to wait for workers.
+    rollout-canary            - Comma-separated LRNs of the boot servers to be updated first.
+                                An empty string disables the canary rollout.
+    rollout-soak-period       - Duration to wait after the canary servers are updated.
+    auto-rollback             - "true" to roll back a failed update to the last completed version.
     github-token              - GitHub personal access token for checking GitHub release.
     node-proxy                - HTTP proxy server URL to access Internet for worker nodes.
     external-ip-address-block - IP address block to be assigned to Nodes by LoadBalancer controllers.
@@ -42,7 +59,7 @@ Possible keys are:
 			return fmt.Errorf("accepts %d arg(s), received %d", 1, len(args))
 		}
 		switch args[0] {
-		case "env", "slack", "proxy", "check-update-interval", "worker-timeout", "node-proxy", "external-ip-address-block":
+		case "env", "slack", "webhook", "email", "teams", "command-hook", "slack-filter", "webhook-filter", "email-filter", "teams-filter", "command-hook-filter", "proxy", "check-update-interval", "worker-timeout", "rollout-canary", "rollout-soak-period", "auto-rollback", "node-proxy", "external-ip-address-block":
 			if len(args) != 2 {
 				return fmt.Errorf("accepts %d arg(s), received %d", 2, len(args))
 			}
@@ -56,11 +73,23 @@ Possible keys are:
 	ValidArgs: []string{
 		"env",
 		"slack",
+		"webhook",
+		"email",
+		"teams",
+		"command-hook",
+		"slack-filter",
+		"webhook-filter",
+		"email-filter",
+		"teams-filter",
+		"command-hook-filter",
 		"proxy",
 		"quay-username",
 		"quay-password",
 		"check-update-interval",
 		"worker-timeout",
+		"rollout-canary",
+		"rollout-soak-period",
+		"auto-rollback",
 		"github-token",
 		"node-proxy",
 		"external-ip-address-block",
@@ -92,6 +121,44 @@ Possible keys are:
 					return errors.New("invalid URL")
 				}
 				return st.PutSlackNotification(ctx, value)
+			case "webhook":
+				value = args[1]
+				u, err := url.Parse(value)
+				if err != nil {
+					return err
+				}
+				if !u.IsAbs() {
+					return errors.New("invalid URL")
+				}
+				return st.PutWebhookNotification(ctx, value)
+			case "email":
+				value = args[1]
+				if _, err := ext.ParseEmailURL(value); err != nil {
+					return err
+				}
+				return st.PutEmailNotification(ctx, value)
+			case "teams":
+				value = args[1]
+				u, err := url.Parse(value)
+				if err != nil {
+					return err
+				}
+				if !u.IsAbs() {
+					return errors.New("invalid URL")
+				}
+				return st.PutTeamsNotification(ctx, value)
+			case "command-hook":
+				value = args[1]
+				if !filepath.IsAbs(value) {
+					return errors.New("not an absolute path: " + value)
+				}
+				return st.PutCommandNotification(ctx, value)
+			case "slack-filter", "webhook-filter", "email-filter", "teams-filter", "command-hook-filter":
+				value = args[1]
+				if _, err := ext.ParseEventFilter(value); err != nil {
+					return err
+				}
+				return st.PutNotificationFilter(ctx, notificationFilterBackend(key), value)
 			case "proxy":
 				value = args[1]
 				u, err := url.Parse(value)
@@ -122,6 +189,36 @@ Possible keys are:
 					return err
 				}
 				return st.PutWorkerTimeout(ctx, duration)
+			case "rollout-canary":
+				value = args[1]
+				var lrns []int
+				if value != "" {
+					for _, v := range strings.Split(value, ",") {
+						lrn, err := strconv.Atoi(strings.TrimSpace(v))
+						if err != nil {
+							return err
+						}
+						if lrn < 0 {
+							return errors.New("invalid LRN: " + v)
+						}
+						lrns = append(lrns, lrn)
+					}
+				}
+				return st.PutRolloutCanary(ctx, lrns)
+			case "rollout-soak-period":
+				value = args[1]
+				duration, err := time.ParseDuration(value)
+				if err != nil {
+					return err
+				}
+				return st.PutRolloutSoakPeriod(ctx, duration)
+			case "auto-rollback":
+				value = args[1]
+				enabled, err := strconv.ParseBool(value)
+				if err != nil {
+					return err
+				}
+				return st.PutAutoRollback(ctx, enabled)
 			case "github-token":
 				value = args[1]
 				return st.PutGitHubToken(ctx, value)
@@ -186,6 +283,16 @@ Possible keys are:
 	},
 }
 
+// notificationFilterBackend returns the name of the notification backend
+// for a config key of a notification filter.
+func notificationFilterBackend(key string) string {
+	backend := strings.TrimSuffix(key, "-filter")
+	if backend == "command-hook" {
+		return "command"
+	}
+	return backend
+}
+
 func init() {
 	configCmd.AddCommand(configSetCmd)
 }
@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+const ipmitoolBin = "ipmitool"
+
+var (
+	consoleLogFile  string
+	consoleReadOnly bool
+	consoleForce    bool
+)
+
+// consoleCmdline returns the command line of ipmitool for SOL.
+// The password is passed by IPMI_PASSWORD environment variable.
+func consoleCmdline(bmcAddr, username string, args ...string) []string {
+	cmdline := []string{ipmitoolBin, "-I", "lanplus", "-H", bmcAddr, "-U", username, "-E", "sol"}
+	return append(cmdline, args...)
+}
+
+// consoleInputFilter filters the input from the user to the SOL session.
+//
+// In read-only mode, the input is discarded except for the escape
+// sequence "~." at the beginning of a line, that terminates the session.
+type consoleInputFilter struct {
+	readOnly      bool
+	lineStart     bool
+	escapePending bool
+}
+
+func newConsoleInputFilter(readOnly bool) *consoleInputFilter {
+	return &consoleInputFilter{
+		readOnly:  readOnly,
+		lineStart: true,
+	}
+}
+
+// filter returns the bytes to be sent to the SOL session.
+// quit is true when the escape sequence to terminate the session is found.
+func (f *consoleInputFilter) filter(in []byte) (out []byte, quit bool) {
+	if !f.readOnly {
+		return in, false
+	}
+
+	for _, c := range in {
+		switch {
+		case f.escapePending && c == '.':
+			// ipmitool terminates the session by "~." at the beginning of a line.
+			// Nothing else has been sent, so ipmitool is still at the beginning.
+			return []byte("~."), true
+		case f.lineStart && c == '~':
+			f.escapePending = true
+			f.lineStart = false
+			continue
+		}
+		f.escapePending = false
+		f.lineStart = c == '\r' || c == '\n'
+	}
+	return nil, false
+}
+
+func copyConsoleInput(w io.WriteCloser, r io.Reader, f *consoleInputFilter) {
+	defer w.Close()
+
+	buf := make([]byte, 1024)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			out, quit := f.filter(buf[:n])
+			if len(out) > 0 {
+				if _, err := w.Write(out); err != nil {
+					return
+				}
+			}
+			if quit {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func runConsole(ctx context.Context, bmcAddr, username, password string) error {
+	env := append(os.Environ(), "IPMI_PASSWORD="+password)
+
+	if consoleForce {
+		cmdline := consoleCmdline(bmcAddr, username, "deactivate")
+		cmd := exec.CommandContext(ctx, cmdline[0], cmdline[1:]...)
+		cmd.Env = env
+		// Deactivation fails if no session is active.  Ignore it.
+		cmd.Run()
+	}
+
+	var stdout io.Writer = os.Stdout
+	if consoleLogFile != "" {
+		f, err := os.OpenFile(consoleLogFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		stdout = io.MultiWriter(os.Stdout, f)
+	}
+
+	cmdline := consoleCmdline(bmcAddr, username, "activate")
+	cmd := exec.CommandContext(ctx, cmdline[0], cmdline[1:]...)
+	cmd.Env = env
+	cmd.Stdout = stdout
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			return err
+		}
+		defer term.Restore(fd, state)
+	}
+
+	if consoleReadOnly {
+		fmt.Fprint(os.Stderr, "[read-only console; type ~. to quit]\r\n")
+	} else {
+		fmt.Fprint(os.Stderr, "[type ~. to quit, ~? for help]\r\n")
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		return err
+	}
+	go copyConsoleInput(stdin, os.Stdin, newConsoleInputFilter(consoleReadOnly))
+	return cmd.Wait()
+}
+
+var consoleCmd = &cobra.Command{
+	Use:   "console SERIAL|IP",
+	Short: "attach to the serial console of a machine",
+	Long: `Attach to the serial console of a machine over IPMI Serial-over-LAN.
+
+SERIAL is the serial number of the machine.
+IP is one of the IP addresses owned by the machine.
+
+Type "~." at the beginning of a line to quit.
+
+With --log, the console output is appended to the file.
+With --read-only, the input is not sent to the machine except for "~.".
+With --force, the SOL session already active is deactivated first.`,
+
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		well.Go(func(ctx context.Context) error {
+			machine, err := lookupMachine(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			username, password, err := getBMCUsernameAndPassword(ctx)
+			if err != nil {
+				return err
+			}
+			return runConsole(ctx, machine.Spec.BMC.IPv4, username, password)
+		})
+		well.Stop()
+		err := well.Wait()
+		if err != nil && !well.IsSignaled(err) {
+			log.ErrorExit(err)
+		}
+	},
+}
+
+func init() {
+	consoleCmd.Flags().StringVar(&consoleLogFile, "log", "", "append the console output to the file")
+	consoleCmd.Flags().BoolVar(&consoleReadOnly, "read-only", false, "do not send the input to the machine")
+	consoleCmd.Flags().BoolVar(&consoleForce, "force", false, "deactivate the SOL session already active")
+	rootCmd.AddCommand(consoleCmd)
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"testing"
+)
+
+func TestConsoleInputFilter(t *testing.T) {
+	f := newConsoleInputFilter(false)
+	out, quit := f.filter([]byte("ls\r~."))
+	if string(out) != "ls\r~." || quit {
+		t.Error("input should be passed through in read-write mode:", string(out), quit)
+	}
+
+	testCases := []struct {
+		name   string
+		inputs []string
+		quit   bool
+	}{
+		{name: "escape at start", inputs: []string{"~."}, quit: true},
+		{name: "escape after newline", inputs: []string{"abc\r", "~", "."}, quit: true},
+		{name: "escape in line", inputs: []string{"abc~."}},
+		{name: "tilde twice", inputs: []string{"~~."}},
+		{name: "other escape", inputs: []string{"~?", "."}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := newConsoleInputFilter(true)
+			var quit bool
+			var out []byte
+			for _, in := range tc.inputs {
+				out, quit = f.filter([]byte(in))
+				if quit {
+					break
+				}
+				if len(out) != 0 {
+					t.Error("input should be discarded in read-only mode:", string(out))
+				}
+			}
+			if quit != tc.quit {
+				t.Error("unexpected quit:", quit)
+			}
+			if quit && string(out) != "~." {
+				t.Error("escape sequence should be sent:", string(out))
+			}
+		})
+	}
+}
+
+func TestConsoleCmdline(t *testing.T) {
+	cmdline := consoleCmdline("10.72.17.4", "cybozu", "activate")
+	expected := []string{"ipmitool", "-I", "lanplus", "-H", "10.72.17.4", "-U", "cybozu", "-E", "sol", "activate"}
+	if len(cmdline) != len(expected) {
+		t.Fatal("unexpected command line:", cmdline)
+	}
+	for i := range expected {
+		if cmdline[i] != expected[i] {
+			t.Error("unexpected command line:", cmdline)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var firmwareCmd = &cobra.Command{
+	Use:   "firmware",
+	Short: "firmware related commands",
+	Long:  `Firmware related commands.`,
+}
+
+func init() {
+	rootCmd.AddCommand(firmwareCmd)
+}
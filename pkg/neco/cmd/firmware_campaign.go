@@ -0,0 +1,264 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/storage"
+	"github.com/cybozu-go/sabakan/v2"
+	"github.com/spf13/cobra"
+)
+
+var firmwareCampaignCmd = &cobra.Command{
+	Use:   "campaign",
+	Short: "firmware campaign related commands",
+	Long: `Firmware campaign related commands.
+
+A firmware campaign records the application of firmware updaters by
+neco apply-firmware and the state of each machine.`,
+}
+
+func newFirmwareMachine(m sabakan.Machine) *neco.FirmwareMachine {
+	return &neco.FirmwareMachine{
+		Serial:    m.Spec.Serial,
+		Node:      m.Spec.IPv4[0],
+		BMC:       m.Spec.BMC.IPv4,
+		State:     neco.FirmwarePending,
+		UpdatedAt: time.Now().UTC(),
+	}
+}
+
+func setFirmwareMachineState(m *neco.FirmwareMachine, state neco.FirmwareMachineState, message string) {
+	m.State = state
+	m.Message = message
+	m.UpdatedAt = time.Now().UTC()
+}
+
+func getFirmwareInventory(bmcAddr, username, password string) ([]neco.FirmwareComponent, error) {
+	client, err := connectRedfish(bmcAddr, username, password)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Logout()
+
+	us, err := client.Service.UpdateService()
+	if err != nil {
+		return nil, err
+	}
+	items, err := us.FirmwareInventories()
+	if err != nil {
+		return nil, err
+	}
+
+	inventory := make([]neco.FirmwareComponent, len(items))
+	for i, item := range items {
+		inventory[i] = neco.FirmwareComponent{
+			ID:      item.ID,
+			Name:    item.Name,
+			Version: item.Version,
+		}
+	}
+	return inventory, nil
+}
+
+// applyFirmwareCampaign sends the firmware updaters of the campaign to machines.
+// fms are the states of machines in the campaign in the same order as machines.
+func applyFirmwareCampaign(ctx context.Context, st storage.Storage, c *neco.FirmwareCampaign, machines []sabakan.Machine, fms []*neco.FirmwareMachine, needReboot bool) error {
+	assetUrls, err := assetURLs(c.Assets)
+	if err != nil {
+		return err
+	}
+	cmdline := append(append([]string{}, applyFirmwareCmdline...), assetUrls...)
+
+	username, password, err := getBMCUsernameAndPassword(ctx)
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	var mtx sync.Mutex
+	failedAddrs := []string{}
+	succeededAddrs := []string{}
+	succeededMachines := []sabakan.Machine{}
+	for i := range machines {
+		wg.Add(1)
+		go func(machine sabakan.Machine, fm *neco.FirmwareMachine) {
+			defer wg.Done()
+			addr := machine.Spec.IPv4[0]
+			err := applyFirmwareToMachine(ctx, machine, fm, cmdline, username, password)
+			if err != nil {
+				setFirmwareMachineState(fm, neco.FirmwareFailed, err.Error())
+			}
+			putErr := st.PutFirmwareMachine(ctx, c.Name, fm)
+			if putErr != nil {
+				log.Warn("failed to record firmware campaign", map[string]interface{}{
+					"campaign":  c.Name,
+					"serial":    fm.Serial,
+					log.FnError: putErr,
+				})
+			}
+
+			mtx.Lock()
+			defer mtx.Unlock()
+			if err != nil {
+				fmt.Println(addr+":", err)
+				failedAddrs = append(failedAddrs, addr)
+			} else {
+				succeededAddrs = append(succeededAddrs, addr)
+				succeededMachines = append(succeededMachines, machine)
+			}
+		}(machines[i], fms[i])
+	}
+	wg.Wait()
+
+	printApplySummary(len(machines), succeededAddrs, failedAddrs)
+
+	if !needReboot || !askReboot() {
+		return nil
+	}
+
+	scheduled := make(map[string]bool)
+	for _, m := range succeededMachines {
+		scheduled[m.Spec.Serial] = true
+	}
+	for _, fm := range fms {
+		if !scheduled[fm.Serial] {
+			continue
+		}
+		setFirmwareMachineState(fm, neco.FirmwareScheduled, fm.Message)
+		err := st.PutFirmwareMachine(ctx, c.Name, fm)
+		if err != nil {
+			return err
+		}
+	}
+	return rebootMachines(succeededMachines)
+}
+
+func applyFirmwareToMachine(ctx context.Context, machine sabakan.Machine, fm *neco.FirmwareMachine, cmdline []string, username, password string) error {
+	// The inventory before the application is needed only for the verification
+	// without expected versions, so failing to read it does not stop the application.
+	var warning string
+	inventory, err := getFirmwareInventory(fm.BMC, username, password)
+	if err != nil {
+		log.Warn("failed to read firmware inventory", map[string]interface{}{
+			"serial":    fm.Serial,
+			log.FnError: err,
+		})
+		fm.Before = nil
+		warning = "warning: failed to read firmware inventory before applying: " + err.Error()
+	} else {
+		fm.Before = neco.FirmwareVersions(inventory)
+	}
+
+	output, err := runCommandOnWorker(ctx, machine, cmdline)
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+	}
+	fm.UploadedAt = time.Now().UTC()
+	setFirmwareMachineState(fm, neco.FirmwareUploaded, warning)
+	return nil
+}
+
+// refreshFirmwareCampaign checks machines whose firmware was sent and
+// records whether they have rebooted and their firmware is verified.
+func refreshFirmwareCampaign(ctx context.Context, st storage.Storage, c *neco.FirmwareCampaign, fms []*neco.FirmwareMachine) error {
+	var username, password string
+	for _, fm := range fms {
+		switch fm.State {
+		case neco.FirmwareUploaded, neco.FirmwareScheduled:
+			rebooted, err := rebootCheck(ctx, fm.Serial, fm.UploadedAt, false)
+			if err != nil || !rebooted {
+				continue
+			}
+			setFirmwareMachineState(fm, neco.FirmwareRebooted, fm.Message)
+		case neco.FirmwareRebooted:
+		default:
+			continue
+		}
+
+		if username == "" {
+			var err error
+			username, password, err = getBMCUsernameAndPassword(ctx)
+			if err != nil {
+				return err
+			}
+		}
+		inventory, err := getFirmwareInventory(fm.BMC, username, password)
+		if err != nil {
+			log.Warn("failed to read firmware inventory", map[string]interface{}{
+				"serial":    fm.Serial,
+				log.FnError: err,
+			})
+		} else {
+			switch err := neco.VerifyFirmware(c.Expect, fm.Before, inventory); err {
+			case nil:
+				setFirmwareMachineState(fm, neco.FirmwareVerified, "")
+			case neco.ErrFirmwareUnchanged:
+				setFirmwareMachineState(fm, neco.FirmwareUnchanged, err.Error())
+			default:
+				setFirmwareMachineState(fm, neco.FirmwareFailed, err.Error())
+			}
+		}
+
+		err = st.PutFirmwareMachine(ctx, c.Name, fm)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func countFirmwareMachines(fms []*neco.FirmwareMachine) map[neco.FirmwareMachineState]int {
+	counts := make(map[neco.FirmwareMachineState]int)
+	for _, fm := range fms {
+		counts[fm.State]++
+	}
+	return counts
+}
+
+func writeFirmwareCampaign(w io.Writer, c *neco.FirmwareCampaign, fms []*neco.FirmwareMachine) {
+	counts := countFirmwareMachines(fms)
+	fmt.Fprintln(w, "Campaign:", c.Name)
+	fmt.Fprintln(w, "Created:", c.CreatedAt.Format(time.RFC3339))
+	fmt.Fprintln(w, "Assets:", strings.Join(c.Assets, " "))
+	if len(c.Expect) > 0 {
+		fmt.Fprintln(w, "Expect:", c.Expect)
+	}
+	fmt.Fprintf(w, "Machines: %d (pending: %d, uploaded: %d, scheduled: %d, rebooted: %d, verified: %d, unchanged: %d, failed: %d)\n\n",
+		len(fms), counts[neco.FirmwarePending], counts[neco.FirmwareUploaded], counts[neco.FirmwareScheduled],
+		counts[neco.FirmwareRebooted], counts[neco.FirmwareVerified], counts[neco.FirmwareUnchanged], counts[neco.FirmwareFailed])
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "SERIAL\tNODE\tSTATE\tUPDATED\tMESSAGE")
+	for _, fm := range fms {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", fm.Serial, fm.Node, fm.State, fm.UpdatedAt.Format(time.RFC3339), fm.Message)
+	}
+	tw.Flush()
+}
+
+func getFirmwareCampaign(ctx context.Context, st storage.Storage, name string) (*neco.FirmwareCampaign, []*neco.FirmwareMachine, error) {
+	c, err := st.GetFirmwareCampaign(ctx, name)
+	if err == storage.ErrNotFound {
+		return nil, nil, errors.New("campaign not found: " + name)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	fms, err := st.GetFirmwareMachines(ctx, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c, fms, nil
+}
+
+func init() {
+	firmwareCmd.AddCommand(firmwareCampaignCmd)
+}
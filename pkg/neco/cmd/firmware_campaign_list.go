@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/storage"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+)
+
+var firmwareCampaignListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "list firmware campaigns",
+	Long:  `List firmware campaigns with the number of machines in each state.`,
+	Args:  cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		etcd, err := neco.EtcdClient()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+		defer etcd.Close()
+		st := storage.NewStorage(etcd)
+		well.Go(func(ctx context.Context) error {
+			campaigns, err := st.GetFirmwareCampaigns(ctx)
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tCREATED\tMACHINES\tVERIFIED\tUNCHANGED\tFAILED")
+			for _, c := range campaigns {
+				fms, err := st.GetFirmwareMachines(ctx, c.Name)
+				if err != nil {
+					return err
+				}
+				counts := countFirmwareMachines(fms)
+				fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\n", c.Name, c.CreatedAt.Format(time.RFC3339),
+					len(fms), counts[neco.FirmwareVerified], counts[neco.FirmwareUnchanged], counts[neco.FirmwareFailed])
+			}
+			return w.Flush()
+		})
+		well.Stop()
+		err = well.Wait()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+	},
+}
+
+func init() {
+	firmwareCampaignCmd.AddCommand(firmwareCampaignListCmd)
+}
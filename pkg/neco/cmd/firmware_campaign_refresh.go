@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"context"
+	"os"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/storage"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+)
+
+var firmwareCampaignRefreshCmd = &cobra.Command{
+	Use:   "refresh NAME",
+	Short: "verify the firmware of rebooted machines in a firmware campaign",
+	Long: `Check machines whose firmware was sent in a firmware campaign.
+
+If a machine has rebooted since then, this reads its firmware inventory
+through Redfish and records whether the firmware is verified.
+Then, this shows the state of machines as "neco firmware campaign show".`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		etcd, err := neco.EtcdClient()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+		defer etcd.Close()
+		st := storage.NewStorage(etcd)
+		well.Go(func(ctx context.Context) error {
+			c, fms, err := getFirmwareCampaign(ctx, st, args[0])
+			if err != nil {
+				return err
+			}
+			err = refreshFirmwareCampaign(ctx, st, c, fms)
+			if err != nil {
+				return err
+			}
+			writeFirmwareCampaign(os.Stdout, c, fms)
+			return nil
+		})
+		well.Stop()
+		err = well.Wait()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+	},
+}
+
+func init() {
+	firmwareCampaignCmd.AddCommand(firmwareCampaignRefreshCmd)
+}
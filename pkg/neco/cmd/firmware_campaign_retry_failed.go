@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/storage"
+	"github.com/cybozu-go/sabakan/v2"
+	"github.com/spf13/cobra"
+)
+
+var firmwareCampaignRetryReboot bool
+
+var firmwareCampaignRetryFailedCmd = &cobra.Command{
+	Use:   "retry-failed NAME",
+	Short: "apply the firmware of a campaign again to failed machines",
+	Long: `Apply the firmware updaters of a campaign again to the machines
+that failed to receive them or failed the verification.
+
+The updaters are not uploaded again; they must still exist as sabakan assets.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		etcd, err := neco.EtcdClient()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+		defer etcd.Close()
+		st := storage.NewStorage(etcd)
+
+		ctx := context.Background()
+		c, fms, err := getFirmwareCampaign(ctx, st, args[0])
+		if err != nil {
+			log.ErrorExit(err)
+		}
+
+		var machines []sabakan.Machine
+		var failed []*neco.FirmwareMachine
+		for _, fm := range fms {
+			if fm.State != neco.FirmwareFailed {
+				continue
+			}
+			m, err := lookupMachine(ctx, fm.Serial)
+			if err != nil {
+				log.ErrorExit(fmt.Errorf("failed to lookup %s: %w", fm.Serial, err))
+			}
+			machines = append(machines, *m)
+			failed = append(failed, fm)
+		}
+		if len(machines) == 0 {
+			fmt.Println("no failed machines")
+			return
+		}
+		fmt.Printf("Applying to %d machines in campaign %s.\n", len(machines), c.Name)
+
+		err = applyFirmwareCampaign(ctx, st, c, machines, failed, firmwareCampaignRetryReboot)
+		if err != nil {
+			log.ErrorExit(err)
+		}
+	},
+}
+
+func init() {
+	firmwareCampaignRetryFailedCmd.Flags().BoolVar(&firmwareCampaignRetryReboot, "reboot", false, "Schedule reboot")
+	firmwareCampaignCmd.AddCommand(firmwareCampaignRetryFailedCmd)
+}
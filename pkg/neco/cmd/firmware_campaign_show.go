@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"context"
+	"os"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/storage"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+)
+
+var firmwareCampaignShowCmd = &cobra.Command{
+	Use:   "show NAME",
+	Short: "show the state of machines in a firmware campaign",
+	Long: `Show the recorded state of machines in a firmware campaign.
+
+This does not check machines.  Use "neco firmware campaign refresh"
+to verify the firmware of rebooted machines.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		etcd, err := neco.EtcdClient()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+		defer etcd.Close()
+		st := storage.NewStorage(etcd)
+		well.Go(func(ctx context.Context) error {
+			c, fms, err := getFirmwareCampaign(ctx, st, args[0])
+			if err != nil {
+				return err
+			}
+			writeFirmwareCampaign(os.Stdout, c, fms)
+			return nil
+		})
+		well.Stop()
+		err = well.Wait()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+	},
+}
+
+func init() {
+	firmwareCampaignCmd.AddCommand(firmwareCampaignShowCmd)
+}
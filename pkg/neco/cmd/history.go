@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/storage"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+)
+
+func showHistory(ctx context.Context, st storage.Storage, version string, w io.Writer) error {
+	if version == "" {
+		req, err := st.GetRequest(ctx)
+		if err == storage.ErrNotFound {
+			return errors.New("no update request; specify VERSION")
+		}
+		if err != nil {
+			return err
+		}
+		version = req.Version
+	}
+
+	histories, err := st.GetStepHistories(ctx, version)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, "Version:", version)
+	if len(histories) == 0 {
+		fmt.Fprintln(w, "    no history")
+		return nil
+	}
+
+	lrn := -1
+	for _, h := range histories {
+		if h.LRN != lrn {
+			lrn = h.LRN
+			fmt.Fprintf(w, "\nBoot server %d\n", lrn)
+		}
+		fmt.Fprintf(w, "    %-32s %s  %10s  %s\n",
+			neco.StepLabel(h.Step, h.StepName)+":",
+			h.StartedAt.Format(time.RFC3339),
+			h.Duration().Round(time.Second).String(),
+			h.Cond.String())
+		if len(h.Message) > 0 {
+			fmt.Fprintln(w, "        message:", h.Message)
+		}
+	}
+
+	return nil
+}
+
+var historyCmd = &cobra.Command{
+	Use:   "history [VERSION]",
+	Short: "show the history of update steps",
+	Long: `Show the timeline of update steps run on each boot server.
+
+If VERSION is not given, the version of the current update request is used.
+Only the 10 most recently updated versions are kept.`,
+
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var version string
+		if len(args) == 1 {
+			version = args[0]
+		}
+
+		etcd, err := neco.EtcdClient()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+		defer etcd.Close()
+		st := storage.NewStorage(etcd)
+		well.Go(func(ctx context.Context) error {
+			return showHistory(ctx, st, version, os.Stdout)
+		})
+		well.Stop()
+		err = well.Wait()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+}
@@ -0,0 +1,15 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var hwCmd = &cobra.Command{
+	Use:   "hw",
+	Short: "hardware related commands",
+	Long:  `Hardware related commands.`,
+}
+
+func init() {
+	rootCmd.AddCommand(hwCmd)
+}
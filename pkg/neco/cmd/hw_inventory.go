@@ -0,0 +1,459 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/sabakan/v2"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+	"github.com/stmcginnis/gofish/common"
+)
+
+// hwInventory is the hardware inventory of a machine read through Redfish.
+type hwInventory struct {
+	Serial      string        `json:"serial"`
+	MachineType string        `json:"machine_type"`
+	BMCAddress  string        `json:"bmc_address"`
+	BIOS        string        `json:"bios"`
+	BMC         string        `json:"bmc"`
+	NICs        []hwComponent `json:"nics"`
+	Disks       []hwComponent `json:"disks"`
+	CPU         hwCPU         `json:"cpu"`
+	Memory      hwMemory      `json:"memory"`
+	PSUs        []hwPSU       `json:"psus"`
+	Error       string        `json:"error,omitempty"`
+}
+
+type hwComponent struct {
+	ID       string `json:"id"`
+	Model    string `json:"model"`
+	Firmware string `json:"firmware"`
+}
+
+type hwCPU struct {
+	Model   string `json:"model"`
+	Count   int    `json:"count"`
+	Cores   int    `json:"cores"`
+	Threads int    `json:"threads"`
+}
+
+type hwMemory struct {
+	TotalGiB float32 `json:"total_gib"`
+	DIMMs    int     `json:"dimms"`
+}
+
+type hwPSU struct {
+	Name   string `json:"name"`
+	State  string `json:"state"`
+	Health string `json:"health"`
+}
+
+// hwDrift is a firmware component whose version differs from the baseline.
+type hwDrift struct {
+	Serial      string `json:"serial"`
+	MachineType string `json:"machine_type"`
+	Component   string `json:"component"`
+	Baseline    string `json:"baseline"`
+	Actual      string `json:"actual"`
+}
+
+var (
+	hwInventoryGetOpts     sabakanMachinesGetOpts
+	hwInventoryOutput      string
+	hwInventoryParallel    int
+	hwInventoryDiffAgainst string
+)
+
+func collectInventory(m sabakan.Machine, username, password string) (*hwInventory, error) {
+	inv := &hwInventory{
+		Serial:      m.Spec.Serial,
+		MachineType: m.Spec.Labels[machineTypeLabelName],
+		BMCAddress:  m.Spec.BMC.IPv4,
+		NICs:        []hwComponent{},
+		Disks:       []hwComponent{},
+		PSUs:        []hwPSU{},
+	}
+
+	client, err := connectRedfish(m.Spec.BMC.IPv4, username, password)
+	if err != nil {
+		return inv, err
+	}
+	defer client.Logout()
+
+	system, err := getComputerSystem(client.Service)
+	if err != nil {
+		return inv, err
+	}
+	inv.BIOS = system.BIOSVersion
+	inv.CPU.Model = system.ProcessorSummary.Model
+	inv.CPU.Count = system.ProcessorSummary.Count
+	inv.CPU.Threads = system.ProcessorSummary.LogicalProcessorCount
+	inv.Memory.TotalGiB = system.MemorySummary.TotalSystemMemoryGiB
+
+	processors, err := system.Processors()
+	if err != nil {
+		return inv, fmt.Errorf("failed to get processors: %w", err)
+	}
+	for _, p := range processors {
+		inv.CPU.Cores += p.TotalCores
+	}
+
+	dimms, err := system.Memory()
+	if err != nil {
+		return inv, fmt.Errorf("failed to get memory: %w", err)
+	}
+	for _, d := range dimms {
+		if d.CapacityMiB > 0 {
+			inv.Memory.DIMMs++
+		}
+	}
+
+	storages, err := system.Storage()
+	if err != nil {
+		return inv, fmt.Errorf("failed to get storage: %w", err)
+	}
+	for _, s := range storages {
+		drives, err := s.Drives()
+		if err != nil {
+			return inv, fmt.Errorf("failed to get drives: %w", err)
+		}
+		for _, d := range drives {
+			inv.Disks = append(inv.Disks, hwComponent{ID: d.ID, Model: d.Model, Firmware: d.Revision})
+		}
+	}
+
+	managers, err := client.Service.Managers()
+	if err != nil {
+		return inv, fmt.Errorf("failed to get managers: %w", err)
+	}
+	if len(managers) > 0 {
+		inv.BMC = managers[0].FirmwareVersion
+	}
+
+	chassis, err := client.Service.Chassis()
+	if err != nil {
+		return inv, fmt.Errorf("failed to get chassis: %w", err)
+	}
+	for _, c := range chassis {
+		adapters, err := c.NetworkAdapters()
+		if err != nil {
+			return inv, fmt.Errorf("failed to get network adapters: %w", err)
+		}
+		for _, a := range adapters {
+			for _, ctrl := range a.Controllers {
+				inv.NICs = append(inv.NICs, hwComponent{ID: a.ID, Model: a.Model, Firmware: ctrl.FirmwarePackageVersion})
+			}
+		}
+
+		power, err := c.Power()
+		if err != nil {
+			return inv, fmt.Errorf("failed to get power: %w", err)
+		}
+		if power == nil {
+			continue
+		}
+		for _, psu := range power.PowerSupplies {
+			inv.PSUs = append(inv.PSUs, hwPSU{Name: psu.Name, State: string(psu.Status.State), Health: string(psu.Status.Health)})
+		}
+	}
+
+	sortComponents(inv.NICs)
+	sortComponents(inv.Disks)
+	return inv, nil
+}
+
+func sortComponents(cs []hwComponent) {
+	sort.Slice(cs, func(i, j int) bool {
+		return cs[i].ID < cs[j].ID
+	})
+}
+
+// collectInventories reads inventories of machines in parallel.
+// Errors are recorded in the inventories.
+func collectInventories(ctx context.Context, machines []sabakan.Machine, parallel int, username, password string) []*hwInventory {
+	if parallel < 1 {
+		parallel = 1
+	}
+	invs := make([]*hwInventory, len(machines))
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, m := range machines {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			invs[i] = &hwInventory{Serial: m.Spec.Serial, MachineType: m.Spec.Labels[machineTypeLabelName], Error: ctx.Err().Error()}
+			continue
+		}
+		wg.Add(1)
+		go func(i int, m sabakan.Machine) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			inv, err := collectInventory(m, username, password)
+			if err != nil {
+				inv.Error = err.Error()
+			}
+			invs[i] = inv
+		}(i, m)
+	}
+	wg.Wait()
+
+	sort.Slice(invs, func(i, j int) bool {
+		return invs[i].Serial < invs[j].Serial
+	})
+	return invs
+}
+
+// firmwareVersions returns the versions of firmware components of the machine.
+// NICs and disks are keyed by their models.  If components of a model have
+// different versions, they are joined by commas.
+func (inv *hwInventory) firmwareVersions() map[string]string {
+	versions := map[string]string{
+		"bios": inv.BIOS,
+		"bmc":  inv.BMC,
+	}
+	add := func(prefix string, cs []hwComponent) {
+		byModel := make(map[string][]string)
+		for _, c := range cs {
+			byModel[c.Model] = appendUnique(byModel[c.Model], c.Firmware)
+		}
+		for model, vs := range byModel {
+			sort.Strings(vs)
+			versions[prefix+"/"+model] = strings.Join(vs, ",")
+		}
+	}
+	add("nic", inv.NICs)
+	add("disk", inv.Disks)
+	return versions
+}
+
+func appendUnique(s []string, v string) []string {
+	for _, e := range s {
+		if e == v {
+			return s
+		}
+	}
+	return append(s, v)
+}
+
+// baselineFirmware returns the most common version of each firmware component
+// for each machine type in the baseline inventories.
+func baselineFirmware(baseline []*hwInventory) map[string]map[string]string {
+	counts := make(map[string]map[string]map[string]int)
+	for _, inv := range baseline {
+		if inv.Error != "" {
+			continue
+		}
+		byComponent := counts[inv.MachineType]
+		if byComponent == nil {
+			byComponent = make(map[string]map[string]int)
+			counts[inv.MachineType] = byComponent
+		}
+		for component, version := range inv.firmwareVersions() {
+			if byComponent[component] == nil {
+				byComponent[component] = make(map[string]int)
+			}
+			byComponent[component][version]++
+		}
+	}
+
+	result := make(map[string]map[string]string)
+	for machineType, byComponent := range counts {
+		result[machineType] = make(map[string]string)
+		for component, byVersion := range byComponent {
+			var best string
+			var bestCount int
+			for version, count := range byVersion {
+				if count > bestCount || (count == bestCount && version < best) {
+					best = version
+					bestCount = count
+				}
+			}
+			result[machineType][component] = best
+		}
+	}
+	return result
+}
+
+// diffInventories returns firmware components of machines whose versions
+// differ from the most common versions for their machine types in baseline.
+func diffInventories(baseline, current []*hwInventory) []hwDrift {
+	base := baselineFirmware(baseline)
+
+	drifts := []hwDrift{}
+	for _, inv := range current {
+		if inv.Error != "" {
+			continue
+		}
+		expected, ok := base[inv.MachineType]
+		if !ok {
+			continue
+		}
+		actual := inv.firmwareVersions()
+
+		components := make([]string, 0, len(expected))
+		for component := range expected {
+			components = append(components, component)
+		}
+		for component := range actual {
+			if _, ok := expected[component]; !ok {
+				components = append(components, component)
+			}
+		}
+		sort.Strings(components)
+
+		for _, component := range components {
+			if expected[component] == actual[component] {
+				continue
+			}
+			drifts = append(drifts, hwDrift{
+				Serial:      inv.Serial,
+				MachineType: inv.MachineType,
+				Component:   component,
+				Baseline:    expected[component],
+				Actual:      actual[component],
+			})
+		}
+	}
+	return drifts
+}
+
+func readInventories(filename string) ([]*hwInventory, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var invs []*hwInventory
+	err = json.Unmarshal(data, &invs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filename, err)
+	}
+	return invs, nil
+}
+
+func componentFirmware(cs []hwComponent) string {
+	var vs []string
+	for _, c := range cs {
+		vs = appendUnique(vs, c.Firmware)
+	}
+	sort.Strings(vs)
+	return strings.Join(vs, ",")
+}
+
+func psuSummary(psus []hwPSU) string {
+	var ok int
+	for _, psu := range psus {
+		if psu.Health == string(common.OKHealth) {
+			ok++
+		}
+	}
+	return fmt.Sprintf("%d/%d OK", ok, len(psus))
+}
+
+func writeInventories(w io.Writer, invs []*hwInventory) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "SERIAL\tTYPE\tBIOS\tBMC\tNIC\tDISK\tCPU\tMEMORY\tPSU\tERROR")
+	for _, inv := range invs {
+		cpu := fmt.Sprintf("%dx %s (%d cores)", inv.CPU.Count, inv.CPU.Model, inv.CPU.Cores)
+		memory := fmt.Sprintf("%gGiB (%d DIMMs)", inv.Memory.TotalGiB, inv.Memory.DIMMs)
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			inv.Serial, inv.MachineType, inv.BIOS, inv.BMC,
+			componentFirmware(inv.NICs), componentFirmware(inv.Disks),
+			cpu, memory, psuSummary(inv.PSUs), inv.Error)
+	}
+	tw.Flush()
+}
+
+func writeDrifts(w io.Writer, drifts []hwDrift) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "SERIAL\tTYPE\tCOMPONENT\tBASELINE\tACTUAL")
+	for _, d := range drifts {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", d.Serial, d.MachineType, d.Component, d.Baseline, d.Actual)
+	}
+	tw.Flush()
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	return enc.Encode(v)
+}
+
+var hwInventoryCmd = &cobra.Command{
+	Use:   "inventory",
+	Short: "show hardware inventory of machines",
+	Long: `Show firmware versions of BIOS, BMC, NICs and disks, CPU and memory
+configuration, and PSU state of machines read through Redfish.
+
+Machines can be selected by sabactl machines get-like options.
+
+With --diff-against FILE, this compares the firmware versions of each
+machine with the most common versions among the machines of the same
+machine-type in FILE, and shows only the differences.  FILE is the output
+of this command with --output json.`,
+	Args: cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		if hwInventoryOutput != "text" && hwInventoryOutput != "json" {
+			log.ErrorExit(errors.New("unknown output format: " + hwInventoryOutput))
+		}
+
+		var baseline []*hwInventory
+		if hwInventoryDiffAgainst != "" {
+			var err error
+			baseline, err = readInventories(hwInventoryDiffAgainst)
+			if err != nil {
+				log.ErrorExit(err)
+			}
+		}
+
+		well.Go(func(ctx context.Context) error {
+			machines, err := sabakanMachinesGet(ctx, &hwInventoryGetOpts)
+			if err != nil {
+				return err
+			}
+			username, password, err := getBMCUsernameAndPassword(ctx)
+			if err != nil {
+				return err
+			}
+			invs := collectInventories(ctx, machines, hwInventoryParallel, username, password)
+
+			if baseline != nil {
+				drifts := diffInventories(baseline, invs)
+				if hwInventoryOutput == "json" {
+					return writeJSON(os.Stdout, drifts)
+				}
+				writeDrifts(os.Stdout, drifts)
+				return nil
+			}
+			if hwInventoryOutput == "json" {
+				return writeJSON(os.Stdout, invs)
+			}
+			writeInventories(os.Stdout, invs)
+			return nil
+		})
+		well.Stop()
+		err := well.Wait()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+	},
+}
+
+func init() {
+	hwInventoryCmd.Flags().StringVarP(&hwInventoryOutput, "output", "o", "text", "output format: text or json")
+	hwInventoryCmd.Flags().IntVar(&hwInventoryParallel, "parallel", 10, "maximum number of machines to read concurrently")
+	hwInventoryCmd.Flags().StringVar(&hwInventoryDiffAgainst, "diff-against", "", "baseline inventory file saved with --output json")
+	addSabakanMachinesGetOpts(hwInventoryCmd, &hwInventoryGetOpts)
+	hwCmd.AddCommand(hwInventoryCmd)
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDiffInventories(t *testing.T) {
+	inv := func(serial, machineType, bios, bmc, nic string) *hwInventory {
+		return &hwInventory{
+			Serial:      serial,
+			MachineType: machineType,
+			BIOS:        bios,
+			BMC:         bmc,
+			NICs: []hwComponent{
+				{ID: "NIC.1", Model: "X710", Firmware: nic},
+				{ID: "NIC.2", Model: "X710", Firmware: nic},
+			},
+			Disks: []hwComponent{
+				{ID: "Disk.1", Model: "PM1725", Firmware: "D1"},
+			},
+		}
+	}
+
+	baseline := []*hwInventory{
+		inv("001", "type-a", "1.1", "5.0", "20.0"),
+		inv("002", "type-a", "1.1", "5.0", "20.0"),
+		inv("003", "type-a", "1.0", "5.0", "20.0"),
+		inv("004", "type-b", "2.0", "6.0", "21.0"),
+		{Serial: "005", MachineType: "type-b", Error: "connection refused"},
+	}
+
+	drifted := inv("003", "type-a", "1.0", "5.0", "20.0")
+	drifted.NICs[1].Firmware = "19.5"
+	current := []*hwInventory{
+		inv("001", "type-a", "1.1", "5.0", "20.0"),
+		drifted,
+		inv("004", "type-b", "2.0", "6.1", "21.0"),
+		{Serial: "005", MachineType: "type-b", Error: "connection refused"},
+		inv("006", "type-c", "3.0", "7.0", "22.0"),
+	}
+
+	expected := []hwDrift{
+		{Serial: "003", MachineType: "type-a", Component: "bios", Baseline: "1.1", Actual: "1.0"},
+		{Serial: "003", MachineType: "type-a", Component: "nic/X710", Baseline: "20.0", Actual: "19.5,20.0"},
+		{Serial: "004", MachineType: "type-b", Component: "bmc", Baseline: "6.0", Actual: "6.1"},
+	}
+	drifts := diffInventories(baseline, current)
+	if !cmp.Equal(drifts, expected) {
+		t.Error("unexpected drifts:", cmp.Diff(expected, drifts))
+	}
+
+	drifts = diffInventories(baseline, baseline)
+	if len(drifts) != 1 || drifts[0].Serial != "003" {
+		t.Error("only 003 should drift from the baseline itself:", drifts)
+	}
+}
@@ -0,0 +1,15 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var machineCmd = &cobra.Command{
+	Use:   "machine",
+	Short: "machine related commands",
+	Long:  `Machine related commands.`,
+}
+
+func init() {
+	rootCmd.AddCommand(machineCmd)
+}
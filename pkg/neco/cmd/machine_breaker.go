@@ -0,0 +1,18 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var machineBreakerCmd = &cobra.Command{
+	Use:   "breaker",
+	Short: "circuit breaker of sabakan-state-setter",
+	Long: `Show or operate the circuit breaker of sabakan-state-setter.
+
+The breaker stops state transitions of machines to unhealthy or
+unreachable when too many machines would change state in a short time.`,
+}
+
+func init() {
+	machineCmd.AddCommand(machineBreakerCmd)
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/storage"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+)
+
+var machineBreakerBypassDuration time.Duration
+
+var machineBreakerBypassCmd = &cobra.Command{
+	Use:   "bypass",
+	Short: "disable the circuit breaker for a while",
+	Long: `Disable the circuit breaker of sabakan-state-setter for a while.
+
+This resets the tripped breaker, and the breaker does not trip until
+the duration passes.  Use this during planned mass maintenance.
+0 duration cancels the bypass.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		etcd, err := neco.EtcdClient()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+		defer etcd.Close()
+		st := storage.NewStorage(etcd)
+		well.Go(func(ctx context.Context) error {
+			return st.UpdateTransitionBreaker(ctx, func(b *neco.TransitionBreaker) error {
+				now := time.Now().UTC()
+				if machineBreakerBypassDuration == 0 {
+					b.BypassUntil = time.Time{}
+				} else {
+					b.BypassUntil = now.Add(machineBreakerBypassDuration)
+					b.Tripped = false
+					b.Reason = ""
+					b.Blocked = nil
+				}
+				b.UpdatedAt = now
+				return nil
+			})
+		})
+		well.Stop()
+		err = well.Wait()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+	},
+}
+
+func init() {
+	machineBreakerBypassCmd.Flags().DurationVar(&machineBreakerBypassDuration, "duration", time.Hour, "duration to disable the breaker (0 cancels the bypass)")
+	machineBreakerCmd.AddCommand(machineBreakerBypassCmd)
+}
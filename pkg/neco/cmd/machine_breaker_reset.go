@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/storage"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+)
+
+var machineBreakerResetCmd = &cobra.Command{
+	Use:   "reset",
+	Short: "reset the tripped circuit breaker",
+	Long: `Reset the tripped circuit breaker of sabakan-state-setter.
+
+The stopped transitions are made at the next evaluation unless the
+breaker trips again.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		etcd, err := neco.EtcdClient()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+		defer etcd.Close()
+		st := storage.NewStorage(etcd)
+		well.Go(func(ctx context.Context) error {
+			return st.UpdateTransitionBreaker(ctx, func(b *neco.TransitionBreaker) error {
+				b.Tripped = false
+				b.Reason = ""
+				b.Blocked = nil
+				b.UpdatedAt = time.Now().UTC()
+				return nil
+			})
+		})
+		well.Stop()
+		err = well.Wait()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+	},
+}
+
+func init() {
+	machineBreakerCmd.AddCommand(machineBreakerResetCmd)
+}
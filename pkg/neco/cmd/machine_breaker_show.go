@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/storage"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+)
+
+func writeTransitionBreaker(w io.Writer, b *neco.TransitionBreaker, now time.Time) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	if b.Tripped {
+		fmt.Fprintf(tw, "Status:\ttripped\n")
+		fmt.Fprintf(tw, "Tripped at:\t%s\n", b.TrippedAt.Format(time.RFC3339))
+		fmt.Fprintf(tw, "Reason:\t%s\n", b.Reason)
+	} else {
+		fmt.Fprintf(tw, "Status:\tclosed\n")
+	}
+	if b.Bypassed(now) {
+		fmt.Fprintf(tw, "Bypass until:\t%s (in %s)\n", b.BypassUntil.Format(time.RFC3339), b.BypassUntil.Sub(now).Truncate(time.Second))
+	}
+	if !b.UpdatedAt.IsZero() {
+		fmt.Fprintf(tw, "Updated at:\t%s\n", b.UpdatedAt.Format(time.RFC3339))
+	}
+	tw.Flush()
+
+	if len(b.Blocked) == 0 {
+		return
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Blocked transitions:")
+	tw = tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "  SERIAL\tFROM\tTO")
+	for _, t := range b.Blocked {
+		fmt.Fprintf(tw, "  %s\t%s\t%s\n", t.Serial, t.From, t.To)
+	}
+	tw.Flush()
+}
+
+var machineBreakerShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "show the status of the circuit breaker",
+	Long: `Show the status of the circuit breaker of sabakan-state-setter,
+and the transitions stopped by the breaker.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		etcd, err := neco.EtcdClient()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+		defer etcd.Close()
+		st := storage.NewStorage(etcd)
+		well.Go(func(ctx context.Context) error {
+			b, err := st.GetTransitionBreaker(ctx)
+			if err != nil {
+				return err
+			}
+			writeTransitionBreaker(os.Stdout, b, time.Now())
+			return nil
+		})
+		well.Stop()
+		err = well.Wait()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+	},
+}
+
+func init() {
+	machineBreakerCmd.AddCommand(machineBreakerShowCmd)
+}
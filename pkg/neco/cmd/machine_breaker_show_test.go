@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cybozu-go/neco"
+)
+
+func TestWriteTransitionBreaker(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	buf := new(bytes.Buffer)
+	writeTransitionBreaker(buf, &neco.TransitionBreaker{}, now)
+	if buf.String() != "Status:  closed\n" {
+		t.Errorf("unexpected output: %q", buf.String())
+	}
+
+	buf.Reset()
+	writeTransitionBreaker(buf, &neco.TransitionBreaker{
+		Tripped:   true,
+		Reason:    "4 machines would become unhealthy or unreachable within 10m0s, more than 3",
+		TrippedAt: now.Add(-time.Minute),
+		Blocked: []neco.BlockedTransition{
+			{Serial: "1234", From: "healthy", To: "unreachable"},
+		},
+		BypassUntil: now.Add(-time.Hour),
+		UpdatedAt:   now,
+	}, now)
+	out := buf.String()
+	for _, s := range []string{
+		"Status:      tripped\n",
+		"Tripped at:  2026-10-01T11:59:00Z\n",
+		"Reason:      4 machines",
+		"  1234    healthy  unreachable\n",
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("%q is not in output:\n%s", s, out)
+		}
+	}
+	if strings.Contains(out, "Bypass") {
+		t.Error("expired bypass should not be shown:\n" + out)
+	}
+}
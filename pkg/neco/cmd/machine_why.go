@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/storage"
+	"github.com/cybozu-go/sabakan/v2"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+)
+
+// describeTransition returns when and to which state the machine will transition.
+func describeTransition(h *neco.MachineHealth, now time.Time) string {
+	switch {
+	case h.Candidate == "":
+		return "the state will not be changed"
+	case h.Candidate == h.State:
+		return "the state stays " + h.Candidate
+	case h.Candidate == string(sabakan.StateUnhealthy) && h.TransitionAt != nil:
+		if h.TransitionAt.After(now) {
+			return fmt.Sprintf("becomes unhealthy at %s (in %s) unless it recovers",
+				h.TransitionAt.Format(time.RFC3339), h.TransitionAt.Sub(now).Truncate(time.Second))
+		}
+		return "becomes unhealthy at the next evaluation"
+	}
+	return fmt.Sprintf("becomes %s at the next evaluation", h.Candidate)
+}
+
+func formatLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	kvs := make([]string, len(keys))
+	for i, k := range keys {
+		kvs[i] = fmt.Sprintf("%s=%q", k, labels[k])
+	}
+	return "{" + strings.Join(kvs, ",") + "}"
+}
+
+func writeMachineWhy(w io.Writer, h *neco.MachineHealth, now time.Time) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "Serial:\t%s\n", h.Serial)
+	fmt.Fprintf(tw, "IPv4:\t%s\n", h.IPv4)
+	fmt.Fprintf(tw, "Machine type:\t%s\n", h.MachineType)
+	fmt.Fprintf(tw, "Evaluated at:\t%s (%s ago)\n", h.EvaluatedAt.Format(time.RFC3339), now.Sub(h.EvaluatedAt).Truncate(time.Second))
+	fmt.Fprintf(tw, "State:\t%s\n", h.State)
+	fmt.Fprintf(tw, "Reason:\t%s\n", h.Reason)
+	fmt.Fprintf(tw, "Next:\t%s\n", describeTransition(h, now))
+	if h.UnhealthySince != nil {
+		fmt.Fprintf(tw, "Unhealthy since:\t%s (grace period: %s)\n", h.UnhealthySince.Format(time.RFC3339), h.GracePeriod)
+	}
+	serf := h.SerfStatus
+	if serf == "" {
+		serf = "not a member"
+	}
+	fmt.Fprintf(tw, "Serf status:\t%s\n", serf)
+	if h.SystemdUnitsFailed != nil {
+		fmt.Fprintf(tw, "Failed units:\t%s\n", *h.SystemdUnitsFailed)
+	}
+	if len(h.Warnings) > 0 {
+		fmt.Fprintf(tw, "Warnings:\t%s\n", strings.Join(h.Warnings, ", "))
+	}
+	tw.Flush()
+
+	if len(h.Checks) == 0 {
+		return
+	}
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "METRIC\tRULE\tSEVERITY\tRESULT\tHEALTHY\tREASON")
+	for _, c := range h.Checks {
+		result := "ok"
+		if !c.Healthy {
+			result = "NG"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d/%d\t%s\n", c.Metric, c.Rule, c.Severity, result, c.HealthyCount, c.Matched, c.Reason)
+		for _, f := range c.Failures {
+			value := "-"
+			if f.Value != nil {
+				value = fmt.Sprint(*f.Value)
+			}
+			fmt.Fprintf(tw, "  %s\t= %s\t\t\t\t%s\n", formatLabels(f.Labels), value, f.Reason)
+		}
+	}
+	tw.Flush()
+}
+
+var machineWhyCmd = &cobra.Command{
+	Use:   "why SERIAL",
+	Short: "show why a machine is in its current state",
+	Long: `Show why a machine is in its current state.
+
+This shows the latest health evaluation of the machine by
+sabakan-state-setter, and when the machine will transition.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		etcd, err := neco.EtcdClient()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+		defer etcd.Close()
+		st := storage.NewStorage(etcd)
+		well.Go(func(ctx context.Context) error {
+			h, err := st.GetMachineHealth(ctx, args[0])
+			if err == storage.ErrNotFound {
+				return errors.New("no health evaluation for " + args[0])
+			}
+			if err != nil {
+				return err
+			}
+			writeMachineWhy(os.Stdout, h, time.Now())
+			return nil
+		})
+		well.Stop()
+		err = well.Wait()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+	},
+}
+
+func init() {
+	machineCmd.AddCommand(machineWhyCmd)
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cybozu-go/neco"
+)
+
+func TestDescribeTransition(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	later := now.Add(25 * time.Minute)
+	earlier := now.Add(-time.Minute)
+
+	testCases := []struct {
+		health   neco.MachineHealth
+		expected string
+	}{
+		{
+			health:   neco.MachineHealth{State: "uninitialized"},
+			expected: "the state will not be changed",
+		},
+		{
+			health:   neco.MachineHealth{State: "healthy", Candidate: "healthy"},
+			expected: "the state stays healthy",
+		},
+		{
+			health:   neco.MachineHealth{State: "healthy", Candidate: "unhealthy", TransitionAt: &later},
+			expected: "becomes unhealthy at 2026-10-01T12:25:00Z (in 25m0s) unless it recovers",
+		},
+		{
+			health:   neco.MachineHealth{State: "healthy", Candidate: "unhealthy", TransitionAt: &earlier},
+			expected: "becomes unhealthy at the next evaluation",
+		},
+		{
+			health:   neco.MachineHealth{State: "healthy", Candidate: "unreachable"},
+			expected: "becomes unreachable at the next evaluation",
+		},
+	}
+	for _, tc := range testCases {
+		actual := describeTransition(&tc.health, now)
+		if actual != tc.expected {
+			t.Errorf("expected %q, actual %q", tc.expected, actual)
+		}
+	}
+}
+
+func TestWriteMachineWhy(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	since := now.Add(-35 * time.Minute)
+	transition := since.Add(time.Hour)
+	value := 75.0
+	h := &neco.MachineHealth{
+		Serial:      "1234",
+		IPv4:        "10.69.0.4",
+		MachineType: "qemu",
+		State:       "healthy",
+		Candidate:   "unhealthy",
+		Reason:      `1 of 2 hw_disk_temperature_celsius metrics do not satisfy "value < 70"`,
+		SerfStatus:  "alive",
+		Checks: []neco.MachineHealthCheck{
+			{
+				Metric:       "hw_disk_temperature_celsius",
+				Rule:         "value < 70",
+				Severity:     "unhealthy",
+				Matched:      2,
+				HealthyCount: 1,
+				Reason:       `1 of 2 hw_disk_temperature_celsius metrics do not satisfy "value < 70"`,
+				Failures: []neco.MachineHealthFailure{
+					{Labels: map[string]string{"device": "HDD.slot.1"}, Value: &value, Reason: "the rule is not satisfied"},
+				},
+			},
+		},
+		UnhealthySince: &since,
+		GracePeriod:    time.Hour,
+		TransitionAt:   &transition,
+		EvaluatedAt:    now.Add(-time.Minute),
+	}
+
+	buf := new(bytes.Buffer)
+	writeMachineWhy(buf, h, now)
+	out := buf.String()
+	for _, expected := range []string{
+		"(1m0s ago)",
+		"becomes unhealthy at 2026-10-01T12:25:00Z (in 25m0s)",
+		"grace period: 1h0m0s",
+		"hw_disk_temperature_celsius  value < 70",
+		`{device="HDD.slot.1"}`,
+		"= 75",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("output should contain %q:\n%s", expected, out)
+		}
+	}
+}
@@ -4,8 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net"
+	"os"
 	"os/exec"
+	"sort"
+	"sync"
+	"text/tabwriter"
 	"time"
 
 	"github.com/cybozu-go/log"
@@ -74,7 +79,10 @@ func getRedfishClient(ctx context.Context, bmcAddr string) (*gofish.APIClient, e
 	if err != nil {
 		return nil, err
 	}
+	return connectRedfish(bmcAddr, username, password)
+}
 
+func connectRedfish(bmcAddr, username, password string) (*gofish.APIClient, error) {
 	config := gofish.ClientConfig{
 		Endpoint:  fmt.Sprintf("https://%s", bmcAddr),
 		Username:  username,
@@ -117,47 +125,204 @@ func waitForStop(ctx context.Context, nodeAddr string) error {
 }
 
 func power(ctx context.Context, action, bmcAddr string) error {
-	client, err := getRedfishClient(ctx, bmcAddr)
+	username, password, err := getBMCUsernameAndPassword(ctx)
 	if err != nil {
 		return err
 	}
-	defer client.Logout()
-
-	system, err := getComputerSystem(client.Service)
+	result, err := powerMachine(action, false, bmcAddr, username, password)
 	if err != nil {
 		return err
 	}
+	fmt.Println(result)
+	return nil
+}
 
-	var resetType redfish.ResetType
+func powerResetType(action string, graceful bool) (redfish.ResetType, error) {
 	switch action {
 	case "start":
 		// Use 'ON' because some machines don't support 'ForceOn'.
-		resetType = redfish.OnResetType
+		return redfish.OnResetType, nil
 	case "stop":
-		resetType = redfish.ForceOffResetType
+		if graceful {
+			return redfish.GracefulShutdownResetType, nil
+		}
+		return redfish.ForceOffResetType, nil
 	case "restart":
+		if graceful {
+			return redfish.GracefulRestartResetType, nil
+		}
 		// Use 'ForceRestart' because some machines don't support 'GracefulRestart'.
-		resetType = redfish.ForceRestartResetType
+		return redfish.ForceRestartResetType, nil
 	case "status":
-		fmt.Println(system.PowerState)
-		return nil
-	default:
-		return errors.New("invalid action: " + action)
+		return "", nil
+	}
+	return "", errors.New("invalid action: " + action)
+}
+
+// powerMachine controls power of a machine and returns the result to be shown.
+// For "status" action, the result is the power state of the machine.
+func powerMachine(action string, graceful bool, bmcAddr, username, password string) (string, error) {
+	resetType, err := powerResetType(action, graceful)
+	if err != nil {
+		return "", err
+	}
+
+	client, err := connectRedfish(bmcAddr, username, password)
+	if err != nil {
+		return "", err
+	}
+	defer client.Logout()
+
+	system, err := getComputerSystem(client.Service)
+	if err != nil {
+		return "", err
+	}
+
+	if action == "status" {
+		return string(system.PowerState), nil
 	}
 
 	err = system.Reset(resetType)
+	if err != nil {
+		return "", err
+	}
+	return "ok", nil
+}
+
+type powerResult struct {
+	machine sabakan.Machine
+	result  string
+	err     error
+}
+
+// selectPowerTargets returns machines to control power.
+// Boot servers are excluded unless includeBoot is true.
+func selectPowerTargets(machines []sabakan.Machine, includeBoot bool) []sabakan.Machine {
+	targets := make([]sabakan.Machine, 0, len(machines))
+	for _, m := range machines {
+		if m.Spec.Role == "boot" && !includeBoot {
+			continue
+		}
+		targets = append(targets, m)
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].Spec.Serial < targets[j].Spec.Serial
+	})
+	return targets
+}
+
+// runPowerFleet calls fn for each machine with at most parallel concurrent calls.
+// The results are returned in the order of machines.
+func runPowerFleet(ctx context.Context, machines []sabakan.Machine, parallel int, fn func(context.Context, sabakan.Machine) (string, error)) []powerResult {
+	if parallel < 1 {
+		parallel = 1
+	}
+	results := make([]powerResult, len(machines))
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, m := range machines {
+		results[i].machine = m
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			results[i].err = ctx.Err()
+			continue
+		}
+		wg.Add(1)
+		go func(i int, m sabakan.Machine) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			results[i].result, results[i].err = fn(ctx, m)
+		}(i, m)
+	}
+	wg.Wait()
+	return results
+}
+
+func writePowerResults(w io.Writer, results []powerResult) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "SERIAL\tROLE\tRACK\tBMC\tRESULT")
+	for _, r := range results {
+		result := r.result
+		if r.err != nil {
+			result = "error: " + r.err.Error()
+		}
+		m := r.machine
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\n", m.Spec.Serial, m.Spec.Role, m.Spec.Rack, m.Spec.BMC.IPv4, result)
+	}
+	tw.Flush()
+}
+
+func powerFleet(ctx context.Context, action string) error {
+	machines, err := sabakanMachinesGet(ctx, &powerGetOpts)
+	if err != nil {
+		return err
+	}
+	targets := selectPowerTargets(machines, powerIncludeBoot)
+	if len(targets) == 0 {
+		return errors.New("no machines are selected")
+	}
+
+	if powerDryRun {
+		results := make([]powerResult, len(targets))
+		for i, m := range targets {
+			results[i] = powerResult{machine: m, result: "dry-run: " + action}
+		}
+		writePowerResults(os.Stdout, results)
+		return nil
+	}
+
+	username, password, err := getBMCUsernameAndPassword(ctx)
 	if err != nil {
 		return err
 	}
-	fmt.Println("ok")
+	results := runPowerFleet(ctx, targets, powerParallel, func(ctx context.Context, m sabakan.Machine) (string, error) {
+		if m.Spec.BMC.IPv4 == "" {
+			return "", errors.New("BMC IP address not found")
+		}
+		result, err := powerMachine(action, powerGraceful, m.Spec.BMC.IPv4, username, password)
+		if err != nil {
+			return "", err
+		}
+		if waitForStopFlag && len(m.Spec.IPv4) > 0 {
+			err := waitForStop(ctx, m.Spec.IPv4[0])
+			if err != nil {
+				return "", err
+			}
+			result = "stopped"
+		}
+		return result, nil
+	})
+	writePowerResults(os.Stdout, results)
+
+	var failed int
+	for _, r := range results {
+		if r.err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to %s %d of %d machines", action, failed, len(results))
+	}
 	return nil
 }
 
+func powerSelectorGiven() bool {
+	for _, v := range powerGetOpts.params {
+		if *v != "" {
+			return true
+		}
+	}
+	return false
+}
+
 var powerCmd = &cobra.Command{
-	Use:     "power ACTION SERIAL|IP",
+	Use:     "power ACTION [SERIAL|IP]",
 	Aliases: []string{"ipmipower"},
-	Short:   "control power of a machine",
-	Long: `Control power of a machine using Redfish API.
+	Short:   "control power of machines",
+	Long: `Control power of machines using Redfish API.
 	
 	ACTION should be one of:
 		- start:   to turn on the machine power.
@@ -166,25 +331,61 @@ var powerCmd = &cobra.Command{
 		- status:  to report the power status of the machine.
 		
 	SERIAL is the serial number of the machine.
-	IP is one of the IP addresses owned by the machine.`,
+	IP is one of the IP addresses owned by the machine.
+
+	Instead of SERIAL or IP, machines can be selected by sabactl
+	machines get-like options such as --rack, --role, --labels and
+	--state.  In this case, the power of the selected machines is
+	controlled concurrently up to --parallel, and the result of each
+	machine is shown as a table.  Boot servers are excluded unless
+	--include-boot is specified.  --dry-run only lists the selected
+	machines.
+
+	With --graceful, stop and restart actions request the OS to shut
+	down gracefully instead of forcibly.`,
 
-	Args:      cobra.ExactArgs(2),
+	Args:      cobra.RangeArgs(1, 2),
 	ValidArgs: []string{"start", "stop", "restart", "status"},
 	Run: func(cmd *cobra.Command, args []string) {
 		well.Go(func(ctx context.Context) error {
 			action := args[0]
+			if _, err := powerResetType(action, powerGraceful); err != nil {
+				return err
+			}
 			if (action != "stop" && action != "restart") && waitForStopFlag {
 				return fmt.Errorf("invalid flag for %s action: --wait-for-stop", action)
 			}
+			if (action != "stop" && action != "restart") && powerGraceful {
+				return fmt.Errorf("invalid flag for %s action: --graceful", action)
+			}
+
+			if len(args) == 1 {
+				if !powerSelectorGiven() {
+					return errors.New("specify SERIAL|IP or options to select machines")
+				}
+				return powerFleet(ctx, action)
+			}
+			if powerSelectorGiven() {
+				return errors.New("SERIAL|IP cannot be used with options to select machines")
+			}
 
 			machine, err := lookupMachine(ctx, args[1])
 			if err != nil {
 				return err
 			}
-			err = power(ctx, action, machine.Spec.BMC.IPv4)
+			if powerDryRun {
+				writePowerResults(os.Stdout, []powerResult{{machine: *machine, result: "dry-run: " + action}})
+				return nil
+			}
+			username, password, err := getBMCUsernameAndPassword(ctx)
 			if err != nil {
 				return err
 			}
+			result, err := powerMachine(action, powerGraceful, machine.Spec.BMC.IPv4, username, password)
+			if err != nil {
+				return err
+			}
+			fmt.Println(result)
 			if waitForStopFlag {
 				err := waitForStop(ctx, machine.Spec.IPv4[0])
 				if err != nil {
@@ -201,9 +402,21 @@ var powerCmd = &cobra.Command{
 	},
 }
 
-var waitForStopFlag bool
+var (
+	waitForStopFlag  bool
+	powerGraceful    bool
+	powerParallel    int
+	powerDryRun      bool
+	powerIncludeBoot bool
+	powerGetOpts     sabakanMachinesGetOpts
+)
 
 func init() {
 	rootCmd.AddCommand(powerCmd)
 	powerCmd.Flags().BoolVar(&waitForStopFlag, "wait-for-stop", false, "")
+	powerCmd.Flags().BoolVar(&powerGraceful, "graceful", false, "shut down or restart the OS gracefully")
+	powerCmd.Flags().IntVar(&powerParallel, "parallel", 10, "maximum number of machines to control concurrently")
+	powerCmd.Flags().BoolVar(&powerDryRun, "dry-run", false, "list the selected machines without controlling power")
+	powerCmd.Flags().BoolVar(&powerIncludeBoot, "include-boot", false, "include boot servers in the selected machines")
+	addSabakanMachinesGetOpts(powerCmd, &powerGetOpts)
 }
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cybozu-go/neco/bmc/redfishtest"
+	"github.com/cybozu-go/sabakan/v2"
+	"github.com/stmcginnis/gofish/redfish"
+)
+
+func TestPowerFleet(t *testing.T) {
+	machines := []sabakan.Machine{
+		{Spec: sabakan.MachineSpec{Serial: "003", Role: "cs"}},
+		{Spec: sabakan.MachineSpec{Serial: "001", Role: "boot"}},
+		{Spec: sabakan.MachineSpec{Serial: "002", Role: "ss"}},
+		{Spec: sabakan.MachineSpec{Serial: "004", Role: "cs"}},
+	}
+
+	targets := selectPowerTargets(machines, false)
+	if len(targets) != 3 {
+		t.Fatal("boot servers should be excluded:", targets)
+	}
+	if targets[0].Spec.Serial != "002" || targets[2].Spec.Serial != "004" {
+		t.Error("targets should be sorted by serial:", targets)
+	}
+	if len(selectPowerTargets(machines, true)) != 4 {
+		t.Error("boot servers should be included")
+	}
+
+	var mu sync.Mutex
+	var running, maxRunning int
+	results := runPowerFleet(context.Background(), targets, 2, func(ctx context.Context, m sabakan.Machine) (string, error) {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+
+		if m.Spec.Serial == "003" {
+			return "", errors.New("failed")
+		}
+		return "ok", nil
+	})
+	if maxRunning > 2 {
+		t.Error("too many concurrent operations:", maxRunning)
+	}
+	if len(results) != 3 {
+		t.Fatal("unexpected results:", results)
+	}
+	for i, r := range results {
+		if r.machine.Spec.Serial != targets[i].Spec.Serial {
+			t.Error("results should be in the order of machines:", r.machine.Spec.Serial)
+		}
+		if (r.err != nil) != (r.machine.Spec.Serial == "003") {
+			t.Error("unexpected result:", r.machine.Spec.Serial, r.result, r.err)
+		}
+	}
+}
+
+func TestPowerMachine(t *testing.T) {
+	s := redfishtest.NewServer(redfishtest.Config{Username: "user", Password: "pass"})
+	defer s.Close()
+
+	result, err := powerMachine("status", false, s.Addr(), "user", "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != string(redfish.OnPowerState) {
+		t.Error("unexpected power state:", result)
+	}
+
+	result, err = powerMachine("stop", true, s.Addr(), "user", "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "ok" || s.PowerState() != redfish.OffPowerState {
+		t.Error("machine should be stopped:", result, s.PowerState())
+	}
+
+	_, err = powerMachine("stop", false, s.Addr(), "user", "pass")
+	if err == nil {
+		t.Error("stopping a stopped machine should fail")
+	}
+
+	_, err = powerMachine("start", false, s.Addr(), "user", "wrong")
+	if err == nil {
+		t.Error("wrong password should fail")
+	}
+
+	s.Fail(http.MethodPost, redfishtest.ResetURI, http.StatusInternalServerError, "internal error", 1)
+	_, err = powerMachine("start", false, s.Addr(), "user", "pass")
+	if err == nil {
+		t.Error("injected failure should be returned")
+	}
+	_, err = powerMachine("start", false, s.Addr(), "user", "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resets := s.Resets()
+	expected := []redfish.ResetType{redfish.GracefulShutdownResetType, redfish.OnResetType}
+	if len(resets) != len(expected) || resets[0] != expected[0] || resets[1] != expected[1] {
+		t.Error("unexpected resets:", resets)
+	}
+}
@@ -3,6 +3,8 @@ package cmd
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"os"
 	"time"
@@ -11,13 +13,29 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// rebootAndWaitInitialWait is the time to wait before checking the boot-up.
+const rebootAndWaitInitialWait = 60 * time.Second
+
+var (
+	rebootAndWaitTimeout        time.Duration
+	rebootAndWaitDiagnosticsDir string
+)
+
 var rebootAndWaitCmd = &cobra.Command{
 	Use:   "reboot-and-wait SERIAL_OR_IP",
 	Short: "reboot a machine and wait for its boot-up",
-	Long:  `Reboot a machine having SERIAL or IP address, and wait for its boot-up.`,
+	Long: `Reboot a machine having SERIAL or IP address, and wait for its boot-up.
+
+By default, this waits forever.  If --timeout is given and the machine
+does not come back within it, this collects diagnostics of the machine
+into --diagnostics-dir, shows the summary, and fails.  --timeout must be
+at least 60s because this waits for 60s before checking the boot-up.`,
 
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if rebootAndWaitTimeout > 0 && rebootAndWaitTimeout < rebootAndWaitInitialWait {
+			return fmt.Errorf("--timeout must be at least %s", rebootAndWaitInitialWait)
+		}
 		cmd.SilenceUsage = true
 		target := args[0]
 		if target == "-" {
@@ -79,6 +97,7 @@ func rebootAndWaitMain(target string) error {
 	log.Info("rebooting a machine", map[string]interface{}{
 		"serial_or_ip": target,
 	})
+	rebootTime := time.Now()
 	err = power(context.Background(), "restart", machine.Spec.BMC.IPv4)
 	if err != nil {
 		log.Error("failed to reboot via IPMI", map[string]interface{}{
@@ -88,13 +107,39 @@ func rebootAndWaitMain(target string) error {
 		return err
 	}
 
+	ctx := context.Background()
+	if rebootAndWaitTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, rebootAndWaitTimeout)
+		defer cancel()
+	}
+
 	// sleep for a while to ignore a delayed change of uptime occurred before my reboot, if any
-	time.Sleep(60 * time.Second)
+	select {
+	case <-ctx.Done():
+	case <-time.After(rebootAndWaitInitialWait):
+	}
 
-	_, err = rebootCheck(target, oldUptime, true)
+	_, err = rebootCheck(ctx, target, oldUptime, true)
+	if err != nil && (errors.Is(err, context.DeadlineExceeded) || ctx.Err() != nil) {
+		log.Error("machine did not come back from reboot", map[string]interface{}{
+			"serial_or_ip": target,
+			"timeout":      rebootAndWaitTimeout.String(),
+		})
+		diagErr := diagnoseReboot(context.Background(), os.Stdout, rebootAndWaitDiagnosticsDir, machine, rebootTime)
+		if diagErr != nil {
+			log.Error("failed to collect diagnostics", map[string]interface{}{
+				"serial_or_ip": target,
+				log.FnError:    diagErr,
+			})
+		}
+		return fmt.Errorf("timed out waiting for %s to come back from reboot", target)
+	}
 	return err
 }
 
 func init() {
+	rebootAndWaitCmd.Flags().DurationVar(&rebootAndWaitTimeout, "timeout", 0, "timeout to wait for the boot-up (0 means no timeout)")
+	rebootAndWaitCmd.Flags().StringVar(&rebootAndWaitDiagnosticsDir, "diagnostics-dir", defaultRebootDiagnosticsDir, "directory to write diagnostics")
 	rootCmd.AddCommand(rebootAndWaitCmd)
 }
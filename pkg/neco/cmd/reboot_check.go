@@ -35,10 +35,19 @@ type serfMemberContainer struct {
 	Members []serfMember `json:"members"`
 }
 
+var (
+	rebootCheckTimeout        time.Duration
+	rebootCheckDiagnosticsDir string
+)
+
 var rebootCheckCmd = &cobra.Command{
 	Use:   "reboot-check SERIAL_OR_IP UNIXTIME",
 	Short: "check machine's (re)boot-up",
-	Long:  `Check (re)boot-up of a machine having SERIAL or IP address after the UNIXTIME.`,
+	Long: `Check (re)boot-up of a machine having SERIAL or IP address after the UNIXTIME.
+
+If --timeout is given and the machine has not come back within the
+duration since UNIXTIME, this collects diagnostics of the machine into
+--diagnostics-dir once and shows the summary to the standard error.`,
 
 	Args: cobra.ExactArgs(2),
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -60,7 +69,8 @@ func rebootCheckMain(target, timestamp string) error {
 	}
 	tm := time.Unix(int64(unixtime), 0)
 
-	rebooted, err := rebootCheck(target, tm, false)
+	ctx := context.Background()
+	rebooted, err := rebootCheck(ctx, target, tm, false)
 	if err != nil {
 		return err
 	}
@@ -71,11 +81,24 @@ func rebootCheckMain(target, timestamp string) error {
 		fmt.Println("false")
 	}
 
+	if !rebooted && rebootCheckTimeout > 0 && time.Since(tm) > rebootCheckTimeout {
+		// The standard output is reserved for the result.
+		err := diagnoseRebootOnce(ctx, os.Stderr, rebootCheckDiagnosticsDir, target, tm)
+		if err != nil {
+			log.Error("failed to collect diagnostics", map[string]interface{}{
+				"serial_or_ip": target,
+				log.FnError:    err,
+			})
+		}
+	}
+
 	return nil
 }
 
-func rebootCheck(target string, timestamp time.Time, wait bool) (bool, error) {
-	machine, err := lookupMachine(context.Background(), target)
+// rebootCheck checks that the machine has booted after timestamp and is healthy.
+// If wait is true, this waits for it until ctx is done.
+func rebootCheck(ctx context.Context, target string, timestamp time.Time, wait bool) (bool, error) {
+	machine, err := lookupMachine(ctx, target)
 	if err != nil {
 		log.Error("failed to lookup serial or IP address", map[string]interface{}{
 			"serial_or_ip": target,
@@ -113,12 +136,19 @@ func rebootCheck(target string, timestamp time.Time, wait bool) (bool, error) {
 		if !wait {
 			return false, nil
 		}
-		time.Sleep(1 * time.Second)
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-time.After(1 * time.Second):
+		}
 	}
 
 	for {
-		machine, err := lookupMachine(context.Background(), target)
+		machine, err := lookupMachine(ctx, target)
 		if err != nil {
+			if ctx.Err() != nil {
+				return false, ctx.Err()
+			}
 			log.Error("failed to lookup serial or IP address", map[string]interface{}{
 				"serial_or_ip": target,
 				log.FnError:    err,
@@ -133,7 +163,11 @@ func rebootCheck(target string, timestamp time.Time, wait bool) (bool, error) {
 		if !wait {
 			return false, nil
 		}
-		time.Sleep(1 * time.Second)
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-time.After(1 * time.Second):
+		}
 	}
 
 	return true, nil
@@ -160,5 +194,7 @@ func getSerfMemberBySerial(serial string) (*serfMember, error) {
 }
 
 func init() {
+	rebootCheckCmd.Flags().DurationVar(&rebootCheckTimeout, "timeout", 0, "collect diagnostics if the machine has not come back within the duration (0 means no timeout)")
+	rebootCheckCmd.Flags().StringVar(&rebootCheckDiagnosticsDir, "diagnostics-dir", defaultRebootDiagnosticsDir, "directory to write diagnostics")
 	rootCmd.AddCommand(rebootCheckCmd)
 }
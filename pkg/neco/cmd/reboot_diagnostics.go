@@ -0,0 +1,350 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cybozu-go/sabakan/v2"
+	"github.com/stmcginnis/gofish"
+	"github.com/stmcginnis/gofish/redfish"
+)
+
+const defaultRebootDiagnosticsDir = "/var/log/neco/reboot-diagnostics"
+
+// rebootDiagnostics is the diagnostic information of a machine that
+// did not come back from reboot.
+type rebootDiagnostics struct {
+	Serial      string    `json:"serial"`
+	BMC         string    `json:"bmc"`
+	RebootTime  time.Time `json:"reboot_time"`
+	CollectedAt time.Time `json:"collected_at"`
+
+	PowerState   string              `json:"power_state"`
+	BootProgress *rebootBootProgress `json:"boot_progress,omitempty"`
+	POSTCode     string              `json:"post_code"`
+	SEL          []rebootLogEntry    `json:"sel"`
+
+	Serf    *serfMember      `json:"serf"`
+	Machine *sabakan.Machine `json:"machine"`
+
+	Errors []string `json:"errors,omitempty"`
+}
+
+type rebootBootProgress struct {
+	LastState     string `json:"last_state"`
+	LastStateTime string `json:"last_state_time,omitempty"`
+	OemLastState  string `json:"oem_last_state,omitempty"`
+}
+
+type rebootLogEntry struct {
+	Created  string `json:"created"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+func (d *rebootDiagnostics) addError(what string, err error) {
+	d.Errors = append(d.Errors, fmt.Sprintf("%s: %s", what, err.Error()))
+}
+
+// readLogEntries reads the entries of a log service.
+// Entries embedded in the collection are used as they are.
+// The collection may be split into pages linked by Members@odata.nextLink.
+func readLogEntries(client *gofish.APIClient, service *redfish.LogService) ([]rebootLogEntry, error) {
+	var result []rebootLogEntry
+	for next := service.ODataID + "/Entries"; next != ""; {
+		entries, nextLink, err := readLogEntriesPage(client, next)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, entries...)
+		if nextLink == next {
+			return nil, fmt.Errorf("next link of log entries points to itself: %s", next)
+		}
+		next = nextLink
+	}
+	return result, nil
+}
+
+func readLogEntriesPage(client *gofish.APIClient, uri string) ([]rebootLogEntry, string, error) {
+	resp, err := client.Get(uri)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	var entries struct {
+		Members []struct {
+			ODataID  string `json:"@odata.id"`
+			Created  string
+			Severity string
+			Message  string
+		}
+		NextLink string `json:"Members@odata.nextLink"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&entries)
+	if err != nil {
+		return nil, "", err
+	}
+
+	result := make([]rebootLogEntry, 0, len(entries.Members))
+	for _, m := range entries.Members {
+		if m.Created == "" && m.Message == "" {
+			e, err := redfish.GetLogEntry(client, m.ODataID)
+			if err != nil {
+				return nil, "", err
+			}
+			m.Created, m.Severity, m.Message = e.Created, string(e.Severity), e.Message
+		}
+		result = append(result, rebootLogEntry{Created: m.Created, Severity: m.Severity, Message: m.Message})
+	}
+	return result, entries.NextLink, nil
+}
+
+// filterLogEntries returns the entries created at or after since.
+// Entries whose creation time cannot be parsed are kept.
+func filterLogEntries(entries []rebootLogEntry, since time.Time) []rebootLogEntry {
+	result := []rebootLogEntry{}
+	for _, e := range entries {
+		created, err := time.Parse(time.RFC3339, e.Created)
+		if err == nil && created.Before(since) {
+			continue
+		}
+		result = append(result, e)
+	}
+	return result
+}
+
+func isSELService(service *redfish.LogService) bool {
+	return strings.EqualFold(service.ID, "Sel") || service.LogEntryType == redfish.SELLogEntryTypes
+}
+
+func isPOSTCodeService(service *redfish.LogService) bool {
+	return strings.Contains(strings.ToLower(service.ID), "postcode")
+}
+
+// collectRedfishDiagnostics reads the power state, boot progress, the
+// last POST code and SEL entries since the reboot through Redfish.
+func collectRedfishDiagnostics(d *rebootDiagnostics, client *gofish.APIClient) {
+	system, err := getComputerSystem(client.Service)
+	if err != nil {
+		d.addError("redfish system", err)
+		return
+	}
+	d.PowerState = string(system.PowerState)
+
+	// gofish does not support BootProgress, so read it from the raw resource.
+	resp, err := client.Get(system.ODataID)
+	if err != nil {
+		d.addError("redfish boot progress", err)
+	} else {
+		var raw struct {
+			BootProgress *struct {
+				LastState     string
+				LastStateTime string
+				OemLastState  string
+			}
+		}
+		err = json.NewDecoder(resp.Body).Decode(&raw)
+		resp.Body.Close()
+		if err != nil {
+			d.addError("redfish boot progress", err)
+		} else if raw.BootProgress != nil {
+			d.BootProgress = &rebootBootProgress{
+				LastState:     raw.BootProgress.LastState,
+				LastStateTime: raw.BootProgress.LastStateTime,
+				OemLastState:  raw.BootProgress.OemLastState,
+			}
+		}
+	}
+
+	var services []*redfish.LogService
+	systemServices, err := system.LogServices()
+	if err != nil {
+		d.addError("redfish system log services", err)
+	}
+	services = append(services, systemServices...)
+	managers, err := client.Service.Managers()
+	if err != nil {
+		d.addError("redfish managers", err)
+	}
+	for _, m := range managers {
+		managerServices, err := m.LogServices()
+		if err != nil {
+			d.addError("redfish manager log services", err)
+			continue
+		}
+		services = append(services, managerServices...)
+	}
+
+	d.SEL = []rebootLogEntry{}
+	for _, s := range services {
+		switch {
+		case isSELService(s):
+			entries, err := readLogEntries(client, s)
+			if err != nil {
+				d.addError("redfish SEL", err)
+				continue
+			}
+			d.SEL = append(d.SEL, filterLogEntries(entries, d.RebootTime)...)
+		case isPOSTCodeService(s):
+			entries, err := readLogEntries(client, s)
+			if err != nil {
+				d.addError("redfish POST codes", err)
+				continue
+			}
+			if len(entries) > 0 {
+				d.POSTCode = entries[len(entries)-1].Message
+			}
+		}
+	}
+}
+
+// collectRebootDiagnostics collects the diagnostic information of the machine.
+// Failures in collecting each item are recorded in the result.
+func collectRebootDiagnostics(ctx context.Context, machine *sabakan.Machine, rebootTime time.Time, username, password string) *rebootDiagnostics {
+	d := &rebootDiagnostics{
+		Serial:      machine.Spec.Serial,
+		BMC:         machine.Spec.BMC.IPv4,
+		RebootTime:  rebootTime.UTC(),
+		CollectedAt: time.Now().UTC(),
+		SEL:         []rebootLogEntry{},
+	}
+
+	client, err := connectRedfish(machine.Spec.BMC.IPv4, username, password)
+	if err != nil {
+		d.addError("redfish", err)
+	} else {
+		collectRedfishDiagnostics(d, client)
+		client.Logout()
+	}
+
+	member, err := getSerfMemberBySerial(machine.Spec.Serial)
+	if err != nil {
+		d.addError("serf", err)
+	}
+	d.Serf = member
+
+	current, err := lookupMachine(ctx, machine.Spec.Serial)
+	if err != nil {
+		d.addError("sabakan", err)
+		current = machine
+	}
+	d.Machine = current
+
+	return d
+}
+
+// rebootDiagnosticsDirName returns the name of the directory for the bundle.
+func rebootDiagnosticsDirName(serial string, rebootTime time.Time) string {
+	return fmt.Sprintf("%s-%s", serial, rebootTime.UTC().Format("20060102T150405Z"))
+}
+
+func writeRebootDiagnosticsSummary(w io.Writer, d *rebootDiagnostics) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "Serial:\t%s\n", d.Serial)
+	fmt.Fprintf(tw, "BMC:\t%s\n", d.BMC)
+	fmt.Fprintf(tw, "Reboot time:\t%s\n", d.RebootTime.Format(time.RFC3339))
+	fmt.Fprintf(tw, "Power state:\t%s\n", d.PowerState)
+	if d.BootProgress != nil {
+		state := d.BootProgress.LastState
+		if d.BootProgress.OemLastState != "" {
+			state += " (" + d.BootProgress.OemLastState + ")"
+		}
+		fmt.Fprintf(tw, "Boot progress:\t%s\n", state)
+	}
+	fmt.Fprintf(tw, "Last POST code:\t%s\n", d.POSTCode)
+	fmt.Fprintf(tw, "SEL entries:\t%d\n", len(d.SEL))
+	if d.Serf != nil {
+		fmt.Fprintf(tw, "Serf status:\t%s (uptime: %s)\n", d.Serf.Status, d.Serf.Tags[serfTagUptime])
+	} else {
+		fmt.Fprintf(tw, "Serf status:\tnot a member\n")
+	}
+	if d.Machine != nil {
+		fmt.Fprintf(tw, "Sabakan state:\t%s\n", d.Machine.Status.State)
+	}
+	tw.Flush()
+
+	for _, e := range d.SEL {
+		fmt.Fprintf(w, "  %s [%s] %s\n", e.Created, e.Severity, e.Message)
+	}
+	for _, e := range d.Errors {
+		fmt.Fprintln(w, "Error:", e)
+	}
+}
+
+// writeRebootDiagnostics writes the bundle under dir and returns its path.
+func writeRebootDiagnostics(dir string, d *rebootDiagnostics) (string, error) {
+	bundle := filepath.Join(dir, rebootDiagnosticsDirName(d.Serial, d.RebootTime))
+	err := os.MkdirAll(bundle, 0755)
+	if err != nil {
+		return "", err
+	}
+
+	files := map[string]interface{}{
+		"redfish.json": map[string]interface{}{
+			"power_state":   d.PowerState,
+			"boot_progress": d.BootProgress,
+			"post_code":     d.POSTCode,
+		},
+		"sel.json":     d.SEL,
+		"serf.json":    d.Serf,
+		"sabakan.json": d.Machine,
+		"bundle.json":  d,
+	}
+	for name, v := range files {
+		data, err := json.MarshalIndent(v, "", "    ")
+		if err != nil {
+			return "", err
+		}
+		err = os.WriteFile(filepath.Join(bundle, name), append(data, '\n'), 0644)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	f, err := os.Create(filepath.Join(bundle, "summary.txt"))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	writeRebootDiagnosticsSummary(f, d)
+	return bundle, f.Sync()
+}
+
+// diagnoseReboot collects and writes diagnostics of a machine that did not
+// come back from reboot, and shows the summary to w.
+func diagnoseReboot(ctx context.Context, w io.Writer, dir string, machine *sabakan.Machine, rebootTime time.Time) error {
+	username, password, err := getBMCUsernameAndPassword(ctx)
+	if err != nil {
+		return err
+	}
+	d := collectRebootDiagnostics(ctx, machine, rebootTime, username, password)
+	bundle, err := writeRebootDiagnostics(dir, d)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Machine %s did not come back from reboot.  Diagnostics are saved in %s\n", d.Serial, bundle)
+	writeRebootDiagnosticsSummary(w, d)
+	return nil
+}
+
+// diagnoseRebootOnce is the same as diagnoseReboot but does nothing if
+// the diagnostics for the reboot have already been collected.
+func diagnoseRebootOnce(ctx context.Context, w io.Writer, dir, target string, rebootTime time.Time) error {
+	machine, err := lookupMachine(ctx, target)
+	if err != nil {
+		return err
+	}
+	bundle := filepath.Join(dir, rebootDiagnosticsDirName(machine.Spec.Serial, rebootTime))
+	if _, err := os.Stat(bundle); err == nil {
+		return nil
+	}
+	return diagnoseReboot(ctx, w, dir, machine, rebootTime)
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cybozu-go/neco/bmc/redfishtest"
+	"github.com/cybozu-go/sabakan/v2"
+	"github.com/stmcginnis/gofish/redfish"
+)
+
+func TestRebootDiagnostics(t *testing.T) {
+	// Split the logs into pages to check that all pages are read.
+	s := redfishtest.NewServer(redfishtest.Config{Username: "user", Password: "pass", LogPageSize: 1})
+	defer s.Close()
+
+	rebootTime := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	s.AddSELEntry(redfishtest.LogEntry{
+		Created:  rebootTime.Add(-time.Hour),
+		Severity: redfish.OKEventSeverity,
+		Message:  "The system was powered on.",
+	})
+	s.AddSELEntry(redfishtest.LogEntry{
+		Created:  rebootTime.Add(time.Minute),
+		Severity: redfish.CriticalEventSeverity,
+		Message:  "Multi-bit memory errors detected on a memory device at location DIMM_A1.",
+	})
+	s.AddPOSTCode(rebootTime.Add(time.Minute), "0x15")
+	s.AddPOSTCode(rebootTime.Add(2*time.Minute), "0x4F")
+	s.SetBootProgress("MemoryInitializationStarted")
+
+	d := &rebootDiagnostics{
+		Serial:     "1234abcd",
+		BMC:        s.Addr(),
+		RebootTime: rebootTime,
+		Serf:       &serfMember{Name: "rack0-cs1", Status: "failed", Tags: map[string]string{serfTagUptime: "2026-10-01 11:00:00"}},
+		Machine: &sabakan.Machine{
+			Spec:   sabakan.MachineSpec{Serial: "1234abcd"},
+			Status: sabakan.MachineStatus{State: sabakan.StateUnreachable},
+		},
+	}
+	client, err := connectRedfish(s.Addr(), "user", "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	collectRedfishDiagnostics(d, client)
+	client.Logout()
+
+	if len(d.Errors) != 0 {
+		t.Fatal("unexpected errors:", d.Errors)
+	}
+	if d.PowerState != string(redfish.OnPowerState) {
+		t.Error("unexpected power state:", d.PowerState)
+	}
+	if d.BootProgress == nil || d.BootProgress.LastState != "MemoryInitializationStarted" {
+		t.Error("unexpected boot progress:", d.BootProgress)
+	}
+	if d.POSTCode != "POST Code: 0x4F" {
+		t.Error("the last POST code should be collected:", d.POSTCode)
+	}
+	if len(d.SEL) != 1 || !strings.Contains(d.SEL[0].Message, "DIMM_A1") {
+		t.Error("only SEL entries since the reboot should be collected:", d.SEL)
+	}
+
+	dir := t.TempDir()
+	bundle, err := writeRebootDiagnostics(dir, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bundle != filepath.Join(dir, "1234abcd-20261001T120000Z") {
+		t.Error("unexpected bundle path:", bundle)
+	}
+	for _, name := range []string{"redfish.json", "sel.json", "serf.json", "sabakan.json", "bundle.json", "summary.txt"} {
+		if _, err := os.Stat(filepath.Join(bundle, name)); err != nil {
+			t.Error(name, "should be written:", err)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(bundle, "bundle.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved rebootDiagnostics
+	err = json.Unmarshal(data, &saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.POSTCode != d.POSTCode || len(saved.SEL) != 1 || saved.Machine.Status.State != sabakan.StateUnreachable {
+		t.Error("unexpected bundle:", string(data))
+	}
+
+	buf := new(bytes.Buffer)
+	writeRebootDiagnosticsSummary(buf, d)
+	summary := buf.String()
+	for _, expected := range []string{"MemoryInitializationStarted", "POST Code: 0x4F", "failed", "unreachable", "DIMM_A1"} {
+		if !strings.Contains(summary, expected) {
+			t.Errorf("summary should contain %q: %s", expected, summary)
+		}
+	}
+}
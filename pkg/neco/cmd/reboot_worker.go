@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
@@ -11,6 +12,7 @@ import (
 
 	"github.com/cybozu-go/log"
 	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/storage"
 	"github.com/cybozu-go/sabakan/v2"
 	"github.com/cybozu-go/sabakan/v2/client"
 	"github.com/cybozu-go/well"
@@ -86,12 +88,26 @@ var rebootWorkerCmd = &cobra.Command{
 	Short: "reboot all worker nodes",
 	Long: `Reboot all worker nodes for their updates.
 
+This creates a rolling reboot job in etcd and runs it.  The job reboots
+machines rack by rack so that at most --max-unavailable-per-rack machines
+in a rack are unavailable at the same time.  A rebooted machine is
+available again when its serf uptime is updated and sabakan reports it
+healthy.  If it does not become available within --timeout, it is marked
+as failed and keeps occupying its rack.
+
 This uses CKE's function of graceful reboot for the nodes used by CKE.
 As for the other nodes, this reboots them immediately.
-If some nodes are already powered off, this command does not do anything to those nodes.`,
+If some nodes are already powered off, this command does not do anything to those nodes.
+
+The progress of the job is recorded in etcd.  If this command stops
+halfway, run "neco reboot-worker resume" to continue the job.`,
 
 	Args: cobra.ExactArgs(0),
 	Run: func(cmd *cobra.Command, args []string) {
+		if rebootWorkerMaxUnavailable < 1 {
+			log.ErrorExit(errors.New("--max-unavailable-per-rack must be positive"))
+		}
+
 		fmt.Println("WARNING: this command reboots all servers other than boot servers and may cause system instability.")
 		ans, err := askYorN("Continue?")
 		if err != nil {
@@ -109,31 +125,100 @@ If some nodes are already powered off, this command does not do anything to thos
 			return
 		}
 
-		err = rebootMain()
+		etcd, err := neco.EtcdClient()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+		defer etcd.Close()
+		st := storage.NewStorage(etcd)
+
+		well.Go(func(ctx context.Context) error {
+			err := createRebootJob(ctx, st)
+			if err != nil {
+				return err
+			}
+			return runRebootJob(ctx, etcd, false)
+		})
+		well.Stop()
+		err = well.Wait()
 		if err != nil {
 			log.ErrorExit(err)
 		}
 	},
 }
 
-var rebootWorkerGetOpts sabakanMachinesGetOpts
+var (
+	rebootWorkerGetOpts        sabakanMachinesGetOpts
+	rebootWorkerMaxUnavailable int
+	rebootWorkerTimeout        time.Duration
+)
+
+func createRebootJob(ctx context.Context, st storage.Storage) error {
+	job, err := st.GetRebootJob(ctx)
+	switch err {
+	case nil:
+		if !job.State.Finished() {
+			return fmt.Errorf("a reboot job is %s; use neco reboot-worker status, resume or cancel", job.State)
+		}
+	case storage.ErrNotFound:
+	default:
+		return err
+	}
 
-func rebootMain() error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
 	machines, err := sabakanMachinesGet(ctx, &rebootWorkerGetOpts)
 	if err != nil {
 		return err
 	}
+	cluster, err := getCKECluster()
+	if err != nil {
+		return err
+	}
+	ckeNodeAddrs := make(map[string]bool, len(cluster.Nodes))
+	for _, node := range cluster.Nodes {
+		ckeNodeAddrs[node.Address] = true
+	}
 
-	toreboot := []sabakan.Machine{}
+	var targets []*neco.RebootMachine
 	for _, m := range machines {
-		if m.Spec.Role != "boot" {
-			toreboot = append(toreboot, m)
+		if m.Spec.Role == "boot" {
+			continue
+		}
+		if len(m.Spec.IPv4) == 0 {
+			log.Warn("IP addresses not found; skipping", map[string]interface{}{
+				"serial": m.Spec.Serial,
+			})
+			continue
 		}
+		targets = append(targets, &neco.RebootMachine{
+			Serial: m.Spec.Serial,
+			Rack:   m.Spec.Rack,
+			Node:   m.Spec.IPv4[0],
+			BMC:    m.Spec.BMC.IPv4,
+			CKE:    ckeNodeAddrs[m.Spec.IPv4[0]],
+			State:  neco.RebootPending,
+		})
+	}
+	if len(targets) == 0 {
+		return errors.New("no machines to reboot")
 	}
 
-	return rebootMachines(toreboot)
+	job = &neco.RebootJob{
+		State:                 neco.RebootJobRunning,
+		MaxUnavailablePerRack: rebootWorkerMaxUnavailable,
+		Timeout:               rebootWorkerTimeout,
+		CreatedAt:             time.Now().UTC(),
+	}
+	err = st.CreateRebootJob(ctx, job, targets)
+	if err == storage.ErrConflict {
+		return errors.New("another reboot job has been created")
+	}
+	if err != nil {
+		return err
+	}
+	log.Info("created a reboot job", map[string]interface{}{
+		"machines": len(targets),
+	})
+	return nil
 }
 
 func rebootMachines(machines []sabakan.Machine) error {
@@ -145,14 +230,7 @@ func rebootMachines(machines []sabakan.Machine) error {
 		machineAddrs[m.Spec.IPv4[0]] = true
 	}
 
-	comm := exec.Command(neco.CKECLIBin, "cluster", "get")
-	comm.Stderr = os.Stderr
-	output, err := comm.Output()
-	if err != nil {
-		return err
-	}
-	cluster := new(ckeCluster)
-	err = yaml.Unmarshal(output, cluster)
+	cluster, err := getCKECluster()
 	if err != nil {
 		return err
 	}
@@ -202,11 +280,7 @@ func rebootMachines(machines []sabakan.Machine) error {
 				"rack": rack,
 				"role": roles[address],
 			})
-			comm := exec.Command(neco.CKECLIBin, "reboot-queue", "add", "-")
-			comm.Stdin = strings.NewReader(address + "\n")
-			comm.Stdout = os.Stdout
-			comm.Stderr = os.Stderr
-			err := comm.Run()
+			err := addCKERebootQueue(address)
 			if err != nil {
 				return err
 			}
@@ -258,6 +332,29 @@ func rebootMachines(machines []sabakan.Machine) error {
 	return nil
 }
 
+func getCKECluster() (*ckeCluster, error) {
+	comm := exec.Command(neco.CKECLIBin, "cluster", "get")
+	comm.Stderr = os.Stderr
+	output, err := comm.Output()
+	if err != nil {
+		return nil, err
+	}
+	cluster := new(ckeCluster)
+	err = yaml.Unmarshal(output, cluster)
+	if err != nil {
+		return nil, err
+	}
+	return cluster, nil
+}
+
+func addCKERebootQueue(address string) error {
+	comm := exec.Command(neco.CKECLIBin, "reboot-queue", "add", "-")
+	comm.Stdin = strings.NewReader(address + "\n")
+	comm.Stdout = os.Stdout
+	comm.Stderr = os.Stderr
+	return comm.Run()
+}
+
 func rebootNode(ctx context.Context, bmdAddr string) error {
 	client, err := getRedfishClient(ctx, bmdAddr)
 	if err != nil {
@@ -302,4 +399,6 @@ func askYorN(query string) (bool, error) {
 func init() {
 	rootCmd.AddCommand(rebootWorkerCmd)
 	addSabakanMachinesGetOpts(rebootWorkerCmd, &rebootWorkerGetOpts)
+	rebootWorkerCmd.Flags().IntVar(&rebootWorkerMaxUnavailable, "max-unavailable-per-rack", 1, "maximum number of unavailable machines in a rack")
+	rebootWorkerCmd.Flags().DurationVar(&rebootWorkerTimeout, "timeout", 30*time.Minute, "time limit for a machine to reboot and become healthy")
 }
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/storage"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+)
+
+var rebootWorkerCancelCmd = &cobra.Command{
+	Use:   "cancel",
+	Short: "cancel the reboot job",
+	Long: `Cancel the rolling reboot job.
+
+The process running the job exits without rebooting more machines.
+Machines being rebooted, including those in the reboot queue of CKE,
+are not stopped.`,
+	Args: cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		etcd, err := neco.EtcdClient()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+		defer etcd.Close()
+		st := storage.NewStorage(etcd)
+		well.Go(func(ctx context.Context) error {
+			return st.UpdateRebootJob(ctx, func(job *neco.RebootJob) error {
+				if job.State.Finished() {
+					return fmt.Errorf("the reboot job is already %s", job.State)
+				}
+				job.State = neco.RebootJobCancelled
+				return nil
+			})
+		})
+		well.Stop()
+		err = well.Wait()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+	},
+}
+
+func init() {
+	rebootWorkerCmd.AddCommand(rebootWorkerCancelCmd)
+}
@@ -0,0 +1,259 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/storage"
+	"github.com/stmcginnis/gofish/redfish"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+)
+
+const rebootJobInterval = 10 * time.Second
+
+// rebootJobRunner runs the rolling reboot job stored in etcd.
+// Only one runner holding the lock of storage.KeyRebootWorkerLeader
+// updates the states of machines.
+type rebootJobRunner struct {
+	storage   storage.Storage
+	leaderKey string
+	username  string
+	password  string
+}
+
+// runRebootJob runs the rolling reboot job until it is finished or paused.
+// If retryFailed is true, failed machines are rebooted again.
+func runRebootJob(ctx context.Context, ec *clientv3.Client, retryFailed bool) error {
+	sess, err := concurrency.NewSession(ec, concurrency.WithTTL(60))
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	mu := concurrency.NewMutex(sess, storage.KeyRebootWorkerLeader)
+	err = mu.TryLock(ctx)
+	if err == concurrency.ErrLocked {
+		return errors.New("another process is running the reboot job")
+	}
+	if err != nil {
+		return err
+	}
+	defer mu.Unlock(context.Background())
+
+	username, password, err := getBMCUsernameAndPassword(ctx)
+	if err != nil {
+		return err
+	}
+	r := rebootJobRunner{
+		storage:   storage.NewStorage(ec),
+		leaderKey: mu.Key(),
+		username:  username,
+		password:  password,
+	}
+
+	if retryFailed {
+		err := r.retryFailed(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	for {
+		done, err := r.runOnce(ctx)
+		if err != nil || done {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(rebootJobInterval):
+		}
+	}
+}
+
+func (r rebootJobRunner) retryFailed(ctx context.Context) error {
+	machines, err := r.storage.GetRebootMachines(ctx)
+	if err != nil {
+		return err
+	}
+	for _, m := range machines {
+		if m.State != neco.RebootFailed {
+			continue
+		}
+		m.State = neco.RebootPending
+		m.StartedAt = time.Time{}
+		m.FinishedAt = time.Time{}
+		m.Message = ""
+		err := r.storage.PutRebootMachine(ctx, m, r.leaderKey)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runOnce checks machines being rebooted and starts rebooting next machines.
+// It returns true if the job is finished or paused.
+func (r rebootJobRunner) runOnce(ctx context.Context) (bool, error) {
+	job, err := r.storage.GetRebootJob(ctx)
+	if err != nil {
+		return false, err
+	}
+	if job.State == neco.RebootJobCancelled {
+		log.Info("the reboot job has been cancelled", nil)
+		return true, nil
+	}
+
+	machines, err := r.storage.GetRebootMachines(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	var rebooting, pending, failed int
+	for _, m := range machines {
+		if m.State == neco.RebootRebooting {
+			err := r.check(ctx, job, m)
+			if err != nil {
+				return false, err
+			}
+		}
+		switch m.State {
+		case neco.RebootRebooting:
+			rebooting++
+		case neco.RebootPending:
+			pending++
+		case neco.RebootFailed:
+			failed++
+		}
+	}
+
+	switch {
+	case job.State == neco.RebootJobPaused && rebooting == 0:
+		log.Info("the reboot job has been paused", nil)
+		return true, nil
+	case job.State != neco.RebootJobRunning:
+		return false, nil
+	case pending == 0 && rebooting == 0:
+		err := r.storage.UpdateRebootJob(ctx, func(job *neco.RebootJob) error {
+			if job.State != neco.RebootJobRunning {
+				return fmt.Errorf("the reboot job has been %s", job.State)
+			}
+			job.State = neco.RebootJobCompleted
+			return nil
+		})
+		if err != nil {
+			return false, err
+		}
+		if failed > 0 {
+			return true, fmt.Errorf("failed to reboot %d machines; see neco reboot-worker status", failed)
+		}
+		log.Info("the reboot job has been completed", nil)
+		return true, nil
+	}
+
+	next := neco.NextRebootMachines(job.MaxUnavailablePerRack, machines)
+	if len(next) == 0 && rebooting == 0 {
+		return true, fmt.Errorf("%d failed machines block the reboot job; run neco reboot-worker resume to retry them", failed)
+	}
+	for _, m := range next {
+		err := r.start(ctx, m)
+		if err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// check marks m as done when it has rebooted and become healthy,
+// or as failed when it exceeds the timeout.
+func (r rebootJobRunner) check(ctx context.Context, job *neco.RebootJob, m *neco.RebootMachine) error {
+	rebooted, err := rebootCheck(ctx, m.Serial, m.StartedAt, false)
+	if err != nil {
+		// rebootCheck has logged the error.  Check the machine again later.
+		rebooted = false
+	}
+
+	now := time.Now().UTC()
+	switch {
+	case rebooted:
+		m.State = neco.RebootDone
+		log.Info("machine has been rebooted", map[string]interface{}{
+			"serial": m.Serial,
+			"node":   m.Node,
+		})
+	case now.Sub(m.StartedAt) > job.Timeout:
+		m.State = neco.RebootFailed
+		m.Message = "timed out waiting for the machine to become healthy"
+		log.Warn("machine did not become healthy", map[string]interface{}{
+			"serial": m.Serial,
+			"node":   m.Node,
+		})
+	default:
+		return nil
+	}
+	m.FinishedAt = now
+	return r.storage.PutRebootMachine(ctx, m, r.leaderKey)
+}
+
+// start starts rebooting m.  The state is recorded before rebooting
+// so that the job can be resumed after this process dies.
+func (r rebootJobRunner) start(ctx context.Context, m *neco.RebootMachine) error {
+	m.State = neco.RebootRebooting
+	m.StartedAt = time.Now().UTC()
+	err := r.storage.PutRebootMachine(ctx, m, r.leaderKey)
+	if err != nil {
+		return err
+	}
+
+	log.Info("rebooting machine", map[string]interface{}{
+		"serial": m.Serial,
+		"node":   m.Node,
+		"rack":   m.Rack,
+		"cke":    m.CKE,
+	})
+	state, err := r.reboot(m)
+	if err != nil {
+		log.Warn("failed to reboot machine", map[string]interface{}{
+			"serial":    m.Serial,
+			"node":      m.Node,
+			log.FnError: err,
+		})
+		state = neco.RebootFailed
+		m.Message = err.Error()
+	}
+	if state == neco.RebootRebooting {
+		return nil
+	}
+	m.State = state
+	m.FinishedAt = time.Now().UTC()
+	return r.storage.PutRebootMachine(ctx, m, r.leaderKey)
+}
+
+func (r rebootJobRunner) reboot(m *neco.RebootMachine) (neco.RebootMachineState, error) {
+	if m.CKE {
+		return neco.RebootRebooting, addCKERebootQueue(m.Node)
+	}
+
+	if m.BMC == "" {
+		return "", errors.New("BMC IP address not found")
+	}
+	status, err := powerMachine("status", false, m.BMC, r.username, r.password)
+	if err != nil {
+		return "", err
+	}
+	if status == string(redfish.OffPowerState) {
+		m.Message = "already powered off"
+		return neco.RebootSkipped, nil
+	}
+	_, err = powerMachine("restart", false, m.BMC, r.username, r.password)
+	if err != nil {
+		return "", err
+	}
+	return neco.RebootRebooting, nil
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/storage"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+)
+
+var rebootWorkerPauseCmd = &cobra.Command{
+	Use:   "pause",
+	Short: "pause the reboot job",
+	Long: `Pause the rolling reboot job.
+
+The process running the job stops rebooting more machines, waits for
+the machines being rebooted, and exits.  Run "neco reboot-worker resume"
+to continue the job.`,
+	Args: cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		etcd, err := neco.EtcdClient()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+		defer etcd.Close()
+		st := storage.NewStorage(etcd)
+		well.Go(func(ctx context.Context) error {
+			return st.UpdateRebootJob(ctx, func(job *neco.RebootJob) error {
+				if job.State != neco.RebootJobRunning {
+					return fmt.Errorf("the reboot job is %s", job.State)
+				}
+				job.State = neco.RebootJobPaused
+				return nil
+			})
+		})
+		well.Stop()
+		err = well.Wait()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+	},
+}
+
+func init() {
+	rebootWorkerCmd.AddCommand(rebootWorkerPauseCmd)
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/storage"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+)
+
+var rebootWorkerResumeCmd = &cobra.Command{
+	Use:   "resume",
+	Short: "resume the reboot job",
+	Long: `Resume the rolling reboot job that is paused, or whose process
+stopped halfway, and run it until it finishes.
+
+Machines that failed to reboot are rebooted again.`,
+	Args: cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		etcd, err := neco.EtcdClient()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+		defer etcd.Close()
+		st := storage.NewStorage(etcd)
+		well.Go(func(ctx context.Context) error {
+			err := st.UpdateRebootJob(ctx, func(job *neco.RebootJob) error {
+				if job.State.Finished() {
+					return fmt.Errorf("the reboot job is %s", job.State)
+				}
+				job.State = neco.RebootJobRunning
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			return runRebootJob(ctx, etcd, true)
+		})
+		well.Stop()
+		err = well.Wait()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+	},
+}
+
+func init() {
+	rebootWorkerCmd.AddCommand(rebootWorkerResumeCmd)
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/storage"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+)
+
+func writeRebootJob(w io.Writer, job *neco.RebootJob, machines []*neco.RebootMachine) {
+	counts := make(map[neco.RebootMachineState]int)
+	for _, m := range machines {
+		counts[m.State]++
+	}
+
+	fmt.Fprintln(w, "State:", job.State)
+	fmt.Fprintln(w, "Created:", job.CreatedAt.Format(time.RFC3339))
+	fmt.Fprintln(w, "Max unavailable per rack:", job.MaxUnavailablePerRack)
+	fmt.Fprintln(w, "Timeout:", job.Timeout)
+	fmt.Fprintf(w, "Machines: %d (pending: %d, rebooting: %d, done: %d, skipped: %d, failed: %d)\n\n",
+		len(machines), counts[neco.RebootPending], counts[neco.RebootRebooting],
+		counts[neco.RebootDone], counts[neco.RebootSkipped], counts[neco.RebootFailed])
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "SERIAL\tRACK\tNODE\tCKE\tSTATE\tSTARTED\tFINISHED\tMESSAGE")
+	for _, m := range machines {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%v\t%s\t%s\t%s\t%s\n",
+			m.Serial, m.Rack, m.Node, m.CKE, m.State, formatRebootTime(m.StartedAt), formatRebootTime(m.FinishedAt), m.Message)
+	}
+	tw.Flush()
+}
+
+func formatRebootTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
+
+var rebootWorkerStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "show the progress of the reboot job",
+	Long:  `Show the progress of the rolling reboot job.`,
+	Args:  cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		etcd, err := neco.EtcdClient()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+		defer etcd.Close()
+		st := storage.NewStorage(etcd)
+		well.Go(func(ctx context.Context) error {
+			job, err := st.GetRebootJob(ctx)
+			if err == storage.ErrNotFound {
+				fmt.Println("no reboot job")
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			machines, err := st.GetRebootMachines(ctx)
+			if err != nil {
+				return err
+			}
+			writeRebootJob(os.Stdout, job, machines)
+			return nil
+		})
+		well.Stop()
+		err = well.Wait()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+	},
+}
+
+func init() {
+	rebootWorkerCmd.AddCommand(rebootWorkerStatusCmd)
+}
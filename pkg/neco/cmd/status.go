@@ -2,6 +2,8 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -12,64 +14,237 @@ import (
 	"github.com/cybozu-go/neco"
 	"github.com/cybozu-go/neco/storage"
 	"github.com/cybozu-go/well"
+	"github.com/mattn/go-isatty"
 	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
 )
 
-func showStatus(ctx context.Context, st storage.Storage, w io.Writer) error {
+var (
+	statusOutput    string
+	statusWatchFlag bool
+)
+
+// statusAPIVersion is the version of the document written by
+// "neco status --output json|yaml".
+// Change it when making incompatible changes to statusDocument.
+const statusAPIVersion = "v1"
+
+// statusDocument is the machine-readable status of the update process.
+type statusDocument struct {
+	APIVersion      string               `json:"api_version"`
+	BootServers     []int                `json:"boot_servers"`
+	LatestRelease   string               `json:"latest_release"`
+	PinnedVersion   string               `json:"pinned_version,omitempty"`
+	SkippedVersions []string             `json:"skipped_versions,omitempty"`
+	PendingReason   string               `json:"pending_reason,omitempty"`
+	Rollback        *neco.RollbackRecord `json:"rollback,omitempty"`
+	Update          statusUpdate         `json:"update"`
+	Contents        statusContents       `json:"contents"`
+}
+
+type statusUpdate struct {
+	Status  string              `json:"status"`
+	Request *neco.UpdateRequest `json:"request"`
+	Workers []statusWorker      `json:"workers"`
+}
+
+type statusWorker struct {
+	LRN int `json:"lrn"`
+	*neco.UpdateStatus
+	Condition string `json:"condition"`
+}
+
+type statusContents struct {
+	Sabakan       *neco.ContentsUpdateStatus `json:"sabakan"`
+	CKE           *neco.ContentsUpdateStatus `json:"cke"`
+	DHCPJSON      *neco.ContentsUpdateStatus `json:"dhcp_json"`
+	CKETemplate   *neco.ContentsUpdateStatus `json:"cke_template"`
+	UserResources *neco.ContentsUpdateStatus `json:"user_resources"`
+}
+
+func showStatus(ctx context.Context, st storage.Storage, w io.Writer, format string) error {
 	ss, err := st.NewSnapshot(ctx)
 	if err != nil {
 		return err
 	}
 
-	req := ss.Request
-	lrns := ss.Servers
-	statuses := ss.Statuses
-
-	fmt.Fprintln(w, "Boot servers:", lrns)
-	fmt.Fprintln(w, "Update process")
-	if req == nil {
-		fmt.Fprintln(w, "    status: clear")
+	var marshal func(interface{}) ([]byte, error)
+	switch format {
+	case "text":
+		writeStatus(w, ss)
 		return nil
+	case "json":
+		marshal = func(v interface{}) ([]byte, error) {
+			return json.MarshalIndent(v, "", "    ")
+		}
+	case "yaml":
+		marshal = yaml.Marshal
+	default:
+		return errors.New("unknown output format: " + format)
 	}
 
-	switch {
-	case checkUpdateAborted(req.Version, statuses):
-		fmt.Fprintln(w, "    status: aborted")
-	case neco.UpdateCompleted(req.Version, req.Servers, statuses):
-		fmt.Fprintln(w, "    status: completed")
-	default:
-		fmt.Fprintln(w, "    status: running")
+	doc, err := newStatusDocument(ctx, st, ss)
+	if err != nil {
+		return err
 	}
+	data, err := marshal(doc)
+	if err != nil {
+		return err
+	}
+	if format == "json" {
+		data = append(data, '\n')
+	}
+	_, err = w.Write(data)
+	return err
+}
 
-	fmt.Fprintln(w, "   version:", req.Version)
-	fmt.Fprintln(w, "   members:", req.Servers)
-	fmt.Fprintln(w, "   started:", req.StartedAt.Format(time.RFC3339))
+func newStatusDocument(ctx context.Context, st storage.Storage, ss *storage.Snapshot) (*statusDocument, error) {
+	doc := &statusDocument{
+		APIVersion:      statusAPIVersion,
+		BootServers:     ss.Servers,
+		LatestRelease:   ss.Latest,
+		PinnedVersion:   ss.Pin,
+		SkippedVersions: ss.Skipped,
+		PendingReason:   pendingReason(ss, time.Now()),
+		Rollback:        ss.Rollback,
+		Update: statusUpdate{
+			Status:  updateProcessStatus(ss.Request, ss.Statuses),
+			Request: ss.Request,
+			Workers: []statusWorker{},
+		},
+	}
+	if doc.BootServers == nil {
+		doc.BootServers = []int{}
+	}
 
-	if len(statuses) == 0 {
-		return nil
+	// Statuses left by the previous update are not included.
+	for _, lrn := range sortedLRNs(ss.Statuses) {
+		status := ss.Statuses[lrn]
+		if ss.Request == nil || status.Version != ss.Request.Version {
+			continue
+		}
+		doc.Update.Workers = append(doc.Update.Workers, statusWorker{
+			LRN:          lrn,
+			UpdateStatus: status,
+			Condition:    status.Cond.String(),
+		})
 	}
 
+	getters := []struct {
+		get  func(context.Context) (*neco.ContentsUpdateStatus, error)
+		dest **neco.ContentsUpdateStatus
+	}{
+		{st.GetSabakanContentsStatus, &doc.Contents.Sabakan},
+		{st.GetCKEContentsStatus, &doc.Contents.CKE},
+		{st.GetDHCPJSONContentsStatus, &doc.Contents.DHCPJSON},
+		{st.GetCKETemplateContentsStatus, &doc.Contents.CKETemplate},
+		{st.GetUserResourcesContentsStatus, &doc.Contents.UserResources},
+	}
+	for _, g := range getters {
+		status, err := g.get(ctx)
+		switch err {
+		case nil:
+			*g.dest = status
+		case storage.ErrNotFound:
+		default:
+			return nil, err
+		}
+	}
+
+	return doc, nil
+}
+
+// updateProcessStatus returns one of "clear", "aborted", "completed", or "running".
+func updateProcessStatus(req *neco.UpdateRequest, statuses map[int]*neco.UpdateStatus) string {
+	switch {
+	case req == nil:
+		return "clear"
+	case checkUpdateAborted(req.Version, statuses):
+		return "aborted"
+	case neco.UpdateCompleted(req.Version, req.Targets(), statuses):
+		return "completed"
+	default:
+		return "running"
+	}
+}
+
+func sortedLRNs(statuses map[int]*neco.UpdateStatus) []int {
 	bs := make([]int, 0, len(statuses))
 	for k := range statuses {
 		bs = append(bs, k)
 	}
 	sort.Ints(bs)
+	return bs
+}
 
-	for _, lrn := range bs {
+func writeStatus(w io.Writer, ss *storage.Snapshot) {
+	req := ss.Request
+	lrns := ss.Servers
+	statuses := ss.Statuses
+
+	fmt.Fprintln(w, "Boot servers:", lrns)
+	if ss.Pin != "" {
+		fmt.Fprintln(w, "Pinned version:", ss.Pin)
+	}
+	if len(ss.Skipped) > 0 {
+		fmt.Fprintln(w, "Skipped versions:", ss.Skipped)
+	}
+	if reason := pendingReason(ss, time.Now()); reason != "" {
+		fmt.Fprintf(w, "Pending update to %s: %s\n", ss.Target(), reason)
+	}
+	if ss.Rollback != nil {
+		fmt.Fprintln(w, "Rollback")
+		fmt.Fprintln(w, "    failed:", ss.Rollback.Version)
+		fmt.Fprintln(w, "    target:", ss.Rollback.Target)
+		fmt.Fprintln(w, "      time:", ss.Rollback.RolledBackAt.Format(time.RFC3339))
+	}
+	fmt.Fprintln(w, "Update process")
+	fmt.Fprintln(w, "    status:", updateProcessStatus(req, statuses))
+	if req == nil {
+		return
+	}
+
+	fmt.Fprintln(w, "   version:", req.Version)
+	fmt.Fprintln(w, "   members:", req.Servers)
+	if req.Strategy != nil {
+		fmt.Fprintln(w, "     stage:", req.Strategy.Stage)
+		fmt.Fprintln(w, "   targets:", req.Targets())
+		if req.InCanary() && !req.Strategy.CanaryCompletedAt.IsZero() {
+			fmt.Fprintln(w, "   soaking: until", req.Strategy.CanaryCompletedAt.Add(req.Strategy.SoakPeriod).Format(time.RFC3339))
+		}
+	}
+	fmt.Fprintln(w, "   started:", req.StartedAt.Format(time.RFC3339))
+
+	for _, lrn := range sortedLRNs(statuses) {
 		status := statuses[lrn]
 		if status.Version != req.Version {
 			continue
 		}
 		fmt.Fprintf(w, "\nBoot server %d\n", lrn)
-		fmt.Fprintln(w, "    step:", status.Step)
+		fmt.Fprintln(w, "    step:", neco.StepLabel(status.Step, status.StepName))
 		fmt.Fprintln(w, "    condition:", status.Cond.String())
 		if len(status.Message) > 0 {
 			fmt.Fprintln(w, "    message:", status.Message)
 		}
 	}
+}
 
-	return nil
+// pendingReason returns the reason why the update to the latest release
+// does not start, or an empty string if it is not pending.
+func pendingReason(ss *storage.Snapshot, now time.Time) string {
+	target := ss.Target()
+	if target == "" {
+		return ""
+	}
+	if ss.Request != nil && ss.Request.Version == target && !ss.Request.InCanary() {
+		return ""
+	}
+	if ss.Pin == "" && ss.Rollback != nil && ss.Rollback.Version == target {
+		return "the version was rolled back; run neco update unblock to retry"
+	}
+	return neco.CheckUpdateWindow(now, ss.Freeze, ss.Windows)
 }
+
 func checkUpdateAborted(ver string, statuses map[int]*neco.UpdateStatus) bool {
 	for _, status := range statuses {
 		if status.Version != ver {
@@ -86,7 +261,17 @@ func checkUpdateAborted(ver string, statuses map[int]*neco.UpdateStatus) bool {
 var statusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "show the update process status",
-	Long:  `Show the status of the current update process.`,
+	Long: `Show the status of the current update process.
+
+With --output json or yaml, this writes a versioned document that also
+includes the status of sabakan, CKE, DHCP, CKE template and user-defined
+resources contents.  The document has "api_version" field, which is
+changed only on incompatible changes.
+
+With --watch, this redraws the progress of each boot server whenever
+the status changes in etcd, and exits when the update process finishes.
+The exit status is 0 if the update completed, 2 if it aborted, and 3 if
+neco-updater stopped it.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		etcd, err := neco.EtcdClient()
 		if err != nil {
@@ -94,17 +279,36 @@ var statusCmd = &cobra.Command{
 		}
 		defer etcd.Close()
 		st := storage.NewStorage(etcd)
+
+		if statusWatchFlag && statusOutput != "text" {
+			log.ErrorExit(errors.New("--watch supports only text output"))
+		}
+
+		var exitCode int
 		well.Go(func(ctx context.Context) error {
-			return showStatus(ctx, st, os.Stdout)
+			if statusWatchFlag {
+				code, err := watchStatus(ctx, st, os.Stdout, isatty.IsTerminal(os.Stdout.Fd()))
+				exitCode = code
+				return err
+			}
+			return showStatus(ctx, st, os.Stdout, statusOutput)
 		})
 		well.Stop()
 		err = well.Wait()
+		if well.IsSignaled(err) {
+			os.Exit(1)
+		}
 		if err != nil {
 			log.ErrorExit(err)
 		}
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
 	},
 }
 
 func init() {
+	statusCmd.Flags().BoolVarP(&statusWatchFlag, "watch", "w", false, "watch the progress until the update process finishes")
+	statusCmd.Flags().StringVarP(&statusOutput, "output", "o", "text", "output format: text, json or yaml")
 	rootCmd.AddCommand(statusCmd)
 }
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/storage"
+	"github.com/cybozu-go/neco/storage/test"
+	"sigs.k8s.io/yaml"
+)
+
+func TestStatusDocument(t *testing.T) {
+	t.Parallel()
+
+	ec := test.NewEtcdClient(t)
+	defer ec.Close()
+	ctx := context.Background()
+	st := storage.NewStorage(ec)
+
+	leaderKey := "test/leader"
+	_, err := ec.Put(ctx, leaderKey, "aaa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.RegisterBootserver(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.RegisterBootserver(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.PutRequest(ctx, neco.UpdateRequest{
+		Version:   "1.0.0",
+		Servers:   []int{0, 1},
+		StartedAt: time.Now().UTC(),
+	}, leaderKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The status of boot server 0 is left by the previous update.
+	err = st.PutStatus(ctx, 0, neco.UpdateStatus{Version: "0.9.0", Step: 18, Cond: neco.CondComplete})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.PutStatus(ctx, 1, neco.UpdateStatus{Version: "1.0.0", Step: 2, Cond: neco.CondAbort, Message: "failed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.PutCKEContentsStatus(ctx, &neco.ContentsUpdateStatus{Version: "1.0.0", Success: true}, leaderKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+	err = showStatus(ctx, st, buf, "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc statusDocument
+	err = json.Unmarshal(buf.Bytes(), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.APIVersion != statusAPIVersion {
+		t.Error("unexpected api version:", doc.APIVersion)
+	}
+	if len(doc.BootServers) != 2 {
+		t.Error("unexpected boot servers:", doc.BootServers)
+	}
+	if doc.Update.Status != "aborted" {
+		t.Error("unexpected update status:", doc.Update.Status)
+	}
+	if doc.Update.Request == nil || doc.Update.Request.Version != "1.0.0" {
+		t.Error("unexpected update request:", doc.Update.Request)
+	}
+	if len(doc.Update.Workers) != 1 {
+		t.Fatal("unexpected workers:", doc.Update.Workers)
+	}
+	w := doc.Update.Workers[0]
+	if w.LRN != 1 || w.Step != 2 || w.Condition != "aborted" || w.Message != "failed" {
+		t.Error("unexpected worker status:", w.LRN, *w.UpdateStatus, w.Condition)
+	}
+	if doc.Contents.CKE == nil || !doc.Contents.CKE.Success {
+		t.Error("unexpected CKE contents status:", doc.Contents.CKE)
+	}
+	if doc.Contents.Sabakan != nil {
+		t.Error("sabakan contents status should be null:", doc.Contents.Sabakan)
+	}
+
+	buf.Reset()
+	err = showStatus(ctx, st, buf, "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var yamlDoc statusDocument
+	err = yaml.Unmarshal(buf.Bytes(), &yamlDoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if yamlDoc.Update.Status != "aborted" || len(yamlDoc.Update.Workers) != 1 {
+		t.Error("unexpected yaml document:", buf.String())
+	}
+
+	err = showStatus(ctx, st, buf, "xml")
+	if err == nil {
+		t.Error("unknown format should be rejected")
+	}
+}
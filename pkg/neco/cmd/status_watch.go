@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/storage"
+	"github.com/cybozu-go/neco/worker"
+)
+
+// Exit codes of "neco status --watch".
+const (
+	watchExitCompleted = 0
+	watchExitAborted   = 2
+	watchExitStopped   = 3
+)
+
+// statusWatch redraws the progress of the update process on every
+// change of the update request or worker statuses.
+type statusWatch struct {
+	w         io.Writer
+	clear     bool
+	finalStep int
+	now       func() time.Time
+
+	req      *neco.UpdateRequest
+	statuses map[int]*neco.UpdateStatus
+	// since is the time each boot server entered its current step.
+	since map[int]time.Time
+
+	exitCode int
+}
+
+func newStatusWatch(w io.Writer, clear bool, finalStep int) *statusWatch {
+	return &statusWatch{
+		w:         w,
+		clear:     clear,
+		finalStep: finalStep,
+		now:       time.Now,
+		statuses:  make(map[int]*neco.UpdateStatus),
+		since:     make(map[int]time.Time),
+	}
+}
+
+// init loads the current status from ss and step histories.
+// It returns true if the update process has already finished.
+func (sw *statusWatch) init(ctx context.Context, st storage.Storage, ss *storage.Snapshot) (bool, error) {
+	sw.req = ss.Request
+	if sw.req == nil {
+		sw.draw()
+		return false, nil
+	}
+
+	for lrn, status := range ss.Statuses {
+		if status.Version == sw.req.Version {
+			sw.statuses[lrn] = status
+		}
+	}
+
+	histories, err := st.GetStepHistories(ctx, sw.req.Version)
+	if err != nil {
+		return false, err
+	}
+	for _, h := range histories {
+		if h.EndedAt.After(sw.since[h.LRN]) {
+			sw.since[h.LRN] = h.EndedAt
+		}
+	}
+
+	sw.draw()
+	return sw.finished(), nil
+}
+
+func (sw *statusWatch) handleRequest(ctx context.Context, req *neco.UpdateRequest) (bool, error) {
+	if sw.req == nil || req.Version != sw.req.Version || !req.StartedAt.Equal(sw.req.StartedAt) {
+		sw.statuses = make(map[int]*neco.UpdateStatus)
+		sw.since = make(map[int]time.Time)
+	}
+	sw.req = req
+	sw.draw()
+	return sw.finished(), nil
+}
+
+func (sw *statusWatch) handleStatus(ctx context.Context, lrn int, st *neco.UpdateStatus) (bool, error) {
+	if sw.req == nil || st.Version != sw.req.Version {
+		return false, nil
+	}
+	prev := sw.statuses[lrn]
+	if prev == nil || prev.Step != st.Step || prev.Cond != st.Cond {
+		sw.since[lrn] = sw.now()
+	}
+	sw.statuses[lrn] = st
+	sw.draw()
+	return sw.finished(), nil
+}
+
+func (sw *statusWatch) handleError(ctx context.Context, err error) error {
+	return err
+}
+
+// finished returns true and sets the exit code if the update process
+// has completed, aborted, or stopped.
+func (sw *statusWatch) finished() bool {
+	if sw.req == nil {
+		return false
+	}
+	switch {
+	case checkUpdateAborted(sw.req.Version, sw.statuses):
+		sw.exitCode = watchExitAborted
+		return true
+	case sw.req.Stop:
+		sw.exitCode = watchExitStopped
+		return true
+	case !sw.req.InCanary() && neco.UpdateCompleted(sw.req.Version, sw.req.Targets(), sw.statuses):
+		sw.exitCode = watchExitCompleted
+		return true
+	}
+	return false
+}
+
+func (sw *statusWatch) draw() {
+	if sw.clear {
+		fmt.Fprint(sw.w, "\x1b[H\x1b[2J")
+	} else {
+		fmt.Fprintln(sw.w)
+	}
+	now := sw.now()
+	fmt.Fprintln(sw.w, now.Format(time.RFC3339))
+
+	req := sw.req
+	if req == nil {
+		fmt.Fprintln(sw.w, "Update process: clear")
+		return
+	}
+
+	status := updateProcessStatus(req, sw.statuses)
+	if req.Stop {
+		status = "stopped"
+	}
+	fmt.Fprintf(sw.w, "Update process: %s to %s, started %s ago\n", status, req.Version, formatElapsed(now.Sub(req.StartedAt)))
+	if req.Strategy != nil {
+		fmt.Fprintf(sw.w, "Stage: %s, targets: %v\n", req.Strategy.Stage, req.Targets())
+	}
+	fmt.Fprintln(sw.w)
+
+	tw := tabwriter.NewWriter(sw.w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "LRN\tSTEP\tNAME\tCONDITION\tELAPSED\tMESSAGE")
+	for _, lrn := range req.Servers {
+		st := sw.statuses[lrn]
+		if st == nil {
+			cond := "waiting"
+			if !req.IsTarget(lrn) {
+				cond = "not target"
+			}
+			fmt.Fprintf(tw, "%d\t-\t-\t%s\t-\t\n", lrn, cond)
+			continue
+		}
+
+		step := strconv.Itoa(st.Step)
+		if sw.finalStep > 0 {
+			step += "/" + strconv.Itoa(sw.finalStep)
+		}
+		name := st.StepName
+		if name == "" {
+			name = "-"
+		}
+		elapsed := "-"
+		if since, ok := sw.since[lrn]; ok {
+			elapsed = formatElapsed(now.Sub(since))
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n", lrn, step, name, st.Cond.String(), elapsed, st.Message)
+	}
+	tw.Flush()
+}
+
+func formatElapsed(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	return d.Truncate(time.Second).String()
+}
+
+func watchStatus(ctx context.Context, st storage.Storage, w io.Writer, clear bool) (int, error) {
+	// The final step number is that of the installed neco package.
+	// It is unknown if the installed package has an invalid pipeline.
+	var finalStep int
+	if p, err := worker.DefaultPipeline(); err == nil {
+		finalStep = p.FinalStep()
+	}
+
+	ss, err := st.NewSnapshot(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	sw := newStatusWatch(w, clear, finalStep)
+	done, err := sw.init(ctx, st, ss)
+	if err != nil {
+		return 0, err
+	}
+	if done {
+		return sw.exitCode, nil
+	}
+
+	err = storage.NewStatusWatcher(sw.handleRequest, sw.handleStatus, sw.handleError).
+		Watch(ctx, st, ss.Revision)
+	if err != nil {
+		return 0, err
+	}
+	if ctx.Err() != nil {
+		return 0, ctx.Err()
+	}
+	return sw.exitCode, nil
+}
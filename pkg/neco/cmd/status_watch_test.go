@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cybozu-go/neco"
+)
+
+func TestStatusWatch(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 1, 2, 3, 0, 0, 0, time.UTC)
+	buf := new(bytes.Buffer)
+	sw := newStatusWatch(buf, false, 10)
+	sw.now = func() time.Time { return now }
+
+	req := &neco.UpdateRequest{
+		Version:   "1.0.0",
+		Servers:   []int{0, 1},
+		StartedAt: now.Add(-5 * time.Minute),
+	}
+	done, err := sw.handleRequest(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if done {
+		t.Error("should not finish without statuses")
+	}
+	if !strings.Contains(buf.String(), "running to 1.0.0, started 5m0s ago") {
+		t.Error("unexpected output:", buf.String())
+	}
+
+	done, _ = sw.handleStatus(ctx, 0, &neco.UpdateStatus{Version: "0.9.0", Step: 10, Cond: neco.CondComplete})
+	if done || sw.statuses[0] != nil {
+		t.Error("status of other version should be ignored")
+	}
+
+	buf.Reset()
+	done, _ = sw.handleStatus(ctx, 0, &neco.UpdateStatus{Version: "1.0.0", Step: 3, StepName: "stop-vault", Cond: neco.CondRunning})
+	if done {
+		t.Error("should not finish while running")
+	}
+	if !strings.Contains(buf.String(), "3/10") || !strings.Contains(buf.String(), "stop-vault") {
+		t.Error("unexpected output:", buf.String())
+	}
+
+	now = now.Add(time.Minute)
+	buf.Reset()
+	sw.handleStatus(ctx, 0, &neco.UpdateStatus{Version: "1.0.0", Step: 3, StepName: "stop-vault", Cond: neco.CondRunning, Message: "waiting"})
+	if !strings.Contains(buf.String(), "1m0s") {
+		t.Error("elapsed time should be kept in the same step:", buf.String())
+	}
+
+	done, _ = sw.handleStatus(ctx, 0, &neco.UpdateStatus{Version: "1.0.0", Step: 10, Cond: neco.CondComplete})
+	if done {
+		t.Error("should not finish until all servers complete")
+	}
+	done, _ = sw.handleStatus(ctx, 1, &neco.UpdateStatus{Version: "1.0.0", Step: 10, Cond: neco.CondComplete})
+	if !done || sw.exitCode != watchExitCompleted {
+		t.Error("should finish with completed", done, sw.exitCode)
+	}
+
+	req2 := &neco.UpdateRequest{
+		Version:   "1.1.0",
+		Servers:   []int{0, 1},
+		StartedAt: now,
+	}
+	done, _ = sw.handleRequest(ctx, req2)
+	if done || len(sw.statuses) != 0 {
+		t.Error("statuses should be reset for a new request", sw.statuses)
+	}
+	done, _ = sw.handleStatus(ctx, 1, &neco.UpdateStatus{Version: "1.1.0", Step: 4, Cond: neco.CondAbort, Message: "failed"})
+	if !done || sw.exitCode != watchExitAborted {
+		t.Error("should finish with aborted", done, sw.exitCode)
+	}
+
+	stopped := *req2
+	stopped.Stop = true
+	sw = newStatusWatch(buf, false, 10)
+	done, _ = sw.handleRequest(ctx, &stopped)
+	if !done || sw.exitCode != watchExitStopped {
+		t.Error("should finish with stopped", done, sw.exitCode)
+	}
+}
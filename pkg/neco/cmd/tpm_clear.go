@@ -4,9 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"strings"
+	"time"
 
 	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/neco/bmc"
 	"github.com/cybozu-go/sabakan/v2"
 	"github.com/cybozu-go/well"
 	"github.com/spf13/cobra"
@@ -16,81 +17,12 @@ import (
 
 const machineTypeLabelName = "machine-type"
 
-const (
-	tpmClearLogicTypePowerOff = iota // If clearing TPM is not supported, shutdown the machine.
-	tpmClearLogicTypeDellRedfish
+var (
+	tpmClearForce bool
+	tpmClearWait  bool
 )
 
-var tpmClearForce bool
-
-var supportedMachineTypes = map[string]int{
-	"qemu":         tpmClearLogicTypePowerOff,    // Placemat VM. Clear logic is not implemented on placemat.
-	"r640-boot-1":  tpmClearLogicTypePowerOff,    // Dell, TPM 1.2
-	"r640-boot-2":  tpmClearLogicTypeDellRedfish, // Dell, TPM 2.0
-	"r640-cs-1":    tpmClearLogicTypePowerOff,    // Dell, TPM 1.2
-	"r640-cs-2":    tpmClearLogicTypeDellRedfish, // Dell, TPM 2.0
-	"r740xd-ss-1":  tpmClearLogicTypePowerOff,    // Dell, TPM 1.2
-	"r740xd-ss-2":  tpmClearLogicTypeDellRedfish, // Dell, TPM 2.0
-	"r6525-boot-1": tpmClearLogicTypeDellRedfish, // Dell, TPM 2.0
-	"r6525-cs-1":   tpmClearLogicTypeDellRedfish, // Dell, TPM 2.0
-	"r7525-ss-1":   tpmClearLogicTypeDellRedfish, // Dell, TPM 2.0
-}
-
-// Redfish API endpoints used for clearing TPM device on Dell equipment.
-// These values will probably not be changed. So define as constants.
-// If you want to get these values dynamically, you can get them as follows.
-//
-//	$ curl --insecure -sS -X GET -u $BMC_USER:$BMC_PASS \
-//	       https://$BMC_ADDR/redfish/v1/Managers/iDRAC.Embedded.1 | jq .Links.Oem.Dell.Jobs
-//	{
-//	  "@odata.id": "/redfish/v1/Managers/iDRAC.Embedded.1/Jobs"
-//	}
-//
-//	$ curl --insecure -sS -X GET -u $BMC_USER:$BMC_PASS \
-//	       https://$BMC_ADDR/redfish/v1/Systems/System.Embedded.1/Bios | jq '."@Redfish.Settings".SettingsObject'
-//	{
-//	  "@odata.id": "/redfish/v1/Systems/System.Embedded.1/Bios/Settings"
-//	}
-const (
-	dellRedfishJobURI          = "/redfish/v1/Managers/iDRAC.Embedded.1/Jobs"
-	dellRedfishBiosSettingsURI = "/redfish/v1/Systems/System.Embedded.1/Bios/Settings"
-)
-
-func dellRedfishSetTpmAttribute(client *gofish.APIClient) error {
-	system, err := getComputerSystem(client.Service)
-	if err != nil {
-		return err
-	}
-	bios, err := system.Bios()
-	if err != nil {
-		return err
-	}
-
-	attr := redfish.BiosAttributes{
-		"Tpm2Hierarchy": "Clear",
-	}
-	return bios.UpdateBiosAttributes(attr)
-}
-
-func dellRedfishCreateBiosSettingsJob(client *gofish.APIClient) (string, error) {
-	payload := map[string]string{
-		"TargetSettingsURI": dellRedfishBiosSettingsURI,
-	}
-	resp, err := client.Post(dellRedfishJobURI, payload)
-	if err != nil && !strings.Contains(err.Error(), "SYS011") {
-		// When a job has already been registered, "SYS011" will be returned.
-		// We face this error when we re-execute "neco tpm clear" command due to the failure of the machine restart.
-		// In order to succeed the re-executed command, ignore the "SYS011" error.
-		return "", err
-	}
-	jobURL, err := resp.Location()
-	if err != nil {
-		return "", err
-	}
-	split := strings.Split(jobURL.Path, "/")
-	jobID := split[len(split)-1]
-	return jobID, nil
-}
+var tpmClearWaitInterval = 10 * time.Second
 
 func startOrRestart(client *gofish.APIClient) error {
 	system, err := getComputerSystem(client.Service)
@@ -112,25 +44,35 @@ func startOrRestart(client *gofish.APIClient) error {
 	return system.Reset(resetType)
 }
 
-func dellRedfishClearTpm(ctx context.Context, bmcAddr string) error {
-	client, err := getRedfishClient(ctx, bmcAddr)
+// clearTPM clears TPM devices on the machine whose BMC is bmcAddr, and
+// starts or restarts the machine to make it effective.
+// If the driver cannot clear TPM devices, this shuts down the machine.
+func clearTPM(ctx context.Context, bmcAddr, username, password string, mt bmc.MachineType, driver bmc.Driver, wait bool) error {
+	client, err := connectRedfish(bmcAddr, username, password)
 	if err != nil {
 		return fmt.Errorf("failed to get redfish client: %s", err.Error())
 	}
 	defer client.Logout()
 
-	err = dellRedfishSetTpmAttribute(client)
-	if err != nil {
-		return fmt.Errorf("failed to set bios attribute: %s", err.Error())
+	jobID, err := driver.ClearTPM(ctx, client, mt)
+	if err == bmc.ErrNotSupported {
+		log.Info("clearing TPM is not supported; shutdown", map[string]interface{}{
+			"bmc":    bmcAddr,
+			"driver": driver.Name(),
+		})
+		result, err := powerMachine("stop", false, bmcAddr, username, password)
+		if err != nil {
+			return err
+		}
+		fmt.Println(result)
+		return nil
 	}
-	log.Info("bios attribute is updated", nil)
-
-	jobId, err := dellRedfishCreateBiosSettingsJob(client)
 	if err != nil {
-		return fmt.Errorf("failed to create bios setting job: %s", err.Error())
+		return fmt.Errorf("failed to request clearing TPM: %s", err.Error())
 	}
-	log.Info("bios setting job is created", map[string]interface{}{
-		"job_id": jobId,
+	log.Info("clearing TPM is requested", map[string]interface{}{
+		"driver": driver.Name(),
+		"job_id": jobID,
 	})
 
 	err = startOrRestart(client)
@@ -138,6 +80,17 @@ func dellRedfishClearTpm(ctx context.Context, bmcAddr string) error {
 		return fmt.Errorf("failed to reset: %s", err.Error())
 	}
 	log.Info("machine power operation has been performed", nil)
+
+	if !wait || jobID == "" {
+		return nil
+	}
+	_, err = bmc.WaitJob(ctx, driver, client, jobID, tpmClearWaitInterval)
+	if err != nil {
+		return err
+	}
+	log.Info("job for clearing TPM has completed", map[string]interface{}{
+		"job_id": jobID,
+	})
 	return nil
 }
 
@@ -147,7 +100,11 @@ var tpmClearCmd = &cobra.Command{
 	Long: `Clear TPM devices on a machine.
 
 SERIAL is the serial number of the machine.
-IP is one of the IP addresses owned by the machine.`,
+IP is one of the IP addresses owned by the machine.
+
+The BMC driver is chosen by the "machine-type" label of the machine.
+If the driver cannot clear TPM devices, the machine is shut down instead.
+See "neco bmc machine-type list".`,
 
 	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
@@ -164,27 +121,26 @@ IP is one of the IP addresses owned by the machine.`,
 				return errors.New("machine is not retiring")
 			}
 
-			machineType := machine.Spec.Labels[machineTypeLabelName]
-			logicType, ok := supportedMachineTypes[machineType]
-			if !ok {
+			mt, driver, err := lookupBMCDriver(ctx, machine)
+			if err == bmc.ErrUnknownMachineType {
 				err := power(ctx, "stop", machine.Spec.BMC.IPv4)
 				log.Warn("unknown machine type; shutdown", map[string]interface{}{
-					"serial":       "machine.Spec.Serial",
-					"node":         "machine.Spec.IPv4[0]",
-					"machine_type": "machineType",
+					"serial":       machine.Spec.Serial,
+					"node":         machine.Spec.IPv4[0],
+					"machine_type": machine.Spec.Labels[machineTypeLabelName],
 					"result":       err,
 				})
 				return errors.New("unknown machine type")
 			}
+			if err != nil {
+				return err
+			}
 
-			switch logicType {
-			case tpmClearLogicTypePowerOff:
-				return power(ctx, "stop", machine.Spec.BMC.IPv4)
-			case tpmClearLogicTypeDellRedfish:
-				return dellRedfishClearTpm(ctx, machine.Spec.BMC.IPv4)
-			default:
-				panic(fmt.Sprintf("unreachable; unknown logic type: %d", logicType))
+			username, password, err := getBMCUsernameAndPassword(ctx)
+			if err != nil {
+				return err
 			}
+			return clearTPM(ctx, machine.Spec.BMC.IPv4, username, password, mt, driver, tpmClearWait)
 		})
 		well.Stop()
 		err := well.Wait()
@@ -196,5 +152,6 @@ IP is one of the IP addresses owned by the machine.`,
 
 func init() {
 	tpmClearCmd.Flags().BoolVar(&tpmClearForce, "force", false, "forces the clearing TPM devices")
+	tpmClearCmd.Flags().BoolVar(&tpmClearWait, "wait", false, "wait for the BMC job clearing TPM devices to complete")
 	tpmCmd.AddCommand(tpmClearCmd)
 }
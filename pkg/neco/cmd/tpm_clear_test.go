@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cybozu-go/neco/bmc"
+	"github.com/cybozu-go/neco/bmc/redfishtest"
+	"github.com/stmcginnis/gofish/redfish"
+)
+
+func TestClearTPM(t *testing.T) {
+	ctx := context.Background()
+	tpmClearWaitInterval = 10 * time.Millisecond
+
+	testCases := []struct {
+		name       string
+		machine    bmc.MachineType
+		powerState redfish.PowerState
+		expectOff  bool
+		expectJob  bool
+	}{
+		{
+			name:       "dell-idrac",
+			machine:    bmc.MachineType{Driver: bmc.DellIDRACDriverName},
+			powerState: redfish.OnPowerState,
+			expectJob:  true,
+		},
+		{
+			name:       "dell-idrac powered off",
+			machine:    bmc.MachineType{Driver: bmc.DellIDRACDriverName},
+			powerState: redfish.OffPowerState,
+			expectJob:  true,
+		},
+		{
+			name: "redfish",
+			machine: bmc.MachineType{
+				Driver:             bmc.RedfishDriverName,
+				TPMClearAttributes: redfish.BiosAttributes{"Tpm2Hierarchy": "Clear"},
+			},
+			powerState: redfish.OnPowerState,
+		},
+		{
+			name:       "power-off",
+			machine:    bmc.MachineType{Driver: bmc.PowerOffDriverName},
+			powerState: redfish.OnPowerState,
+			expectOff:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := redfishtest.NewServer(redfishtest.Config{
+				Username:             "user",
+				Password:             "pass",
+				PowerState:           tc.powerState,
+				BiosAttributes:       map[string]interface{}{"Tpm2Hierarchy": "Enabled"},
+				ApplySettingsOnReset: !tc.expectJob,
+			})
+			defer s.Close()
+
+			driver, err := bmc.GetDriver(tc.machine.Driver)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = clearTPM(ctx, s.Addr(), "user", "pass", tc.machine, driver, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.expectOff {
+				if s.PowerState() != redfish.OffPowerState {
+					t.Error("machine should be shut down")
+				}
+				if s.BiosAttributes()["Tpm2Hierarchy"] != "Enabled" {
+					t.Error("TPM should not be cleared")
+				}
+				return
+			}
+
+			if s.PowerState() != redfish.OnPowerState {
+				t.Error("machine should be running")
+			}
+			if s.BiosAttributes()["Tpm2Hierarchy"] != "Clear" {
+				t.Error("TPM should be cleared:", s.BiosAttributes())
+			}
+			jobs := s.Jobs()
+			if tc.expectJob && (len(jobs) != 1 || jobs[0].JobState != redfishtest.JobCompleted) {
+				t.Error("job should be completed:", jobs)
+			}
+		})
+	}
+}
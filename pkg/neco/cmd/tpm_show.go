@@ -3,51 +3,13 @@ package cmd
 import (
 	"context"
 	"encoding/json"
-	"strings"
 
 	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/neco/bmc"
 	"github.com/cybozu-go/well"
 	"github.com/spf13/cobra"
-	"github.com/stmcginnis/gofish"
-	"github.com/stmcginnis/gofish/redfish"
 )
 
-type tpmSettings struct {
-	Devices    []redfish.TrustedModules
-	Attributes redfish.BiosAttributes
-}
-
-func getTpmSettings(client *gofish.APIClient) (*tpmSettings, error) {
-	system, err := getComputerSystem(client.Service)
-	if err != nil {
-		return nil, err
-	}
-
-	// Get BIOS attributes related to TPM.
-	tpmAttrs := redfish.BiosAttributes{}
-	bios, err := system.Bios()
-	if err != nil && !strings.Contains(err.Error(), "404") {
-		// Ignore error if return 404. It means bios endpoint is not implemented.
-		return nil, err
-	}
-	// When 404 error is returned, bios will be nil.
-	// And in some cases, bios will be nil even if ComputerSystem.BIOS() doesn't return an error.
-	// https://github.com/stmcginnis/gofish/blob/v0.7.0/redfish/computersystem.go#L635
-	if bios != nil {
-		for name, val := range bios.Attributes {
-			if !strings.HasPrefix(name, "Tpm") {
-				continue
-			}
-			tpmAttrs[name] = val
-		}
-	}
-
-	return &tpmSettings{
-		Devices:    system.TrustedModules,
-		Attributes: tpmAttrs,
-	}, nil
-}
-
 var tpmShowCmd = &cobra.Command{
 	Use:   "show SERIAL|IP",
 	Short: "show TPM devices on a machine",
@@ -70,7 +32,17 @@ IP is one of the IP addresses owned by the machine.`,
 			}
 			defer client.Logout()
 
-			tpm, err := getTpmSettings(client)
+			// TPM settings can be read with DMTF standard Redfish API
+			// even if the machine type is unknown.
+			_, driver, err := lookupBMCDriver(ctx, machine)
+			if err == bmc.ErrUnknownMachineType {
+				driver, err = bmc.GetDriver(bmc.RedfishDriverName)
+			}
+			if err != nil {
+				return err
+			}
+
+			tpm, err := driver.ShowTPM(ctx, client)
 			if err != nil {
 				return err
 			}
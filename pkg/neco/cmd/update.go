@@ -0,0 +1,15 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var updateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "update process related commands",
+	Long:  `Update process related commands.`,
+}
+
+func init() {
+	rootCmd.AddCommand(updateCmd)
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/storage"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+)
+
+var updateFreezeReason string
+
+var updateFreezeCmd = &cobra.Command{
+	Use:   "freeze",
+	Short: "stop neco-updater from starting new updates",
+	Long: `Stop neco-updater from starting new updates until unfrozen.
+
+The ongoing update process is not affected.`,
+
+	Args: cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		etcd, err := neco.EtcdClient()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+		defer etcd.Close()
+		st := storage.NewStorage(etcd)
+
+		well.Go(func(ctx context.Context) error {
+			return st.PutUpdateFreeze(ctx, &neco.UpdateFreeze{
+				Reason:   updateFreezeReason,
+				FrozenAt: time.Now().UTC(),
+			})
+		})
+		well.Stop()
+		err = well.Wait()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+	},
+}
+
+func init() {
+	updateFreezeCmd.Flags().StringVar(&updateFreezeReason, "reason", "", "reason of the freeze shown by neco status")
+	updateCmd.AddCommand(updateFreezeCmd)
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/storage"
+	"github.com/cybozu-go/well"
+	version "github.com/hashicorp/go-version"
+	"github.com/spf13/cobra"
+)
+
+var updatePinCmd = &cobra.Command{
+	Use:   "pin VERSION",
+	Short: "hold boot servers on a neco release",
+	Long: `Hold boot servers on VERSION of neco release regardless of newer releases.
+
+VERSION may be older than the current version to move back to it.
+neco-updater starts updating to VERSION if it is not the current version.`,
+
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if _, err := version.NewVersion(args[0]); err != nil {
+			log.ErrorExit(err)
+		}
+
+		etcd, err := neco.EtcdClient()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+		defer etcd.Close()
+		st := storage.NewStorage(etcd)
+
+		well.Go(func(ctx context.Context) error {
+			skips, err := st.GetUpdateSkips(ctx)
+			if err != nil {
+				return err
+			}
+			for _, v := range skips {
+				if v == args[0] {
+					return errors.New("version is skipped: " + v)
+				}
+			}
+			return st.PutUpdatePin(ctx, args[0])
+		})
+		well.Stop()
+		err = well.Wait()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+	},
+}
+
+func init() {
+	updateCmd.AddCommand(updatePinCmd)
+}
@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/storage"
+	"github.com/cybozu-go/neco/worker"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+)
+
+var updatePlanOutput string
+
+func writePlan(w io.Writer, plan *worker.Plan, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "    ")
+		return enc.Encode(plan)
+	case "text":
+	default:
+		return errors.New("unknown output format: " + format)
+	}
+
+	fmt.Fprintf(w, "Plan for version %s on boot server %d\n", plan.Version, plan.LRN)
+	if plan.Installed != plan.Version {
+		fmt.Fprintf(w, "Configuration files are rendered by the installed neco package %s\n", plan.Installed)
+	}
+	for _, sp := range plan.Steps {
+		fmt.Fprintf(w, "\nstep %s\n", neco.StepLabel(sp.Step, sp.Name))
+		if sp.IsEmpty() {
+			fmt.Fprintln(w, "    no changes")
+			continue
+		}
+		for _, n := range sp.Notes {
+			fmt.Fprintln(w, "    note:", n)
+		}
+		for _, c := range sp.Images {
+			fmt.Fprintf(w, "    image %s: %s -> %s\n", c.Name, versionOrNone(c.Current), c.Target)
+		}
+		for _, c := range sp.Debs {
+			fmt.Fprintf(w, "    deb %s: %s -> %s\n", c.Name, versionOrNone(c.Current), c.Target)
+		}
+		for _, f := range sp.Files {
+			action := "rewrite"
+			if f.Created {
+				action = "create"
+			}
+			fmt.Fprintf(w, "    %s %s\n", action, f.Path)
+			for _, l := range strings.SplitAfter(f.Diff, "\n") {
+				if l == "" {
+					continue
+				}
+				fmt.Fprint(w, "        ", l)
+			}
+		}
+		if c := sp.Sabakan; c != nil {
+			if c.OSImage != "" {
+				fmt.Fprintln(w, "    sabakan OS image:", c.OSImage)
+			}
+			for _, a := range c.Assets {
+				fmt.Fprintln(w, "    sabakan asset:", a)
+			}
+			for _, a := range c.WorkerAssets {
+				fmt.Fprintln(w, "    sabakan asset (always uploaded):", a)
+			}
+			for _, r := range c.Ignitions {
+				fmt.Fprintln(w, "    sabakan ignition:", r)
+			}
+		}
+	}
+	return nil
+}
+
+func versionOrNone(v string) string {
+	if v == "" {
+		return "(none)"
+	}
+	return v
+}
+
+var updatePlanCmd = &cobra.Command{
+	Use:   "plan VERSION",
+	Short: "show what the update to VERSION would change on this boot server",
+	Long: `Show what the update to VERSION would change on this boot server.
+
+This runs the update steps of neco-worker without changing anything,
+and shows container images and Debian packages to be updated,
+configuration files to be rewritten with their diffs, and sabakan
+contents to be uploaded.
+
+Container images, Debian packages, and sabakan contents are planned
+with the artifacts of VERSION, which are read from the release on
+GitHub unless VERSION is installed.  Configuration files are rendered
+by the installed neco package.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		etcd, err := neco.EtcdClient()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+		defer etcd.Close()
+		st := storage.NewStorage(etcd)
+
+		mylrn, err := neco.MyLRN()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+
+		well.Go(func(ctx context.Context) error {
+			ss, err := st.NewSnapshot(ctx)
+			if err != nil {
+				return err
+			}
+			req := &neco.UpdateRequest{
+				Version: args[0],
+				Servers: ss.Servers,
+			}
+			plan, err := worker.PlanUpdate(ctx, etcd, mylrn, req)
+			if err != nil {
+				return err
+			}
+			return writePlan(os.Stdout, plan, updatePlanOutput)
+		})
+		well.Stop()
+		err = well.Wait()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+	},
+}
+
+func init() {
+	updatePlanCmd.Flags().StringVarP(&updatePlanOutput, "output", "o", "text", "output format: text or json")
+	updateCmd.AddCommand(updatePlanCmd)
+}
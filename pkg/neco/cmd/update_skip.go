@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/storage"
+	"github.com/cybozu-go/well"
+	version "github.com/hashicorp/go-version"
+	"github.com/spf13/cobra"
+)
+
+var updateSkipRemove bool
+
+var updateSkipCmd = &cobra.Command{
+	Use:   "skip VERSION",
+	Short: "never update to a neco release",
+	Long: `Never update to VERSION of neco release.
+
+If VERSION is the latest release, boot servers stay on the current
+version until a newer release is published.`,
+
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if _, err := version.NewVersion(args[0]); err != nil {
+			log.ErrorExit(err)
+		}
+
+		etcd, err := neco.EtcdClient()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+		defer etcd.Close()
+		st := storage.NewStorage(etcd)
+
+		well.Go(func(ctx context.Context) error {
+			if updateSkipRemove {
+				err := st.DeleteUpdateSkip(ctx, args[0])
+				if err == storage.ErrNotFound {
+					return errors.New("version is not skipped: " + args[0])
+				}
+				return err
+			}
+
+			pin, err := st.GetUpdatePin(ctx)
+			if err != nil && err != storage.ErrNotFound {
+				return err
+			}
+			if pin == args[0] {
+				return errors.New("version is pinned: " + pin)
+			}
+			return st.AddUpdateSkip(ctx, args[0])
+		})
+		well.Stop()
+		err = well.Wait()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+	},
+}
+
+func init() {
+	updateSkipCmd.Flags().BoolVar(&updateSkipRemove, "remove", false, "remove VERSION from the skipped versions")
+	updateCmd.AddCommand(updateSkipCmd)
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/storage"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+)
+
+var updateUnblockCmd = &cobra.Command{
+	Use:   "unblock",
+	Short: "allow neco-updater to retry the version that was rolled back",
+	Long: `Remove the record of the last automatic rollback from etcd.
+
+neco-updater does not update to the version that was rolled back
+until this command is run.`,
+
+	Args: cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		etcd, err := neco.EtcdClient()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+		defer etcd.Close()
+		st := storage.NewStorage(etcd)
+
+		well.Go(func(ctx context.Context) error {
+			err := st.DeleteRollback(ctx)
+			if err == storage.ErrNotFound {
+				return errors.New("no version is blocked")
+			}
+			return err
+		})
+		well.Stop()
+		err = well.Wait()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+	},
+}
+
+func init() {
+	updateCmd.AddCommand(updateUnblockCmd)
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/storage"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+)
+
+var updateUnfreezeCmd = &cobra.Command{
+	Use:   "unfreeze",
+	Short: "allow neco-updater to start new updates",
+	Long:  `Allow neco-updater to start new updates frozen by "neco update freeze".`,
+
+	Args: cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		etcd, err := neco.EtcdClient()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+		defer etcd.Close()
+		st := storage.NewStorage(etcd)
+
+		well.Go(func(ctx context.Context) error {
+			err := st.DeleteUpdateFreeze(ctx)
+			if err == storage.ErrNotFound {
+				return errors.New("updates are not frozen")
+			}
+			return err
+		})
+		well.Stop()
+		err = well.Wait()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+	},
+}
+
+func init() {
+	updateCmd.AddCommand(updateUnfreezeCmd)
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/storage"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+)
+
+var updateUnpinCmd = &cobra.Command{
+	Use:   "unpin",
+	Short: "follow the latest neco release again",
+	Long:  `Remove the version pinned by "neco update pin" to follow the latest neco release again.`,
+
+	Args: cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		etcd, err := neco.EtcdClient()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+		defer etcd.Close()
+		st := storage.NewStorage(etcd)
+
+		well.Go(func(ctx context.Context) error {
+			err := st.DeleteUpdatePin(ctx)
+			if err == storage.ErrNotFound {
+				return errors.New("no version is pinned")
+			}
+			return err
+		})
+		well.Stop()
+		err = well.Wait()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+	},
+}
+
+func init() {
+	updateCmd.AddCommand(updateUnpinCmd)
+}
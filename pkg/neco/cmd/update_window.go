@@ -0,0 +1,21 @@
+// This file is not named update_windows.go, which Go builds only for Windows.
+
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var updateWindowsCmd = &cobra.Command{
+	Use:   "windows",
+	Short: "maintenance windows for updates",
+	Long: `Maintenance windows for updates.
+
+neco-updater starts updates only in allow windows if any, and never
+in deny windows.  A window consists of periods that begin at the times
+matched by a standard cron expression and last for the given duration.`,
+}
+
+func init() {
+	updateCmd.AddCommand(updateWindowsCmd)
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/storage"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+)
+
+var updateWindowsAddOpts struct {
+	timeZone string
+	deny     bool
+}
+
+var updateWindowsAddCmd = &cobra.Command{
+	Use:   "add NAME SCHEDULE DURATION",
+	Short: "add or replace a maintenance window",
+	Long: `Add or replace a maintenance window.
+
+SCHEDULE is a standard cron expression of the beginning of periods
+such as "0 1 * * 1-5".  DURATION is parsed by time.ParseDuration.`,
+	Args: cobra.ExactArgs(3),
+	Run: func(cmd *cobra.Command, args []string) {
+		duration, err := time.ParseDuration(args[2])
+		if err != nil {
+			log.ErrorExit(err)
+		}
+		win := neco.MaintenanceWindow{
+			Name:     args[0],
+			Schedule: args[1],
+			Duration: duration,
+			TimeZone: updateWindowsAddOpts.timeZone,
+			Deny:     updateWindowsAddOpts.deny,
+		}
+		if err := win.Validate(); err != nil {
+			log.ErrorExit(err)
+		}
+
+		etcd, err := neco.EtcdClient()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+		defer etcd.Close()
+		st := storage.NewStorage(etcd)
+		well.Go(func(ctx context.Context) error {
+			windows, err := st.GetMaintenanceWindows(ctx)
+			if err != nil {
+				return err
+			}
+
+			replaced := false
+			for i := range windows {
+				if windows[i].Name == win.Name {
+					windows[i] = win
+					replaced = true
+				}
+			}
+			if !replaced {
+				windows = append(windows, win)
+			}
+			return st.PutMaintenanceWindows(ctx, windows)
+		})
+		well.Stop()
+		err = well.Wait()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+	},
+}
+
+func init() {
+	updateWindowsAddCmd.Flags().StringVar(&updateWindowsAddOpts.timeZone, "time-zone", "", "time zone of the schedule such as Asia/Tokyo (default UTC)")
+	updateWindowsAddCmd.Flags().BoolVar(&updateWindowsAddOpts.deny, "deny", false, "deny updates in the window")
+	updateWindowsCmd.AddCommand(updateWindowsAddCmd)
+}
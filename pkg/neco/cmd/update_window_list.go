@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/storage"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+)
+
+var updateWindowsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "list the maintenance windows",
+	Long:  `List the maintenance windows.`,
+	Args:  cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		etcd, err := neco.EtcdClient()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+		defer etcd.Close()
+		st := storage.NewStorage(etcd)
+		well.Go(func(ctx context.Context) error {
+			windows, err := st.GetMaintenanceWindows(ctx)
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tTYPE\tSCHEDULE\tDURATION\tTIME ZONE")
+			for _, win := range windows {
+				typ := "allow"
+				if win.Deny {
+					typ = "deny"
+				}
+				tz := win.TimeZone
+				if tz == "" {
+					tz = "UTC"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", win.Name, typ, win.Schedule, win.Duration, tz)
+			}
+			return w.Flush()
+		})
+		well.Stop()
+		err = well.Wait()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+	},
+}
+
+func init() {
+	updateWindowsCmd.AddCommand(updateWindowsListCmd)
+}
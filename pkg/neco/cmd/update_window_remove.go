@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/storage"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+)
+
+var updateWindowsRemoveCmd = &cobra.Command{
+	Use:   "remove NAME",
+	Short: "remove a maintenance window",
+	Long:  `Remove a maintenance window.`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		etcd, err := neco.EtcdClient()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+		defer etcd.Close()
+		st := storage.NewStorage(etcd)
+		well.Go(func(ctx context.Context) error {
+			windows, err := st.GetMaintenanceWindows(ctx)
+			if err != nil {
+				return err
+			}
+
+			var rest []neco.MaintenanceWindow
+			for _, win := range windows {
+				if win.Name != args[0] {
+					rest = append(rest, win)
+				}
+			}
+			if len(rest) == len(windows) {
+				return errors.New("no such window: " + args[0])
+			}
+			return st.PutMaintenanceWindows(ctx, rest)
+		})
+		well.Stop()
+		err = well.Wait()
+		if err != nil {
+			log.ErrorExit(err)
+		}
+	},
+}
+
+func init() {
+	updateWindowsCmd.AddCommand(updateWindowsRemoveCmd)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"net"
+	"strings"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/neco/bmc/redfishtest"
+	"github.com/cybozu-go/well"
+	"github.com/stmcginnis/gofish/redfish"
+)
+
+var (
+	flagListen     = flag.String("listen", "127.0.0.1:8443", "listen address")
+	flagUsername   = flag.String("username", "", "username for basic authentication")
+	flagPassword   = flag.String("password", "", "password for basic authentication")
+	flagPowerState = flag.String("power-state", "On", "initial power state: On or Off")
+	flagBios       = flag.String("bios", "Tpm2Hierarchy=Enabled", "initial BIOS attributes in NAME=VALUE,... form")
+	flagGeneric    = flag.Bool("generic", false, "apply BIOS settings on reset without iDRAC jobs")
+)
+
+func parseAttributes(s string) map[string]interface{} {
+	attrs := make(map[string]interface{})
+	for _, kv := range strings.Split(s, ",") {
+		if kv == "" {
+			continue
+		}
+		k, v, _ := strings.Cut(kv, "=")
+		attrs[k] = v
+	}
+	return attrs
+}
+
+func main() {
+	flag.Parse()
+	well.LogConfig{}.Apply()
+
+	s := redfishtest.NewUnstartedServer(redfishtest.Config{
+		Username:             *flagUsername,
+		Password:             *flagPassword,
+		PowerState:           redfish.PowerState(*flagPowerState),
+		BiosAttributes:       parseAttributes(*flagBios),
+		ApplySettingsOnReset: *flagGeneric,
+	})
+	l, err := net.Listen("tcp", *flagListen)
+	if err != nil {
+		log.ErrorExit(err)
+	}
+	s.Listener.Close()
+	s.Listener = l
+	s.StartTLS()
+	defer s.Close()
+
+	log.Info("redfish-mock started", map[string]interface{}{
+		"url": s.URL,
+	})
+
+	well.Go(func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	})
+	err = well.Wait()
+	if err != nil && !well.IsSignaled(err) {
+		log.ErrorExit(err)
+	}
+}
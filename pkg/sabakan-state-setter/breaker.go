@@ -0,0 +1,248 @@
+package sss
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/metrics"
+	"github.com/cybozu-go/sabakan/v2"
+)
+
+// BreakerStore is interface for storing the status of the circuit breaker
+type BreakerStore interface {
+	UpdateTransitionBreaker(ctx context.Context, f func(*neco.TransitionBreaker) error) error
+}
+
+var errBreakerUnchanged = errors.New("breaker is unchanged")
+
+// stateTransition is a state transition of a machine decided in a round.
+type stateTransition struct {
+	machine *machine
+	to      sabakan.MachineState
+}
+
+// degrading returns true if the transition is subject to the circuit breaker.
+func (t stateTransition) degrading() bool {
+	return t.to == sabakan.StateUnhealthy || t.to == sabakan.StateUnreachable
+}
+
+type transitionRecord struct {
+	time        time.Time
+	machineType string
+	rack        int
+}
+
+// transitionBreaker decides whether to stop transitions of machines
+// to unhealthy or unreachable.
+type transitionBreaker struct {
+	config *circuitBreakerConfig
+
+	// history is the transitions made within the window.
+	history []transitionRecord
+}
+
+func newTransitionBreaker(cfg *circuitBreakerConfig) *transitionBreaker {
+	if cfg == nil {
+		return nil
+	}
+	return &transitionBreaker{config: cfg}
+}
+
+func (tb *transitionBreaker) record(now time.Time, m *machine) {
+	if tb == nil {
+		return
+	}
+	tb.history = append(tb.history, transitionRecord{time: now, machineType: m.Type, rack: m.Rack})
+}
+
+func (tb *transitionBreaker) expire(now time.Time) {
+	from := now.Add(-tb.config.Window.Duration)
+	i := sort.Search(len(tb.history), func(i int) bool {
+		return tb.history[i].time.After(from)
+	})
+	tb.history = tb.history[i:]
+}
+
+func exceedsPercent(changed map[string]int, total map[string]int, limit float64) (string, bool) {
+	if limit <= 0 {
+		return "", false
+	}
+	keys := make([]string, 0, len(changed))
+	for k := range changed {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		// A single machine never trips the breaker by percentage.
+		n := changed[k]
+		if n > 1 && float64(n)*100 > limit*float64(total[k]) {
+			return k, true
+		}
+	}
+	return "", false
+}
+
+// exceeded returns why the transitions of pending machines in addition to
+// those in the window exceed the limits.  If not exceeded, this returns "".
+func (tb *transitionBreaker) exceeded(now time.Time, pending []stateTransition, machines []*machine) string {
+	tb.expire(now)
+	if len(pending) == 0 {
+		return ""
+	}
+
+	window := tb.config.Window.Duration
+	total := len(tb.history) + len(pending)
+	if tb.config.MaxMachines > 0 && total > tb.config.MaxMachines {
+		return fmt.Sprintf("%d machines would become unhealthy or unreachable within %s, more than %d", total, window, tb.config.MaxMachines)
+	}
+
+	typeTotal := make(map[string]int)
+	rackTotal := make(map[string]int)
+	for _, m := range machines {
+		typeTotal[m.Type]++
+		rackTotal[strconv.Itoa(m.Rack)]++
+	}
+	typeChanged := make(map[string]int)
+	rackChanged := make(map[string]int)
+	for _, r := range tb.history {
+		typeChanged[r.machineType]++
+		rackChanged[strconv.Itoa(r.rack)]++
+	}
+	for _, t := range pending {
+		typeChanged[t.machine.Type]++
+		rackChanged[strconv.Itoa(t.machine.Rack)]++
+	}
+
+	if mt, ok := exceedsPercent(typeChanged, typeTotal, tb.config.MaxMachineTypePercent); ok {
+		return fmt.Sprintf("%d of %d machines of machine type %s would become unhealthy or unreachable within %s, more than %g%%",
+			typeChanged[mt], typeTotal[mt], mt, window, tb.config.MaxMachineTypePercent)
+	}
+	if rack, ok := exceedsPercent(rackChanged, rackTotal, tb.config.MaxRackPercent); ok {
+		return fmt.Sprintf("%d of %d machines in rack %s would become unhealthy or unreachable within %s, more than %g%%",
+			rackChanged[rack], rackTotal[rack], rack, window, tb.config.MaxRackPercent)
+	}
+	return ""
+}
+
+// decide updates the status of the breaker b for the pending transitions.
+// blocked is true if the transitions should be stopped.
+// changed is true if b is modified.
+func (tb *transitionBreaker) decide(b *neco.TransitionBreaker, now time.Time, pending []stateTransition, machines []*machine) (blocked, changed bool) {
+	if b.Bypassed(now) {
+		if b.Tripped || len(b.Blocked) > 0 {
+			b.Tripped = false
+			b.Reason = ""
+			b.Blocked = nil
+			changed = true
+		}
+		return false, changed
+	}
+
+	if !b.Tripped {
+		reason := tb.exceeded(now, pending, machines)
+		if reason == "" {
+			return false, false
+		}
+		b.Tripped = true
+		b.Reason = reason
+		b.TrippedAt = now.UTC()
+		changed = true
+	}
+
+	blockedTransitions := make([]neco.BlockedTransition, len(pending))
+	for i, t := range pending {
+		blockedTransitions[i] = neco.BlockedTransition{
+			Serial: t.machine.Serial,
+			From:   string(t.machine.State),
+			To:     string(t.to),
+		}
+	}
+	if !sameBlockedTransitions(b.Blocked, blockedTransitions) {
+		b.Blocked = blockedTransitions
+		changed = true
+	}
+	return true, changed
+}
+
+func sameBlockedTransitions(a, b []neco.BlockedTransition) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// checkBreaker returns the transitions not stopped by the circuit breaker.
+func (c *Controller) checkBreaker(ctx context.Context, now time.Time, transitions []stateTransition, machines []*machine) []stateTransition {
+	if c.breaker == nil {
+		return transitions
+	}
+
+	var pending, allowed []stateTransition
+	for _, t := range transitions {
+		if t.degrading() {
+			pending = append(pending, t)
+		} else {
+			allowed = append(allowed, t)
+		}
+	}
+
+	var blocked bool
+	var status neco.TransitionBreaker
+	err := c.breakerStore.UpdateTransitionBreaker(ctx, func(b *neco.TransitionBreaker) error {
+		var changed bool
+		blocked, changed = c.breaker.decide(b, now, pending, machines)
+		status = *b
+		if !changed {
+			return errBreakerUnchanged
+		}
+		b.UpdatedAt = now.UTC()
+		return nil
+	})
+	switch err {
+	case nil, errBreakerUnchanged:
+		c.breakerStatus = status
+	default:
+		log.Warn("failed to update the status of circuit breaker", map[string]interface{}{
+			log.FnError: err.Error(),
+		})
+		// Decide with the last known status.
+		status = c.breakerStatus
+		blocked, _ = c.breaker.decide(&status, now, pending, machines)
+		c.breakerStatus = status
+	}
+
+	if status.Tripped {
+		metrics.SabakanStateSetterBreakerTripped.Set(1)
+	} else {
+		metrics.SabakanStateSetterBreakerTripped.Set(0)
+	}
+	if !blocked {
+		metrics.SabakanStateSetterBreakerBlockedMachines.Set(0)
+		return transitions
+	}
+
+	metrics.SabakanStateSetterBreakerBlockedMachines.Set(float64(len(pending)))
+	if len(pending) > 0 {
+		serials := make([]string, len(pending))
+		for i, t := range pending {
+			serials[i] = t.machine.Serial
+		}
+		log.Error("circuit breaker is tripped; stop transitions to unhealthy or unreachable", map[string]interface{}{
+			"reason":     status.Reason,
+			"tripped_at": status.TrippedAt,
+			"serials":    serials,
+		})
+	}
+	return allowed
+}
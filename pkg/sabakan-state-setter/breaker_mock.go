@@ -0,0 +1,41 @@
+package sss
+
+import (
+	"context"
+	"sync"
+
+	"github.com/cybozu-go/neco"
+)
+
+type breakerMockStore struct {
+	mu      sync.Mutex
+	breaker neco.TransitionBreaker
+	err     error
+}
+
+var _ BreakerStore = &breakerMockStore{}
+
+func newMockBreakerStore() *breakerMockStore {
+	return &breakerMockStore{}
+}
+
+func (s *breakerMockStore) UpdateTransitionBreaker(ctx context.Context, f func(*neco.TransitionBreaker) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	b := s.breaker
+	b.Blocked = append([]neco.BlockedTransition(nil), s.breaker.Blocked...)
+	if err := f(&b); err != nil {
+		return err
+	}
+	s.breaker = b
+	return nil
+}
+
+func (s *breakerMockStore) get() neco.TransitionBreaker {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.breaker
+}
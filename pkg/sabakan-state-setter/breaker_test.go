@@ -0,0 +1,199 @@
+package sss
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cybozu-go/neco"
+	sabakan "github.com/cybozu-go/sabakan/v2"
+)
+
+func testMachines(n int, machineType string, rack int) []*machine {
+	machines := make([]*machine, n)
+	for i := range machines {
+		machines[i] = &machine{
+			Serial: fmt.Sprintf("%s-%d-%d", machineType, rack, i),
+			Type:   machineType,
+			Rack:   rack,
+			State:  sabakan.StateHealthy,
+		}
+	}
+	return machines
+}
+
+func toUnreachable(machines ...*machine) []stateTransition {
+	transitions := make([]stateTransition, len(machines))
+	for i, m := range machines {
+		transitions[i] = stateTransition{machine: m, to: sabakan.StateUnreachable}
+	}
+	return transitions
+}
+
+func TestTransitionBreakerExceeded(t *testing.T) {
+	rack0 := testMachines(10, "qemu", 0)
+	rack1 := testMachines(10, "qemu", 1)
+	boot := testMachines(4, "boot", 2)
+	var all []*machine
+	all = append(all, rack0...)
+	all = append(all, rack1...)
+	all = append(all, boot...)
+	now := time.Now()
+
+	tb := newTransitionBreaker(&circuitBreakerConfig{
+		Window:      duration{Duration: 10 * time.Minute},
+		MaxMachines: 3,
+	})
+	if reason := tb.exceeded(now, toUnreachable(rack0[0], rack1[0], boot[0]), all); reason != "" {
+		t.Error("3 machines should not exceed the limit:", reason)
+	}
+	tb.record(now.Add(-5*time.Minute), rack0[1])
+	if reason := tb.exceeded(now, toUnreachable(rack0[0], rack1[0], boot[0]), all); !strings.Contains(reason, "4 machines") {
+		t.Error("transitions within the window should be counted:", reason)
+	}
+	if reason := tb.exceeded(now.Add(6*time.Minute), toUnreachable(rack0[0], rack1[0], boot[0]), all); reason != "" {
+		t.Error("transitions before the window should not be counted:", reason)
+	}
+	if len(tb.history) != 0 {
+		t.Error("expired transitions should be removed:", tb.history)
+	}
+
+	tb = newTransitionBreaker(&circuitBreakerConfig{
+		Window:                duration{Duration: 10 * time.Minute},
+		MaxMachineTypePercent: 25,
+	})
+	if reason := tb.exceeded(now, toUnreachable(boot[0]), all); reason != "" {
+		t.Error("a single machine should not trip the breaker by percentage:", reason)
+	}
+	if reason := tb.exceeded(now, toUnreachable(rack0[0], rack1[0], boot[0]), all); reason != "" {
+		t.Error("2 of 20 qemu machines should not exceed 25%:", reason)
+	}
+	if reason := tb.exceeded(now, toUnreachable(boot[0], boot[1]), all); !strings.Contains(reason, "machine type boot") {
+		t.Error("2 of 4 boot machines should exceed 25%:", reason)
+	}
+
+	tb = newTransitionBreaker(&circuitBreakerConfig{
+		Window:         duration{Duration: 10 * time.Minute},
+		MaxRackPercent: 20,
+	})
+	if reason := tb.exceeded(now, toUnreachable(rack0[0], rack0[1], rack1[0]), all); reason != "" {
+		t.Error("2 of 10 machines in a rack should not exceed 20%:", reason)
+	}
+	if reason := tb.exceeded(now, toUnreachable(rack0[0], rack0[1], rack0[2]), all); !strings.Contains(reason, "rack 0") {
+		t.Error("3 of 10 machines in a rack should exceed 20%:", reason)
+	}
+}
+
+func TestTransitionBreakerDecide(t *testing.T) {
+	machines := testMachines(10, "qemu", 0)
+	now := time.Now()
+	tb := newTransitionBreaker(&circuitBreakerConfig{
+		Window:      duration{Duration: 10 * time.Minute},
+		MaxMachines: 2,
+	})
+
+	b := &neco.TransitionBreaker{}
+	blocked, changed := tb.decide(b, now, toUnreachable(machines[0], machines[1]), machines)
+	if blocked || changed {
+		t.Error("the breaker should not trip", b)
+	}
+
+	blocked, changed = tb.decide(b, now, toUnreachable(machines[:3]...), machines)
+	if !blocked || !changed || !b.Tripped || b.Reason == "" || !b.TrippedAt.Equal(now.UTC()) || len(b.Blocked) != 3 {
+		t.Error("the breaker should trip", b)
+	}
+	expected := neco.BlockedTransition{Serial: machines[0].Serial, From: "healthy", To: "unreachable"}
+	if b.Blocked[0] != expected {
+		t.Error("unexpected blocked transition", b.Blocked[0])
+	}
+
+	blocked, changed = tb.decide(b, now.Add(time.Minute), toUnreachable(machines[:3]...), machines)
+	if !blocked || changed {
+		t.Error("the breaker should block the same transitions without changes", b)
+	}
+
+	// The breaker stays tripped even if the number of transitions decreases.
+	blocked, changed = tb.decide(b, now.Add(time.Hour), toUnreachable(machines[0]), machines)
+	if !blocked || !changed || !b.Tripped || len(b.Blocked) != 1 {
+		t.Error("the breaker should stay tripped", b)
+	}
+
+	b.BypassUntil = now.Add(2 * time.Hour)
+	blocked, changed = tb.decide(b, now.Add(time.Hour), toUnreachable(machines[:3]...), machines)
+	if blocked || !changed || b.Tripped || len(b.Blocked) != 0 {
+		t.Error("the breaker should be bypassed", b)
+	}
+}
+
+func testControllerBreaker(t *testing.T) {
+	t.Parallel()
+
+	var machines []*machine
+	status := map[string]*serfStatus{}
+	for i, m := range testMachines(4, "serfonly", 0) {
+		m.IPv4Addr = fmt.Sprintf("10.0.0.%d", i+1)
+		machines = append(machines, m)
+		status[m.IPv4Addr] = &serfStatus{
+			Status:             "alive",
+			SystemdUnitsFailed: strPtr(""),
+		}
+	}
+	machines[0].State = sabakan.StateUnreachable
+	for _, m := range machines[1:] {
+		status[m.IPv4Addr].Status = "failed"
+	}
+
+	sabaMock := newMockSabakanClient(machines)
+	serfMock, _ := newMockSerfClient(status)
+	ctr := newMockController(sabaMock, newMockPromClient(map[string]string{}), serfMock, newMockNecoCmdExecutor(), machineTypeSerfOnly)
+	ctr.breaker = newTransitionBreaker(&circuitBreakerConfig{
+		Window:      duration{Duration: 10 * time.Minute},
+		MaxMachines: 2,
+	})
+	store := ctr.breakerStore.(*breakerMockStore)
+
+	err := ctr.runOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sabaMock.getState(machines[0].Serial) != sabakan.StateHealthy {
+		t.Error("recovery should not be stopped by the breaker")
+	}
+	for _, m := range machines[1:] {
+		if sabaMock.getState(m.Serial) != sabakan.StateHealthy {
+			t.Error("transition to unreachable should be stopped:", m.Serial)
+		}
+	}
+	b := store.get()
+	if !b.Tripped || len(b.Blocked) != 3 {
+		t.Error("the breaker should be tripped", b)
+	}
+
+	// The breaker stays tripped even if etcd is not available.
+	store.err = errors.New("etcd is down")
+	err = ctr.runOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sabaMock.getState(machines[1].Serial) != sabakan.StateHealthy {
+		t.Error("transition to unreachable should be stopped with the last known status")
+	}
+
+	store.err = nil
+	store.breaker.BypassUntil = time.Now().Add(time.Hour)
+	err = ctr.runOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range machines[1:] {
+		if sabaMock.getState(m.Serial) != sabakan.StateUnreachable {
+			t.Error("transition to unreachable should be made while bypassed:", m.Serial)
+		}
+	}
+	if b := store.get(); b.Tripped {
+		t.Error("the breaker should be reset while bypassed", b)
+	}
+}
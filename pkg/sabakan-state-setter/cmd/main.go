@@ -1,22 +1,25 @@
 package main
 
 import (
-	"context"
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
 	"time"
 
 	"github.com/cybozu-go/log"
 	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/metrics"
 	sss "github.com/cybozu-go/neco/pkg/sabakan-state-setter"
 	"github.com/cybozu-go/well"
 )
 
 var (
 	flagConfigFile     = flag.String("config-file", "", "path of config file")
+	flagHTTPAddress    = flag.String("http-address", "127.0.0.1:10192", "listen address of the HTTP endpoint")
 	flagEtcdSessionTTL = flag.Duration("etcd-session-ttl", 1*time.Minute, "TTL of etcd session")
 	flagInterval       = flag.Duration("interval", 1*time.Minute, "interval of scraping metrics")
+	flagMetricsAddress = flag.String("metrics-address", ":10193", "listen address of the metrics endpoint")
 	flagParallelSize   = flag.Int("parallel", 30, "The number of parallel execution of getting machines metrics")
 	flagSabakanURL     = flag.String("sabakan-url", "http://localhost:10080", "sabakan URL")
 	flagSerfAddress    = flag.String("serf-address", "127.0.0.1:7373", "serf address")
@@ -40,14 +43,41 @@ func main() {
 	}
 	defer etcdClient.Close()
 
+	ctr, err := sss.NewController(etcdClient, *flagSabakanURL, *flagSerfAddress, *flagConfigFile, hostname, *flagInterval, *flagParallelSize, *flagEtcdSessionTTL)
+	if err != nil {
+		log.ErrorExit(fmt.Errorf("failed to create controller: %s", err.Error()))
+	}
+
 	// Using well.Go for terminating this process when catche a signal.
-	well.Go(func(ctx context.Context) error {
-		ctr, err := sss.NewController(etcdClient, *flagSabakanURL, *flagSerfAddress, *flagConfigFile, hostname, *flagInterval, *flagParallelSize, *flagEtcdSessionTTL)
-		if err != nil {
-			return fmt.Errorf("failed to create controller: %s", err.Error())
-		}
-		return ctr.Run(ctx)
-	})
+	well.Go(ctr.Run)
+
+	mux := http.NewServeMux()
+	mux.Handle("/health", ctr.HealthHandler())
+	mux.Handle("/health/", ctr.HealthHandler())
+	hs := &well.HTTPServer{
+		Server: &http.Server{
+			Addr:    *flagHTTPAddress,
+			Handler: mux,
+		},
+	}
+	err = hs.ListenAndServe()
+	if err != nil {
+		log.ErrorExit(err)
+	}
+
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("/metrics", metrics.SabakanStateSetterHandler())
+	ms := &well.HTTPServer{
+		Server: &http.Server{
+			Addr:    *flagMetricsAddress,
+			Handler: metricsMux,
+		},
+	}
+	err = ms.ListenAndServe()
+	if err != nil {
+		log.ErrorExit(err)
+	}
+
 	well.Stop()
 	err = well.Wait()
 	if err != nil && !well.IsSignaled(err) {
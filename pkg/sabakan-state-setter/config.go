@@ -3,6 +3,7 @@ package sss
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"strings"
@@ -16,6 +17,22 @@ type targetMetric struct {
 	Name                string    `json:"name"`
 	Selector            *selector `json:"selector,omitempty"`
 	MinimumHealthyCount *int      `json:"minimum-healthy-count,omitempty"`
+
+	// HealthyIf is the condition for a metric to be healthy.
+	// If nil, defaultHealthRule is used.
+	HealthyIf *healthRule `json:"healthy-if,omitempty"`
+
+	// Severity is what the machine becomes if the metrics are not healthy.
+	// It is either "unhealthy" (default) or "warning".
+	// A warning does not change the machine state.
+	Severity string `json:"severity,omitempty"`
+}
+
+func (t *targetMetric) rule() *healthRule {
+	if t.HealthyIf == nil {
+		return defaultHealthRule
+	}
+	return t.HealthyIf
 }
 
 type selector struct {
@@ -82,8 +99,20 @@ type machineType struct {
 }
 
 type config struct {
-	ShutdownSchedule string         `json:"shutdown-schedule,omitempty"`
-	MachineTypes     []*machineType `json:"machine-types"`
+	ShutdownSchedule string                `json:"shutdown-schedule,omitempty"`
+	MachineTypes     []*machineType        `json:"machine-types"`
+	CircuitBreaker   *circuitBreakerConfig `json:"circuit-breaker,omitempty"`
+
+	machineTypes map[string]*machineType
+}
+
+// circuitBreakerConfig is the limits of transitions of machines to
+// unhealthy or unreachable within a window.  Zero means no limit.
+type circuitBreakerConfig struct {
+	Window                duration `json:"window"`
+	MaxMachines           int      `json:"max-machines,omitempty"`
+	MaxMachineTypePercent float64  `json:"max-machine-type-percent,omitempty"`
+	MaxRackPercent        float64  `json:"max-rack-percent,omitempty"`
 }
 
 type duration struct {
@@ -117,41 +146,62 @@ func (d *duration) UnmarshalJSON(b []byte) error {
 	}
 }
 
-func readConfigFile(name string) (string, map[string]*machineType, error) {
+func readConfigFile(name string) (*config, error) {
 	cf, err := os.Open(name)
 	if err != nil {
-		return "", nil, err
+		return nil, err
 	}
 	defer cf.Close()
 
-	shutdownSchedule, machineTypes, err := parseConfig(cf)
+	return decodeConfig(cf)
+}
+
+func parseConfig(reader io.Reader) (string, map[string]*machineType, error) {
+	cfg, err := decodeConfig(reader)
 	if err != nil {
 		return "", nil, err
 	}
-	return shutdownSchedule, machineTypes, nil
+	return cfg.ShutdownSchedule, cfg.machineTypes, nil
 }
 
-func parseConfig(reader io.Reader) (string, map[string]*machineType, error) {
+func decodeConfig(reader io.Reader) (*config, error) {
 	data, err := io.ReadAll(reader)
 	if err != nil {
-		return "", nil, err
+		return nil, err
 	}
 
 	cfg := &config{}
 	err = yaml.Unmarshal(data, cfg)
 	if err != nil {
-		return "", nil, err
+		return nil, err
 	}
 
 	if len(cfg.MachineTypes) == 0 {
-		return "", nil, errors.New("machine-types are not defined")
+		return nil, errors.New("machine-types are not defined")
 	}
 	machineTypes := make(map[string]*machineType)
 	for _, t := range cfg.MachineTypes {
 		if t.GracePeriod.Duration == 0 {
 			t.GracePeriod.Duration = time.Hour
 		}
+		for _, m := range t.MetricsCheckList {
+			switch m.Severity {
+			case "", severityUnhealthy, severityWarning:
+			default:
+				return nil, fmt.Errorf("invalid severity %q for metric %s of machine type %s", m.Severity, m.Name, t.Name)
+			}
+		}
 		machineTypes[t.Name] = t
 	}
-	return cfg.ShutdownSchedule, machineTypes, nil
+	cfg.machineTypes = machineTypes
+
+	if cb := cfg.CircuitBreaker; cb != nil {
+		if cb.Window.Duration == 0 {
+			cb.Window.Duration = 10 * time.Minute
+		}
+		if cb.MaxMachines < 0 || cb.MaxMachineTypePercent < 0 || cb.MaxRackPercent < 0 {
+			return nil, errors.New("limits of circuit-breaker must not be negative")
+		}
+	}
+	return cfg, nil
 }
@@ -56,4 +56,67 @@ machine-types:
 	if err == nil {
 		t.Error(errors.New("it should be raised an error"))
 	}
+
+	fileContent3 := `
+machine-types:
+  - name: storage
+    metrics:
+      - name: hw_disk_temperature_celsius
+        healthy-if: value < 70
+      - name: hw_memory_correctable_errors_total
+        healthy-if: increase(24h) == 0
+        severity: warning
+`
+	_, machineTypes, err = parseConfig(strings.NewReader(fileContent3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics := machineTypes["storage"].MetricsCheckList
+	if metrics[0].rule().String() != "value < 70" || metrics[0].Severity != "" {
+		t.Error("unexpected rule:", metrics[0].rule(), metrics[0].Severity)
+	}
+	if metrics[1].rule().window != 24*time.Hour || metrics[1].Severity != severityWarning {
+		t.Error("unexpected rule:", metrics[1].rule(), metrics[1].Severity)
+	}
+
+	for _, invalid := range []string{`
+machine-types:
+  - name: storage
+    metrics:
+      - name: hw_disk_temperature_celsius
+        healthy-if: temperature < 70
+`, `
+machine-types:
+  - name: storage
+    metrics:
+      - name: hw_disk_temperature_celsius
+        severity: critical
+`, `
+machine-types:
+  - name: qemu
+circuit-breaker:
+  max-machines: -1
+`} {
+		_, _, err = parseConfig(strings.NewReader(invalid))
+		if err == nil {
+			t.Error("it should be raised an error:", invalid)
+		}
+	}
+
+	cfg, err := decodeConfig(strings.NewReader(`
+machine-types:
+  - name: qemu
+circuit-breaker:
+  max-machines: 10
+  max-rack-percent: 50
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.CircuitBreaker.Window.Duration != 10*time.Minute {
+		t.Error("default value of window is not set")
+	}
+	if cfg.CircuitBreaker.MaxMachines != 10 || cfg.CircuitBreaker.MaxRackPercent != 50 || cfg.CircuitBreaker.MaxMachineTypePercent != 0 {
+		t.Error("unexpected circuit-breaker:", cfg.CircuitBreaker)
+	}
 }
@@ -9,6 +9,8 @@ import (
 	"time"
 
 	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/metrics"
 	"github.com/cybozu-go/neco/storage"
 	"github.com/cybozu-go/sabakan/v2"
 	gqlsabakan "github.com/cybozu-go/sabakan/v2/gql"
@@ -25,12 +27,16 @@ type Controller struct {
 	etcdClient    *clientv3.Client
 	electionValue string
 	sessionTTL    time.Duration
+	leaderKey     string // set in Run
 
 	// Clients
-	necoExecutor  NecoCmdExecutor
-	promClient    PrometheusClient
-	sabakanClient SabakanClientWrapper
-	serfClient    SerfClient
+	necoExecutor   NecoCmdExecutor
+	promClient     PrometheusClient
+	sabakanClient  SabakanClientWrapper
+	serfClient     SerfClient
+	healthStore    HealthStore
+	breakerStore   BreakerStore
+	unhealthyStore UnhealthyStore
 
 	// others
 	interval          time.Duration
@@ -38,6 +44,19 @@ type Controller struct {
 	shutdownSchedule  string
 	machineTypes      map[string]*machineType
 	unhealthyMachines map[string]time.Time
+	samples           *sampleHistory
+
+	// unhealthyMachines stored in etcd
+	storedUnhealthyMachines map[string]time.Time
+
+	// Circuit breaker; nil if not configured
+	breaker       *transitionBreaker
+	breakerStatus neco.TransitionBreaker
+
+	// Health evaluations
+	healthMu      sync.RWMutex
+	healths       map[string]*neco.MachineHealth
+	storedHealths map[string]*neco.MachineHealth
 }
 
 // RegisterUnhealthy registers unhealthy machine and returns true
@@ -63,7 +82,7 @@ func (c *Controller) ClearUnhealthy(m *machine) {
 
 // NewController returns controller for sabakan-state-setter
 func NewController(etcdClient *clientv3.Client, sabakanAddress, serfAddress, configFile, electionValue string, interval time.Duration, parallelSize int, sessionTTL time.Duration) (*Controller, error) {
-	shutdownSchedule, machineTypes, err := readConfigFile(configFile)
+	cfg, err := readConfigFile(configFile)
 	if err != nil {
 		return nil, err
 	}
@@ -80,22 +99,32 @@ func NewController(etcdClient *clientv3.Client, sabakanAddress, serfAddress, con
 
 	promClient := newPromClient()
 	necoExecutor := newNecoCmdExecutor()
+	st := storage.NewStorage(etcdClient)
 
 	return &Controller{
 		etcdClient:    etcdClient,
 		electionValue: electionValue,
 		sessionTTL:    sessionTTL,
 
-		necoExecutor:  necoExecutor,
-		promClient:    promClient,
-		sabakanClient: sabakanClient,
-		serfClient:    serfClient,
+		necoExecutor:   necoExecutor,
+		promClient:     promClient,
+		sabakanClient:  sabakanClient,
+		serfClient:     serfClient,
+		healthStore:    st,
+		breakerStore:   st,
+		unhealthyStore: st,
 
 		interval:          interval,
 		parallelSize:      parallelSize,
-		shutdownSchedule:  shutdownSchedule,
-		machineTypes:      machineTypes,
+		shutdownSchedule:  cfg.ShutdownSchedule,
+		machineTypes:      cfg.machineTypes,
 		unhealthyMachines: make(map[string]time.Time),
+		samples:           newSampleHistory(cfg.machineTypes),
+		breaker:           newTransitionBreaker(cfg.CircuitBreaker),
+		healths:           make(map[string]*neco.MachineHealth),
+		storedHealths:     make(map[string]*neco.MachineHealth),
+
+		storedUnhealthyMachines: make(map[string]time.Time),
 	}, nil
 }
 
@@ -137,6 +166,9 @@ func (c *Controller) Run(ctx context.Context) error {
 		"session": session.Lease(),
 	})
 	leaderKey := election.Key()
+	c.leaderKey = leaderKey
+	metrics.SabakanStateSetterLeader.Set(1)
+	defer metrics.SabakanStateSetterLeader.Set(0)
 
 	// Release the leader before terminating.
 	defer func() {
@@ -156,6 +188,14 @@ func (c *Controller) Run(ctx context.Context) error {
 		}
 	}()
 
+	// Resume the grace periods so that they do not restart on every leader change.
+	if err := c.loadUnhealthyMachines(ctx, time.Now()); err != nil {
+		return fmt.Errorf("failed to load unhealthy machines: %s", err.Error())
+	}
+	if err := c.loadHealths(ctx); err != nil {
+		return fmt.Errorf("failed to load health evaluations: %s", err.Error())
+	}
+
 	if c.shutdownSchedule == "" {
 		log.Info("skip to start shutdown cron job", nil)
 	} else {
@@ -219,6 +259,11 @@ func watchLeaderKey(ctx context.Context, session *concurrency.Session, leaderKey
 }
 
 func (c *Controller) runOnce(ctx context.Context) error {
+	start := time.Now()
+	defer func() {
+		metrics.SabakanStateSetterRunDuration.Observe(time.Since(start).Seconds())
+	}()
+
 	machines, err := c.sabakanClient.GetAllMachines(ctx)
 	if err != nil {
 		log.Warn("failed to get sabakan machines", map[string]interface{}{
@@ -256,7 +301,7 @@ func (c *Controller) runOnce(ctx context.Context) error {
 	newStateMap := map[string]sabakan.MachineState{}
 
 	// Do machines health check
-	healthcheckResult := c.machineHealthCheck(ctx, machines, serfStatus)
+	healthcheckResult, healths := c.machineHealthCheck(ctx, machines, serfStatus)
 	for serial, state := range healthcheckResult {
 		newStateMap[serial] = state
 	}
@@ -268,6 +313,7 @@ func (c *Controller) runOnce(ctx context.Context) error {
 	}
 
 	now := time.Now()
+	var transitions []stateTransition
 	for _, m := range machines {
 		newState, ok := newStateMap[m.Serial]
 		switch {
@@ -282,7 +328,16 @@ func (c *Controller) runOnce(ctx context.Context) error {
 		default:
 			c.ClearUnhealthy(m)
 		}
+		transitions = append(transitions, stateTransition{machine: m, to: newState})
+	}
+
+	// Stop transitions to unhealthy or unreachable if too many machines would change state.
+	transitions = c.checkBreaker(ctx, now, transitions, machines)
 
+	updated := make(map[string]sabakan.MachineState)
+	for _, t := range transitions {
+		m, newState := t.machine, t.to
+		from := m.State
 		err := c.sabakanClient.UpdateSabakanState(ctx, m.Serial, newState)
 		if err != nil {
 			switch e := err.(type) {
@@ -310,16 +365,52 @@ func (c *Controller) runOnce(ctx context.Context) error {
 				"ipv4":   m.IPv4Addr,
 				"state":  newState,
 			})
+			metrics.SabakanStateSetterTransitionsTotal.WithLabelValues(string(from), string(newState)).Inc()
+			updated[m.Serial] = newState
+			if t.degrading() {
+				c.breaker.record(now, m)
+			}
 		}
 	}
 
+	machineCounts := make(map[metrics.SabakanStateSetterMachineCount]int)
+	for _, m := range machines {
+		state, ok := updated[m.Serial]
+		if !ok {
+			state = m.State
+		}
+		machineCounts[metrics.SabakanStateSetterMachineCount{State: string(state), MachineType: m.Type}]++
+	}
+	metrics.SetSabakanStateSetterMachines(machineCounts)
+	c.recordUnhealthyMachines(ctx, machines)
+	metrics.SabakanStateSetterUnhealthyGraceMachines.Set(float64(len(c.unhealthyMachines)))
+
+	for serial, h := range healths {
+		since, ok := c.unhealthyMachines[serial]
+		if !ok {
+			continue
+		}
+		transitionAt := since
+		if mt, ok := c.machineTypes[h.MachineType]; ok {
+			h.GracePeriod = mt.GracePeriod.Duration
+			transitionAt = since.Add(h.GracePeriod)
+		}
+		since = since.UTC()
+		transitionAt = transitionAt.UTC()
+		h.UnhealthySince = &since
+		h.TransitionAt = &transitionAt
+	}
+	c.recordHealths(ctx, machines, healths)
+
 	return nil
 }
 
-func (c *Controller) machineHealthCheck(ctx context.Context, machines []*machine, serfStatus map[string]*serfStatus) map[string]sabakan.MachineState {
+func (c *Controller) machineHealthCheck(ctx context.Context, machines []*machine, serfStatus map[string]*serfStatus) (map[string]sabakan.MachineState, map[string]*neco.MachineHealth) {
 	// Construct a slice of machineStateSource
 	machineStateSources := make([]*machineStateSource, 0, len(machines))
+	machineMap := make(map[string]*machine, len(machines))
 	for _, m := range machines {
+		machineMap[m.Serial] = m
 		switch m.State {
 		case sabakan.StateUninitialized:
 		case sabakan.StateHealthy:
@@ -357,6 +448,7 @@ func (c *Controller) machineHealthCheck(ctx context.Context, machines []*machine
 
 			mfs, err := c.promClient.ConnectMetricsServer(ctx, source.ipv4)
 			if err != nil {
+				metrics.SabakanStateSetterScrapeFailuresTotal.WithLabelValues(source.ipv4).Inc()
 				log.Warn("failed to get metrics", map[string]interface{}{
 					log.FnError: err.Error(),
 					"serial":    source.serial,
@@ -370,15 +462,21 @@ func (c *Controller) machineHealthCheck(ctx context.Context, machines []*machine
 	wg.Wait()
 
 	// Decide next machine state
+	now := time.Now()
 	newStateMap := map[string]sabakan.MachineState{}
+	healths := map[string]*neco.MachineHealth{}
 	for _, mss := range machineStateSources {
+		mss.history = c.samples
+		mss.now = now
 		newState := mss.decideMachineStateCandidate()
+		healths[mss.serial] = mss.health(machineMap[mss.serial], newState)
 		if newState == doNotChangeState {
 			continue
 		}
 		newStateMap[mss.serial] = newState
 	}
-	return newStateMap
+	c.samples.gc(now)
+	return newStateMap, healths
 }
 
 func (c *Controller) machineRetire(ctx context.Context, machines []*machine) map[string]sabakan.MachineState {
@@ -399,6 +497,7 @@ func (c *Controller) machineRetire(ctx context.Context, machines []*machine) map
 				"serial":    m.Serial,
 				"ipv4":      m.IPv4Addr,
 			})
+			metrics.SabakanStateSetterRetirementsTotal.WithLabelValues("failed").Inc()
 			continue
 		}
 
@@ -410,6 +509,7 @@ func (c *Controller) machineRetire(ctx context.Context, machines []*machine) map
 				"ipv4":      m.IPv4Addr,
 				"cmdlog":    string(cmdOutput),
 			})
+			metrics.SabakanStateSetterRetirementsTotal.WithLabelValues("failed").Inc()
 			continue
 		}
 
@@ -418,6 +518,7 @@ func (c *Controller) machineRetire(ctx context.Context, machines []*machine) map
 			"ipv4":   m.IPv4Addr,
 			"cmdlog": string(cmdOutput),
 		})
+		metrics.SabakanStateSetterRetirementsTotal.WithLabelValues("succeeded").Inc()
 		newStateMap[m.Serial] = sabakan.StateRetired
 	}
 
@@ -425,6 +526,8 @@ func (c *Controller) machineRetire(ctx context.Context, machines []*machine) map
 }
 
 func (c *Controller) machineShutdown(ctx context.Context) {
+	metrics.SabakanStateSetterLastShutdown.SetToCurrentTime()
+
 	machines, err := c.sabakanClient.GetRetiredMachines(ctx)
 	if err != nil {
 		log.Warn("shutdown; failed to get retired machines", map[string]interface{}{
@@ -448,6 +551,7 @@ func (c *Controller) machineShutdown(ctx context.Context) {
 				"cmdlog":    string(cmdOutput),
 			})
 			errorMachines = append(errorMachines, m.Serial)
+			metrics.SabakanStateSetterShutdownsTotal.WithLabelValues("failed").Inc()
 			continue
 		}
 
@@ -458,6 +562,7 @@ func (c *Controller) machineShutdown(ctx context.Context) {
 				"serial": m.Serial,
 				"ipv4":   m.IPv4Addr,
 			})
+			metrics.SabakanStateSetterShutdownsTotal.WithLabelValues("skipped").Inc()
 			continue
 		}
 
@@ -470,6 +575,7 @@ func (c *Controller) machineShutdown(ctx context.Context) {
 				"cmdlog":    string(cmdOutput),
 			})
 			errorMachines = append(errorMachines, m.Serial)
+			metrics.SabakanStateSetterShutdownsTotal.WithLabelValues("failed").Inc()
 			continue
 		}
 
@@ -478,6 +584,7 @@ func (c *Controller) machineShutdown(ctx context.Context) {
 			"ipv4":   m.IPv4Addr,
 			"cmdlog": string(cmdOutput),
 		})
+		metrics.SabakanStateSetterShutdownsTotal.WithLabelValues("succeeded").Inc()
 	}
 	if len(errorMachines) != 0 {
 		log.Warn("shutdown; failed to shutdown some machines", map[string]interface{}{
@@ -2,13 +2,18 @@ package sss
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/cybozu-go/neco"
 	sabakan "github.com/cybozu-go/sabakan/v2"
 )
 
-func newMockController(saba *sabakanMockClient, prom *promMockClient, serf *serfMockClient, neco *necoCmdMockExecutor, mt ...*machineType) *Controller {
+func newMockController(saba *sabakanMockClient, prom *promMockClient, serf *serfMockClient, necoExecutor *necoCmdMockExecutor, mt ...*machineType) *Controller {
 	machineTypes := map[string]*machineType{}
 	for _, m := range mt {
 		machineTypes[m.Name] = m
@@ -20,9 +25,16 @@ func newMockController(saba *sabakanMockClient, prom *promMockClient, serf *serf
 		sabakanClient:     saba,
 		promClient:        prom,
 		serfClient:        serf,
-		necoExecutor:      neco,
+		necoExecutor:      necoExecutor,
+		healthStore:       newMockHealthStore(),
+		breakerStore:      newMockBreakerStore(),
+		unhealthyStore:    newMockUnhealthyStore(),
 		machineTypes:      machineTypes,
 		unhealthyMachines: make(map[string]time.Time),
+		healths:           make(map[string]*neco.MachineHealth),
+		storedHealths:     make(map[string]*neco.MachineHealth),
+
+		storedUnhealthyMachines: make(map[string]time.Time),
 	}
 }
 
@@ -470,10 +482,230 @@ func testControllerShutdown(t *testing.T) {
 	}
 }
 
+func testControllerHealth(t *testing.T) {
+	t.Parallel()
+
+	mt := &machineType{
+		Name: "type1",
+		GracePeriod: duration{
+			Duration: time.Minute * 60,
+		},
+	}
+	machines := []*machine{
+		{
+			Serial:   "1",
+			Type:     "type1",
+			IPv4Addr: "10.0.0.100",
+			State:    sabakan.StateHealthy,
+		},
+	}
+	serfStatus := map[string]*serfStatus{
+		"10.0.0.100": {
+			Status:             "alive",
+			SystemdUnitsFailed: strPtr("chrony.service"),
+		},
+	}
+
+	sabaMock := newMockSabakanClient(machines)
+	promMock := newMockPromClient(map[string]string{})
+	serfMock, _ := newMockSerfClient(serfStatus)
+	necoMock := newMockNecoCmdExecutor()
+	ctr := newMockController(sabaMock, promMock, serfMock, necoMock, mt)
+	for i := 0; i < 2; i++ {
+		err := ctr.runOnce(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store := ctr.healthStore.(*healthMockStore)
+	if store.count != 1 {
+		t.Error("unchanged evaluation should not be stored again:", store.count)
+	}
+	h, err := store.GetMachineHealth(context.Background(), "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.State != "healthy" || h.Candidate != "unhealthy" || h.SerfStatus != "alive" {
+		t.Errorf("unexpected health: %+v", h)
+	}
+	if !strings.Contains(h.Reason, "chrony.service") {
+		t.Error("reason should contain the failed unit:", h.Reason)
+	}
+	if h.UnhealthySince == nil || h.TransitionAt == nil || h.TransitionAt.Sub(*h.UnhealthySince) != time.Hour {
+		t.Errorf("unexpected grace period: %v %v", h.UnhealthySince, h.TransitionAt)
+	}
+
+	server := httptest.NewServer(ctr.HealthHandler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/health/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got neco.MachineHealth
+	err = json.NewDecoder(resp.Body).Decode(&got)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Serial != "1" || got.Candidate != "unhealthy" {
+		t.Errorf("unexpected health: %+v", got)
+	}
+
+	resp, err = http.Get(server.URL + "/health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list []*neco.MachineHealth
+	err = json.NewDecoder(resp.Body).Decode(&list)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 {
+		t.Error("unexpected health list:", list)
+	}
+
+	resp, err = http.Get(server.URL + "/health/2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Error("unexpected status:", resp.StatusCode)
+	}
+}
+
+func testControllerHealthRecords(t *testing.T) {
+	t.Parallel()
+
+	ctr := newMockController(newMockSabakanClient(nil), newMockPromClient(map[string]string{}), nil, newMockNecoCmdExecutor())
+	store := ctr.healthStore.(*healthMockStore)
+	ctx := context.Background()
+
+	// The former leader stored the evaluation of a machine removed since then.
+	now := time.Now().UTC()
+	store.healths["removed"] = &neco.MachineHealth{Serial: "removed", EvaluatedAt: now.Add(-time.Hour)}
+	err := ctr.loadHealths(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctr.healths["removed"] = store.healths["removed"]
+
+	machines := []*machine{{Serial: "1", State: sabakan.StateHealthy}}
+	evaluate := func(at time.Time) map[string]*neco.MachineHealth {
+		return map[string]*neco.MachineHealth{
+			"1": {Serial: "1", State: "healthy", SerfStatus: "alive", EvaluatedAt: at},
+		}
+	}
+
+	ctr.recordHealths(ctx, machines, evaluate(now))
+	if store.count != 1 {
+		t.Error("new evaluation should be stored:", store.count)
+	}
+	if _, ok := store.healths["removed"]; ok {
+		t.Error("evaluation of a removed machine should be deleted from etcd")
+	}
+	if _, ok := ctr.healths["removed"]; ok {
+		t.Error("evaluation of a removed machine should be deleted from memory")
+	}
+
+	ctr.recordHealths(ctx, machines, evaluate(now.Add(time.Minute)))
+	if store.count != 1 {
+		t.Error("unchanged evaluation should not be stored again soon:", store.count)
+	}
+
+	at := now.Add(healthRewriteInterval + time.Minute)
+	ctr.recordHealths(ctx, machines, evaluate(at))
+	if store.count != 2 {
+		t.Error("unchanged evaluation should be rewritten after a while:", store.count)
+	}
+	if !store.healths["1"].EvaluatedAt.Equal(at) {
+		t.Error("unexpected evaluation time:", store.healths["1"].EvaluatedAt)
+	}
+}
+
 func TestController(t *testing.T) {
 	t.Run("Run", testControllerRun)
 	t.Run("RunSerfError", testControllerRunSerfError)
 	t.Run("Unhealthy", testControllerUnhealthy)
 	t.Run("Retire", testControllerRetire)
 	t.Run("Shutdown", testControllerShutdown)
+	t.Run("Health", testControllerHealth)
+	t.Run("HealthRecords", testControllerHealthRecords)
+	t.Run("Breaker", testControllerBreaker)
+	t.Run("ResumeGracePeriod", testControllerResumeGracePeriod)
+}
+
+func testControllerResumeGracePeriod(t *testing.T) {
+	t.Parallel()
+
+	mt := &machineType{
+		Name: "type1",
+		GracePeriod: duration{
+			Duration: time.Hour,
+		},
+	}
+	machines := []*machine{
+		{Serial: "resumed", Type: "type1", IPv4Addr: "10.0.0.1", State: sabakan.StateHealthy},
+		{Serial: "new", Type: "type1", IPv4Addr: "10.0.0.2", State: sabakan.StateHealthy},
+		{Serial: "stale", Type: "type1", IPv4Addr: "10.0.0.3", State: sabakan.StateHealthy},
+	}
+	status := map[string]*serfStatus{
+		"10.0.0.1": {Status: "alive", SystemdUnitsFailed: strPtr("foo.service")},
+		"10.0.0.2": {Status: "alive", SystemdUnitsFailed: strPtr("foo.service")},
+		"10.0.0.3": {Status: "alive", SystemdUnitsFailed: strPtr("foo.service")},
+	}
+
+	sabaMock := newMockSabakanClient(machines)
+	serfMock, _ := newMockSerfClient(status)
+	ctr := newMockController(sabaMock, newMockPromClient(map[string]string{}), serfMock, newMockNecoCmdExecutor(), mt)
+	ctr.interval = time.Minute
+	store := ctr.unhealthyStore.(*unhealthyMockStore)
+
+	// The former leader started the grace period of "resumed" just over an hour ago.
+	now := time.Now()
+	since := now.Add(-time.Hour - time.Minute).UTC()
+	staleSince := now.Add(-2 * time.Hour).UTC()
+	store.machines["resumed"] = &neco.UnhealthyMachine{Serial: "resumed", Since: since}
+	store.machines["removed"] = &neco.UnhealthyMachine{Serial: "removed", Since: since}
+	store.machines["stale"] = &neco.UnhealthyMachine{Serial: "stale", Since: staleSince}
+	err := ctr.loadUnhealthyMachines(context.Background(), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ctr.runOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sabaMock.getState("resumed") != sabakan.StateUnhealthy {
+		t.Error("the resumed grace period should have ended")
+	}
+	if sabaMock.getState("new") != sabakan.StateHealthy {
+		t.Error("the grace period of a new unhealthy machine should start")
+	}
+	if _, ok := store.machines["new"]; !ok {
+		t.Error("the grace period of a new unhealthy machine should be stored")
+	}
+	if _, ok := store.machines["removed"]; ok {
+		t.Error("a machine removed from sabakan should be deleted")
+	}
+	if sabaMock.getState("stale") != sabakan.StateHealthy {
+		t.Error("a stale grace period should not be resumed")
+	}
+	if m, ok := store.machines["stale"]; !ok || !m.Since.After(staleSince) {
+		t.Error("the grace period of a stale machine should restart", m)
+	}
+
+	// The machine recovers.
+	status["10.0.0.2"].SystemdUnitsFailed = strPtr("")
+	err = ctr.runOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.machines["new"]; ok {
+		t.Error("a recovered machine should be deleted")
+	}
 }
@@ -0,0 +1,175 @@
+package sss
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/storage"
+)
+
+// HealthStore is interface for storing health evaluations
+type HealthStore interface {
+	PutMachineHealth(ctx context.Context, h *neco.MachineHealth, leaderKey string) error
+	GetMachineHealth(ctx context.Context, serial string) (*neco.MachineHealth, error)
+	GetMachineHealths(ctx context.Context) ([]*neco.MachineHealth, error)
+	DeleteMachineHealth(ctx context.Context, serial, leaderKey string) error
+}
+
+var _ HealthStore = storage.Storage{}
+
+// healthRewriteInterval is the interval to rewrite unchanged evaluations
+// in etcd so that their evaluation time does not get too old.
+const healthRewriteInterval = 10 * time.Minute
+
+// sameHealth returns true if two evaluations are the same except for the evaluation time.
+func sameHealth(a, b *neco.MachineHealth) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	x, y := *a, *b
+	x.EvaluatedAt = y.EvaluatedAt
+	dx, err := json.Marshal(x)
+	if err != nil {
+		return false
+	}
+	dy, err := json.Marshal(y)
+	if err != nil {
+		return false
+	}
+	return string(dx) == string(dy)
+}
+
+// loadHealths reads the evaluations stored by the former leader.
+func (c *Controller) loadHealths(ctx context.Context) error {
+	healths, err := c.healthStore.GetMachineHealths(ctx)
+	if err != nil {
+		return err
+	}
+
+	c.storedHealths = make(map[string]*neco.MachineHealth, len(healths))
+	for _, h := range healths {
+		c.storedHealths[h.Serial] = h
+	}
+	return nil
+}
+
+// recordHealths keeps the latest evaluations in memory, and stores those
+// changed since the last time in etcd.  Unchanged ones are rewritten after
+// healthRewriteInterval.  Evaluations of machines no longer registered in
+// sabakan are removed.
+func (c *Controller) recordHealths(ctx context.Context, machines []*machine, healths map[string]*neco.MachineHealth) {
+	exists := make(map[string]bool, len(machines))
+	for _, m := range machines {
+		exists[m.Serial] = true
+	}
+
+	c.healthMu.Lock()
+	for serial := range c.healths {
+		if !exists[serial] {
+			delete(c.healths, serial)
+		}
+	}
+	for serial, h := range healths {
+		c.healths[serial] = h
+	}
+	c.healthMu.Unlock()
+
+	if c.healthStore == nil {
+		return
+	}
+	for serial, h := range healths {
+		stored := c.storedHealths[serial]
+		if sameHealth(stored, h) && h.EvaluatedAt.Sub(stored.EvaluatedAt) < healthRewriteInterval {
+			continue
+		}
+		err := c.healthStore.PutMachineHealth(ctx, h, c.leaderKey)
+		if err != nil {
+			log.Warn("failed to store health evaluation", map[string]interface{}{
+				log.FnError: err.Error(),
+				"serial":    serial,
+			})
+			continue
+		}
+		c.storedHealths[serial] = h
+	}
+
+	for serial := range c.storedHealths {
+		if exists[serial] {
+			continue
+		}
+		err := c.healthStore.DeleteMachineHealth(ctx, serial, c.leaderKey)
+		if err != nil {
+			log.Warn("failed to delete health evaluation", map[string]interface{}{
+				log.FnError: err.Error(),
+				"serial":    serial,
+			})
+			continue
+		}
+		delete(c.storedHealths, serial)
+	}
+}
+
+// HealthHandler returns the handler to serve health evaluations.
+//
+//   - GET /health returns the latest evaluations of all machines on the leader.
+//   - GET /health/SERIAL returns the latest evaluation of a machine.
+//     If this process is not the leader, this returns the one stored in etcd.
+func (c *Controller) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		serial := strings.Trim(strings.TrimPrefix(r.URL.Path, "/health"), "/")
+		if serial == "" {
+			c.healthMu.RLock()
+			healths := make([]*neco.MachineHealth, 0, len(c.healths))
+			for _, h := range c.healths {
+				healths = append(healths, h)
+			}
+			c.healthMu.RUnlock()
+			sort.Slice(healths, func(i, j int) bool {
+				return healths[i].Serial < healths[j].Serial
+			})
+			renderJSON(w, healths)
+			return
+		}
+
+		c.healthMu.RLock()
+		h := c.healths[serial]
+		c.healthMu.RUnlock()
+		if h == nil && c.healthStore != nil {
+			var err error
+			h, err = c.healthStore.GetMachineHealth(r.Context(), serial)
+			switch err {
+			case nil:
+			case storage.ErrNotFound:
+			default:
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		if h == nil {
+			http.Error(w, "health evaluation not found: "+serial, http.StatusNotFound)
+			return
+		}
+		renderJSON(w, h)
+	})
+}
+
+func renderJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		log.Warn("failed to render JSON", map[string]interface{}{
+			log.FnError: err.Error(),
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package sss
+
+import (
+	"context"
+	"sync"
+
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/storage"
+)
+
+type healthMockStore struct {
+	mu      sync.Mutex
+	healths map[string]*neco.MachineHealth
+	count   int
+}
+
+var _ HealthStore = &healthMockStore{}
+
+func newMockHealthStore() *healthMockStore {
+	return &healthMockStore{
+		healths: map[string]*neco.MachineHealth{},
+	}
+}
+
+func (s *healthMockStore) PutMachineHealth(ctx context.Context, h *neco.MachineHealth, leaderKey string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.healths[h.Serial] = h
+	s.count++
+	return nil
+}
+
+func (s *healthMockStore) GetMachineHealth(ctx context.Context, serial string) (*neco.MachineHealth, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h, ok := s.healths[serial]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	return h, nil
+}
+
+func (s *healthMockStore) GetMachineHealths(ctx context.Context) ([]*neco.MachineHealth, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	healths := make([]*neco.MachineHealth, 0, len(s.healths))
+	for _, h := range s.healths {
+		healths = append(healths, h)
+	}
+	return healths, nil
+}
+
+func (s *healthMockStore) DeleteMachineHealth(ctx context.Context, serial, leaderKey string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.healths, serial)
+	return nil
+}
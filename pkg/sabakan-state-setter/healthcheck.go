@@ -1,7 +1,11 @@
 package sss
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/neco"
 	"github.com/cybozu-go/sabakan/v2"
 	dto "github.com/prometheus/client_model/go"
 )
@@ -23,6 +27,17 @@ type machineStateSource struct {
 	serfStatus  *serfStatus
 	machineType *machineType
 	metrics     map[string]*dto.MetricFamily
+
+	// history and now are used to evaluate rate and increase.
+	history *sampleHistory
+	now     time.Time
+
+	// warnings are the names of metrics that are not healthy with severity warning.
+	warnings []string
+
+	// reason and checks explain the decided state.
+	reason string
+	checks []neco.MachineHealthCheck
 }
 
 func newMachineStateSource(m *machine, serfStatuses map[string]*serfStatus, machineTypes map[string]*machineType) *machineStateSource {
@@ -40,6 +55,7 @@ func (mss *machineStateSource) decideMachineStateCandidate() sabakan.MachineStat
 			"serial": mss.serial,
 			"ipv4":   mss.ipv4,
 		})
+		mss.reason = "the machine is not a member of serf"
 		return sabakan.StateUnreachable
 	}
 
@@ -49,6 +65,7 @@ func (mss *machineStateSource) decideMachineStateCandidate() sabakan.MachineStat
 			"ipv4":   mss.ipv4,
 			"status": mss.serfStatus.Status,
 		})
+		mss.reason = fmt.Sprintf("serf status is %s", mss.serfStatus.Status)
 		return sabakan.StateUnreachable
 	}
 
@@ -58,6 +75,7 @@ func (mss *machineStateSource) decideMachineStateCandidate() sabakan.MachineStat
 			"ipv4":   mss.ipv4,
 			"failed": *mss.serfStatus.SystemdUnitsFailed,
 		})
+		mss.reason = fmt.Sprintf("systemd units failed: %s", *mss.serfStatus.SystemdUnitsFailed)
 		return sabakan.StateUnhealthy
 	}
 
@@ -69,9 +87,11 @@ func (mss *machineStateSource) decideMachineStateCandidate() sabakan.MachineStat
 	if mss.serfStatus.SystemdUnitsFailed == nil {
 		// Do nothing if there is no systemd-units-failed tag and no hardware failure.
 		// In this case, the machine is starting up.
+		mss.reason = "serf tag systemd-units-failed is not set; the machine is starting up"
 		return doNotChangeState
 	}
 
+	mss.reason = "all checks passed"
 	return sabakan.StateHealthy
 }
 
@@ -81,6 +101,7 @@ func (mss *machineStateSource) decideByMonitorHW() sabakan.MachineState {
 			"serial": mss.serial,
 			"ipv4":   mss.ipv4,
 		})
+		mss.reason = "unknown machine type"
 		return sabakan.StateUnhealthy
 	}
 
@@ -93,31 +114,65 @@ func (mss *machineStateSource) decideByMonitorHW() sabakan.MachineState {
 			"serial": mss.serial,
 			"ipv4":   mss.ipv4,
 		})
+		mss.reason = "failed to get metrics"
 		return sabakan.StateUnhealthy
 	}
 
+	// Check all targets to collect warnings even after an unhealthy one is found.
+	state := sabakan.StateHealthy
 	for _, checkTarget := range mss.machineType.MetricsCheckList {
-		_, ok := mss.metrics[checkTarget.Name]
-		if !ok {
-			log.Info("unhealthy; metrics do not contain check target", map[string]interface{}{
-				"serial": mss.serial,
-				"ipv4":   mss.ipv4,
-				"target": checkTarget.Name,
-			})
-			return sabakan.StateUnhealthy
-		}
-
 		res := mss.checkTarget(checkTarget)
-		if res != sabakan.StateHealthy {
-			return res
+		if res != sabakan.StateHealthy && state == sabakan.StateHealthy {
+			state = res
+			mss.reason = mss.checks[len(mss.checks)-1].Reason
 		}
 	}
 
-	return sabakan.StateHealthy
+	return state
 }
 
+// checkTarget checks a target metric and maps the result to a machine state
+// according to its severity.
 func (mss *machineStateSource) checkTarget(target targetMetric) sabakan.MachineState {
-	mf := mss.metrics[target.Name]
+	check := &neco.MachineHealthCheck{
+		Metric:              target.Name,
+		Rule:                target.rule().String(),
+		Severity:            target.Severity,
+		MinimumHealthyCount: target.MinimumHealthyCount,
+	}
+	if check.Severity == "" {
+		check.Severity = severityUnhealthy
+	}
+	res := mss.evaluateTarget(target, check)
+	check.Healthy = res == sabakan.StateHealthy
+	mss.checks = append(mss.checks, *check)
+
+	if res == sabakan.StateUnhealthy && target.Severity == severityWarning {
+		log.Warn("warning; metric is not healthy", map[string]interface{}{
+			"serial": mss.serial,
+			"ipv4":   mss.ipv4,
+			"name":   target.Name,
+			"rule":   target.rule().String(),
+		})
+		mss.warnings = append(mss.warnings, target.Name)
+		return sabakan.StateHealthy
+	}
+	return res
+}
+
+// evaluateTarget evaluates the rule of a target metric and records the result in check.
+func (mss *machineStateSource) evaluateTarget(target targetMetric, check *neco.MachineHealthCheck) sabakan.MachineState {
+	mf, ok := mss.metrics[target.Name]
+	if !ok {
+		log.Info("unhealthy; metrics do not contain check target", map[string]interface{}{
+			"serial": mss.serial,
+			"ipv4":   mss.ipv4,
+			"target": target.Name,
+		})
+		check.Reason = fmt.Sprintf("metric %s does not exist", target.Name)
+		return sabakan.StateUnhealthy
+	}
+
 	matched := target.Selector.Match(mf)
 	if len(matched) == 0 {
 		log.Info("unhealthy; metric with specified labels does not exist", map[string]interface{}{
@@ -126,16 +181,34 @@ func (mss *machineStateSource) checkTarget(target targetMetric) sabakan.MachineS
 			"name":     target.Name,
 			"selector": target.Selector,
 		})
+		check.Reason = fmt.Sprintf("metric %s with specified labels does not exist", target.Name)
 		return sabakan.StateUnhealthy
 	}
 
+	rule := target.rule()
 	var healthyCount int
+	check.Matched = len(matched)
 	for _, m := range matched {
-		gauge := m.GetGauge()
-		if gauge == nil {
+		// Without healthy-if, only gauges are checked as before.
+		if rule == defaultHealthRule && m.GetGauge() == nil {
+			continue
+		}
+		value, satisfied, ok := rule.evaluate(m, mss.history, seriesKey(mss.serial, target.Name, m), mss.now)
+		if !ok {
+			log.Info("unhealthy; metric does not have the value for the rule", map[string]interface{}{
+				"serial": mss.serial,
+				"ipv4":   mss.ipv4,
+				"name":   target.Name,
+				"labels": m.Label,
+				"rule":   rule.String(),
+			})
+			check.Failures = append(check.Failures, neco.MachineHealthFailure{
+				Labels: metricLabels(m),
+				Reason: "the metric does not have the value for the rule",
+			})
 			continue
 		}
-		if gauge.GetValue() == monitorHWStatusOK {
+		if satisfied {
 			healthyCount++
 			continue
 		}
@@ -144,9 +217,16 @@ func (mss *machineStateSource) checkTarget(target targetMetric) sabakan.MachineS
 			"ipv4":   mss.ipv4,
 			"name":   target.Name,
 			"labels": m.Label,
-			"value":  gauge.GetValue(),
+			"value":  value,
+			"rule":   rule.String(),
+		})
+		check.Failures = append(check.Failures, neco.MachineHealthFailure{
+			Labels: metricLabels(m),
+			Value:  &value,
+			Reason: "the rule is not satisfied",
 		})
 	}
+	check.HealthyCount = healthyCount
 
 	if target.MinimumHealthyCount == nil {
 		if healthyCount != len(matched) {
@@ -158,6 +238,7 @@ func (mss *machineStateSource) checkTarget(target targetMetric) sabakan.MachineS
 				"num_metrics":   len(matched),
 				"healthy_count": healthyCount,
 			})
+			check.Reason = fmt.Sprintf("%d of %d %s metrics do not satisfy %q", len(matched)-healthyCount, len(matched), target.Name, rule)
 			return sabakan.StateUnhealthy
 		}
 
@@ -180,6 +261,7 @@ func (mss *machineStateSource) checkTarget(target targetMetric) sabakan.MachineS
 			"minimum_healthy_count": minCount,
 			"healthy_count":         healthyCount,
 		})
+		check.Reason = fmt.Sprintf("only %d of %d %s metrics satisfy %q, fewer than %d", healthyCount, len(matched), target.Name, rule, minCount)
 		return sabakan.StateUnhealthy
 	}
 
@@ -191,3 +273,31 @@ func (mss *machineStateSource) checkTarget(target targetMetric) sabakan.MachineS
 	})
 	return sabakan.StateHealthy
 }
+
+func metricLabels(m *dto.Metric) map[string]string {
+	labels := make(map[string]string, len(m.GetLabel()))
+	for _, l := range m.GetLabel() {
+		labels[l.GetName()] = l.GetValue()
+	}
+	return labels
+}
+
+// health returns the health evaluation of the machine for the decided state.
+func (mss *machineStateSource) health(m *machine, candidate sabakan.MachineState) *neco.MachineHealth {
+	h := &neco.MachineHealth{
+		Serial:      mss.serial,
+		IPv4:        mss.ipv4,
+		MachineType: m.Type,
+		State:       string(m.State),
+		Candidate:   string(candidate),
+		Reason:      mss.reason,
+		Checks:      mss.checks,
+		Warnings:    mss.warnings,
+		EvaluatedAt: mss.now.UTC(),
+	}
+	if mss.serfStatus != nil {
+		h.SerfStatus = mss.serfStatus.Status
+		h.SystemdUnitsFailed = mss.serfStatus.SystemdUnitsFailed
+	}
+	return h
+}
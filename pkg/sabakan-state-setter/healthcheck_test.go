@@ -746,3 +746,83 @@ func intPointer(i int) *int {
 	*p = i
 	return p
 }
+
+func TestCheckTargetSeverity(t *testing.T) {
+	temperature := "hw_disk_temperature_celsius"
+	ms := machineStateSource{
+		serial: "1234",
+		metrics: machineMetrics{
+			Labels: map[string]string{"device": "HDD.slot.1"},
+			Value:  75,
+		}.toMetrics(temperature),
+	}
+	rule, err := parseHealthRule("value < 70")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	target := targetMetric{Name: temperature, HealthyIf: rule}
+	if res := ms.checkTarget(target); res != sabakan.StateUnhealthy {
+		t.Error("ms.checkTarget(target) != sabakan.StateUnhealthy", res)
+	}
+	if len(ms.warnings) != 0 {
+		t.Error("warnings should be empty", ms.warnings)
+	}
+
+	target.Severity = severityWarning
+	if res := ms.checkTarget(target); res != sabakan.StateHealthy {
+		t.Error("ms.checkTarget(target) != sabakan.StateHealthy", res)
+	}
+	if len(ms.warnings) != 1 || ms.warnings[0] != temperature {
+		t.Error("warnings should contain the target", ms.warnings)
+	}
+
+	target.HealthyIf, err = parseHealthRule("value < 80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	target.Severity = ""
+	if res := ms.checkTarget(target); res != sabakan.StateHealthy {
+		t.Error("ms.checkTarget(target) != sabakan.StateHealthy", res)
+	}
+}
+
+func TestCheckTargetDefaultRule(t *testing.T) {
+	name := "hw_processor_status_health"
+	ok := float64(monitorHWStatusOK)
+	ng := float64(1)
+	ms := machineStateSource{
+		serial: "1234",
+		metrics: map[string]*dto.MetricFamily{
+			name: {
+				Name: &name,
+				Metric: []*dto.Metric{
+					{Gauge: &dto.Gauge{Value: &ok}},
+					{Counter: &dto.Counter{Value: &ng}},
+					{Untyped: &dto.Untyped{Value: &ng}},
+				},
+			},
+		},
+	}
+
+	one := 1
+	target := targetMetric{Name: name, MinimumHealthyCount: &one}
+	if res := ms.checkTarget(target); res != sabakan.StateHealthy {
+		t.Error("ms.checkTarget(target) != sabakan.StateHealthy", res)
+	}
+	if len(ms.checks) != 1 || ms.checks[0].HealthyCount != 1 || len(ms.checks[0].Failures) != 0 {
+		t.Error("counters and untyped metrics should be ignored", ms.checks)
+	}
+
+	rule, err := parseHealthRule("value == 0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	target.HealthyIf = rule
+	if res := ms.checkTarget(target); res != sabakan.StateHealthy {
+		t.Error("ms.checkTarget(target) != sabakan.StateHealthy", res)
+	}
+	if len(ms.checks) != 2 || len(ms.checks[1].Failures) != 2 {
+		t.Error("counters and untyped metrics should be evaluated with healthy-if", ms.checks)
+	}
+}
@@ -0,0 +1,289 @@
+package sss
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	severityUnhealthy = "unhealthy"
+	severityWarning   = "warning"
+)
+
+const (
+	ruleFieldValue = "value"
+	ruleFieldCount = "count"
+	ruleFieldSum   = "sum"
+	ruleFieldMean  = "mean"
+
+	ruleFuncRate     = "rate"
+	ruleFuncIncrease = "increase"
+)
+
+var healthRuleRegexp = regexp.MustCompile(`^\s*([a-z]+)\s*(?:\(([^)]*)\))?\s*(==|!=|<=|>=|<|>)\s*(\S+)\s*$`)
+
+// healthRule is a condition for a metric to be healthy.
+// It is written as an expression like "value < 70" or "increase(1h) == 0".
+type healthRule struct {
+	expr      string
+	field     string
+	function  string
+	window    time.Duration
+	operator  string
+	threshold float64
+}
+
+// defaultHealthRule is the rule for metrics of monitor-hw.
+var defaultHealthRule = &healthRule{
+	expr:      "value == 0",
+	field:     ruleFieldValue,
+	operator:  "==",
+	threshold: monitorHWStatusOK,
+}
+
+func parseHealthRule(expr string) (*healthRule, error) {
+	matches := healthRuleRegexp.FindStringSubmatch(expr)
+	if matches == nil {
+		return nil, fmt.Errorf("invalid health rule: %q", expr)
+	}
+
+	r := &healthRule{
+		expr:     strings.TrimSpace(expr),
+		field:    ruleFieldValue,
+		operator: matches[3],
+	}
+	threshold, err := strconv.ParseFloat(matches[4], 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid threshold in health rule %q: %w", expr, err)
+	}
+	r.threshold = threshold
+
+	switch matches[1] {
+	case ruleFieldValue, ruleFieldCount, ruleFieldSum, ruleFieldMean:
+		if matches[2] != "" {
+			return nil, fmt.Errorf("%s does not take arguments in health rule %q", matches[1], expr)
+		}
+		r.field = matches[1]
+	case ruleFuncRate, ruleFuncIncrease:
+		r.function = matches[1]
+		// The argument is "WINDOW" or "FIELD, WINDOW".
+		args := strings.Split(matches[2], ",")
+		if len(args) == 2 {
+			r.field = strings.TrimSpace(args[0])
+			if r.field != ruleFieldValue && r.field != ruleFieldCount && r.field != ruleFieldSum {
+				return nil, fmt.Errorf("invalid field %q in health rule %q", r.field, expr)
+			}
+		} else if len(args) != 1 {
+			return nil, fmt.Errorf("too many arguments in health rule %q", expr)
+		}
+		window, err := time.ParseDuration(strings.TrimSpace(args[len(args)-1]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid window in health rule %q: %w", expr, err)
+		}
+		if window <= 0 {
+			return nil, fmt.Errorf("window must be positive in health rule %q", expr)
+		}
+		r.window = window
+	default:
+		return nil, fmt.Errorf("unknown field or function %q in health rule %q", matches[1], expr)
+	}
+	return r, nil
+}
+
+func (r *healthRule) String() string {
+	return r.expr
+}
+
+func (r healthRule) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.expr)
+}
+
+func (r *healthRule) UnmarshalJSON(b []byte) error {
+	var expr string
+	err := json.Unmarshal(b, &expr)
+	if err != nil {
+		return err
+	}
+	parsed, err := parseHealthRule(expr)
+	if err != nil {
+		return err
+	}
+	*r = *parsed
+	return nil
+}
+
+func (r *healthRule) compare(v float64) bool {
+	switch r.operator {
+	case "==":
+		return v == r.threshold
+	case "!=":
+		return v != r.threshold
+	case "<":
+		return v < r.threshold
+	case "<=":
+		return v <= r.threshold
+	case ">":
+		return v > r.threshold
+	case ">=":
+		return v >= r.threshold
+	}
+	return false
+}
+
+// metricField returns the value of the field of a metric.
+// Gauges, counters and untyped metrics have only "value", and
+// histograms and summaries have "count", "sum" and "mean".
+func metricField(m *dto.Metric, field string) (float64, bool) {
+	switch {
+	case m.Gauge != nil:
+		return m.Gauge.GetValue(), field == ruleFieldValue
+	case m.Counter != nil:
+		return m.Counter.GetValue(), field == ruleFieldValue
+	case m.Untyped != nil:
+		return m.Untyped.GetValue(), field == ruleFieldValue
+	}
+
+	var count uint64
+	var sum float64
+	switch {
+	case m.Histogram != nil:
+		count, sum = m.Histogram.GetSampleCount(), m.Histogram.GetSampleSum()
+	case m.Summary != nil:
+		count, sum = m.Summary.GetSampleCount(), m.Summary.GetSampleSum()
+	default:
+		return 0, false
+	}
+	switch field {
+	case ruleFieldCount:
+		return float64(count), true
+	case ruleFieldSum:
+		return sum, true
+	case ruleFieldMean:
+		if count == 0 {
+			return 0, true
+		}
+		return sum / float64(count), true
+	}
+	return 0, false
+}
+
+// evaluate evaluates the rule for a metric at now.
+// ok is false if the metric does not have the field of the rule.
+//
+// rate and increase are computed from the samples recorded in h under key.
+// Until two or more samples are recorded within the window, the rule is
+// regarded as satisfied.
+func (r *healthRule) evaluate(m *dto.Metric, h *sampleHistory, key string, now time.Time) (value float64, satisfied, ok bool) {
+	v, ok := metricField(m, r.field)
+	if !ok {
+		return 0, false, false
+	}
+	if r.function == "" {
+		return v, r.compare(v), true
+	}
+
+	key = key + "/" + r.field
+	h.add(key, now, v)
+	points := h.since(key, now.Add(-r.window))
+	if len(points) < 2 {
+		return 0, true, true
+	}
+
+	var increase float64
+	for i := 1; i < len(points); i++ {
+		delta := points[i].value - points[i-1].value
+		if delta < 0 {
+			// The counter has been reset.
+			delta = points[i].value
+		}
+		increase += delta
+	}
+	value = increase
+	if r.function == ruleFuncRate {
+		value = increase / points[len(points)-1].time.Sub(points[0].time).Seconds()
+	}
+	return value, r.compare(value), true
+}
+
+// seriesKey returns the key to identify a time series of a machine.
+func seriesKey(serial, name string, m *dto.Metric) string {
+	labels := make([]string, 0, len(m.GetLabel()))
+	for _, l := range m.GetLabel() {
+		labels = append(labels, l.GetName()+"="+strconv.Quote(l.GetValue()))
+	}
+	sort.Strings(labels)
+	return serial + "/" + name + "{" + strings.Join(labels, ",") + "}"
+}
+
+type samplePoint struct {
+	time  time.Time
+	value float64
+}
+
+// sampleHistory keeps samples of metrics to compute rate and increase.
+// A nil sampleHistory keeps nothing.
+type sampleHistory struct {
+	retention time.Duration
+	series    map[string][]samplePoint
+}
+
+func newSampleHistory(machineTypes map[string]*machineType) *sampleHistory {
+	var retention time.Duration
+	for _, mt := range machineTypes {
+		for _, t := range mt.MetricsCheckList {
+			if t.HealthyIf != nil && t.HealthyIf.window > retention {
+				retention = t.HealthyIf.window
+			}
+		}
+	}
+	return &sampleHistory{
+		retention: retention,
+		series:    make(map[string][]samplePoint),
+	}
+}
+
+func (h *sampleHistory) add(key string, t time.Time, v float64) {
+	if h == nil {
+		return
+	}
+	points := h.series[key]
+	if len(points) > 0 && !points[len(points)-1].time.Before(t) {
+		// The sample has already been recorded in this round.
+		points[len(points)-1].value = v
+		return
+	}
+	h.series[key] = append(points, samplePoint{time: t, value: v})
+}
+
+func (h *sampleHistory) since(key string, from time.Time) []samplePoint {
+	if h == nil {
+		return nil
+	}
+	points := h.series[key]
+	i := sort.Search(len(points), func(i int) bool {
+		return !points[i].time.Before(from)
+	})
+	return points[i:]
+}
+
+// gc removes samples older than the longest window of the rules.
+func (h *sampleHistory) gc(now time.Time) {
+	if h == nil {
+		return
+	}
+	for key := range h.series {
+		points := h.since(key, now.Add(-h.retention))
+		if len(points) == 0 {
+			delete(h.series, key)
+			continue
+		}
+		h.series[key] = append([]samplePoint(nil), points...)
+	}
+}
@@ -0,0 +1,154 @@
+package sss
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestParseHealthRule(t *testing.T) {
+	testCases := []struct {
+		expr     string
+		expected *healthRule
+	}{
+		{
+			expr:     "value < 70",
+			expected: &healthRule{expr: "value < 70", field: "value", operator: "<", threshold: 70},
+		},
+		{
+			expr:     "mean<=0.5",
+			expected: &healthRule{expr: "mean<=0.5", field: "mean", operator: "<=", threshold: 0.5},
+		},
+		{
+			expr:     "increase(24h) == 0",
+			expected: &healthRule{expr: "increase(24h) == 0", field: "value", function: "increase", window: 24 * time.Hour, operator: "==", threshold: 0},
+		},
+		{
+			expr:     " rate(count, 1h) < 0.01 ",
+			expected: &healthRule{expr: "rate(count, 1h) < 0.01", field: "count", function: "rate", window: time.Hour, operator: "<", threshold: 0.01},
+		},
+	}
+	for _, tc := range testCases {
+		r, err := parseHealthRule(tc.expr)
+		if err != nil {
+			t.Errorf("%q: %v", tc.expr, err)
+			continue
+		}
+		if *r != *tc.expected {
+			t.Errorf("%q: expected %+v, actual %+v", tc.expr, tc.expected, r)
+		}
+	}
+
+	for _, expr := range []string{
+		"",
+		"value",
+		"value = 0",
+		"value < seventy",
+		"temperature < 70",
+		"value(1h) < 70",
+		"rate < 1",
+		"rate(mean, 1h) < 1",
+		"rate(0s) < 1",
+		"increase(value, 1h, 2h) == 0",
+	} {
+		if _, err := parseHealthRule(expr); err == nil {
+			t.Errorf("%q should be invalid", expr)
+		}
+	}
+}
+
+func TestHealthRuleEvaluate(t *testing.T) {
+	gauge := func(v float64) *dto.Metric {
+		return &dto.Metric{Gauge: &dto.Gauge{Value: &v}}
+	}
+	counter := func(v float64) *dto.Metric {
+		return &dto.Metric{Counter: &dto.Counter{Value: &v}}
+	}
+	count, sum := uint64(4), 2.0
+	histogram := &dto.Metric{Histogram: &dto.Histogram{SampleCount: &count, SampleSum: &sum}}
+
+	mustParse := func(expr string) *healthRule {
+		r, err := parseHealthRule(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	now := time.Now()
+
+	r := mustParse("value < 70")
+	if _, satisfied, ok := r.evaluate(gauge(69), nil, "", now); !ok || !satisfied {
+		t.Error("69 < 70 should be satisfied")
+	}
+	if _, satisfied, ok := r.evaluate(gauge(70), nil, "", now); !ok || satisfied {
+		t.Error("70 < 70 should not be satisfied")
+	}
+	if _, _, ok := r.evaluate(histogram, nil, "", now); ok {
+		t.Error("histogram does not have value")
+	}
+
+	if v, satisfied, ok := mustParse("mean <= 0.5").evaluate(histogram, nil, "", now); !ok || !satisfied || v != 0.5 {
+		t.Error("mean of histogram should be 0.5, actual", v)
+	}
+	if _, _, ok := mustParse("mean <= 0.5").evaluate(gauge(0), nil, "", now); ok {
+		t.Error("gauge does not have mean")
+	}
+
+	h := newSampleHistory(nil)
+	h.retention = time.Hour
+	r = mustParse("increase(1h) == 0")
+	if _, satisfied, ok := r.evaluate(counter(10), h, "k", now); !ok || !satisfied {
+		t.Error("a single sample should be regarded as satisfied")
+	}
+	if _, satisfied, _ := r.evaluate(counter(10), h, "k", now.Add(time.Minute)); !satisfied {
+		t.Error("the counter has not increased")
+	}
+	if v, satisfied, _ := r.evaluate(counter(12), h, "k", now.Add(2*time.Minute)); satisfied || v != 2 {
+		t.Error("the counter should have increased by 2, actual", v)
+	}
+	// The counter is reset and increased by 3.
+	if v, _, _ := r.evaluate(counter(3), h, "k", now.Add(3*time.Minute)); v != 5 {
+		t.Error("the increase over reset should be 5, actual", v)
+	}
+	// Samples older than the window are not used.
+	if v, _, _ := r.evaluate(counter(3), h, "k", now.Add(63*time.Minute)); v != 0 {
+		t.Error("the increase should be 0 in the last hour, actual", v)
+	}
+
+	h.gc(now.Add(63 * time.Minute))
+	if len(h.series["k/value"]) != 2 {
+		t.Error("old samples should be removed", h.series["k/value"])
+	}
+
+	h = newSampleHistory(nil)
+	r = mustParse("rate(count, 1h) < 0.01")
+	r.evaluate(histogram, h, "k", now)
+	count = 64
+	if v, satisfied, _ := r.evaluate(histogram, h, "k", now.Add(100*time.Second)); satisfied || v != 0.6 {
+		t.Error("the rate should be 0.6, actual", v)
+	}
+}
+
+func TestNewSampleHistory(t *testing.T) {
+	_, machineTypes, err := parseConfig(strings.NewReader(`
+machine-types:
+  - name: a
+    metrics:
+      - name: m1
+        healthy-if: rate(1h) < 1
+  - name: b
+    metrics:
+      - name: m2
+        healthy-if: increase(24h) == 0
+      - name: m3
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newSampleHistory(machineTypes)
+	if h.retention != 24*time.Hour {
+		t.Error("retention should be the longest window, actual", h.retention)
+	}
+}
@@ -32,6 +32,7 @@ type machine struct {
 	Serial   string
 	Type     string
 	IPv4Addr string
+	Rack     int
 	State    sabakan.MachineState
 }
 
@@ -48,6 +49,7 @@ type searchMachine struct {
 type spec struct {
 	Serial string   `json:"serial"`
 	Labels []label  `json:"labels"`
+	Rack   int      `json:"rack"`
 	IPv4   []string `json:"ipv4"`
 }
 
@@ -171,6 +173,7 @@ query search($having: MachineParams) {
         name
         value
       }
+      rack
       ipv4
     }
     status {
@@ -199,6 +202,7 @@ query search($having: MachineParams) {
 			Serial:   m.Spec.Serial,
 			Type:     findLabelValue(m.Spec.Labels, machineTypeLabelName),
 			IPv4Addr: m.Spec.IPv4[0],
+			Rack:     m.Spec.Rack,
 			State:    toMachineState(m.Status.State),
 		}
 	}
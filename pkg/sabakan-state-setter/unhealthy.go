@@ -0,0 +1,108 @@
+package sss
+
+import (
+	"context"
+	"time"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/storage"
+)
+
+// UnhealthyStore is interface for storing machines in the grace period of setting unhealthy state
+type UnhealthyStore interface {
+	PutUnhealthyMachine(ctx context.Context, m *neco.UnhealthyMachine, leaderKey string) error
+	GetUnhealthyMachines(ctx context.Context) ([]*neco.UnhealthyMachine, error)
+	DeleteUnhealthyMachine(ctx context.Context, serial, leaderKey string) error
+}
+
+var _ UnhealthyStore = storage.Storage{}
+
+// staleUnhealthyIntervals is the number of intervals after the longest grace period
+// to regard a stored grace period as stale.
+const staleUnhealthyIntervals = 3
+
+// loadUnhealthyMachines resumes the grace periods started by the former leader.
+// Stale grace periods, e.g. left while no leader was running, are not resumed
+// and are deleted at the next recordUnhealthyMachines.
+func (c *Controller) loadUnhealthyMachines(ctx context.Context, now time.Time) error {
+	machines, err := c.unhealthyStore.GetUnhealthyMachines(ctx)
+	if err != nil {
+		return err
+	}
+
+	var maxGracePeriod time.Duration
+	for _, mt := range c.machineTypes {
+		if mt.GracePeriod.Duration > maxGracePeriod {
+			maxGracePeriod = mt.GracePeriod.Duration
+		}
+	}
+	staleBefore := now.Add(-maxGracePeriod - staleUnhealthyIntervals*c.interval)
+
+	c.unhealthyMachines = make(map[string]time.Time, len(machines))
+	c.storedUnhealthyMachines = make(map[string]time.Time, len(machines))
+	var stale int
+	for _, m := range machines {
+		c.storedUnhealthyMachines[m.Serial] = m.Since
+		if m.Since.Before(staleBefore) {
+			stale++
+			continue
+		}
+		c.unhealthyMachines[m.Serial] = m.Since
+	}
+	if len(machines) > 0 {
+		log.Info("resume grace periods of unhealthy machines", map[string]interface{}{
+			"machines": len(machines) - stale,
+			"stale":    stale,
+		})
+	}
+	return nil
+}
+
+// recordUnhealthyMachines stores the changes of the machines in the grace period
+// since the last time in etcd.  Machines no longer registered in sabakan are removed.
+// Failed changes are retried at the next time.
+func (c *Controller) recordUnhealthyMachines(ctx context.Context, machines []*machine) {
+	exists := make(map[string]bool, len(machines))
+	for _, m := range machines {
+		exists[m.Serial] = true
+	}
+	for serial := range c.unhealthyMachines {
+		if !exists[serial] {
+			delete(c.unhealthyMachines, serial)
+		}
+	}
+
+	for serial, since := range c.unhealthyMachines {
+		if stored, ok := c.storedUnhealthyMachines[serial]; ok && stored.Equal(since) {
+			continue
+		}
+		err := c.unhealthyStore.PutUnhealthyMachine(ctx, &neco.UnhealthyMachine{
+			Serial: serial,
+			Since:  since.UTC(),
+		}, c.leaderKey)
+		if err != nil {
+			log.Warn("failed to store unhealthy machine", map[string]interface{}{
+				log.FnError: err.Error(),
+				"serial":    serial,
+			})
+			continue
+		}
+		c.storedUnhealthyMachines[serial] = since
+	}
+
+	for serial := range c.storedUnhealthyMachines {
+		if _, ok := c.unhealthyMachines[serial]; ok {
+			continue
+		}
+		err := c.unhealthyStore.DeleteUnhealthyMachine(ctx, serial, c.leaderKey)
+		if err != nil {
+			log.Warn("failed to delete unhealthy machine", map[string]interface{}{
+				log.FnError: err.Error(),
+				"serial":    serial,
+			})
+			continue
+		}
+		delete(c.storedUnhealthyMachines, serial)
+	}
+}
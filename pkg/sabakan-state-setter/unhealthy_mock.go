@@ -0,0 +1,45 @@
+package sss
+
+import (
+	"context"
+	"sync"
+
+	"github.com/cybozu-go/neco"
+)
+
+type unhealthyMockStore struct {
+	mu       sync.Mutex
+	machines map[string]*neco.UnhealthyMachine
+}
+
+var _ UnhealthyStore = &unhealthyMockStore{}
+
+func newMockUnhealthyStore() *unhealthyMockStore {
+	return &unhealthyMockStore{
+		machines: map[string]*neco.UnhealthyMachine{},
+	}
+}
+
+func (s *unhealthyMockStore) PutUnhealthyMachine(ctx context.Context, m *neco.UnhealthyMachine, leaderKey string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.machines[m.Serial] = m
+	return nil
+}
+
+func (s *unhealthyMockStore) GetUnhealthyMachines(ctx context.Context) ([]*neco.UnhealthyMachine, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	machines := make([]*neco.UnhealthyMachine, 0, len(s.machines))
+	for _, m := range s.machines {
+		machines = append(machines, m)
+	}
+	return machines, nil
+}
+
+func (s *unhealthyMockStore) DeleteUnhealthyMachine(ctx context.Context, serial, leaderKey string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.machines, serial)
+	return nil
+}
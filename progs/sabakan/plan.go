@@ -0,0 +1,88 @@
+package sabakan
+
+import (
+	"context"
+	"net/http"
+	"os"
+
+	"github.com/cybozu-go/neco"
+	sabac "github.com/cybozu-go/sabakan/v2/client"
+)
+
+// ContentsPlan represents the contents that UploadContents would upload.
+type ContentsPlan struct {
+	// OSImage is the version of the OS image to be uploaded, or empty if it is up to date.
+	OSImage string `json:"os_image,omitempty"`
+
+	// Assets are the names of container image assets to be uploaded.
+	Assets []string `json:"assets,omitempty"`
+
+	// WorkerAssets are the names of the assets for worker nodes.
+	// They are always uploaded; sabakan ignores unchanged ones.
+	WorkerAssets []string `json:"worker_assets,omitempty"`
+
+	// Ignitions are the roles whose ignitions are to be uploaded.
+	Ignitions []string `json:"ignitions,omitempty"`
+}
+
+// PlanContents returns the contents that UploadContents would upload for version
+// whose artifacts are artifacts.  It does not modify anything.
+func PlanContents(ctx context.Context, sabakanHTTP *http.Client, version string, artifacts *neco.ArtifactSet) (*ContentsPlan, error) {
+	c, err := sabac.NewClient(neco.SabakanLocalEndpoint, sabakanHTTP)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := new(ContentsPlan)
+
+	index, err := c.ImagesIndex(ctx, imageOS)
+	if err != nil {
+		return nil, err
+	}
+	osVersion := artifacts.OSImage.Version
+	if len(index) == 0 || index[len(index)-1].ID != osVersion {
+		plan.OSImage = osVersion
+	}
+
+	for _, name := range neco.SabakanImages {
+		img, err := artifacts.FindContainerImage(name)
+		if err != nil {
+			return nil, err
+		}
+		assetName := imageAssetName(img)
+		need, err := needAssetUpload(ctx, assetName, c)
+		if err != nil {
+			return nil, err
+		}
+		if need {
+			plan.Assets = append(plan.Assets, assetName)
+		}
+	}
+
+	files, err := os.ReadDir(neco.WorkerAssetsPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		plan.WorkerAssets = append(plan.WorkerAssets, file.Name())
+	}
+
+	roles, err := getInstalledRoles()
+	if err != nil {
+		return nil, err
+	}
+	for _, role := range roles {
+		need, err := needIgnitionUpdate(ctx, c, role, version)
+		if err != nil {
+			return nil, err
+		}
+		if need {
+			plan.Ignitions = append(plan.Ignitions, role)
+		}
+	}
+
+	return plan, nil
+}
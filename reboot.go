@@ -0,0 +1,88 @@
+package neco
+
+import "time"
+
+// RebootJobState is the state of a rolling reboot job.
+type RebootJobState string
+
+// Possible states of a rolling reboot job.
+const (
+	RebootJobRunning   = RebootJobState("running")
+	RebootJobPaused    = RebootJobState("paused")
+	RebootJobCancelled = RebootJobState("cancelled")
+	RebootJobCompleted = RebootJobState("completed")
+)
+
+// Finished returns true if the job will never reboot machines any more.
+func (s RebootJobState) Finished() bool {
+	return s == RebootJobCancelled || s == RebootJobCompleted
+}
+
+// RebootJob represents a rolling reboot of machines by "neco reboot-worker".
+type RebootJob struct {
+	State RebootJobState `json:"state"`
+
+	// MaxUnavailablePerRack is the maximum number of machines in a rack
+	// that are being rebooted or failed to reboot at the same time.
+	MaxUnavailablePerRack int `json:"max_unavailable_per_rack"`
+
+	// Timeout is the time limit for a machine to reboot and become healthy.
+	Timeout time.Duration `json:"timeout"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RebootMachineState is the state of a machine in a rolling reboot job.
+type RebootMachineState string
+
+// Possible states of a machine in a rolling reboot job.
+const (
+	RebootPending   = RebootMachineState("pending")
+	RebootRebooting = RebootMachineState("rebooting")
+	RebootDone      = RebootMachineState("done")
+	RebootSkipped   = RebootMachineState("skipped")
+	RebootFailed    = RebootMachineState("failed")
+)
+
+// RebootMachine represents a machine in a rolling reboot job.
+type RebootMachine struct {
+	Serial string `json:"serial"`
+	Rack   uint   `json:"rack"`
+	Node   string `json:"node"`
+	BMC    string `json:"bmc"`
+
+	// CKE is true if the machine is a node of the CKE cluster.
+	// Such machines are rebooted through the reboot queue of CKE.
+	CKE bool `json:"cke"`
+
+	State      RebootMachineState `json:"state"`
+	StartedAt  time.Time          `json:"started_at,omitempty"`
+	FinishedAt time.Time          `json:"finished_at,omitempty"`
+	Message    string             `json:"message,omitempty"`
+}
+
+// NextRebootMachines returns pending machines that can be rebooted now.
+// Machines being rebooted or failed to reboot are unavailable, and at most
+// maxUnavailable machines in a rack can be unavailable at the same time.
+// Pending machines are chosen in the order of machines.
+func NextRebootMachines(maxUnavailable int, machines []*RebootMachine) []*RebootMachine {
+	unavailable := make(map[uint]int)
+	for _, m := range machines {
+		if m.State == RebootRebooting || m.State == RebootFailed {
+			unavailable[m.Rack]++
+		}
+	}
+
+	var next []*RebootMachine
+	for _, m := range machines {
+		if m.State != RebootPending {
+			continue
+		}
+		if unavailable[m.Rack] >= maxUnavailable {
+			continue
+		}
+		unavailable[m.Rack]++
+		next = append(next, m)
+	}
+	return next
+}
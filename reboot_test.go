@@ -0,0 +1,49 @@
+package neco
+
+import (
+	"testing"
+)
+
+func TestNextRebootMachines(t *testing.T) {
+	machines := []*RebootMachine{
+		{Serial: "001", Rack: 0, State: RebootDone},
+		{Serial: "002", Rack: 0, State: RebootPending},
+		{Serial: "003", Rack: 0, State: RebootPending},
+		{Serial: "004", Rack: 1, State: RebootRebooting},
+		{Serial: "005", Rack: 1, State: RebootPending},
+		{Serial: "006", Rack: 2, State: RebootFailed},
+		{Serial: "007", Rack: 2, State: RebootPending},
+		{Serial: "008", Rack: 3, State: RebootSkipped},
+		{Serial: "009", Rack: 3, State: RebootPending},
+	}
+
+	serials := func(ms []*RebootMachine) []string {
+		var s []string
+		for _, m := range ms {
+			s = append(s, m.Serial)
+		}
+		return s
+	}
+
+	next := serials(NextRebootMachines(1, machines))
+	expected := []string{"002", "009"}
+	if len(next) != len(expected) {
+		t.Fatal("unexpected machines:", next)
+	}
+	for i := range expected {
+		if next[i] != expected[i] {
+			t.Error("unexpected machines:", next)
+		}
+	}
+
+	next = serials(NextRebootMachines(2, machines))
+	expected = []string{"002", "003", "005", "007", "009"}
+	if len(next) != len(expected) {
+		t.Fatal("unexpected machines:", next)
+	}
+	for i := range expected {
+		if next[i] != expected[i] {
+			t.Error("unexpected machines:", next)
+		}
+	}
+}
@@ -1,6 +1,11 @@
 package storage
 
-import "context"
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/cybozu-go/neco/bmc"
+)
 
 // PutBMCBMCUser stores bmc-user.json contents
 func (s Storage) PutBMCBMCUser(ctx context.Context, value string) error {
@@ -31,3 +36,28 @@ func (s Storage) PutBMCIPMIPassword(ctx context.Context, value string) error {
 func (s Storage) GetBMCIPMIPassword(ctx context.Context) (string, error) {
 	return s.get(ctx, KeyBMCIPMIPassword)
 }
+
+// PutBMCMachineTypes stores BMC configurations of machine types.
+func (s Storage) PutBMCMachineTypes(ctx context.Context, value map[string]bmc.MachineType) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return s.put(ctx, KeyBMCMachineTypes, string(data))
+}
+
+// GetBMCMachineTypes returns BMC configurations of machine types.
+// If not configured, this returns ErrNotFound.
+func (s Storage) GetBMCMachineTypes(ctx context.Context) (map[string]bmc.MachineType, error) {
+	data, err := s.get(ctx, KeyBMCMachineTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	var mts map[string]bmc.MachineType
+	err = json.Unmarshal([]byte(data), &mts)
+	if err != nil {
+		return nil, err
+	}
+	return mts, nil
+}
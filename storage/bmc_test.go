@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cybozu-go/neco/bmc"
+	"github.com/cybozu-go/neco/storage/test"
+	"github.com/google/go-cmp/cmp"
+	"github.com/stmcginnis/gofish/redfish"
+)
+
+func TestBMCMachineTypes(t *testing.T) {
+	t.Parallel()
+
+	etcd := test.NewEtcdClient(t)
+	defer etcd.Close()
+	ctx := context.Background()
+	st := NewStorage(etcd)
+
+	_, err := st.GetBMCMachineTypes(ctx)
+	if err != ErrNotFound {
+		t.Error("unexpected error", err)
+	}
+
+	mts := map[string]bmc.MachineType{
+		"r650-cs-1": {Driver: bmc.DellIDRACDriverName},
+		"dl360-cs-1": {
+			Driver:             bmc.RedfishDriverName,
+			TPMClearAttributes: redfish.BiosAttributes{"TpmOperation": "Clear"},
+		},
+	}
+	err = st.PutBMCMachineTypes(ctx, mts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := st.GetBMCMachineTypes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(got, mts) {
+		t.Error("unexpected machine types:", cmp.Diff(got, mts))
+	}
+}
@@ -2,16 +2,19 @@ package storage
 
 import (
 	"context"
+	"encoding/json"
 	"strconv"
 	"time"
 
 	"github.com/cybozu-go/neco"
+	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
 // Default values
 const (
 	DefaultCheckUpdateInterval = 1 * time.Minute
 	DefaultWorkerTimeout       = 60 * time.Minute
+	DefaultRolloutSoakPeriod   = 30 * time.Minute
 )
 
 // PutEnvConfig stores proxy config to storage.
@@ -39,6 +42,61 @@ func (s Storage) GetSlackNotification(ctx context.Context) (string, error) {
 	return s.get(ctx, KeyNotificationSlack)
 }
 
+// PutWebhookNotification stores the URL of a generic JSON webhook to storage.
+func (s Storage) PutWebhookNotification(ctx context.Context, url string) error {
+	return s.put(ctx, KeyNotificationWebhook, url)
+}
+
+// GetWebhookNotification returns the URL of a generic JSON webhook from storage.
+// If not found, this returns ErrNotFound.
+func (s Storage) GetWebhookNotification(ctx context.Context) (string, error) {
+	return s.get(ctx, KeyNotificationWebhook)
+}
+
+// PutEmailNotification stores the SMTP URL for email notification to storage.
+func (s Storage) PutEmailNotification(ctx context.Context, url string) error {
+	return s.put(ctx, KeyNotificationEmail, url)
+}
+
+// GetEmailNotification returns the SMTP URL for email notification from storage.
+// If not found, this returns ErrNotFound.
+func (s Storage) GetEmailNotification(ctx context.Context) (string, error) {
+	return s.get(ctx, KeyNotificationEmail)
+}
+
+// PutTeamsNotification stores Microsoft Teams WebHook URL to storage.
+func (s Storage) PutTeamsNotification(ctx context.Context, url string) error {
+	return s.put(ctx, KeyNotificationTeams, url)
+}
+
+// GetTeamsNotification returns Microsoft Teams WebHook URL from storage.
+// If not found, this returns ErrNotFound.
+func (s Storage) GetTeamsNotification(ctx context.Context) (string, error) {
+	return s.get(ctx, KeyNotificationTeams)
+}
+
+// PutCommandNotification stores the path of a local notification command to storage.
+func (s Storage) PutCommandNotification(ctx context.Context, path string) error {
+	return s.put(ctx, KeyNotificationCommand, path)
+}
+
+// GetCommandNotification returns the path of a local notification command from storage.
+// If not found, this returns ErrNotFound.
+func (s Storage) GetCommandNotification(ctx context.Context) (string, error) {
+	return s.get(ctx, KeyNotificationCommand)
+}
+
+// PutNotificationFilter stores the event filter of a notification backend to storage.
+func (s Storage) PutNotificationFilter(ctx context.Context, backend, filter string) error {
+	return s.put(ctx, keyNotificationFilter(backend), filter)
+}
+
+// GetNotificationFilter returns the event filter of a notification backend from storage.
+// If not found, this returns ErrNotFound.
+func (s Storage) GetNotificationFilter(ctx context.Context, backend string) (string, error) {
+	return s.get(ctx, keyNotificationFilter(backend))
+}
+
 // PutProxyConfig stores proxy config to storage.
 func (s Storage) PutProxyConfig(ctx context.Context, proxy string) error {
 	return s.put(ctx, KeyProxy, proxy)
@@ -115,6 +173,199 @@ func (s Storage) GetWorkerTimeout(ctx context.Context) (time.Duration, error) {
 	return time.Duration(i), nil
 }
 
+// PutRolloutCanary stores rollout-canary config to storage.
+// An empty lrns disables the canary rollout.
+func (s Storage) PutRolloutCanary(ctx context.Context, lrns []int) error {
+	if len(lrns) == 0 {
+		_, err := s.etcd.Delete(ctx, KeyRolloutCanary)
+		return err
+	}
+	data, err := json.Marshal(lrns)
+	if err != nil {
+		return err
+	}
+	return s.put(ctx, KeyRolloutCanary, string(data))
+}
+
+// GetRolloutCanary returns rollout-canary config from storage. It returns
+// nil if the key does not exist.
+func (s Storage) GetRolloutCanary(ctx context.Context) ([]int, error) {
+	data, err := s.get(ctx, KeyRolloutCanary)
+	if err == ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var lrns []int
+	err = json.Unmarshal([]byte(data), &lrns)
+	if err != nil {
+		return nil, err
+	}
+	return lrns, nil
+}
+
+// PutRolloutSoakPeriod stores rollout-soak-period config to storage.
+func (s Storage) PutRolloutSoakPeriod(ctx context.Context, d time.Duration) error {
+	data := strconv.FormatInt(int64(d), 10)
+	return s.put(ctx, KeyRolloutSoakPeriod, data)
+}
+
+// GetRolloutSoakPeriod returns rollout-soak-period config from storage. It
+// returns default value if the key does not exist.
+func (s Storage) GetRolloutSoakPeriod(ctx context.Context) (time.Duration, error) {
+	data, err := s.get(ctx, KeyRolloutSoakPeriod)
+	if err == ErrNotFound {
+		return DefaultRolloutSoakPeriod, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	i, err := strconv.ParseInt(data, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(i), nil
+}
+
+// PutAutoRollback stores auto-rollback config to storage.
+func (s Storage) PutAutoRollback(ctx context.Context, enabled bool) error {
+	return s.put(ctx, KeyAutoRollback, strconv.FormatBool(enabled))
+}
+
+// GetAutoRollback returns auto-rollback config from storage. It returns
+// false if the key does not exist.
+func (s Storage) GetAutoRollback(ctx context.Context) (bool, error) {
+	data, err := s.get(ctx, KeyAutoRollback)
+	if err == ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return strconv.ParseBool(data)
+}
+
+// PutUpdateFreeze freezes updates.
+func (s Storage) PutUpdateFreeze(ctx context.Context, freeze *neco.UpdateFreeze) error {
+	data, err := json.Marshal(freeze)
+	if err != nil {
+		return err
+	}
+	return s.put(ctx, KeyUpdateFreeze, string(data))
+}
+
+// GetUpdateFreeze returns the freeze of updates.
+// If updates are not frozen, this returns ErrNotFound.
+func (s Storage) GetUpdateFreeze(ctx context.Context) (*neco.UpdateFreeze, error) {
+	data, err := s.get(ctx, KeyUpdateFreeze)
+	if err != nil {
+		return nil, err
+	}
+	freeze := new(neco.UpdateFreeze)
+	err = json.Unmarshal([]byte(data), freeze)
+	if err != nil {
+		return nil, err
+	}
+	return freeze, nil
+}
+
+// DeleteUpdateFreeze unfreezes updates.
+// If updates are not frozen, this returns ErrNotFound.
+func (s Storage) DeleteUpdateFreeze(ctx context.Context) error {
+	resp, err := s.etcd.Delete(ctx, KeyUpdateFreeze)
+	if err != nil {
+		return err
+	}
+	if resp.Deleted == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// PutMaintenanceWindows stores maintenance windows to storage.
+func (s Storage) PutMaintenanceWindows(ctx context.Context, windows []neco.MaintenanceWindow) error {
+	data, err := json.Marshal(windows)
+	if err != nil {
+		return err
+	}
+	return s.put(ctx, KeyMaintenanceWindows, string(data))
+}
+
+// GetMaintenanceWindows returns maintenance windows from storage.
+// It returns nil if the key does not exist.
+func (s Storage) GetMaintenanceWindows(ctx context.Context) ([]neco.MaintenanceWindow, error) {
+	data, err := s.get(ctx, KeyMaintenanceWindows)
+	if err == ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var windows []neco.MaintenanceWindow
+	err = json.Unmarshal([]byte(data), &windows)
+	if err != nil {
+		return nil, err
+	}
+	return windows, nil
+}
+
+// PutUpdatePin pins the version to be updated to.
+func (s Storage) PutUpdatePin(ctx context.Context, version string) error {
+	return s.put(ctx, KeyUpdatePin, version)
+}
+
+// GetUpdatePin returns the pinned version.
+// If no version is pinned, this returns ErrNotFound.
+func (s Storage) GetUpdatePin(ctx context.Context) (string, error) {
+	return s.get(ctx, KeyUpdatePin)
+}
+
+// DeleteUpdatePin unpins the version.
+// If no version is pinned, this returns ErrNotFound.
+func (s Storage) DeleteUpdatePin(ctx context.Context) error {
+	resp, err := s.etcd.Delete(ctx, KeyUpdatePin)
+	if err != nil {
+		return err
+	}
+	if resp.Deleted == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// AddUpdateSkip adds a version not to be updated to.
+func (s Storage) AddUpdateSkip(ctx context.Context, version string) error {
+	return s.put(ctx, KeyUpdateSkipPrefix+version, "")
+}
+
+// DeleteUpdateSkip removes a version from the skipped versions.
+// If the version is not skipped, this returns ErrNotFound.
+func (s Storage) DeleteUpdateSkip(ctx context.Context, version string) error {
+	resp, err := s.etcd.Delete(ctx, KeyUpdateSkipPrefix+version)
+	if err != nil {
+		return err
+	}
+	if resp.Deleted == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetUpdateSkips returns the skipped versions.
+func (s Storage) GetUpdateSkips(ctx context.Context) ([]string, error) {
+	resp, err := s.etcd.Get(ctx, KeyUpdateSkipPrefix, clientv3.WithPrefix(), clientv3.WithKeysOnly())
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]string, len(resp.Kvs))
+	for i, kv := range resp.Kvs {
+		versions[i] = string(kv.Key[len(KeyUpdateSkipPrefix):])
+	}
+	return versions, nil
+}
+
 // PutGitHubToken stores github-token config to storage.
 func (s Storage) PutGitHubToken(ctx context.Context, token string) error {
 	return s.put(ctx, KeyGitHubToken, token)
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"reflect"
 	"testing"
 	"time"
 
@@ -67,6 +68,37 @@ func testSlackNotification(t *testing.T) {
 	}
 }
 
+func testNotificationFilter(t *testing.T) {
+	t.Parallel()
+
+	etcd := test.NewEtcdClient(t)
+	defer etcd.Close()
+	ctx := context.Background()
+	st := NewStorage(etcd)
+
+	_, err := st.GetNotificationFilter(ctx, "slack")
+	if err != ErrNotFound {
+		t.Error("notification filter should not be found")
+	}
+
+	err = st.PutNotificationFilter(ctx, "slack", "all")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filter, err := st.GetNotificationFilter(ctx, "slack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filter != "all" {
+		t.Error(`filter != "all"`, filter)
+	}
+	_, err = st.GetNotificationFilter(ctx, "email")
+	if err != ErrNotFound {
+		t.Error("notification filter of email should not be found")
+	}
+}
+
 func testProxyConfig(t *testing.T) {
 	t.Parallel()
 
@@ -197,11 +229,198 @@ func testWorkerTimeout(t *testing.T) {
 	}
 }
 
+func testRolloutConfig(t *testing.T) {
+	t.Parallel()
+
+	etcd := test.NewEtcdClient(t)
+	defer etcd.Close()
+	ctx := context.Background()
+	st := NewStorage(etcd)
+
+	lrns, err := st.GetRolloutCanary(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lrns != nil {
+		t.Error(`lrns != nil`, lrns)
+	}
+
+	err = st.PutRolloutCanary(ctx, []int{0, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lrns, err = st.GetRolloutCanary(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(lrns, []int{0, 2}) {
+		t.Error(`lrns != []int{0, 2}`, lrns)
+	}
+
+	err = st.PutRolloutCanary(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lrns, err = st.GetRolloutCanary(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lrns != nil {
+		t.Error(`lrns != nil`, lrns)
+	}
+
+	d, err := st.GetRolloutSoakPeriod(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d != DefaultRolloutSoakPeriod {
+		t.Error(`d != DefaultRolloutSoakPeriod`, d)
+	}
+
+	err = st.PutRolloutSoakPeriod(ctx, 2*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err = st.GetRolloutSoakPeriod(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d != 2*time.Hour {
+		t.Error(`d != 2*time.Hour`, d)
+	}
+}
+
+func testUpdateWindows(t *testing.T) {
+	t.Parallel()
+
+	etcd := test.NewEtcdClient(t)
+	defer etcd.Close()
+	ctx := context.Background()
+	st := NewStorage(etcd)
+
+	_, err := st.GetUpdateFreeze(ctx)
+	if err != ErrNotFound {
+		t.Error("updates should not be frozen", err)
+	}
+	freeze := &neco.UpdateFreeze{Reason: "year-end", FrozenAt: time.Now().UTC()}
+	err = st.PutUpdateFreeze(ctx, freeze)
+	if err != nil {
+		t.Fatal(err)
+	}
+	freeze2, err := st.GetUpdateFreeze(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(freeze, freeze2) {
+		t.Error("unexpected freeze", freeze2)
+	}
+	err = st.DeleteUpdateFreeze(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.DeleteUpdateFreeze(ctx)
+	if err != ErrNotFound {
+		t.Error("DeleteUpdateFreeze should return ErrNotFound", err)
+	}
+
+	windows, err := st.GetMaintenanceWindows(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if windows != nil {
+		t.Error("windows should be nil", windows)
+	}
+	expected := []neco.MaintenanceWindow{
+		{Name: "night", Schedule: "0 1 * * *", Duration: time.Hour, TimeZone: "Asia/Tokyo"},
+		{Name: "weekend", Schedule: "0 0 * * 6", Duration: 48 * time.Hour, Deny: true},
+	}
+	err = st.PutMaintenanceWindows(ctx, expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	windows, err = st.GetMaintenanceWindows(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(windows, expected) {
+		t.Error("unexpected windows", windows)
+	}
+}
+
+func testUpdatePin(t *testing.T) {
+	t.Parallel()
+
+	etcd := test.NewEtcdClient(t)
+	defer etcd.Close()
+	ctx := context.Background()
+	st := NewStorage(etcd)
+
+	_, err := st.GetUpdatePin(ctx)
+	if err != ErrNotFound {
+		t.Error("no version should be pinned", err)
+	}
+	err = st.PutUpdatePin(ctx, "1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pin, err := st.GetUpdatePin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pin != "1.0.0" {
+		t.Error(`pin != "1.0.0"`, pin)
+	}
+	err = st.DeleteUpdatePin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.DeleteUpdatePin(ctx)
+	if err != ErrNotFound {
+		t.Error("DeleteUpdatePin should return ErrNotFound", err)
+	}
+
+	err = st.AddUpdateSkip(ctx, "1.1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.AddUpdateSkip(ctx, "1.2.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	skips, err := st.GetUpdateSkips(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(skips, []string{"1.1.0", "1.2.0"}) {
+		t.Error("unexpected skipped versions", skips)
+	}
+	err = st.DeleteUpdateSkip(ctx, "1.1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.DeleteUpdateSkip(ctx, "1.1.0")
+	if err != ErrNotFound {
+		t.Error("DeleteUpdateSkip should return ErrNotFound", err)
+	}
+
+	snap, err := st.NewSnapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(snap.Skipped, []string{"1.2.0"}) {
+		t.Error("unexpected skipped versions in snapshot", snap.Skipped)
+	}
+}
+
 func TestConfig(t *testing.T) {
 	t.Run("EnvConfig", testEnvConfig)
 	t.Run("SlackNotification", testSlackNotification)
+	t.Run("NotificationFilter", testNotificationFilter)
 	t.Run("ProxyConfig", testProxyConfig)
 	t.Run("Quay", testQuay)
 	t.Run("CheckUpdateIntervalConfig", testCheckUpdateIntervalConfig)
 	t.Run("WorkerTimeout", testWorkerTimeout)
+	t.Run("RolloutConfig", testRolloutConfig)
+	t.Run("UpdateWindows", testUpdateWindows)
+	t.Run("UpdatePin", testUpdatePin)
 }
@@ -1,6 +1,11 @@
 package storage
 
-import "context"
+import (
+	"context"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/clientv3util"
+)
 
 func (s Storage) get(ctx context.Context, key string) (string, error) {
 	resp, err := s.etcd.Get(ctx, key)
@@ -22,3 +27,19 @@ func (s Storage) del(ctx context.Context, key string) error {
 	_, err := s.etcd.Delete(ctx, key)
 	return err
 }
+
+func (s Storage) leaderTxn(ctx context.Context, leaderKey string, op clientv3.Op) error {
+	resp, err := s.etcd.Txn(ctx).
+		If(clientv3util.KeyExists(leaderKey)).
+		Then(op).
+		Commit()
+	if err != nil {
+		return err
+	}
+
+	if !resp.Succeeded {
+		return ErrNoLeader
+	}
+
+	return nil
+}
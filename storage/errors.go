@@ -14,4 +14,7 @@ var (
 
 	// ErrTimedOut is returned when the request is timed out.
 	ErrTimedOut = errors.New("timed out")
+
+	// ErrConflict is returned when a key was modified concurrently.
+	ErrConflict = errors.New("conflict")
 )
@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/cybozu-go/neco"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/clientv3util"
+)
+
+// CreateFirmwareCampaign stores a new firmware campaign and its machines.
+// If a campaign of the same name exists, this returns ErrConflict.
+func (s Storage) CreateFirmwareCampaign(ctx context.Context, c *neco.FirmwareCampaign, machines []*neco.FirmwareMachine) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	ops := []clientv3.Op{clientv3.OpPut(keyFirmwareCampaign(c.Name), string(data))}
+	for _, m := range machines {
+		data, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		ops = append(ops, clientv3.OpPut(keyFirmwareMachine(c.Name, m.Serial), string(data)))
+	}
+
+	resp, err := s.etcd.Txn(ctx).
+		If(clientv3util.KeyMissing(keyFirmwareCampaign(c.Name))).
+		Then(ops...).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return ErrConflict
+	}
+	return nil
+}
+
+// GetFirmwareCampaign returns the firmware campaign of the name.
+// If not found, this returns ErrNotFound.
+func (s Storage) GetFirmwareCampaign(ctx context.Context, name string) (*neco.FirmwareCampaign, error) {
+	data, err := s.get(ctx, keyFirmwareCampaign(name))
+	if err != nil {
+		return nil, err
+	}
+
+	c := new(neco.FirmwareCampaign)
+	err = json.Unmarshal([]byte(data), c)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// GetFirmwareCampaigns returns all firmware campaigns sorted by creation time.
+func (s Storage) GetFirmwareCampaigns(ctx context.Context) ([]*neco.FirmwareCampaign, error) {
+	resp, err := s.etcd.Get(ctx, KeyFirmwareCampaignPrefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+
+	campaigns := make([]*neco.FirmwareCampaign, 0, resp.Count)
+	for _, kv := range resp.Kvs {
+		c := new(neco.FirmwareCampaign)
+		err = json.Unmarshal(kv.Value, c)
+		if err != nil {
+			return nil, err
+		}
+		campaigns = append(campaigns, c)
+	}
+	sort.SliceStable(campaigns, func(i, j int) bool {
+		return campaigns[i].CreatedAt.Before(campaigns[j].CreatedAt)
+	})
+	return campaigns, nil
+}
+
+// GetFirmwareMachines returns the machines of a firmware campaign
+// sorted by their serials.
+func (s Storage) GetFirmwareMachines(ctx context.Context, campaign string) ([]*neco.FirmwareMachine, error) {
+	resp, err := s.etcd.Get(ctx, keyFirmwareMachines(campaign), clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+
+	machines := make([]*neco.FirmwareMachine, 0, resp.Count)
+	for _, kv := range resp.Kvs {
+		m := new(neco.FirmwareMachine)
+		err = json.Unmarshal(kv.Value, m)
+		if err != nil {
+			return nil, err
+		}
+		machines = append(machines, m)
+	}
+	sort.Slice(machines, func(i, j int) bool {
+		return machines[i].Serial < machines[j].Serial
+	})
+	return machines, nil
+}
+
+// PutFirmwareMachine stores the state of a machine in a firmware campaign.
+func (s Storage) PutFirmwareMachine(ctx context.Context, campaign string, m *neco.FirmwareMachine) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	_, err = s.etcd.Put(ctx, keyFirmwareMachine(campaign, m.Serial), string(data))
+	return err
+}
@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/storage/test"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFirmwareCampaign(t *testing.T) {
+	t.Parallel()
+
+	etcd := test.NewEtcdClient(t)
+	defer etcd.Close()
+	ctx := context.Background()
+	st := NewStorage(etcd)
+
+	_, err := st.GetFirmwareCampaign(ctx, "bios")
+	if err != ErrNotFound {
+		t.Error("campaign should not be found", err)
+	}
+
+	now := time.Now().UTC()
+	c1 := &neco.FirmwareCampaign{
+		Name:      "bios",
+		Assets:    []string{"bios.exe"},
+		Expect:    map[string]string{"BIOS": "1.1.0"},
+		CreatedAt: now,
+	}
+	c2 := &neco.FirmwareCampaign{
+		Name:      "bmc",
+		Assets:    []string{"bmc.exe"},
+		CreatedAt: now.Add(-time.Hour),
+	}
+	machines := []*neco.FirmwareMachine{
+		{Serial: "002", Node: "10.0.0.2", State: neco.FirmwarePending},
+		{Serial: "001", Node: "10.0.0.1", State: neco.FirmwarePending},
+	}
+	err = st.CreateFirmwareCampaign(ctx, c1, machines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.CreateFirmwareCampaign(ctx, c1, nil)
+	if err != ErrConflict {
+		t.Error("campaign of the same name should not be created", err)
+	}
+	err = st.CreateFirmwareCampaign(ctx, c2, machines[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := st.GetFirmwareCampaign(ctx, "bios")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(got, c1) {
+		t.Error("unexpected campaign:", cmp.Diff(got, c1))
+	}
+	campaigns, err := st.GetFirmwareCampaigns(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(campaigns) != 2 || campaigns[0].Name != "bmc" {
+		t.Error("campaigns should be sorted by creation time:", campaigns)
+	}
+
+	m := *machines[1]
+	m.State = neco.FirmwareUploaded
+	m.Before = map[string]string{"Installed-BIOS": "1.0.0"}
+	err = st.PutFirmwareMachine(ctx, "bios", &m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotMachines, err := st.GetFirmwareMachines(ctx, "bios")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gotMachines) != 2 {
+		t.Fatal("unexpected machines:", gotMachines)
+	}
+	if !cmp.Equal(gotMachines[0], &m) {
+		t.Error("unexpected machine:", cmp.Diff(gotMachines[0], &m))
+	}
+	gotMachines, err = st.GetFirmwareMachines(ctx, "bmc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gotMachines) != 1 {
+		t.Error("machines of other campaigns should not be returned:", gotMachines)
+	}
+}
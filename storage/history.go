@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/cybozu-go/neco"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// PutStepHistory appends a record of an update step.
+// Records are immutable; this returns an error if the same record exists.
+func (s Storage) PutStepHistory(ctx context.Context, h *neco.StepHistory) error {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+
+	key := keyHistory(h.Version, h.LRN, h.StartedAt)
+	resp, err := s.etcd.Txn(ctx).
+		If(clientv3.Compare(clientv3.CreateRevision(key), "=", 0)).
+		Then(clientv3.OpPut(key, string(data))).
+		Commit()
+	if err != nil {
+		return err
+	}
+
+	if !resp.Succeeded {
+		return errors.New("step history already exists: " + key)
+	}
+
+	return nil
+}
+
+// GetStepHistories returns the records of update steps for a version.
+// The records are sorted by LRN and then by start time.
+func (s Storage) GetStepHistories(ctx context.Context, version string) ([]*neco.StepHistory, error) {
+	resp, err := s.etcd.Get(ctx, keyHistoryVersion(version), clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+
+	histories := make([]*neco.StepHistory, 0, resp.Count)
+	for _, kv := range resp.Kvs {
+		h := new(neco.StepHistory)
+		err = json.Unmarshal(kv.Value, h)
+		if err != nil {
+			return nil, err
+		}
+		histories = append(histories, h)
+	}
+
+	sort.SliceStable(histories, func(i, j int) bool {
+		if histories[i].LRN != histories[j].LRN {
+			return histories[i].LRN < histories[j].LRN
+		}
+		return histories[i].StartedAt.Before(histories[j].StartedAt)
+	})
+
+	return histories, nil
+}
+
+// PruneStepHistories deletes the records of update steps except for
+// the keep versions whose records were most recently created.
+func (s Storage) PruneStepHistories(ctx context.Context, keep int) error {
+	resp, err := s.etcd.Get(ctx, KeyHistoryPrefix, clientv3.WithPrefix(), clientv3.WithKeysOnly())
+	if err != nil {
+		return err
+	}
+
+	revs := make(map[string]int64)
+	for _, kv := range resp.Kvs {
+		version, _, _ := strings.Cut(strings.TrimPrefix(string(kv.Key), KeyHistoryPrefix), "/")
+		if kv.CreateRevision > revs[version] {
+			revs[version] = kv.CreateRevision
+		}
+	}
+	if len(revs) <= keep {
+		return nil
+	}
+
+	versions := make([]string, 0, len(revs))
+	for version := range revs {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return revs[versions[i]] > revs[versions[j]]
+	})
+
+	for _, version := range versions[keep:] {
+		_, err := s.etcd.Delete(ctx, keyHistoryVersion(version), clientv3.WithPrefix())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/storage/test"
+	"github.com/google/go-cmp/cmp"
+)
+
+func testStepHistory(t *testing.T) {
+	t.Parallel()
+
+	etcd := test.NewEtcdClient(t)
+	defer etcd.Close()
+	ctx := context.Background()
+	st := NewStorage(etcd)
+
+	histories, err := st.GetStepHistories(ctx, "1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(histories) != 0 {
+		t.Error("histories should be empty", histories)
+	}
+
+	now := time.Date(2022, 12, 1, 0, 0, 0, 0, time.UTC)
+	inputs := []*neco.StepHistory{
+		{Version: "1.0.0", LRN: 1, Step: 2, StartedAt: now.Add(time.Minute), EndedAt: now.Add(2 * time.Minute), Cond: neco.CondAbort, Message: "failed"},
+		{Version: "1.0.0", LRN: 0, Step: 1, StartedAt: now, EndedAt: now.Add(time.Second), Cond: neco.CondComplete},
+		{Version: "1.0.0", LRN: 1, Step: 1, StartedAt: now, EndedAt: now.Add(time.Minute), Cond: neco.CondComplete},
+		{Version: "1.0.0-1", LRN: 0, Step: 1, StartedAt: now, EndedAt: now, Cond: neco.CondComplete},
+	}
+	for _, h := range inputs {
+		err = st.PutStepHistory(ctx, h)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = st.PutStepHistory(ctx, inputs[0])
+	if err == nil {
+		t.Error("step history should be immutable")
+	}
+
+	histories, err = st.GetStepHistories(ctx, "1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []*neco.StepHistory{inputs[1], inputs[2], inputs[0]}
+	if !cmp.Equal(histories, expected) {
+		t.Error("unexpected histories", cmp.Diff(histories, expected))
+	}
+	if histories[2].Duration() != time.Minute {
+		t.Error("unexpected duration", histories[2].Duration())
+	}
+}
+
+func testPruneStepHistories(t *testing.T) {
+	t.Parallel()
+
+	etcd := test.NewEtcdClient(t)
+	defer etcd.Close()
+	ctx := context.Background()
+	st := NewStorage(etcd)
+
+	now := time.Date(2022, 12, 1, 0, 0, 0, 0, time.UTC)
+	inputs := []*neco.StepHistory{
+		{Version: "1.0.0", LRN: 0, Step: 1, StartedAt: now, EndedAt: now, Cond: neco.CondComplete},
+		{Version: "1.0.1", LRN: 0, Step: 1, StartedAt: now, EndedAt: now, Cond: neco.CondComplete},
+		{Version: "1.0.2", LRN: 0, Step: 1, StartedAt: now, EndedAt: now, Cond: neco.CondComplete},
+		// 1.0.0 is updated again after 1.0.2.
+		{Version: "1.0.0", LRN: 0, Step: 1, StartedAt: now.Add(time.Hour), EndedAt: now.Add(time.Hour), Cond: neco.CondComplete},
+	}
+	for _, h := range inputs {
+		err := st.PutStepHistory(ctx, h)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := st.PruneStepHistories(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]int{"1.0.0": 2, "1.0.1": 0, "1.0.2": 1}
+	for version, n := range expected {
+		histories, err := st.GetStepHistories(ctx, version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(histories) != n {
+			t.Errorf("unexpected number of histories for %s: %d", version, len(histories))
+		}
+	}
+}
+
+func TestHistory(t *testing.T) {
+	t.Run("StepHistory", testStepHistory)
+	t.Run("PruneStepHistories", testPruneStepHistories)
+}
@@ -58,14 +58,28 @@ func (s Storage) GetNecoRelease(ctx context.Context) (string, error) {
 	return string(resp.Kvs[0].Value), nil
 }
 
-// WaitInfo waits for update of keys under `info/`
+// WaitInfo waits for update of keys under `info/`, the configurations
+// of updates under `config/update-` such as the pinned version,
+// `config/auto-rollback`, or keys under `rollback/`.
 func (s Storage) WaitInfo(ctx context.Context, rev int64) error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
 	ch := s.etcd.Watch(ctx, KeyInfoPrefix,
 		clientv3.WithPrefix(), clientv3.WithKeysOnly(), clientv3.WithRev(rev+1))
+	configCh := s.etcd.Watch(ctx, KeyUpdatePrefix,
+		clientv3.WithPrefix(), clientv3.WithKeysOnly(), clientv3.WithRev(rev+1))
+	autoRollbackCh := s.etcd.Watch(ctx, KeyAutoRollback,
+		clientv3.WithKeysOnly(), clientv3.WithRev(rev+1))
+	rollbackCh := s.etcd.Watch(ctx, KeyRollbackPrefix,
+		clientv3.WithPrefix(), clientv3.WithKeysOnly(), clientv3.WithRev(rev+1))
 
-	resp := <-ch
+	var resp clientv3.WatchResponse
+	select {
+	case resp = <-ch:
+	case resp = <-configCh:
+	case resp = <-autoRollbackCh:
+	case resp = <-rollbackCh:
+	}
 	return resp.Err()
 }
@@ -3,6 +3,7 @@ package storage
 import (
 	"fmt"
 	"strconv"
+	"time"
 )
 
 // etcd keys
@@ -10,6 +11,7 @@ const (
 	KeySabakanStateSetterLeader = "leader/sabakan-state-setter/"
 	KeyUpdaterLeader            = "leader/updater/"
 	KeyWorkerLeader             = "leader/worker/"
+	KeyRebootWorkerLeader       = "leader/reboot-worker/"
 	KeyInfoPrefix               = "info/"
 	KeyBootserversPrefix        = "info/bootservers/"
 	KeyNecoRelease              = "info/neco-release"
@@ -25,12 +27,25 @@ const (
 	KeyUserResourcesContents    = "contents/user-resources"
 	KeyConfigPrefix             = "config/"
 	KeyNotificationSlack        = "config/notification/slack"
+	KeyNotificationWebhook      = "config/notification/webhook"
+	KeyNotificationEmail        = "config/notification/email"
+	KeyNotificationTeams        = "config/notification/teams"
+	KeyNotificationCommand      = "config/notification/command"
+	KeyNotificationFilterPrefix = "config/notification/filter/"
 	KeyProxy                    = "config/proxy"
 	KeyQuayUsername             = "config/quay-username"
 	KeyQuayPassword             = "config/quay-password"
 	KeyEnv                      = "config/env"
 	KeyCheckUpdateInterval      = "config/check-update-interval"
 	KeyWorkerTimeout            = "config/worker-timeout"
+	KeyRolloutCanary            = "config/rollout-canary"
+	KeyRolloutSoakPeriod        = "config/rollout-soak-period"
+	KeyAutoRollback             = "config/auto-rollback"
+	KeyUpdateFreeze             = "config/update-freeze"
+	KeyMaintenanceWindows       = "config/maintenance-windows"
+	KeyUpdatePrefix             = "config/update-"
+	KeyUpdatePin                = "config/update-pin"
+	KeyUpdateSkipPrefix         = "config/update-skip/"
 	KeyGitHubToken              = "config/github-token"
 	KeyNodeProxy                = "config/node-proxy"
 	KeyExternalIPAddressBlock   = "config/external-ip-address-block"
@@ -40,14 +55,27 @@ const (
 	KeyVaultUnsealKey           = "vault-unseal-key"
 	KeyVaultRootToken           = "vault-root-token"
 	KeyFinishPrefix             = "finish/"
+	KeyHistoryPrefix            = "history/"
+	KeyRollbackPrefix           = "rollback/"
+	KeyLastCompleted            = "rollback/last-completed"
+	KeyRollback                 = "rollback/current"
+	KeyRebootPrefix             = "reboot/"
+	KeyRebootJob                = "reboot/job"
+	KeyRebootMachinePrefix      = "reboot/machines/"
+	KeyFirmwareCampaignPrefix   = "firmware/campaigns/"
+	KeyFirmwareMachinePrefix    = "firmware/machines/"
 	KeyContainersFormat         = "install/%d/containers/%s"
 	KeyDebsFormat               = "install/%d/debs/%s"
 	KeyInstallPrefix            = "install/"
 	KeyBMCBMCUser               = "bmc/bmc-user"
 	KeyBMCIPMIUser              = "bmc/ipmi-user"
 	KeyBMCIPMIPassword          = "bmc/ipmi-password"
+	KeyBMCMachineTypes          = "bmc/machine-types"
 	KeyTeleportAuthToken        = "teleport/auth-token"
 	KeyCKEWeight                = "cke/weight"
+	KeyMachineHealthPrefix      = "sabakan-state-setter/health/"
+	KeyTransitionBreaker        = "sabakan-state-setter/breaker"
+	KeyUnhealthyMachinePrefix   = "sabakan-state-setter/unhealthy/"
 )
 
 func keyBootServer(lrn int) string {
@@ -66,6 +94,42 @@ func keyFinish(lrn int) string {
 	return KeyFinishPrefix + strconv.Itoa(lrn)
 }
 
+func keyNotificationFilter(backend string) string {
+	return KeyNotificationFilterPrefix + backend
+}
+
+func keyHistoryVersion(version string) string {
+	return KeyHistoryPrefix + version + "/"
+}
+
+func keyHistory(version string, lrn int, startedAt time.Time) string {
+	return fmt.Sprintf("%s%d/%020d", keyHistoryVersion(version), lrn, startedAt.UnixNano())
+}
+
+func keyRebootMachine(serial string) string {
+	return KeyRebootMachinePrefix + serial
+}
+
+func keyMachineHealth(serial string) string {
+	return KeyMachineHealthPrefix + serial
+}
+
+func keyUnhealthyMachine(serial string) string {
+	return KeyUnhealthyMachinePrefix + serial
+}
+
+func keyFirmwareCampaign(name string) string {
+	return KeyFirmwareCampaignPrefix + name
+}
+
+func keyFirmwareMachines(campaign string) string {
+	return KeyFirmwareMachinePrefix + campaign + "/"
+}
+
+func keyFirmwareMachine(campaign, serial string) string {
+	return keyFirmwareMachines(campaign) + serial
+}
+
 func keyContainer(lrn int, name string) string {
 	return fmt.Sprintf(KeyContainersFormat, lrn, name)
 }
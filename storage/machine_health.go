@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/cybozu-go/neco"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// PutMachineHealth stores the latest health evaluation of a machine.
+// If the caller has lost the leadership, this returns ErrNoLeader.
+func (s Storage) PutMachineHealth(ctx context.Context, h *neco.MachineHealth, leaderKey string) error {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	return s.leaderTxn(ctx, leaderKey, clientv3.OpPut(keyMachineHealth(h.Serial), string(data)))
+}
+
+// GetMachineHealth returns the latest health evaluation of a machine.
+// If not found, this returns ErrNotFound.
+func (s Storage) GetMachineHealth(ctx context.Context, serial string) (*neco.MachineHealth, error) {
+	data, err := s.get(ctx, keyMachineHealth(serial))
+	if err != nil {
+		return nil, err
+	}
+
+	h := new(neco.MachineHealth)
+	err = json.Unmarshal([]byte(data), h)
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// GetMachineHealths returns the health evaluations of all machines.
+func (s Storage) GetMachineHealths(ctx context.Context) ([]*neco.MachineHealth, error) {
+	resp, err := s.etcd.Get(ctx, KeyMachineHealthPrefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+
+	healths := make([]*neco.MachineHealth, 0, resp.Count)
+	for _, kv := range resp.Kvs {
+		h := new(neco.MachineHealth)
+		err = json.Unmarshal(kv.Value, h)
+		if err != nil {
+			return nil, err
+		}
+		healths = append(healths, h)
+	}
+	return healths, nil
+}
+
+// DeleteMachineHealth removes the health evaluation of a machine.
+// If the caller has lost the leadership, this returns ErrNoLeader.
+func (s Storage) DeleteMachineHealth(ctx context.Context, serial, leaderKey string) error {
+	return s.leaderTxn(ctx, leaderKey, clientv3.OpDelete(keyMachineHealth(serial)))
+}
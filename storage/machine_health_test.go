@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/storage/test"
+	"github.com/google/go-cmp/cmp"
+	"go.etcd.io/etcd/client/v3/concurrency"
+)
+
+func TestMachineHealth(t *testing.T) {
+	t.Parallel()
+
+	etcd := test.NewEtcdClient(t)
+	defer etcd.Close()
+	ctx := context.Background()
+	st := NewStorage(etcd)
+
+	_, err := st.GetMachineHealth(ctx, "1234")
+	if err != ErrNotFound {
+		t.Error("unexpected error", err)
+	}
+
+	sess, err := concurrency.NewSession(etcd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	e := concurrency.NewElection(sess, KeySabakanStateSetterLeader)
+	err = e.Campaign(ctx, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaderKey := e.Key()
+
+	since := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	transition := since.Add(time.Hour)
+	value := 75.0
+	h := &neco.MachineHealth{
+		Serial:      "1234",
+		IPv4:        "10.69.0.4",
+		MachineType: "qemu",
+		State:       "healthy",
+		Candidate:   "unhealthy",
+		Reason:      "metric is not healthy",
+		SerfStatus:  "alive",
+		Checks: []neco.MachineHealthCheck{
+			{
+				Metric:   "hw_disk_temperature_celsius",
+				Rule:     "value < 70",
+				Severity: "unhealthy",
+				Matched:  1,
+				Failures: []neco.MachineHealthFailure{
+					{Labels: map[string]string{"device": "HDD.slot.1"}, Value: &value, Reason: "rule is not satisfied"},
+				},
+			},
+		},
+		UnhealthySince: &since,
+		GracePeriod:    time.Hour,
+		TransitionAt:   &transition,
+		EvaluatedAt:    since.Add(time.Minute),
+	}
+	err = st.PutMachineHealth(ctx, h, leaderKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := st.GetMachineHealth(ctx, "1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(got, h) {
+		t.Error("unexpected machine health:", cmp.Diff(got, h))
+	}
+
+	err = st.PutMachineHealth(ctx, &neco.MachineHealth{Serial: "5678", EvaluatedAt: since}, leaderKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	healths, err := st.GetMachineHealths(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(healths) != 2 || !cmp.Equal(healths[0], h) || healths[1].Serial != "5678" {
+		t.Error("unexpected machine healths:", healths)
+	}
+
+	err = st.DeleteMachineHealth(ctx, "1234", leaderKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = st.GetMachineHealth(ctx, "1234")
+	if err != ErrNotFound {
+		t.Error("unexpected error", err)
+	}
+
+	err = e.Resign(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = st.PutMachineHealth(ctx, h, leaderKey)
+	if err != ErrNoLeader {
+		t.Error("should lost leadership")
+	}
+	err = st.DeleteMachineHealth(ctx, "5678", leaderKey)
+	if err != ErrNoLeader {
+		t.Error("should lost leadership")
+	}
+	healths, err = st.GetMachineHealths(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(healths) != 1 || healths[0].Serial != "5678" {
+		t.Error("unexpected machine healths:", healths)
+	}
+}
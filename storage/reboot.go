@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/cybozu-go/neco"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/clientv3util"
+)
+
+func (s Storage) getRebootJob(ctx context.Context) (*neco.RebootJob, int64, error) {
+	resp, err := s.etcd.Get(ctx, KeyRebootJob)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.Count == 0 {
+		return nil, 0, ErrNotFound
+	}
+
+	job := new(neco.RebootJob)
+	err = json.Unmarshal(resp.Kvs[0].Value, job)
+	if err != nil {
+		return nil, 0, err
+	}
+	return job, resp.Kvs[0].ModRevision, nil
+}
+
+// GetRebootJob returns the rolling reboot job.
+// If not found, this returns ErrNotFound.
+func (s Storage) GetRebootJob(ctx context.Context) (*neco.RebootJob, error) {
+	job, _, err := s.getRebootJob(ctx)
+	return job, err
+}
+
+// CreateRebootJob replaces the rolling reboot job and its machines.
+// If the current job is not finished, or it is modified concurrently,
+// this returns ErrConflict.
+func (s Storage) CreateRebootJob(ctx context.Context, job *neco.RebootJob, machines []*neco.RebootMachine) error {
+	current, rev, err := s.getRebootJob(ctx)
+	switch err {
+	case nil:
+		if !current.State.Finished() {
+			return ErrConflict
+		}
+	case ErrNotFound:
+	default:
+		return err
+	}
+
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	ops := []clientv3.Op{clientv3.OpPut(KeyRebootJob, string(data))}
+	newKeys := make(map[string]bool)
+	for _, m := range machines {
+		data, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		key := keyRebootMachine(m.Serial)
+		newKeys[key] = true
+		ops = append(ops, clientv3.OpPut(key, string(data)))
+	}
+
+	// etcd does not allow to delete a range that overlaps with other
+	// operations in a transaction, so the machines are deleted one by one.
+	resp, err := s.etcd.Get(ctx, KeyRebootMachinePrefix, clientv3.WithPrefix(), clientv3.WithKeysOnly())
+	if err != nil {
+		return err
+	}
+	for _, kv := range resp.Kvs {
+		if !newKeys[string(kv.Key)] {
+			ops = append(ops, clientv3.OpDelete(string(kv.Key)))
+		}
+	}
+
+	txnResp, err := s.etcd.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(KeyRebootJob), "=", rev)).
+		Then(ops...).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !txnResp.Succeeded {
+		return ErrConflict
+	}
+	return nil
+}
+
+// UpdateRebootJob updates the rolling reboot job by calling f.
+// f is called again if the job is modified concurrently.
+// If f returns an error, this returns it without updating the job.
+// If not found, this returns ErrNotFound.
+func (s Storage) UpdateRebootJob(ctx context.Context, f func(*neco.RebootJob) error) error {
+	for {
+		job, rev, err := s.getRebootJob(ctx)
+		if err != nil {
+			return err
+		}
+		err = f(job)
+		if err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(job)
+		if err != nil {
+			return err
+		}
+		resp, err := s.etcd.Txn(ctx).
+			If(clientv3.Compare(clientv3.ModRevision(KeyRebootJob), "=", rev)).
+			Then(clientv3.OpPut(KeyRebootJob, string(data))).
+			Commit()
+		if err != nil {
+			return err
+		}
+		if resp.Succeeded {
+			return nil
+		}
+	}
+}
+
+// GetRebootMachines returns the machines of the rolling reboot job
+// sorted by their serials.
+func (s Storage) GetRebootMachines(ctx context.Context) ([]*neco.RebootMachine, error) {
+	resp, err := s.etcd.Get(ctx, KeyRebootMachinePrefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+
+	machines := make([]*neco.RebootMachine, 0, resp.Count)
+	for _, kv := range resp.Kvs {
+		m := new(neco.RebootMachine)
+		err = json.Unmarshal(kv.Value, m)
+		if err != nil {
+			return nil, err
+		}
+		machines = append(machines, m)
+	}
+	sort.Slice(machines, func(i, j int) bool {
+		return machines[i].Serial < machines[j].Serial
+	})
+	return machines, nil
+}
+
+// PutRebootMachine stores the state of a machine in the rolling reboot job.
+// leaderKey is the key of the process running the job.
+// If the caller has lost the leadership, this returns ErrNoLeader.
+func (s Storage) PutRebootMachine(ctx context.Context, m *neco.RebootMachine, leaderKey string) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.etcd.Txn(ctx).
+		If(clientv3util.KeyExists(leaderKey)).
+		Then(clientv3.OpPut(keyRebootMachine(m.Serial), string(data))).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return ErrNoLeader
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/storage/test"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRebootJob(t *testing.T) {
+	t.Parallel()
+
+	etcd := test.NewEtcdClient(t)
+	defer etcd.Close()
+	ctx := context.Background()
+	st := NewStorage(etcd)
+
+	_, err := st.GetRebootJob(ctx)
+	if err != ErrNotFound {
+		t.Error("reboot job should not be found", err)
+	}
+	err = st.UpdateRebootJob(ctx, func(job *neco.RebootJob) error { return nil })
+	if err != ErrNotFound {
+		t.Error("UpdateRebootJob should return ErrNotFound", err)
+	}
+
+	job := &neco.RebootJob{
+		State:                 neco.RebootJobRunning,
+		MaxUnavailablePerRack: 1,
+		Timeout:               time.Hour,
+		CreatedAt:             time.Now().UTC(),
+	}
+	machines := []*neco.RebootMachine{
+		{Serial: "002", Rack: 1, Node: "10.0.0.2", State: neco.RebootPending},
+		{Serial: "001", Rack: 0, Node: "10.0.0.1", CKE: true, State: neco.RebootPending},
+	}
+	err = st.CreateRebootJob(ctx, job, machines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.CreateRebootJob(ctx, job, nil)
+	if err != ErrConflict {
+		t.Error("unfinished job should not be replaced", err)
+	}
+
+	got, err := st.GetRebootJob(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(got, job) {
+		t.Error("unexpected job:", cmp.Diff(got, job))
+	}
+	gotMachines, err := st.GetRebootMachines(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gotMachines) != 2 || gotMachines[0].Serial != "001" || !gotMachines[0].CKE {
+		t.Error("unexpected machines:", gotMachines)
+	}
+
+	leaderKey := "test/leader"
+	m := *machines[0]
+	m.State = neco.RebootRebooting
+	err = st.PutRebootMachine(ctx, &m, leaderKey)
+	if err != ErrNoLeader {
+		t.Error("PutRebootMachine should fail without leadership", err)
+	}
+	_, err = etcd.Put(ctx, leaderKey, "aaa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.PutRebootMachine(ctx, &m, leaderKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotMachines, err = st.GetRebootMachines(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotMachines[1].State != neco.RebootRebooting {
+		t.Error("machine state should be updated:", gotMachines[1])
+	}
+
+	errInvalid := errors.New("invalid")
+	err = st.UpdateRebootJob(ctx, func(job *neco.RebootJob) error { return errInvalid })
+	if err != errInvalid {
+		t.Error("UpdateRebootJob should return the error of f", err)
+	}
+	err = st.UpdateRebootJob(ctx, func(job *neco.RebootJob) error {
+		job.State = neco.RebootJobCancelled
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = st.CreateRebootJob(ctx, job, machines[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotMachines, err = st.GetRebootMachines(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gotMachines) != 1 || gotMachines[0].State != neco.RebootPending {
+		t.Error("machines of the finished job should be replaced:", gotMachines)
+	}
+}
@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/cybozu-go/neco"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/clientv3util"
+)
+
+// PutLastCompleted stores the last version that completed successfully.
+// leaderKey is the current leader key.
+// If the caller has lost the leadership, this returns ErrNoLeader.
+func (s Storage) PutLastCompleted(ctx context.Context, version, leaderKey string) error {
+	resp, err := s.etcd.Txn(ctx).
+		If(clientv3util.KeyExists(leaderKey)).
+		Then(clientv3.OpPut(KeyLastCompleted, version)).
+		Commit()
+	if err != nil {
+		return err
+	}
+
+	if !resp.Succeeded {
+		return ErrNoLeader
+	}
+
+	return nil
+}
+
+// GetLastCompleted returns the last version that completed successfully.
+// If not found, this returns ErrNotFound.
+func (s Storage) GetLastCompleted(ctx context.Context) (string, error) {
+	return s.get(ctx, KeyLastCompleted)
+}
+
+// PutRollbackRequest stores UpdateRequest to roll back the failed update
+// together with RollbackRecord, and deletes worker statuses in a single
+// transaction.
+// leaderKey is the current leader key.
+// If the caller has lost the leadership, this returns ErrNoLeader.
+func (s Storage) PutRollbackRequest(ctx context.Context, req neco.UpdateRequest, rec *neco.RollbackRecord, leaderKey string) error {
+	reqData, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	recData, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.etcd.Txn(ctx).
+		If(clientv3util.KeyExists(leaderKey)).
+		Then(
+			clientv3.OpPut(KeyCurrent, string(reqData)),
+			clientv3.OpPut(KeyRollback, string(recData)),
+			clientv3.OpDelete(KeyWorkerStatusPrefix, clientv3.WithPrefix()),
+		).
+		Commit()
+	if err != nil {
+		return err
+	}
+
+	if !resp.Succeeded {
+		return ErrNoLeader
+	}
+
+	return nil
+}
+
+// GetRollback returns the record of the last automatic rollback.
+// If not found, this returns ErrNotFound.
+func (s Storage) GetRollback(ctx context.Context) (*neco.RollbackRecord, error) {
+	data, err := s.get(ctx, KeyRollback)
+	if err != nil {
+		return nil, err
+	}
+
+	rec := new(neco.RollbackRecord)
+	err = json.Unmarshal([]byte(data), rec)
+	if err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// DeleteRollback deletes the record of the last automatic rollback
+// to allow neco-updater to update to the failed version again.
+// If not found, this returns ErrNotFound.
+func (s Storage) DeleteRollback(ctx context.Context) error {
+	resp, err := s.etcd.Delete(ctx, KeyRollback)
+	if err != nil {
+		return err
+	}
+	if resp.Deleted == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
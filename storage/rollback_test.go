@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/storage/test"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRollback(t *testing.T) {
+	t.Parallel()
+
+	etcd := test.NewEtcdClient(t)
+	defer etcd.Close()
+	ctx := context.Background()
+	st := NewStorage(etcd)
+
+	leaderKey := "test/leader"
+	_, err := st.etcd.Put(ctx, leaderKey, "aaa")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = st.GetLastCompleted(ctx)
+	if err != ErrNotFound {
+		t.Error("last completed version should not be found", err)
+	}
+	err = st.PutLastCompleted(ctx, "1.0.0", leaderKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.PutLastCompleted(ctx, "1.0.0", "test/no-leader")
+	if err != ErrNoLeader {
+		t.Error("PutLastCompleted should fail without leadership", err)
+	}
+
+	err = st.PutStatus(ctx, 0, neco.UpdateStatus{Version: "1.1.0", Step: 2, Cond: neco.CondAbort})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := neco.UpdateRequest{
+		Version:   "1.0.0",
+		Servers:   []int{0, 1},
+		StartedAt: time.Now().UTC(),
+	}
+	rec := &neco.RollbackRecord{
+		Version:      "1.1.0",
+		Target:       "1.0.0",
+		RolledBackAt: time.Now().UTC(),
+	}
+	err = st.PutRollbackRequest(ctx, req, rec, leaderKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.PutAutoRollback(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snap, err := st.NewSnapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(*snap.Request, req) {
+		t.Error(`!cmp.Equal(*snap.Request, req)`, *snap.Request)
+	}
+	if len(snap.Statuses) != 0 {
+		t.Error("worker statuses should be deleted", snap.Statuses)
+	}
+	if !snap.AutoRollback {
+		t.Error("auto rollback should be enabled")
+	}
+	if snap.LastCompleted != "1.0.0" {
+		t.Error(`snap.LastCompleted != "1.0.0"`, snap.LastCompleted)
+	}
+	if !cmp.Equal(snap.Rollback, rec) {
+		t.Error(`!cmp.Equal(snap.Rollback, rec)`, snap.Rollback)
+	}
+
+	err = st.DeleteRollback(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = st.GetRollback(ctx)
+	if err != ErrNotFound {
+		t.Error("rollback record should be deleted", err)
+	}
+	err = st.DeleteRollback(ctx)
+	if err != ErrNotFound {
+		t.Error("DeleteRollback should return ErrNotFound", err)
+	}
+}
+
+func TestWaitInfoRollback(t *testing.T) {
+	t.Parallel()
+
+	etcd := test.NewEtcdClient(t)
+	defer etcd.Close()
+	ctx := context.Background()
+	st := NewStorage(etcd)
+
+	leaderKey := "test/leader"
+	_, err := st.etcd.Put(ctx, leaderKey, "aaa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := neco.UpdateRequest{
+		Version:   "1.0.0",
+		Servers:   []int{0},
+		StartedAt: time.Now().UTC(),
+	}
+	rec := &neco.RollbackRecord{
+		Version:      "1.1.0",
+		Target:       "1.0.0",
+		RolledBackAt: time.Now().UTC(),
+	}
+	err = st.PutRollbackRequest(ctx, req, rec, leaderKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waitAfter := func(f func() error) {
+		snap, err := st.NewSnapshot(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ch := make(chan error, 1)
+		go func() {
+			ch <- st.WaitInfo(ctx, snap.Revision)
+		}()
+		err = f()
+		if err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case err := <-ch:
+			if err != nil {
+				t.Error(err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("WaitInfo did not return")
+		}
+	}
+
+	waitAfter(func() error { return st.DeleteRollback(ctx) })
+	waitAfter(func() error { return st.PutAutoRollback(ctx, true) })
+}
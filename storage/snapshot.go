@@ -16,6 +16,37 @@ type Snapshot struct {
 	Statuses map[int]*neco.UpdateStatus
 	Latest   string
 	Servers  []int
+
+	// AutoRollback is true if neco-updater rolls back a failed update.
+	AutoRollback bool
+	// LastCompleted is the last version that completed successfully.
+	LastCompleted string
+	// Rollback is the record of the last automatic rollback, or nil.
+	Rollback *neco.RollbackRecord
+
+	// Freeze is non-nil if updates are frozen.
+	Freeze *neco.UpdateFreeze
+	// Windows are the maintenance windows for updates.
+	Windows []neco.MaintenanceWindow
+
+	// Pin is the version pinned by an operator, or empty.
+	Pin string
+	// Skipped are the versions skipped by an operator.
+	Skipped []string
+}
+
+// Target returns the version to be updated to.  This is the pinned
+// version if any, or the latest release unless it is skipped.
+func (ss *Snapshot) Target() string {
+	if ss.Pin != "" {
+		return ss.Pin
+	}
+	for _, v := range ss.Skipped {
+		if v == ss.Latest {
+			return ""
+		}
+	}
+	return ss.Latest
 }
 
 // NewSnapshot takes the up-to-date snapshot.
@@ -73,5 +104,75 @@ func (s Storage) NewSnapshot(ctx context.Context) (*Snapshot, error) {
 	}
 	snap.Statuses = statuses
 
+	resp, err = s.etcd.Get(ctx, KeyAutoRollback, clientv3.WithRev(rev))
+	if err != nil {
+		return nil, err
+	}
+	if resp.Count > 0 {
+		enabled, err := strconv.ParseBool(string(resp.Kvs[0].Value))
+		if err != nil {
+			return nil, err
+		}
+		snap.AutoRollback = enabled
+	}
+
+	resp, err = s.etcd.Get(ctx, KeyUpdateFreeze, clientv3.WithRev(rev))
+	if err != nil {
+		return nil, err
+	}
+	if resp.Count > 0 {
+		freeze := new(neco.UpdateFreeze)
+		err = json.Unmarshal(resp.Kvs[0].Value, freeze)
+		if err != nil {
+			return nil, err
+		}
+		snap.Freeze = freeze
+	}
+
+	resp, err = s.etcd.Get(ctx, KeyUpdatePin, clientv3.WithRev(rev))
+	if err != nil {
+		return nil, err
+	}
+	if resp.Count > 0 {
+		snap.Pin = string(resp.Kvs[0].Value)
+	}
+
+	resp, err = s.etcd.Get(ctx, KeyUpdateSkipPrefix, clientv3.WithPrefix(), clientv3.WithKeysOnly(), clientv3.WithRev(rev))
+	if err != nil {
+		return nil, err
+	}
+	for _, kv := range resp.Kvs {
+		snap.Skipped = append(snap.Skipped, string(kv.Key[len(KeyUpdateSkipPrefix):]))
+	}
+
+	resp, err = s.etcd.Get(ctx, KeyMaintenanceWindows, clientv3.WithRev(rev))
+	if err != nil {
+		return nil, err
+	}
+	if resp.Count > 0 {
+		err = json.Unmarshal(resp.Kvs[0].Value, &snap.Windows)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err = s.etcd.Get(ctx, KeyRollbackPrefix, clientv3.WithPrefix(), clientv3.WithRev(rev))
+	if err != nil {
+		return nil, err
+	}
+	for _, kv := range resp.Kvs {
+		switch string(kv.Key) {
+		case KeyLastCompleted:
+			snap.LastCompleted = string(kv.Value)
+		case KeyRollback:
+			rec := new(neco.RollbackRecord)
+			err = json.Unmarshal(kv.Value, rec)
+			if err != nil {
+				return nil, err
+			}
+			snap.Rollback = rec
+		}
+	}
+
 	return snap, nil
 }
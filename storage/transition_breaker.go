@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/cybozu-go/neco"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func (s Storage) getTransitionBreaker(ctx context.Context) (*neco.TransitionBreaker, int64, error) {
+	resp, err := s.etcd.Get(ctx, KeyTransitionBreaker)
+	if err != nil {
+		return nil, 0, err
+	}
+	b := new(neco.TransitionBreaker)
+	if resp.Count == 0 {
+		return b, 0, nil
+	}
+
+	err = json.Unmarshal(resp.Kvs[0].Value, b)
+	if err != nil {
+		return nil, 0, err
+	}
+	return b, resp.Kvs[0].ModRevision, nil
+}
+
+// GetTransitionBreaker returns the status of the circuit breaker of sabakan-state-setter.
+// If not stored, this returns the status of a breaker that has never tripped.
+func (s Storage) GetTransitionBreaker(ctx context.Context) (*neco.TransitionBreaker, error) {
+	b, _, err := s.getTransitionBreaker(ctx)
+	return b, err
+}
+
+// UpdateTransitionBreaker updates the status of the circuit breaker by calling f.
+// f is called again if the status is modified concurrently.
+// If f returns an error, this returns it without updating the status.
+func (s Storage) UpdateTransitionBreaker(ctx context.Context, f func(*neco.TransitionBreaker) error) error {
+	for {
+		b, rev, err := s.getTransitionBreaker(ctx)
+		if err != nil {
+			return err
+		}
+		err = f(b)
+		if err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(b)
+		if err != nil {
+			return err
+		}
+		resp, err := s.etcd.Txn(ctx).
+			If(clientv3.Compare(clientv3.ModRevision(KeyTransitionBreaker), "=", rev)).
+			Then(clientv3.OpPut(KeyTransitionBreaker, string(data))).
+			Commit()
+		if err != nil {
+			return err
+		}
+		if resp.Succeeded {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/storage/test"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTransitionBreaker(t *testing.T) {
+	t.Parallel()
+
+	etcd := test.NewEtcdClient(t)
+	defer etcd.Close()
+	ctx := context.Background()
+	st := NewStorage(etcd)
+
+	b, err := st.GetTransitionBreaker(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(b, &neco.TransitionBreaker{}) {
+		t.Error("breaker should not be tripped:", b)
+	}
+
+	trippedAt := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	expected := &neco.TransitionBreaker{
+		Tripped:   true,
+		Reason:    "30 machines would change state within 10m0s, more than 20",
+		TrippedAt: trippedAt,
+		Blocked:   []neco.BlockedTransition{{Serial: "1234", From: "healthy", To: "unreachable"}},
+		UpdatedAt: trippedAt,
+	}
+	err = st.UpdateTransitionBreaker(ctx, func(b *neco.TransitionBreaker) error {
+		*b = *expected
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err = st.GetTransitionBreaker(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(b, expected) {
+		t.Error("unexpected breaker:", cmp.Diff(b, expected))
+	}
+
+	errSkip := errors.New("skip")
+	err = st.UpdateTransitionBreaker(ctx, func(b *neco.TransitionBreaker) error {
+		b.Tripped = false
+		return errSkip
+	})
+	if err != errSkip {
+		t.Error("unexpected error:", err)
+	}
+
+	// Modify the status concurrently at the first call.
+	calls := 0
+	err = st.UpdateTransitionBreaker(ctx, func(b *neco.TransitionBreaker) error {
+		calls++
+		if calls == 1 {
+			err := st.UpdateTransitionBreaker(ctx, func(b *neco.TransitionBreaker) error {
+				b.Blocked = nil
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		b.Tripped = false
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Error("f should be called again on conflict:", calls)
+	}
+	b, err = st.GetTransitionBreaker(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Tripped || len(b.Blocked) != 0 {
+		t.Error("unexpected breaker:", b)
+	}
+}
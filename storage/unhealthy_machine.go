@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/cybozu-go/neco"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// PutUnhealthyMachine stores a machine in the grace period of setting unhealthy state.
+// If the caller has lost the leadership, this returns ErrNoLeader.
+func (s Storage) PutUnhealthyMachine(ctx context.Context, m *neco.UnhealthyMachine, leaderKey string) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return s.leaderTxn(ctx, leaderKey, clientv3.OpPut(keyUnhealthyMachine(m.Serial), string(data)))
+}
+
+// GetUnhealthyMachines returns all machines in the grace period of setting unhealthy state.
+func (s Storage) GetUnhealthyMachines(ctx context.Context) ([]*neco.UnhealthyMachine, error) {
+	resp, err := s.etcd.Get(ctx, KeyUnhealthyMachinePrefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+
+	machines := make([]*neco.UnhealthyMachine, 0, resp.Count)
+	for _, kv := range resp.Kvs {
+		m := new(neco.UnhealthyMachine)
+		err = json.Unmarshal(kv.Value, m)
+		if err != nil {
+			return nil, err
+		}
+		machines = append(machines, m)
+	}
+	return machines, nil
+}
+
+// DeleteUnhealthyMachine removes a machine from the grace period of setting unhealthy state.
+// If the caller has lost the leadership, this returns ErrNoLeader.
+func (s Storage) DeleteUnhealthyMachine(ctx context.Context, serial, leaderKey string) error {
+	return s.leaderTxn(ctx, leaderKey, clientv3.OpDelete(keyUnhealthyMachine(serial)))
+}
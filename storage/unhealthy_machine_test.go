@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/storage/test"
+	"github.com/google/go-cmp/cmp"
+	"go.etcd.io/etcd/client/v3/concurrency"
+)
+
+func TestUnhealthyMachine(t *testing.T) {
+	t.Parallel()
+
+	etcd := test.NewEtcdClient(t)
+	defer etcd.Close()
+	ctx := context.Background()
+	st := NewStorage(etcd)
+
+	machines, err := st.GetUnhealthyMachines(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(machines) != 0 {
+		t.Error("no machines should be stored:", machines)
+	}
+
+	sess, err := concurrency.NewSession(etcd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	e := concurrency.NewElection(sess, KeySabakanStateSetterLeader)
+	err = e.Campaign(ctx, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaderKey := e.Key()
+
+	since := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	expected := []*neco.UnhealthyMachine{
+		{Serial: "1234", Since: since},
+		{Serial: "5678", Since: since.Add(time.Minute)},
+	}
+	for _, m := range expected {
+		err = st.PutUnhealthyMachine(ctx, m, leaderKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	machines, err = st.GetUnhealthyMachines(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(machines, expected) {
+		t.Error("unexpected unhealthy machines:", cmp.Diff(machines, expected))
+	}
+
+	err = st.DeleteUnhealthyMachine(ctx, "1234", leaderKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	machines, err = st.GetUnhealthyMachines(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(machines, expected[1:]) {
+		t.Error("unexpected unhealthy machines:", cmp.Diff(machines, expected[1:]))
+	}
+
+	err = e.Resign(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = st.PutUnhealthyMachine(ctx, expected[0], leaderKey)
+	if err != ErrNoLeader {
+		t.Error("should lost leadership")
+	}
+	err = st.DeleteUnhealthyMachine(ctx, "5678", leaderKey)
+	if err != ErrNoLeader {
+		t.Error("should lost leadership")
+	}
+	machines, err = st.GetUnhealthyMachines(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(machines, expected[1:]) {
+		t.Error("unexpected unhealthy machines:", cmp.Diff(machines, expected[1:]))
+	}
+}
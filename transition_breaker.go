@@ -0,0 +1,32 @@
+package neco
+
+import "time"
+
+// TransitionBreaker is the status of the circuit breaker of sabakan-state-setter.
+// The breaker stops state transitions of machines to unhealthy or unreachable
+// when too many machines would change state in a short time.
+type TransitionBreaker struct {
+	Tripped   bool      `json:"tripped"`
+	Reason    string    `json:"reason,omitempty"`
+	TrippedAt time.Time `json:"tripped_at,omitempty"`
+
+	// Blocked are the transitions stopped by the breaker in the last evaluation.
+	Blocked []BlockedTransition `json:"blocked,omitempty"`
+
+	// BypassUntil disables the breaker until the time.
+	BypassUntil time.Time `json:"bypass_until,omitempty"`
+
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+}
+
+// BlockedTransition is a state transition of a machine stopped by the breaker.
+type BlockedTransition struct {
+	Serial string `json:"serial"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+}
+
+// Bypassed returns true if the breaker is disabled at now.
+func (b *TransitionBreaker) Bypassed(now time.Time) bool {
+	return now.Before(b.BypassUntil)
+}
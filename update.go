@@ -1,6 +1,10 @@
 package neco
 
-import "time"
+import (
+	"sort"
+	"strconv"
+	"time"
+)
 
 // Environments to use release or pre-release neco
 const (
@@ -12,11 +16,34 @@ const (
 )
 
 // UpdateRequest represents request from neco-updater
+//
+// Servers are all the boot servers in the cluster; they are used to
+// generate the configurations such as etcd membership.  The boot servers
+// that run the update are given by Targets.
 type UpdateRequest struct {
-	Version   string    `json:"version"`
-	Servers   []int     `json:"servers"`
-	Stop      bool      `json:"stop"`
-	StartedAt time.Time `json:"started_at"`
+	Version   string           `json:"version"`
+	Servers   []int            `json:"servers"`
+	Stop      bool             `json:"stop"`
+	StartedAt time.Time        `json:"started_at"`
+	Strategy  *RolloutStrategy `json:"strategy,omitempty"`
+}
+
+// Rollout stages.
+const (
+	StageCanary    = "canary"
+	StageRemaining = "remaining"
+)
+
+// RolloutStrategy represents a staged rollout of an update request.
+// The canary servers run the steps that change only themselves first,
+// then all servers run the whole update after the soak period.
+type RolloutStrategy struct {
+	Canary     []int         `json:"canary"`
+	SoakPeriod time.Duration `json:"soak_period"`
+	Stage      string        `json:"stage"`
+
+	// CanaryCompletedAt is the time when the canary servers completed the update.
+	CanaryCompletedAt time.Time `json:"canary_completed_at,omitempty"`
 }
 
 // IsMember returns true if a boot server is the member of this update request.
@@ -30,6 +57,44 @@ func (r UpdateRequest) IsMember(lrn int) bool {
 	return false
 }
 
+// InCanary returns true if the request is in the canary stage.
+func (r UpdateRequest) InCanary() bool {
+	return r.Strategy != nil && r.Strategy.Stage == StageCanary
+}
+
+// Targets returns the sorted LRNs of the boot servers that run the update
+// in the current stage.  Only the canary servers run it in the canary stage,
+// and all servers run it otherwise.
+func (r UpdateRequest) Targets() []int {
+	if !r.InCanary() {
+		return r.Servers
+	}
+
+	canary := make(map[int]bool)
+	for _, lrn := range r.Strategy.Canary {
+		canary[lrn] = true
+	}
+	targets := make([]int, 0, len(r.Servers))
+	for _, lrn := range r.Servers {
+		if canary[lrn] {
+			targets = append(targets, lrn)
+		}
+	}
+	sort.Ints(targets)
+	return targets
+}
+
+// IsTarget returns true if a boot server runs the update in the current stage.
+func (r UpdateRequest) IsTarget(lrn int) bool {
+	for _, n := range r.Targets() {
+		if n == lrn {
+			return true
+		}
+	}
+
+	return false
+}
+
 // UpdateCondition is the condition of the update process.
 type UpdateCondition int
 
@@ -59,10 +124,20 @@ func (c UpdateCondition) String() string {
 
 // UpdateStatus represents status report from neco-worker
 type UpdateStatus struct {
-	Version string          `json:"version"`
-	Step    int             `json:"step"`
-	Cond    UpdateCondition `json:"cond"`
-	Message string          `json:"message"`
+	Version  string          `json:"version"`
+	Step     int             `json:"step"`
+	StepName string          `json:"step_name,omitempty"`
+	Cond     UpdateCondition `json:"cond"`
+	Message  string          `json:"message"`
+}
+
+// StepLabel returns a human readable label of an update step.
+// It returns "NAME (STEP)" such as "update-etcd (2)", or just STEP if name is empty.
+func StepLabel(step int, name string) string {
+	if name == "" {
+		return strconv.Itoa(step)
+	}
+	return name + " (" + strconv.Itoa(step) + ")"
 }
 
 // UpdateCompleted returns true if the current update process has
@@ -86,6 +161,35 @@ func UpdateCompleted(version string, lrns []int, statuses map[int]*UpdateStatus)
 	return true
 }
 
+// StepHistory represents a record of an update step run by neco-worker.
+type StepHistory struct {
+	Version   string          `json:"version"`
+	LRN       int             `json:"lrn"`
+	Step      int             `json:"step"`
+	StepName  string          `json:"step_name,omitempty"`
+	StartedAt time.Time       `json:"started_at"`
+	EndedAt   time.Time       `json:"ended_at"`
+	Cond      UpdateCondition `json:"cond"`
+	Message   string          `json:"message,omitempty"`
+}
+
+// Duration returns the time taken to run the step.
+func (h StepHistory) Duration() time.Duration {
+	return h.EndedAt.Sub(h.StartedAt)
+}
+
+// RollbackRecord represents an automatic rollback by neco-updater.
+type RollbackRecord struct {
+	// Version is the version that failed to be updated.  neco-updater does
+	// not update to this version again until an operator clears the record.
+	Version string `json:"version"`
+
+	// Target is the version that was rolled back to.
+	Target string `json:"target"`
+
+	RolledBackAt time.Time `json:"rolled_back_at"`
+}
+
 // ContentsUpdateStatus represents update status of uploaded assets.
 type ContentsUpdateStatus struct {
 	Version string `json:"version"`
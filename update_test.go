@@ -30,3 +30,38 @@ func TestUpdateStatus(t *testing.T) {
 		t.Errorf("st != st2, %+v", st2)
 	}
 }
+
+func TestUpdateRequestTargets(t *testing.T) {
+	req := UpdateRequest{
+		Version: "1.2.3",
+		Servers: []int{0, 1, 2, 3},
+	}
+	if !cmp.Equal(req.Targets(), []int{0, 1, 2, 3}) {
+		t.Error("unexpected targets without strategy:", req.Targets())
+	}
+
+	req.Strategy = &RolloutStrategy{
+		Canary: []int{2, 5},
+		Stage:  StageCanary,
+	}
+	if !cmp.Equal(req.Targets(), []int{2}) {
+		t.Error("unexpected targets in canary stage:", req.Targets())
+	}
+	if !req.IsTarget(2) || req.IsTarget(0) || req.IsTarget(5) {
+		t.Error("unexpected IsTarget in canary stage")
+	}
+	if !req.InCanary() {
+		t.Error("request should be in canary stage")
+	}
+
+	req.Strategy.Stage = StageRemaining
+	if !cmp.Equal(req.Targets(), []int{0, 1, 2, 3}) {
+		t.Error("unexpected targets in remaining stage:", req.Targets())
+	}
+	if !req.IsTarget(2) || !req.IsTarget(3) {
+		t.Error("unexpected IsTarget in remaining stage")
+	}
+	if req.InCanary() {
+		t.Error("request should not be in canary stage")
+	}
+}
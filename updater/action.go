@@ -21,6 +21,12 @@ const (
 	ActionWaitWorkers
 	ActionStop
 	ActionWaitClear
+	ActionStartSoak
+	ActionWaitSoak
+	ActionExtend
+	ActionRecordCompleted
+	ActionRollback
+	ActionWaitWindow
 )
 
 func (a Action) String() string {
@@ -39,6 +45,18 @@ func (a Action) String() string {
 		return "request-stop"
 	case ActionWaitClear:
 		return "wait-for-user-recovery"
+	case ActionStartSoak:
+		return "start-soak"
+	case ActionWaitSoak:
+		return "wait-for-soak"
+	case ActionExtend:
+		return "extend-rollout"
+	case ActionRecordCompleted:
+		return "record-completed"
+	case ActionRollback:
+		return "rollback"
+	case ActionWaitWindow:
+		return "wait-for-window"
 	default:
 		panic("no such action")
 	}
@@ -46,19 +64,28 @@ func (a Action) String() string {
 
 // NextAction decides the next action to do for neco-updater.
 func NextAction(ss *storage.Snapshot, timeout time.Duration) (Action, error) {
-	if ss.Latest == "" {
+	if ss.Latest == "" && ss.Pin == "" {
 		return ActionWaitInfo, nil
 	}
-	latestVer, err := version.NewVersion(ss.Latest)
-	if err != nil {
-		return ActionError, err
-	}
+
+	// target is empty if the latest release is skipped.
+	target := ss.Target()
+
+	// the version that was rolled back is not updated again until
+	// an operator clears the rollback record or pins the version.
+	blocked := ss.Pin == "" && ss.Rollback != nil && ss.Rollback.Version == target
 
 	if ss.Request == nil {
-		return ActionNewVersion, nil
+		if target == "" || blocked {
+			return ActionWaitInfo, nil
+		}
+		return inWindow(ss, ActionNewVersion), nil
 	}
 
 	if ss.Request.Stop {
+		if canRollback(ss) {
+			return ActionRollback, nil
+		}
 		return ActionWaitClear, nil
 	}
 
@@ -73,25 +100,68 @@ func NextAction(ss *storage.Snapshot, timeout time.Duration) (Action, error) {
 		}
 	}
 
-	if !neco.UpdateCompleted(ss.Request.Version, ss.Request.Servers, ss.Statuses) {
+	if !neco.UpdateCompleted(ss.Request.Version, ss.Request.Targets(), ss.Statuses) {
 		if time.Since(ss.Request.StartedAt) > timeout {
 			return ActionStop, nil
 		}
 		return ActionWaitWorkers, nil
 	}
 
+	// extend the request to all servers after the canary servers
+	// are soaked.
+	if ss.Request.InCanary() {
+		strategy := ss.Request.Strategy
+		if strategy.CanaryCompletedAt.IsZero() {
+			return ActionStartSoak, nil
+		}
+		if time.Since(strategy.CanaryCompletedAt) < strategy.SoakPeriod {
+			return ActionWaitSoak, nil
+		}
+		return inWindow(ss, ActionExtend), nil
+	}
+
+	if ss.LastCompleted != ss.Request.Version {
+		return ActionRecordCompleted, nil
+	}
+
 	// reconfigure the new set of boot servers with unchanged neco package version.
 	if !reflect.DeepEqual(ss.Request.Servers, ss.Servers) {
 		return ActionReconfigure, nil
 	}
 
+	if target == "" || blocked {
+		return ActionWaitInfo, nil
+	}
+	targetVer, err := version.NewVersion(target)
+	if err != nil {
+		return ActionError, err
+	}
 	requestVer, err := version.NewVersion(ss.Request.Version)
 	if err != nil {
 		return ActionError, err
 	}
-	if !latestVer.Equal(requestVer) {
-		return ActionNewVersion, nil
+	if !targetVer.Equal(requestVer) {
+		return inWindow(ss, ActionNewVersion), nil
 	}
 
 	return ActionWaitInfo, nil
 }
+
+// inWindow returns action if an update may start now.
+// Otherwise, it returns ActionWaitWindow to defer the update.
+func inWindow(ss *storage.Snapshot, action Action) Action {
+	if neco.CheckUpdateWindow(time.Now(), ss.Freeze, ss.Windows) != "" {
+		return ActionWaitWindow
+	}
+	return action
+}
+
+// canRollback returns true if the stopped request should be rolled back
+// to the last completed version automatically.  Rollback is done only once
+// until an operator clears the rollback record.
+func canRollback(ss *storage.Snapshot) bool {
+	if !ss.AutoRollback || ss.Rollback != nil {
+		return false
+	}
+	return ss.LastCompleted != "" && ss.LastCompleted != ss.Request.Version
+}
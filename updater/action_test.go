@@ -30,6 +30,34 @@ func TestNextAction(t *testing.T) {
 		},
 	}
 
+	canaryReq := *req
+	canaryReq.Servers = []int{0, 1, 2}
+	canaryReq.Strategy = &neco.RolloutStrategy{
+		Canary:     []int{0, 1},
+		SoakPeriod: timeout,
+		Stage:      neco.StageCanary,
+	}
+	soakingReq := canaryReq
+	soakingReq.Strategy = &neco.RolloutStrategy{
+		Canary:            []int{0, 1},
+		SoakPeriod:        timeout,
+		Stage:             neco.StageCanary,
+		CanaryCompletedAt: time.Now(),
+	}
+	soakedReq := canaryReq
+	soakedReq.Strategy = &neco.RolloutStrategy{
+		Canary:            []int{0, 1},
+		SoakPeriod:        timeout,
+		Stage:             neco.StageCanary,
+		CanaryCompletedAt: time.Now().Add(-2 * timeout),
+	}
+	remainingReq := canaryReq
+	remainingReq.Strategy = &neco.RolloutStrategy{
+		Canary:     []int{0, 1},
+		SoakPeriod: timeout,
+		Stage:      neco.StageRemaining,
+	}
+
 	tests := []struct {
 		name string
 		ss   *storage.Snapshot
@@ -85,34 +113,289 @@ func TestNextAction(t *testing.T) {
 		},
 		{
 			name: "reconfigure",
+			ss: &storage.Snapshot{
+				Latest:        "1.0.0",
+				Request:       req,
+				Statuses:      statuses,
+				Servers:       []int{0, 1, 2},
+				LastCompleted: "1.0.0",
+			},
+			want: ActionReconfigure,
+		},
+		{
+			name: "update",
+			ss: &storage.Snapshot{
+				Latest:        "1.1.0",
+				Request:       req,
+				Statuses:      statuses,
+				Servers:       []int{0, 1},
+				LastCompleted: "1.0.0",
+			},
+			want: ActionNewVersion,
+		},
+		{
+			name: "completed",
+			ss: &storage.Snapshot{
+				Latest:        "1.0.0",
+				Request:       req,
+				Statuses:      statuses,
+				Servers:       []int{0, 1},
+				LastCompleted: "1.0.0",
+			},
+			want: ActionWaitInfo,
+		},
+		{
+			name: "canary-completed",
 			ss: &storage.Snapshot{
 				Latest:   "1.0.0",
-				Request:  req,
+				Request:  &canaryReq,
 				Statuses: statuses,
 				Servers:  []int{0, 1, 2},
 			},
-			want: ActionReconfigure,
+			want: ActionStartSoak,
 		},
 		{
-			name: "update",
+			name: "soaking",
 			ss: &storage.Snapshot{
 				Latest:   "1.1.0",
-				Request:  req,
+				Request:  &soakingReq,
 				Statuses: statuses,
-				Servers:  []int{0, 1},
+				Servers:  []int{0, 1, 2},
+			},
+			want: ActionWaitSoak,
+		},
+		{
+			name: "soaked",
+			ss: &storage.Snapshot{
+				Latest:   "1.0.0",
+				Request:  &soakedReq,
+				Statuses: statuses,
+				Servers:  []int{0, 1, 2},
+			},
+			want: ActionExtend,
+		},
+		{
+			name: "remaining-not-completed",
+			ss: &storage.Snapshot{
+				Latest:   "1.0.0",
+				Request:  &remainingReq,
+				Statuses: statuses,
+				Servers:  []int{0, 1, 2},
+			},
+			want: ActionWaitWorkers,
+		},
+		{
+			name: "record-completed",
+			ss: &storage.Snapshot{
+				Latest:        "1.1.0",
+				Request:       req,
+				Statuses:      statuses,
+				Servers:       []int{0, 1},
+				LastCompleted: "0.9.0",
+			},
+			want: ActionRecordCompleted,
+		},
+		{
+			name: "rollback",
+			ss: &storage.Snapshot{
+				Latest:        "1.1.0",
+				Request:       &neco.UpdateRequest{Version: "1.1.0", Stop: true},
+				AutoRollback:  true,
+				LastCompleted: "1.0.0",
+			},
+			want: ActionRollback,
+		},
+		{
+			name: "rollback-disabled",
+			ss: &storage.Snapshot{
+				Latest:        "1.1.0",
+				Request:       &neco.UpdateRequest{Version: "1.1.0", Stop: true},
+				LastCompleted: "1.0.0",
+			},
+			want: ActionWaitClear,
+		},
+		{
+			name: "rollback-failed",
+			ss: &storage.Snapshot{
+				Latest:        "1.1.0",
+				Request:       &neco.UpdateRequest{Version: "1.0.0", Stop: true},
+				AutoRollback:  true,
+				LastCompleted: "1.0.0",
+				Rollback:      &neco.RollbackRecord{Version: "1.1.0", Target: "1.0.0"},
+			},
+			want: ActionWaitClear,
+		},
+		{
+			name: "rollback-no-completed",
+			ss: &storage.Snapshot{
+				Latest:       "1.1.0",
+				Request:      &neco.UpdateRequest{Version: "1.1.0", Stop: true},
+				AutoRollback: true,
+			},
+			want: ActionWaitClear,
+		},
+		{
+			name: "rolled-back",
+			ss: &storage.Snapshot{
+				Latest:        "1.1.0",
+				Request:       req,
+				Statuses:      statuses,
+				Servers:       []int{0, 1},
+				LastCompleted: "1.0.0",
+				Rollback:      &neco.RollbackRecord{Version: "1.1.0", Target: "1.0.0"},
+			},
+			want: ActionWaitInfo,
+		},
+		{
+			name: "rolled-back-newer-release",
+			ss: &storage.Snapshot{
+				Latest:        "1.2.0",
+				Request:       req,
+				Statuses:      statuses,
+				Servers:       []int{0, 1},
+				LastCompleted: "1.0.0",
+				Rollback:      &neco.RollbackRecord{Version: "1.1.0", Target: "1.0.0"},
 			},
 			want: ActionNewVersion,
 		},
 		{
-			name: "completed",
+			name: "rolled-back-recovered",
+			ss: &storage.Snapshot{
+				Latest:   "1.1.0",
+				Rollback: &neco.RollbackRecord{Version: "1.1.0", Target: "1.0.0"},
+			},
+			want: ActionWaitInfo,
+		},
+		{
+			name: "frozen",
+			ss: &storage.Snapshot{
+				Latest:        "1.1.0",
+				Request:       req,
+				Statuses:      statuses,
+				Servers:       []int{0, 1},
+				LastCompleted: "1.0.0",
+				Freeze:        &neco.UpdateFreeze{Reason: "test"},
+			},
+			want: ActionWaitWindow,
+		},
+		{
+			name: "frozen-recover",
+			ss: &storage.Snapshot{
+				Latest: "1.0.0",
+				Freeze: &neco.UpdateFreeze{Reason: "test"},
+			},
+			want: ActionWaitWindow,
+		},
+		{
+			name: "frozen-soaked",
 			ss: &storage.Snapshot{
 				Latest:   "1.0.0",
-				Request:  req,
+				Request:  &soakedReq,
 				Statuses: statuses,
-				Servers:  []int{0, 1},
+				Servers:  []int{0, 1, 2},
+				Freeze:   &neco.UpdateFreeze{Reason: "test"},
+			},
+			want: ActionWaitWindow,
+		},
+		{
+			name: "deny-window",
+			ss: &storage.Snapshot{
+				Latest:        "1.1.0",
+				Request:       req,
+				Statuses:      statuses,
+				Servers:       []int{0, 1},
+				LastCompleted: "1.0.0",
+				Windows: []neco.MaintenanceWindow{
+					{Name: "always", Schedule: "* * * * *", Duration: 2 * time.Minute, Deny: true},
+				},
+			},
+			want: ActionWaitWindow,
+		},
+		{
+			name: "frozen-completed",
+			ss: &storage.Snapshot{
+				Latest:        "1.0.0",
+				Request:       req,
+				Statuses:      statuses,
+				Servers:       []int{0, 1},
+				LastCompleted: "1.0.0",
+				Freeze:        &neco.UpdateFreeze{Reason: "test"},
 			},
 			want: ActionWaitInfo,
 		},
+		{
+			name: "pinned",
+			ss: &storage.Snapshot{
+				Latest:        "1.1.0",
+				Request:       req,
+				Statuses:      statuses,
+				Servers:       []int{0, 1},
+				LastCompleted: "1.0.0",
+				Pin:           "1.0.0",
+			},
+			want: ActionWaitInfo,
+		},
+		{
+			name: "pinned-older",
+			ss: &storage.Snapshot{
+				Latest:        "1.1.0",
+				Request:       req,
+				Statuses:      statuses,
+				Servers:       []int{0, 1},
+				LastCompleted: "1.0.0",
+				Pin:           "0.9.0",
+			},
+			want: ActionNewVersion,
+		},
+		{
+			name: "pinned-no-latest",
+			ss: &storage.Snapshot{
+				Pin: "1.0.0",
+			},
+			want: ActionNewVersion,
+		},
+		{
+			name: "pinned-rolled-back",
+			ss: &storage.Snapshot{
+				Latest:        "1.1.0",
+				Request:       req,
+				Statuses:      statuses,
+				Servers:       []int{0, 1},
+				LastCompleted: "1.0.0",
+				Rollback:      &neco.RollbackRecord{Version: "1.1.0", Target: "1.0.0"},
+				Pin:           "1.1.0",
+			},
+			want: ActionNewVersion,
+		},
+		{
+			name: "skipped",
+			ss: &storage.Snapshot{
+				Latest:        "1.1.0",
+				Request:       req,
+				Statuses:      statuses,
+				Servers:       []int{0, 1},
+				LastCompleted: "1.0.0",
+				Skipped:       []string{"1.1.0"},
+			},
+			want: ActionWaitInfo,
+		},
+		{
+			name: "skipped-recover",
+			ss: &storage.Snapshot{
+				Latest:  "1.1.0",
+				Skipped: []string{"1.1.0"},
+			},
+			want: ActionWaitInfo,
+		},
+		{
+			name: "skipped-running",
+			ss: &storage.Snapshot{
+				Latest:  "1.0.0",
+				Request: req,
+				Skipped: []string{"1.0.0"},
+			},
+			want: ActionWaitWorkers,
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,53 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const healthCheckTimeout = 30 * time.Second
+
+// checkCanaryHealth checks the health of the canary boot servers before
+// extending the update to all boot servers.
+//
+// Every canary boot server must be an etcd member that responds without
+// errors, and the etcd cluster must have a leader.
+func checkCanaryHealth(ctx context.Context, ec *clientv3.Client, lrns []int) error {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	mlr, err := ec.MemberList(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, lrn := range lrns {
+		name := fmt.Sprintf("boot-%d", lrn)
+		var endpoint string
+		for _, member := range mlr.Members {
+			if member.Name == name && len(member.ClientURLs) > 0 {
+				endpoint = member.ClientURLs[0]
+				break
+			}
+		}
+		if endpoint == "" {
+			return fmt.Errorf("boot server %d is not an etcd member", lrn)
+		}
+
+		resp, err := ec.Status(ctx, endpoint)
+		if err != nil {
+			return fmt.Errorf("etcd on boot server %d is unhealthy: %w", lrn, err)
+		}
+		if len(resp.Errors) > 0 {
+			return fmt.Errorf("etcd on boot server %d reports errors: %v", lrn, resp.Errors)
+		}
+		if resp.Leader == 0 {
+			return fmt.Errorf("etcd on boot server %d has no leader", lrn)
+		}
+	}
+
+	return nil
+}
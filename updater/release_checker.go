@@ -7,6 +7,7 @@ import (
 
 	"github.com/cybozu-go/log"
 	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/metrics"
 	"github.com/cybozu-go/neco/storage"
 	"github.com/google/go-github/v48/github"
 )
@@ -92,6 +93,7 @@ func (c *ReleaseChecker) update(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	metrics.UpdaterLastReleaseCheck.SetToCurrentTime()
 
 	if latest == c.current {
 		return nil
@@ -115,10 +117,33 @@ func (c *ReleaseChecker) update(ctx context.Context) error {
 		return nil
 	}
 
+	skips, err := c.storage.GetUpdateSkips(ctx)
+	if err != nil {
+		return err
+	}
+	for _, v := range skips {
+		if v == latest {
+			log.Info("ignored skipped neco release", map[string]interface{}{
+				"version": latest,
+			})
+			return nil
+		}
+	}
+
 	c.current = latest
-	log.Info("found a new neco release", map[string]interface{}{
+	fields := map[string]interface{}{
 		"version": latest,
-	})
+	}
+	pin, err := c.storage.GetUpdatePin(ctx)
+	switch err {
+	case nil:
+		// the release is recorded, but neco-updater keeps the pinned version.
+		fields["pinned"] = pin
+	case storage.ErrNotFound:
+	default:
+		return err
+	}
+	log.Info("found a new neco release", fields)
 
 	return c.storage.UpdateNecoRelease(ctx, latest, c.leaderKey)
 }
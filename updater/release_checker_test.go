@@ -0,0 +1,60 @@
+package updater
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cybozu-go/neco/storage"
+	"github.com/cybozu-go/neco/storage/test"
+)
+
+func TestReleaseCheckerSkip(t *testing.T) {
+	t.Parallel()
+
+	ec := test.NewEtcdClient(t)
+	defer ec.Close()
+	ctx := context.Background()
+	st := storage.NewStorage(ec)
+
+	leaderKey := "test/leader"
+	_, err := ec.Put(ctx, leaderKey, "aaa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.AddUpdateSkip(ctx, "2023.01.02-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	latest := "2023.01.02-2"
+	c := NewReleaseChecker(st, leaderKey, nil)
+	c.current = "2023.01.01-1"
+	c.check = func(ctx context.Context) (string, error) {
+		return latest, nil
+	}
+
+	err = c.update(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := st.GetNecoRelease(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current != "" {
+		t.Error("skipped release should not be recorded:", current)
+	}
+
+	latest = "2023.01.03-3"
+	err = c.update(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err = st.GetNecoRelease(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current != latest {
+		t.Error("new release should be recorded:", current)
+	}
+}
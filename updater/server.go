@@ -10,11 +10,19 @@ import (
 	"github.com/cybozu-go/log"
 	"github.com/cybozu-go/neco"
 	"github.com/cybozu-go/neco/ext"
+	"github.com/cybozu-go/neco/metrics"
 	"github.com/cybozu-go/neco/storage"
 	"github.com/cybozu-go/well"
 	"go.etcd.io/etcd/client/v3/concurrency"
 )
 
+// windowCheckInterval is the interval to check maintenance windows and
+// the freeze of updates while an update is deferred.
+const windowCheckInterval = time.Minute
+
+// stepHistoryVersions is the number of versions whose step histories are kept.
+const stepHistoryVersions = 10
+
 // Server represents neco-updater server
 type Server struct {
 	session  *concurrency.Session
@@ -40,6 +48,7 @@ func (s Server) Run(ctx context.Context) error {
 	}
 
 	e := concurrency.NewElection(s.session, storage.KeyUpdaterLeader)
+	defer metrics.UpdaterLeader.Set(0)
 
 RETRY:
 	select {
@@ -57,6 +66,7 @@ RETRY:
 	log.Info("I am the leader", map[string]interface{}{
 		"session": s.session.Lease(),
 	})
+	metrics.UpdaterLeader.Set(1)
 
 	env := well.NewEnvironment(ctx)
 	env.Go(func(ctx context.Context) error {
@@ -78,6 +88,7 @@ RETRY:
 	ctxWithTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
 	err2 := e.Resign(ctxWithTimeout)
 	cancel()
+	metrics.UpdaterLeader.Set(0)
 	if err2 != nil {
 		return err2
 	}
@@ -93,19 +104,30 @@ func (s Server) runLoop(ctx context.Context, leaderKey string) error {
 	if err != nil {
 		return err
 	}
+
+	// The version and the reason of the deferred update logged last time.
+	var deferredVersion, deferredReason string
 	for {
 		ss, err := s.storage.NewSnapshot(ctx)
 		if err != nil {
 			return err
 		}
+		metrics.SetUpdaterRequest(ss.Request)
+		metrics.SetUpdaterStatuses(ss.Request, ss.Statuses)
 
 		action, err := NextAction(ss, timeout)
 		if err != nil {
 			return err
 		}
-		log.Info("next action", map[string]interface{}{
-			"action": action.String(),
-		})
+		if action != ActionWaitWindow {
+			deferredVersion, deferredReason = "", ""
+		}
+		// Waiting for the update window is logged only when it starts or its reason changes.
+		if action != ActionWaitWindow || deferredVersion == "" {
+			log.Info("next action", map[string]interface{}{
+				"action": action.String(),
+			})
+		}
 
 		switch action {
 		case ActionWaitInfo:
@@ -123,24 +145,35 @@ func (s Server) runLoop(ctx context.Context, leaderKey string) error {
 			if err != nil {
 				return err
 			}
-			err = s.notifier.NotifyInfo(req, "start boot servers reconfiguration.")
-			if err != nil {
-				log.Warn("failed to notify", map[string]interface{}{log.FnError: err})
-			}
+			s.notify(ext.Event{
+				Type:    ext.EventReconfigured,
+				Request: req,
+				Message: "start boot servers reconfiguration.",
+			})
 		case ActionNewVersion:
-			req := neco.UpdateRequest{
-				Version:   ss.Latest,
-				Servers:   ss.Servers,
-				StartedAt: time.Now().UTC(),
+			req, err := s.newRequest(ctx, ss.Target(), ss.Servers)
+			if err != nil {
+				return err
 			}
 			err = s.storage.PutRequest(ctx, req, leaderKey)
 			if err != nil {
 				return err
 			}
-			err = s.notifier.NotifyInfo(req, "start updating the new release.")
+			err = s.storage.PruneStepHistories(ctx, stepHistoryVersions)
 			if err != nil {
-				log.Warn("failed to notify", map[string]interface{}{log.FnError: err})
+				log.Warn("failed to prune step histories", map[string]interface{}{
+					log.FnError: err,
+				})
+			}
+			msg := "start updating the new release."
+			if req.InCanary() {
+				msg = fmt.Sprintf("start updating the new release on the canary boot servers %v.", req.Targets())
 			}
+			s.notify(ext.Event{
+				Type:    ext.EventRequestCreated,
+				Request: req,
+				Message: msg,
+			})
 		case ActionWaitWorkers:
 			err = s.waitComplete(ctx, leaderKey, ss, timeout)
 			if err != nil {
@@ -153,17 +186,191 @@ func (s Server) runLoop(ctx context.Context, leaderKey string) error {
 			if err != nil {
 				return err
 			}
+			metrics.UpdaterAbortsTotal.Inc()
 		case ActionWaitClear:
 			err = s.storage.WaitRequestDeletion(ctx, ss.Revision)
 			if err != nil {
 				return err
 			}
+			s.notify(ext.Event{
+				Type:    ext.EventRecovered,
+				Request: *ss.Request,
+			})
+		case ActionStartSoak:
+			req := *ss.Request
+			strategy := *req.Strategy
+			strategy.CanaryCompletedAt = time.Now().UTC()
+			req.Strategy = &strategy
+			err = s.storage.PutRequest(ctx, req, leaderKey)
+			if err != nil {
+				return err
+			}
+			s.notify(ext.Event{
+				Type:     ext.EventCanaryCompleted,
+				Request:  req,
+				Message:  "soak period: " + strategy.SoakPeriod.String(),
+				Statuses: ss.Statuses,
+			})
+		case ActionWaitSoak:
+			strategy := ss.Request.Strategy
+			wait := time.Until(strategy.CanaryCompletedAt.Add(strategy.SoakPeriod))
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+		case ActionExtend:
+			err = s.extend(ctx, leaderKey, ss)
+			if err != nil {
+				return err
+			}
+		case ActionWaitWindow:
+			reason := neco.CheckUpdateWindow(time.Now(), ss.Freeze, ss.Windows)
+			if ss.Target() != deferredVersion || reason != deferredReason {
+				log.Info("update is deferred", map[string]interface{}{
+					"version": ss.Target(),
+					"reason":  reason,
+				})
+				deferredVersion, deferredReason = ss.Target(), reason
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(windowCheckInterval):
+			}
+		case ActionRecordCompleted:
+			err = s.storage.PutLastCompleted(ctx, ss.Request.Version, leaderKey)
+			if err != nil {
+				return err
+			}
+		case ActionRollback:
+			req := neco.UpdateRequest{
+				Version:   ss.LastCompleted,
+				Servers:   ss.Servers,
+				StartedAt: time.Now().UTC(),
+			}
+			rec := &neco.RollbackRecord{
+				Version:      ss.Request.Version,
+				Target:       ss.LastCompleted,
+				RolledBackAt: req.StartedAt,
+			}
+			err = s.storage.PutRollbackRequest(ctx, req, rec, leaderKey)
+			if err != nil {
+				return err
+			}
+			log.Warn("rolled back the failed update", map[string]interface{}{
+				"version": rec.Version,
+				"target":  rec.Target,
+			})
+			metrics.UpdaterRollbacksTotal.Inc()
+			s.notify(ext.Event{
+				Type:     ext.EventRolledBack,
+				Request:  req,
+				Message:  fmt.Sprintf("rolled back from %s to %s.", rec.Version, rec.Target),
+				Statuses: ss.Statuses,
+			})
 		default:
 			return fmt.Errorf("invalid action %s: %d", action.String(), int(action))
 		}
 	}
 }
 
+// newRequest returns a new update request for all servers.
+// If canary boot servers are configured, the request begins with them.
+func (s Server) newRequest(ctx context.Context, version string, servers []int) (neco.UpdateRequest, error) {
+	req := neco.UpdateRequest{
+		Version:   version,
+		Servers:   servers,
+		StartedAt: time.Now().UTC(),
+	}
+
+	canary, err := s.storage.GetRolloutCanary(ctx)
+	if err != nil {
+		return req, err
+	}
+	if len(canary) == 0 {
+		return req, nil
+	}
+	soak, err := s.storage.GetRolloutSoakPeriod(ctx)
+	if err != nil {
+		return req, err
+	}
+	req.Strategy = &neco.RolloutStrategy{
+		Canary:     canary,
+		SoakPeriod: soak,
+		Stage:      neco.StageCanary,
+	}
+
+	// Staging makes no sense if the canary covers none or all of the servers.
+	n := len(req.Targets())
+	if n == 0 || n == len(servers) {
+		log.Warn("canary rollout is disabled for the current boot servers", map[string]interface{}{
+			"canary":  canary,
+			"servers": servers,
+		})
+		req.Strategy = nil
+	}
+	return req, nil
+}
+
+// extend extends the request to all servers if the canary
+// servers are healthy.  Otherwise, this stops the request.
+func (s Server) extend(ctx context.Context, leaderKey string, ss *storage.Snapshot) error {
+	req := *ss.Request
+	err := checkCanaryHealth(ctx, s.session.Client(), req.Targets())
+	if err != nil {
+		log.Error("canary boot servers are unhealthy", map[string]interface{}{
+			log.FnError: err,
+			"version":   req.Version,
+			"canary":    req.Targets(),
+		})
+		req.Stop = true
+		err2 := s.storage.PutRequest(ctx, req, leaderKey)
+		if err2 != nil {
+			return err2
+		}
+		metrics.UpdaterAbortsTotal.Inc()
+		s.notify(ext.Event{
+			Type:     ext.EventHealthGateFailed,
+			Request:  req,
+			Message:  err.Error(),
+			Statuses: ss.Statuses,
+		})
+		return nil
+	}
+
+	// All boot servers including the canary ones run the whole update,
+	// so the statuses of the canary stage are cleared.
+	strategy := *req.Strategy
+	strategy.Stage = neco.StageRemaining
+	req.Strategy = &strategy
+	req.StartedAt = time.Now().UTC()
+	err = s.storage.PutReconfigureRequest(ctx, req, leaderKey)
+	if err != nil {
+		return err
+	}
+	s.notify(ext.Event{
+		Type:    ext.EventRolloutExtended,
+		Request: req,
+		Message: fmt.Sprintf("start updating all boot servers %v.", req.Targets()),
+	})
+	return nil
+}
+
+func (s Server) notify(ev ext.Event) {
+	notify(s.notifier, ev)
+}
+
+func notify(n ext.Notifier, ev ext.Event) {
+	err := n.Notify(ev)
+	if err != nil {
+		log.Warn("failed to notify", map[string]interface{}{
+			log.FnError: err,
+			"event":     string(ev.Type),
+		})
+	}
+}
+
 func (s Server) waitComplete(ctx context.Context, leaderKey string, ss *storage.Snapshot, timeout time.Duration) error {
 	deadline := ss.Request.StartedAt.Add(timeout)
 	ctxWithDeadline, cancel := context.WithDeadline(ctx, deadline)
@@ -181,10 +388,12 @@ func (s Server) waitComplete(ctx context.Context, leaderKey string, ss *storage.
 			"started_at": ss.Request.StartedAt,
 			"timeout":    timeout.String(),
 		})
-		err = s.notifier.NotifyFailure(*ss.Request, "workers take too long for update: "+timeout.String())
-		if err != nil {
-			log.Warn("failed to notify", map[string]interface{}{log.FnError: err})
-		}
+		s.notify(ext.Event{
+			Type:     ext.EventTimedOut,
+			Request:  *ss.Request,
+			Message:  "workers take too long for update: " + timeout.String(),
+			Statuses: statuses,
+		})
 		return nil
 	}
 	return err
@@ -200,7 +409,10 @@ func (h statusHandler) handleStatus(ctx context.Context, lrn int, st *neco.Updat
 	if st.Version != h.req.Version {
 		return false
 	}
+	prev := h.statuses[lrn]
 	h.statuses[lrn] = st
+	metrics.SetUpdaterStatus(h.req, lrn, st)
+	h.notifySteps(lrn, prev, st)
 
 	switch st.Cond {
 	case neco.CondAbort:
@@ -209,10 +421,15 @@ func (h statusHandler) handleStatus(ctx context.Context, lrn int, st *neco.Updat
 			"lrn":     lrn,
 			"message": st.Message,
 		})
-		err := h.notifier.NotifyFailure(*h.req, "update request was aborted: "+st.Message)
-		if err != nil {
-			log.Warn("failed to notify", map[string]interface{}{log.FnError: err})
-		}
+		notify(h.notifier, ext.Event{
+			Type:     ext.EventAborted,
+			Request:  *h.req,
+			LRN:      lrn,
+			Step:     st.Step,
+			StepName: st.StepName,
+			Message:  st.Message,
+			Statuses: h.statuses,
+		})
 		return true
 	case neco.CondComplete:
 		log.Info("worker finished updating", map[string]interface{}{
@@ -221,18 +438,62 @@ func (h statusHandler) handleStatus(ctx context.Context, lrn int, st *neco.Updat
 		})
 	}
 
-	completed := neco.UpdateCompleted(h.req.Version, h.req.Servers, h.statuses)
+	completed := neco.UpdateCompleted(h.req.Version, h.req.Targets(), h.statuses)
 	if completed {
 		log.Info("all worker finished updating", map[string]interface{}{
 			"version": h.req.Version,
-			"servers": h.req.Servers,
+			"targets": h.req.Targets(),
 		})
-		err := h.notifier.NotifySucceeded(*h.req)
-		if err != nil {
-			log.Warn("failed to notify", map[string]interface{}{log.FnError: err})
+		if h.req.InCanary() {
+			// neco-updater notifies EventCanaryCompleted when it starts soaking.
+			return true
 		}
+		notify(h.notifier, ext.Event{
+			Type:     ext.EventCompleted,
+			Request:  *h.req,
+			Statuses: h.statuses,
+		})
 		return true
 	}
 
 	return false
 }
+
+// notifySteps notifies step events deduced from the status change of a worker.
+// A worker puts its status with the next step when it starts the step,
+// and with CondComplete when it finishes the final step.
+func (h statusHandler) notifySteps(lrn int, prev, st *neco.UpdateStatus) {
+	if prev != nil && prev.Version != st.Version {
+		prev = nil
+	}
+	if prev != nil && prev.Step == st.Step && prev.Cond == st.Cond {
+		return
+	}
+
+	ev := ext.Event{
+		Request:  *h.req,
+		LRN:      lrn,
+		Statuses: h.statuses,
+	}
+	if prev != nil && prev.Cond == neco.CondRunning && prev.Step < st.Step {
+		ev.Type = ext.EventStepFinished
+		ev.Step = prev.Step
+		ev.StepName = prev.StepName
+		notify(h.notifier, ev)
+	}
+
+	switch st.Cond {
+	case neco.CondRunning:
+		if prev == nil || prev.Step < st.Step {
+			ev.Type = ext.EventStepStarted
+			ev.Step = st.Step
+			ev.StepName = st.StepName
+			notify(h.notifier, ev)
+		}
+	case neco.CondComplete:
+		ev.Type = ext.EventStepFinished
+		ev.Step = st.Step
+		ev.StepName = st.StepName
+		notify(h.notifier, ev)
+	}
+}
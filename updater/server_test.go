@@ -0,0 +1,85 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/ext"
+)
+
+type recordNotifier struct {
+	events []string
+}
+
+func (n *recordNotifier) Notify(ev ext.Event) error {
+	s := string(ev.Type)
+	if ev.HasStep() {
+		s = fmt.Sprintf("%s:%d:%d", ev.Type, ev.LRN, ev.Step)
+	}
+	n.events = append(n.events, s)
+	return nil
+}
+
+func TestHandleStatus(t *testing.T) {
+	req := &neco.UpdateRequest{
+		Version: "1.0.0",
+		Servers: []int{0, 1},
+	}
+	n := &recordNotifier{}
+	h := statusHandler{req: req, statuses: make(map[int]*neco.UpdateStatus), notifier: n}
+
+	updates := []struct {
+		lrn  int
+		st   neco.UpdateStatus
+		done bool
+	}{
+		{0, neco.UpdateStatus{Version: "1.0.0", Step: 1, Cond: neco.CondRunning}, false},
+		{1, neco.UpdateStatus{Version: "1.0.0", Step: 1, Cond: neco.CondRunning}, false},
+		{0, neco.UpdateStatus{Version: "1.0.0", Step: 2, Cond: neco.CondRunning}, false},
+		{1, neco.UpdateStatus{Version: "1.0.0", Step: 2, Cond: neco.CondRunning}, false},
+		{0, neco.UpdateStatus{Version: "1.0.0", Step: 2, Cond: neco.CondComplete}, false},
+		{1, neco.UpdateStatus{Version: "1.0.0", Step: 2, Cond: neco.CondComplete}, true},
+	}
+	for _, u := range updates {
+		st := u.st
+		done := h.handleStatus(context.Background(), u.lrn, &st)
+		if done != u.done {
+			t.Errorf("unexpected result for %d %#v: %v", u.lrn, u.st, done)
+		}
+	}
+
+	expected := []string{
+		"step-started:0:1",
+		"step-started:1:1",
+		"step-finished:0:1",
+		"step-started:0:2",
+		"step-finished:1:1",
+		"step-started:1:2",
+		"step-finished:0:2",
+		"step-finished:1:2",
+		"completed",
+	}
+	if !reflect.DeepEqual(n.events, expected) {
+		t.Error("unexpected events:", n.events)
+	}
+
+	n.events = nil
+	h.statuses = map[int]*neco.UpdateStatus{
+		0: {Version: "1.0.0", Step: 2, Cond: neco.CondRunning},
+	}
+	done := h.handleStatus(context.Background(), 0, &neco.UpdateStatus{
+		Version: "1.0.0",
+		Step:    2,
+		Cond:    neco.CondAbort,
+		Message: "failed",
+	})
+	if !done {
+		t.Error("abort should finish the handler")
+	}
+	if !reflect.DeepEqual(n.events, []string{"aborted:0:2"}) {
+		t.Error("unexpected events:", n.events)
+	}
+}
@@ -0,0 +1,102 @@
+package neco
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// MaintenanceWindow represents periods when neco-updater may, or may not
+// if Deny is true, start updates.
+//
+// Each period begins at the time matched by Schedule, a standard cron
+// expression such as "0 1 * * 1-5", and lasts for Duration.
+type MaintenanceWindow struct {
+	Name     string        `json:"name"`
+	Schedule string        `json:"schedule"`
+	Duration time.Duration `json:"duration"`
+	TimeZone string        `json:"time_zone,omitempty"`
+	Deny     bool          `json:"deny,omitempty"`
+}
+
+// Validate validates the window.
+func (w MaintenanceWindow) Validate() error {
+	if w.Name == "" {
+		return errors.New("window name is empty")
+	}
+	if w.Duration <= 0 {
+		return errors.New("window duration must be positive")
+	}
+	if _, err := w.location(); err != nil {
+		return err
+	}
+	if _, err := cron.ParseStandard(w.Schedule); err != nil {
+		return fmt.Errorf("invalid schedule %q: %w", w.Schedule, err)
+	}
+	return nil
+}
+
+func (w MaintenanceWindow) location() (*time.Location, error) {
+	if w.TimeZone == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(w.TimeZone)
+}
+
+// Contains returns true if t is in one of the periods of the window.
+func (w MaintenanceWindow) Contains(t time.Time) (bool, error) {
+	loc, err := w.location()
+	if err != nil {
+		return false, err
+	}
+	sched, err := cron.ParseStandard(w.Schedule)
+	if err != nil {
+		return false, err
+	}
+
+	// t is in a period if the period begins in (t-Duration, t].
+	start := sched.Next(t.Add(-w.Duration).In(loc))
+	return !start.After(t), nil
+}
+
+// UpdateFreeze represents an explicit freeze of updates by an operator.
+type UpdateFreeze struct {
+	Reason   string    `json:"reason,omitempty"`
+	FrozenAt time.Time `json:"frozen_at"`
+}
+
+// CheckUpdateWindow checks if neco-updater may start an update at t.
+// It returns an empty string if permitted, or the reason why not.
+//
+// Updates are not permitted while frozen or in a deny window.
+// If there are allow windows, updates are permitted only in them.
+func CheckUpdateWindow(t time.Time, freeze *UpdateFreeze, windows []MaintenanceWindow) string {
+	if freeze != nil {
+		if freeze.Reason == "" {
+			return "updates are frozen"
+		}
+		return "updates are frozen: " + freeze.Reason
+	}
+
+	var hasAllow, allowed bool
+	for _, w := range windows {
+		in, err := w.Contains(t)
+		if err != nil {
+			return fmt.Sprintf("invalid maintenance window %s: %v", w.Name, err)
+		}
+		if w.Deny {
+			if in {
+				return "in deny window " + w.Name
+			}
+			continue
+		}
+		hasAllow = true
+		allowed = allowed || in
+	}
+	if hasAllow && !allowed {
+		return "out of maintenance windows"
+	}
+	return ""
+}
@@ -0,0 +1,81 @@
+package neco
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMaintenanceWindow(t *testing.T) {
+	w := MaintenanceWindow{
+		Name:     "night",
+		Schedule: "0 1 * * 1-5",
+		Duration: 3 * time.Hour,
+		TimeZone: "Asia/Tokyo",
+	}
+	if err := w.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	jst, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		t      time.Time
+		expect bool
+	}{
+		{time.Date(2023, 1, 2, 0, 59, 0, 0, jst), false},
+		{time.Date(2023, 1, 2, 1, 0, 0, 0, jst), true},
+		{time.Date(2023, 1, 2, 3, 59, 59, 0, jst), true},
+		{time.Date(2023, 1, 2, 4, 0, 0, 0, jst), false},
+		{time.Date(2023, 1, 1, 2, 0, 0, 0, jst), false},
+		// 2023-01-02 02:00 JST in UTC
+		{time.Date(2023, 1, 1, 17, 0, 0, 0, time.UTC), true},
+	}
+	for _, c := range testCases {
+		in, err := w.Contains(c.t)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if in != c.expect {
+			t.Errorf("Contains(%s) = %v", c.t, in)
+		}
+	}
+
+	invalid := []MaintenanceWindow{
+		{Schedule: "0 1 * * *", Duration: time.Hour},
+		{Name: "a", Schedule: "0 1 * *", Duration: time.Hour},
+		{Name: "a", Schedule: "0 1 * * *"},
+		{Name: "a", Schedule: "0 1 * * *", Duration: time.Hour, TimeZone: "No/Such"},
+	}
+	for _, w := range invalid {
+		if err := w.Validate(); err == nil {
+			t.Error("should be invalid:", w)
+		}
+	}
+}
+
+func TestCheckUpdateWindow(t *testing.T) {
+	now := time.Date(2023, 1, 2, 2, 0, 0, 0, time.UTC)
+	allow := MaintenanceWindow{Name: "allow", Schedule: "0 1 * * *", Duration: 2 * time.Hour}
+	deny := MaintenanceWindow{Name: "deny", Schedule: "30 1 * * *", Duration: time.Hour, Deny: true}
+	other := MaintenanceWindow{Name: "other", Schedule: "0 12 * * *", Duration: time.Hour}
+
+	if reason := CheckUpdateWindow(now, nil, nil); reason != "" {
+		t.Error("should be permitted without windows:", reason)
+	}
+	if reason := CheckUpdateWindow(now, nil, []MaintenanceWindow{allow, other}); reason != "" {
+		t.Error("should be permitted in allow window:", reason)
+	}
+	if reason := CheckUpdateWindow(now, nil, []MaintenanceWindow{other}); reason == "" {
+		t.Error("should not be permitted out of allow windows")
+	}
+	if reason := CheckUpdateWindow(now, nil, []MaintenanceWindow{allow, deny}); !strings.Contains(reason, "deny") {
+		t.Error("should not be permitted in deny window:", reason)
+	}
+	freeze := &UpdateFreeze{Reason: "year-end", FrozenAt: now}
+	if reason := CheckUpdateWindow(now, freeze, []MaintenanceWindow{allow}); !strings.Contains(reason, "year-end") {
+		t.Error("should not be permitted while frozen:", reason)
+	}
+}
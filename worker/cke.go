@@ -53,11 +53,11 @@ func (o *operator) replaceCKEFiles(ctx context.Context, lrns []int) (bool, error
 	if err != nil {
 		return false, err
 	}
-	err = os.MkdirAll(filepath.Dir(neco.CKEConfFile), 0755)
+	err = o.mkdirAll(filepath.Dir(neco.CKEConfFile), 0755)
 	if err != nil {
 		return false, err
 	}
-	r, err := replaceFile(neco.CKEConfFile, buf.Bytes(), 0644)
+	r, err := o.replaceFile(neco.CKEConfFile, buf.Bytes(), 0644)
 	if err != nil {
 		return false, err
 	}
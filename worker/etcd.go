@@ -85,6 +85,8 @@ func (o *operator) UpdateEtcd(ctx context.Context, req *neco.UpdateRequest) erro
 		}
 	}
 
+	// Use all boot servers, not only the targets of the current stage,
+	// so that a staged rollout does not change the etcd membership.
 	err = o.replaceEtcdFiles(ctx, req.Servers)
 	if err != nil {
 		return err
@@ -195,7 +197,7 @@ func (o *operator) replaceEtcdFiles(ctx context.Context, lrns []int) error {
 		return err
 	}
 
-	_, err = replaceFile(neco.ServiceFile(neco.EtcdService), buf.Bytes(), 0644)
+	_, err = o.replaceFile(neco.ServiceFile(neco.EtcdService), buf.Bytes(), 0644)
 	if err != nil {
 		return err
 	}
@@ -206,7 +208,7 @@ func (o *operator) replaceEtcdFiles(ctx context.Context, lrns []int) error {
 		return err
 	}
 
-	_, err = replaceFile(neco.EtcdConfFile, buf.Bytes(), 0644)
+	_, err = o.replaceFile(neco.EtcdConfFile, buf.Bytes(), 0644)
 	if err != nil {
 		return err
 	}
@@ -267,6 +269,8 @@ func (o *operator) RestartEtcd(index int, req *neco.UpdateRequest) error {
 	// This is hard-coded in the etcd source code.
 	time.Sleep(6 * time.Second)
 
+	// This removes only the members that are not in req.Servers,
+	// so the boot servers out of the current rollout stage are kept.
 	err = removeEtcdMembers(ctx, ec, req)
 	if err != nil {
 		log.Error("failed to remove members", map[string]interface{}{log.FnError: err.Error()})
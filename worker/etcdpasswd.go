@@ -3,7 +3,6 @@ package worker
 import (
 	"bytes"
 	"context"
-	"os"
 	"path/filepath"
 
 	"github.com/cybozu-go/log"
@@ -68,11 +67,11 @@ func (o *operator) replaceEtcdpasswdFiles(ctx context.Context, lrns []int) (bool
 	if err != nil {
 		return false, err
 	}
-	err = os.MkdirAll(filepath.Dir(neco.EtcdpasswdDropIn), 0755)
+	err = o.mkdirAll(filepath.Dir(neco.EtcdpasswdDropIn), 0755)
 	if err != nil {
 		return false, err
 	}
-	r1, err := replaceFile(neco.EtcdpasswdDropIn, buf.Bytes(), 0644)
+	r1, err := o.replaceFile(neco.EtcdpasswdDropIn, buf.Bytes(), 0644)
 	if err != nil {
 		return false, err
 	}
@@ -82,11 +81,11 @@ func (o *operator) replaceEtcdpasswdFiles(ctx context.Context, lrns []int) (bool
 	if err != nil {
 		return false, err
 	}
-	err = os.MkdirAll(filepath.Dir(neco.EtcdpasswdConfFile), 0755)
+	err = o.mkdirAll(filepath.Dir(neco.EtcdpasswdConfFile), 0755)
 	if err != nil {
 		return false, err
 	}
-	r2, err := replaceFile(neco.EtcdpasswdConfFile, buf.Bytes(), 0644)
+	r2, err := o.replaceFile(neco.EtcdpasswdConfFile, buf.Bytes(), 0644)
 	if err != nil {
 		return false, err
 	}
@@ -2,7 +2,6 @@ package worker
 
 import (
 	"context"
-	"fmt"
 	"net/http"
 	"os"
 
@@ -20,11 +19,8 @@ type Operator interface {
 	// UpdateNeco updates neco package.
 	UpdateNeco(ctx context.Context, req *neco.UpdateRequest) error
 
-	// FinalStep is the step number of the final operation.
-	FinalStep() int
-
-	// RunStep executes operations for given step.
-	RunStep(ctx context.Context, req *neco.UpdateRequest, step int) error
+	// Pipeline returns the update steps.
+	Pipeline() Pipeline
 
 	// RestoreServices starts installed services at startup.
 	StartServices(ctx context.Context) error
@@ -42,10 +38,18 @@ type operator struct {
 	localClient      *http.Client
 	fetcher          neco.ImageFetcher
 	containerRuntime neco.ContainerRuntime
+	pipeline         Pipeline
+
+	// plan is non-nil when planning the changes of a step.
+	plan *StepPlan
 }
 
 // NewOperator creates an Operator
 func NewOperator(ctx context.Context, ec *clientv3.Client, mylrn int) (Operator, error) {
+	return newOperator(ctx, ec, mylrn)
+}
+
+func newOperator(ctx context.Context, ec *clientv3.Client, mylrn int) (*operator, error) {
 	st := storage.NewStorage(ec)
 	localClient := ext.LocalHTTPClient()
 	proxyClient, err := ext.ProxyHTTPClient(ctx, st)
@@ -85,7 +89,7 @@ func NewOperator(ctx context.Context, ec *clientv3.Client, mylrn int) (Operator,
 		return nil, err
 	}
 
-	return &operator{
+	op := &operator{
 		mylrn:            mylrn,
 		ec:               ec,
 		storage:          st,
@@ -94,7 +98,12 @@ func NewOperator(ctx context.Context, ec *clientv3.Client, mylrn int) (Operator,
 		localClient:      localClient,
 		fetcher:          fetcher,
 		containerRuntime: rt,
-	}, nil
+	}
+	op.pipeline, err = NewPipeline(op.steps())
+	if err != nil {
+		return nil, err
+	}
+	return op, nil
 }
 
 func (o *operator) UpdateNeco(ctx context.Context, req *neco.UpdateRequest) error {
@@ -121,55 +130,38 @@ func (o *operator) UpdateNeco(ctx context.Context, req *neco.UpdateRequest) erro
 	return InstallDebianPackage(ctx, o.proxyClient, o.ghClient, deb, true)
 }
 
-func (o *operator) FinalStep() int {
-	return 18
+func (o *operator) Pipeline() Pipeline {
+	return o.pipeline
 }
 
-func (o *operator) RunStep(ctx context.Context, req *neco.UpdateRequest, step int) error {
-
-	switch step {
-	case 1:
-		return o.FetchImages(ctx, req)
-	case 2:
-		return o.UpdateEtcd(ctx, req)
-	case 3:
-		return o.StopVault(ctx, req)
-	case 4:
-		return o.UpdateVault(ctx, req)
-	case 5:
-		return o.UpdateSetupHW(ctx, req)
-	case 6:
-		return o.UpdateSerf(ctx, req)
-	case 7:
-		return o.UpdateSetupSerfTags(ctx, req)
-	case 8:
-		return o.UpdateEtcdpasswd(ctx, req)
-	case 9:
-		return o.UpdateSabakan(ctx, req)
-	case 10:
-		return o.UpdateSabakanStateSetter(ctx, req)
-	case 11:
-		return o.StopCKE(ctx, req)
-	case 12:
-		return o.UpdateCKE(ctx, req)
-	case 13:
-		return o.UpdateCKEContents(ctx, req)
-	case 14:
-		return o.UpdateSabakanContents(ctx, req)
-	case 15:
-		return o.UpdateDHCPJSON(ctx, req)
-	case 16:
-		return o.UpdatePromtail(ctx, req)
-	case 17:
-		return o.UpdateUserResources(ctx, req)
-	case 18:
-		// THIS MUST BE THE FINAL STEP!!!!!
-		// to synchronize before restarting etcd.
-		return nil
-		// DO NOT ADD ANY STEP AFTER THIS LINE!!!
-	}
+// DefaultPipeline returns the update steps of neco-worker of this version.
+// The steps are not bound to an operator, so they must not be run.
+func DefaultPipeline() (Pipeline, error) {
+	return NewPipeline((&operator{}).steps())
+}
 
-	return fmt.Errorf("invalid step: %d", step)
+// steps declares the update steps of neco-worker.
+// The order of steps is resolved by NewPipeline.
+func (o *operator) steps() []*Step {
+	return []*Step{
+		{Name: "fetch-images", Canary: true, Run: o.FetchImages},
+		{Name: "update-etcd", Requires: []string{"fetch-images"}, Run: o.UpdateEtcd, Plan: o.PlanEtcd},
+		{Name: "stop-vault", Requires: []string{"update-etcd"}, Run: o.StopVault},
+		{Name: "update-vault", Requires: []string{"stop-vault"}, Run: o.UpdateVault, Plan: o.PlanVault},
+		{Name: "update-setup-hw", Canary: true, Run: o.UpdateSetupHW, Plan: o.PlanSetupHW},
+		{Name: "update-serf", Requires: []string{"fetch-images"}, Canary: true, Run: o.UpdateSerf, Plan: o.PlanSerf},
+		{Name: "update-setup-serf-tags", Requires: []string{"update-serf"}, Canary: true, Run: o.UpdateSetupSerfTags, Plan: o.PlanSetupSerfTags},
+		{Name: "update-etcdpasswd", Requires: []string{"update-etcd"}, Canary: true, Run: o.UpdateEtcdpasswd, Plan: o.PlanEtcdpasswd},
+		{Name: "update-sabakan", Requires: []string{"update-vault"}, Run: o.UpdateSabakan, Plan: o.PlanSabakan},
+		{Name: "update-sabakan-state-setter", Requires: []string{"update-sabakan", "update-serf"}, Run: o.UpdateSabakanStateSetter},
+		{Name: "stop-cke", Requires: []string{"update-vault"}, Run: o.StopCKE},
+		{Name: "update-cke", Requires: []string{"stop-cke"}, Run: o.UpdateCKE, Plan: o.PlanCKE},
+		{Name: "update-cke-contents", Requires: []string{"update-cke"}, Run: o.UpdateCKEContents},
+		{Name: "update-sabakan-contents", Requires: []string{"update-sabakan"}, Run: o.UpdateSabakanContents, Plan: o.PlanSabakanContents},
+		{Name: "update-dhcp-json", Requires: []string{"update-sabakan"}, Run: o.UpdateDHCPJSON},
+		{Name: "update-promtail", Canary: true, Run: o.UpdatePromtail, Plan: o.PlanPromtail},
+		{Name: "update-user-resources", Requires: []string{"update-cke-contents"}, Run: o.UpdateUserResources},
+	}
 }
 
 func (o *operator) restoreService(ctx context.Context, svc string) error {
@@ -0,0 +1,319 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/progs/sabakan"
+	setup_serf_tags "github.com/cybozu-go/neco/progs/setup-serf-tags"
+	"github.com/cybozu-go/neco/storage"
+	"github.com/google/go-github/v48/github"
+	"github.com/pmezard/go-difflib/difflib"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// Plan is the result of a dry run of the update process on a boot server.
+// Installed is the version of the installed neco package, which renders
+// the configuration files in the plan.
+type Plan struct {
+	Version   string      `json:"version"`
+	Installed string      `json:"installed"`
+	LRN       int         `json:"lrn"`
+	Steps     []*StepPlan `json:"steps"`
+}
+
+// StepPlan represents the changes that an update step would make.
+type StepPlan struct {
+	Step    int                   `json:"step"`
+	Name    string                `json:"name"`
+	Images  []VersionChange       `json:"images,omitempty"`
+	Debs    []VersionChange       `json:"debs,omitempty"`
+	Files   []FileChange          `json:"files,omitempty"`
+	Sabakan *sabakan.ContentsPlan `json:"sabakan,omitempty"`
+	Notes   []string              `json:"notes,omitempty"`
+
+	// artifacts is the artifacts of the version to be planned.
+	artifacts *neco.ArtifactSet
+}
+
+// VersionChange represents a change of a container image or a Debian package.
+// Current is empty if it has not been installed by neco-worker.
+type VersionChange struct {
+	Name    string `json:"name"`
+	Current string `json:"current"`
+	Target  string `json:"target"`
+}
+
+// FileChange represents a file to be created or rewritten.
+type FileChange struct {
+	Path    string `json:"path"`
+	Created bool   `json:"created"`
+	Diff    string `json:"diff"`
+}
+
+// IsEmpty returns true if the step would change nothing.
+func (p *StepPlan) IsEmpty() bool {
+	return len(p.Images) == 0 && len(p.Debs) == 0 && len(p.Files) == 0 && p.Sabakan == nil && len(p.Notes) == 0
+}
+
+func (p *StepPlan) addFile(name string, data []byte) (bool, error) {
+	current, err := os.ReadFile(name)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return false, err
+	case bytes.Equal(current, data):
+		return false, nil
+	}
+
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(current)),
+		B:        difflib.SplitLines(string(data)),
+		FromFile: name,
+		ToFile:   name,
+		Context:  3,
+	})
+	if err != nil {
+		return false, err
+	}
+	p.Files = append(p.Files, FileChange{
+		Path:    name,
+		Created: current == nil,
+		Diff:    diff,
+	})
+	return true, nil
+}
+
+// withPlan returns a copy of o that records changes to p instead of making them.
+func (o *operator) withPlan(p *StepPlan) *operator {
+	po := *o
+	po.plan = p
+	return &po
+}
+
+// replaceFile replaces the file with data.  If o is planning, this only
+// records the change.
+func (o *operator) replaceFile(name string, data []byte, mode os.FileMode) (bool, error) {
+	if o.plan != nil {
+		return o.plan.addFile(name, data)
+	}
+	return replaceFile(name, data, mode)
+}
+
+// mkdirAll creates a directory unless o is planning.
+func (o *operator) mkdirAll(path string, perm os.FileMode) error {
+	if o.plan != nil {
+		return nil
+	}
+	return os.MkdirAll(path, perm)
+}
+
+// PlanUpdate plans the update for req on the local boot server.
+func PlanUpdate(ctx context.Context, ec *clientv3.Client, mylrn int, req *neco.UpdateRequest) (*Plan, error) {
+	op, err := newOperator(ctx, ec, mylrn)
+	if err != nil {
+		return nil, err
+	}
+	return op.Plan(ctx, req)
+}
+
+// Plan runs the update steps in the non-mutating mode and returns the changes.
+// Container images, Debian packages and sabakan contents are planned with
+// the artifacts of req.Version.  If req.Version is not installed, they are
+// read from the release on GitHub.
+func (o *operator) Plan(ctx context.Context, req *neco.UpdateRequest) (*Plan, error) {
+	installed, err := neco.GetDebianVersion(neco.NecoPackageName)
+	if err != nil {
+		return nil, err
+	}
+
+	artifacts := &neco.CurrentArtifacts
+	if req.Version != installed {
+		artifacts, err = fetchArtifactSet(ctx, o.ghClient, req.Version)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return o.makePlan(ctx, req, installed, artifacts)
+}
+
+func (o *operator) makePlan(ctx context.Context, req *neco.UpdateRequest, installed string, artifacts *neco.ArtifactSet) (*Plan, error) {
+	plan := &Plan{
+		Version:   req.Version,
+		Installed: installed,
+		LRN:       o.mylrn,
+	}
+	for n, s := range o.pipeline {
+		sp := &StepPlan{
+			Step:      n + 1,
+			Name:      s.Name,
+			artifacts: artifacts,
+		}
+		if s.Plan != nil {
+			err := s.Plan(ctx, req, sp)
+			if err != nil {
+				return nil, err
+			}
+		}
+		plan.Steps = append(plan.Steps, sp)
+	}
+	return plan, nil
+}
+
+// fetchArtifactSet reads the artifacts of the release of version from GitHub.
+func fetchArtifactSet(ctx context.Context, gh *github.Client, version string) (*neco.ArtifactSet, error) {
+	fc, _, _, err := gh.Repositories.GetContents(ctx, neco.GitHubRepoOwner, neco.GitHubRepoName, neco.ReleaseArtifactsFile,
+		&github.RepositoryContentGetOptions{Ref: "release-" + version})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the artifacts of %s: %w", version, err)
+	}
+	if fc == nil {
+		return nil, fmt.Errorf("%s is not a file", neco.ReleaseArtifactsFile)
+	}
+	src, err := fc.GetContent()
+	if err != nil {
+		return nil, err
+	}
+	return neco.ParseArtifactSet([]byte(src))
+}
+
+func (o *operator) planContainerImage(ctx context.Context, p *StepPlan, name string) error {
+	img, err := p.artifacts.FindContainerImage(name)
+	if err != nil {
+		return err
+	}
+	current, err := o.storage.GetContainerTag(ctx, o.mylrn, name)
+	if err != nil && err != storage.ErrNotFound {
+		return err
+	}
+	if current == img.Tag {
+		return nil
+	}
+	p.Images = append(p.Images, VersionChange{Name: name, Current: current, Target: img.Tag})
+	return nil
+}
+
+func (o *operator) planDebianPackage(ctx context.Context, p *StepPlan, name string) error {
+	deb, err := p.artifacts.FindDebianPackage(name)
+	if err != nil {
+		return err
+	}
+	current, err := o.storage.GetDebVersion(ctx, o.mylrn, name)
+	if err != nil && err != storage.ErrNotFound {
+		return err
+	}
+	if current == deb.Release {
+		return nil
+	}
+	p.Debs = append(p.Debs, VersionChange{Name: name, Current: current, Target: deb.Release})
+	return nil
+}
+
+func (o *operator) PlanEtcd(ctx context.Context, req *neco.UpdateRequest, p *StepPlan) error {
+	if err := o.planContainerImage(ctx, p, "etcd"); err != nil {
+		return err
+	}
+	return o.withPlan(p).replaceEtcdFiles(ctx, req.Servers)
+}
+
+func (o *operator) PlanVault(ctx context.Context, req *neco.UpdateRequest, p *StepPlan) error {
+	if err := o.planContainerImage(ctx, p, "vault"); err != nil {
+		return err
+	}
+	_, err := o.withPlan(p).replaceVaultFiles(ctx, req.Servers)
+	return err
+}
+
+func (o *operator) PlanSetupHW(ctx context.Context, req *neco.UpdateRequest, p *StepPlan) error {
+	if err := o.planContainerImage(ctx, p, "setup-hw"); err != nil {
+		return err
+	}
+	_, err := o.withPlan(p).replaceSetupHWFiles(ctx)
+	return err
+}
+
+func (o *operator) PlanSerf(ctx context.Context, req *neco.UpdateRequest, p *StepPlan) error {
+	if err := o.planContainerImage(ctx, p, "serf"); err != nil {
+		return err
+	}
+	var otherlrns []int
+	for _, lrn := range req.Servers {
+		if lrn == o.mylrn {
+			continue
+		}
+		otherlrns = append(otherlrns, lrn)
+	}
+	_, err := o.withPlan(p).replaceSerfFiles(ctx, otherlrns)
+	return err
+}
+
+func (o *operator) PlanSetupSerfTags(ctx context.Context, req *neco.UpdateRequest, p *StepPlan) error {
+	if _, err := p.addFile(neco.ServiceFile(setupSerfTags), []byte(setupSerfTagsService)); err != nil {
+		return err
+	}
+	if _, err := p.addFile(neco.TimerFile(setupSerfTags), []byte(setupSerfTagsTimer)); err != nil {
+		return err
+	}
+	buf := new(bytes.Buffer)
+	err := setup_serf_tags.GenerateScript(buf, req.Version)
+	if err != nil {
+		return err
+	}
+	_, err = p.addFile(binPath, buf.Bytes())
+	return err
+}
+
+func (o *operator) PlanEtcdpasswd(ctx context.Context, req *neco.UpdateRequest, p *StepPlan) error {
+	if err := o.planDebianPackage(ctx, p, "etcdpasswd"); err != nil {
+		return err
+	}
+	_, err := o.withPlan(p).replaceEtcdpasswdFiles(ctx, req.Servers)
+	return err
+}
+
+func (o *operator) PlanSabakan(ctx context.Context, req *neco.UpdateRequest, p *StepPlan) error {
+	if err := o.planContainerImage(ctx, p, "sabakan"); err != nil {
+		return err
+	}
+	_, err := o.withPlan(p).replaceSabakanFiles(ctx, o.mylrn, req.Servers)
+	return err
+}
+
+func (o *operator) PlanCKE(ctx context.Context, req *neco.UpdateRequest, p *StepPlan) error {
+	_, err := o.withPlan(p).replaceCKEFiles(ctx, req.Servers)
+	return err
+}
+
+func (o *operator) PlanSabakanContents(ctx context.Context, req *neco.UpdateRequest, p *StepPlan) error {
+	isActive, err := neco.IsActiveService(ctx, neco.SabakanService)
+	if err != nil {
+		return err
+	}
+	if !isActive {
+		p.Notes = append(p.Notes, "sabakan is inactive; contents will not be uploaded")
+		return nil
+	}
+
+	status, err := o.storage.GetSabakanContentsStatus(ctx)
+	switch {
+	case err == storage.ErrNotFound:
+	case err != nil:
+		return err
+	case status.Version == req.Version && status.Success:
+		return nil
+	}
+
+	p.Sabakan, err = sabakan.PlanContents(ctx, o.localClient, req.Version, p.artifacts)
+	return err
+}
+
+func (o *operator) PlanPromtail(ctx context.Context, req *neco.UpdateRequest, p *StepPlan) error {
+	if err := o.planContainerImage(ctx, p, "promtail"); err != nil {
+		return err
+	}
+	_, err := o.withPlan(p).replacePromtailFiles(ctx, o.mylrn)
+	return err
+}
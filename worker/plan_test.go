@@ -0,0 +1,204 @@
+package worker
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/storage"
+	"github.com/cybozu-go/neco/storage/test"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-github/v48/github"
+)
+
+func TestPlanReplaceFile(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "existing")
+	err := os.WriteFile(existing, []byte("a\nb\nc\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := filepath.Join(dir, "sub", "created")
+
+	p := &StepPlan{Name: "test"}
+	o := (&operator{}).withPlan(p)
+
+	if err := o.mkdirAll(filepath.Dir(created), 0755); err != nil {
+		t.Fatal(err)
+	}
+	changed, err := o.replaceFile(created, []byte("new\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Error("new file should be reported as changed")
+	}
+	changed, err = o.replaceFile(existing, []byte("a\nB\nc\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Error("modified file should be reported as changed")
+	}
+	changed, err = o.replaceFile(existing, []byte("a\nb\nc\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed {
+		t.Error("same file should not be reported as changed")
+	}
+
+	if _, err := os.Stat(filepath.Dir(created)); !os.IsNotExist(err) {
+		t.Error("directory should not be created while planning")
+	}
+	data, err := os.ReadFile(existing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "a\nb\nc\n" {
+		t.Error("file should not be modified while planning")
+	}
+
+	if len(p.Files) != 2 {
+		t.Fatal("unexpected files:", p.Files)
+	}
+	if !p.Files[0].Created || p.Files[1].Created {
+		t.Error("unexpected created flags:", p.Files)
+	}
+	if !strings.Contains(p.Files[1].Diff, "-b\n+B\n") {
+		t.Error("unexpected diff:", p.Files[1].Diff)
+	}
+	if p.IsEmpty() {
+		t.Error("plan should not be empty")
+	}
+}
+
+const testReleaseArtifacts = `// Code generated by generate-artifacts. DO NOT EDIT.
+//go:build release
+
+package neco
+
+var CurrentArtifacts = ArtifactSet{
+	Images: []ContainerImage{
+		{Name: "etcd", Repository: "quay.io/cybozu/etcd", Tag: "3.6.0.1", Private: false},
+		%s,
+	},
+	Debs: []DebianPackage{
+		{Name: "etcdpasswd", Owner: "cybozu-go", Repository: "etcdpasswd", Release: "v1.5.0"},
+	},
+	OSImage: OSImage{Channel: "stable", Version: "3510.2.0"},
+}
+`
+
+func TestPlanOtherVersion(t *testing.T) {
+	t.Parallel()
+
+	// serf is not updated because its tag is the same as the installed one.
+	serf, err := neco.CurrentArtifacts.FindContainerImage("serf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := fmt.Sprintf(testReleaseArtifacts, serf.MarshalGo())
+	etcd, err := neco.CurrentArtifacts.FindContainerImage("etcd")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/cybozu-go/neco/contents/"+neco.ReleaseArtifactsFile {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Query().Get("ref") != "release-2099.01.01-1" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"type":     "file",
+			"encoding": "base64",
+			"name":     neco.ReleaseArtifactsFile,
+			"content":  base64.StdEncoding.EncodeToString([]byte(src)),
+		})
+	}))
+	defer ts.Close()
+	gh := github.NewClient(nil)
+	gh.BaseURL, _ = url.Parse(ts.URL + "/")
+
+	ctx := context.Background()
+	_, err = fetchArtifactSet(ctx, gh, "2099.01.02-1")
+	if err == nil {
+		t.Error("fetching artifacts of unknown version should fail")
+	}
+	artifacts, err := fetchArtifactSet(ctx, gh, "2099.01.01-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ec := test.NewEtcdClient(t)
+	defer ec.Close()
+	st := storage.NewStorage(ec)
+	err = st.RecordContainerTag(ctx, 0, "etcd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.RecordContainerTag(ctx, 0, "serf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o := &operator{mylrn: 0, storage: st}
+	o.pipeline, err = NewPipeline([]*Step{
+		{
+			Name: "images",
+			Run:  nopRun,
+			Plan: func(ctx context.Context, req *neco.UpdateRequest, p *StepPlan) error {
+				if err := o.planContainerImage(ctx, p, "etcd"); err != nil {
+					return err
+				}
+				return o.planContainerImage(ctx, p, "serf")
+			},
+		},
+		{
+			Name: "debs",
+			Run:  nopRun,
+			Plan: func(ctx context.Context, req *neco.UpdateRequest, p *StepPlan) error {
+				return o.planDebianPackage(ctx, p, "etcdpasswd")
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := &neco.UpdateRequest{Version: "2099.01.01-1", Servers: []int{0}}
+	plan, err := o.makePlan(ctx, req, "2022.12.01-1", artifacts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Version != req.Version || plan.Installed != "2022.12.01-1" {
+		t.Error("unexpected versions:", plan.Version, plan.Installed)
+	}
+	if len(plan.Steps) != 3 {
+		t.Fatal("unexpected steps:", plan.Steps)
+	}
+	expectedImages := []VersionChange{{Name: "etcd", Current: etcd.Tag, Target: "3.6.0.1"}}
+	if !cmp.Equal(plan.Steps[0].Images, expectedImages) {
+		t.Error("unexpected images:", plan.Steps[0].Images)
+	}
+	expectedDebs := []VersionChange{{Name: "etcdpasswd", Current: "", Target: "v1.5.0"}}
+	if !cmp.Equal(plan.Steps[1].Debs, expectedDebs) {
+		t.Error("unexpected debs:", plan.Steps[1].Debs)
+	}
+	if !plan.Steps[2].IsEmpty() {
+		t.Error("final step should change nothing:", plan.Steps[2])
+	}
+}
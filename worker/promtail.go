@@ -3,7 +3,6 @@ package worker
 import (
 	"bytes"
 	"context"
-	"os"
 	"path/filepath"
 
 	"github.com/cybozu-go/neco"
@@ -45,11 +44,11 @@ func (o *operator) replacePromtailFiles(ctx context.Context, mylrn int) (bool, e
 	if err != nil {
 		return false, err
 	}
-	err = os.MkdirAll(filepath.Dir(neco.PromtailConfFile), 0755)
+	err = o.mkdirAll(filepath.Dir(neco.PromtailConfFile), 0755)
 	if err != nil {
 		return false, err
 	}
-	r1, err := replaceFile(neco.PromtailConfFile, buf.Bytes(), 0644)
+	r1, err := o.replaceFile(neco.PromtailConfFile, buf.Bytes(), 0644)
 	if err != nil {
 		return false, err
 	}
@@ -59,11 +58,11 @@ func (o *operator) replacePromtailFiles(ctx context.Context, mylrn int) (bool, e
 	if err != nil {
 		return false, err
 	}
-	err = os.MkdirAll(filepath.Dir(neco.ServiceFile(neco.PromtailService)), 0755)
+	err = o.mkdirAll(filepath.Dir(neco.ServiceFile(neco.PromtailService)), 0755)
 	if err != nil {
 		return false, err
 	}
-	r2, err := replaceFile(neco.ServiceFile(neco.PromtailService), buf.Bytes(), 0644)
+	r2, err := o.replaceFile(neco.ServiceFile(neco.PromtailService), buf.Bytes(), 0644)
 	if err != nil {
 		return false, err
 	}
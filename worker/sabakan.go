@@ -153,11 +153,11 @@ func (o *operator) replaceSabakanFiles(ctx context.Context, mylrn int, lrns []in
 	if err != nil {
 		return false, err
 	}
-	err = os.MkdirAll(filepath.Dir(neco.SabakanConfFile), 0755)
+	err = o.mkdirAll(filepath.Dir(neco.SabakanConfFile), 0755)
 	if err != nil {
 		return false, err
 	}
-	r1, err := replaceFile(neco.SabakanConfFile, buf.Bytes(), 0644)
+	r1, err := o.replaceFile(neco.SabakanConfFile, buf.Bytes(), 0644)
 	if err != nil {
 		return false, err
 	}
@@ -167,11 +167,11 @@ func (o *operator) replaceSabakanFiles(ctx context.Context, mylrn int, lrns []in
 	if err != nil {
 		return false, err
 	}
-	err = os.MkdirAll(filepath.Dir(neco.ServiceFile("sabakan")), 0755)
+	err = o.mkdirAll(filepath.Dir(neco.ServiceFile("sabakan")), 0755)
 	if err != nil {
 		return false, err
 	}
-	r2, err := replaceFile(neco.ServiceFile("sabakan"), buf.Bytes(), 0644)
+	r2, err := o.replaceFile(neco.ServiceFile("sabakan"), buf.Bytes(), 0644)
 	if err != nil {
 		return false, err
 	}
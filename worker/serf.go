@@ -3,7 +3,6 @@ package worker
 import (
 	"bytes"
 	"context"
-	"os"
 	"path/filepath"
 	"strconv"
 	"time"
@@ -83,11 +82,11 @@ func (o *operator) replaceSerfFiles(ctx context.Context, lrns []int) (bool, erro
 	if err != nil {
 		return false, err
 	}
-	err = os.MkdirAll(filepath.Dir(neco.SerfConfFile), 0755)
+	err = o.mkdirAll(filepath.Dir(neco.SerfConfFile), 0755)
 	if err != nil {
 		return false, err
 	}
-	r1, err := replaceFile(neco.ServiceFile(neco.SerfService), buf.Bytes(), 0644)
+	r1, err := o.replaceFile(neco.ServiceFile(neco.SerfService), buf.Bytes(), 0644)
 	if err != nil {
 		return false, err
 	}
@@ -110,7 +109,7 @@ func (o *operator) replaceSerfFiles(ctx context.Context, lrns []int) (bool, erro
 		return false, err
 	}
 
-	r2, err := replaceFile(neco.SerfConfFile, buf.Bytes(), 0644)
+	r2, err := o.replaceFile(neco.SerfConfFile, buf.Bytes(), 0644)
 	if err != nil {
 		return false, err
 	}
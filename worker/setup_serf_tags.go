@@ -59,7 +59,7 @@ func (o *operator) UpdateSetupSerfTags(ctx context.Context, req *neco.UpdateRequ
 	if err != nil {
 		return err
 	}
-	_, err = replaceFile(binPath, buf.Bytes(), 0755)
+	_, err = o.replaceFile(binPath, buf.Bytes(), 0755)
 	if err != nil {
 		return err
 	}
@@ -3,7 +3,6 @@ package worker
 import (
 	"bytes"
 	"context"
-	"os"
 	"path/filepath"
 
 	"github.com/cybozu-go/log"
@@ -46,9 +45,9 @@ func (o *operator) replaceSetupHWFiles(ctx context.Context) (bool, error) {
 	if err != nil {
 		return false, err
 	}
-	err = os.MkdirAll(filepath.Dir(neco.ServiceFile(neco.SetupHWService)), 0755)
+	err = o.mkdirAll(filepath.Dir(neco.ServiceFile(neco.SetupHWService)), 0755)
 	if err != nil {
 		return false, err
 	}
-	return replaceFile(neco.ServiceFile(neco.SetupHWService), buf.Bytes(), 0644)
+	return o.replaceFile(neco.ServiceFile(neco.SetupHWService), buf.Bytes(), 0644)
 }
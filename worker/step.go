@@ -0,0 +1,131 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cybozu-go/neco"
+)
+
+// FinalStepName is the name of the step appended to every Pipeline.
+// It synchronizes all boot servers before restarting etcd.
+const FinalStepName = "finalize"
+
+// Step is a named operation of the update process.
+type Step struct {
+	// Name identifies the step.  It is recorded in neco.UpdateStatus.
+	Name string
+
+	// Requires lists the names of the steps that must finish before this step.
+	Requires []string
+
+	// Canary is true if this step changes only the boot server running it.
+	// In the canary stage of a staged rollout, the canary boot servers run
+	// only such steps.  Steps that change cluster-wide services or data run
+	// on all boot servers in the remaining stage.
+	Canary bool
+
+	// Run executes the operations of this step.
+	Run func(ctx context.Context, req *neco.UpdateRequest) error
+
+	// Plan records the changes that Run would make without making them.
+	// This may be nil if the step has nothing to plan.
+	Plan func(ctx context.Context, req *neco.UpdateRequest, p *StepPlan) error
+}
+
+// Pipeline is the ordered list of update steps.
+// Steps are numbered from 1 as in neco.UpdateStatus.
+// All boot servers must reach a step before any of them runs it.
+type Pipeline []*Step
+
+// NewPipeline resolves the order of steps from their dependencies.
+// Steps keep the declared order unless a step requires a later one.
+// The final step is appended automatically.
+func NewPipeline(steps []*Step) (Pipeline, error) {
+	byName := make(map[string]*Step)
+	for _, s := range steps {
+		if s.Name == "" {
+			return nil, errors.New("step has no name")
+		}
+		if s.Run == nil {
+			return nil, errors.New("step has no operation: " + s.Name)
+		}
+		if s.Name == FinalStepName {
+			return nil, errors.New("reserved step name: " + s.Name)
+		}
+		if _, ok := byName[s.Name]; ok {
+			return nil, errors.New("duplicate step: " + s.Name)
+		}
+		byName[s.Name] = s
+	}
+	for _, s := range steps {
+		for _, r := range s.Requires {
+			if _, ok := byName[r]; !ok {
+				return nil, fmt.Errorf("step %s requires unknown step %s", s.Name, r)
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int)
+	p := make(Pipeline, 0, len(steps)+1)
+	var visit func(s *Step) error
+	visit = func(s *Step) error {
+		switch state[s.Name] {
+		case visiting:
+			return errors.New("circular dependency at step " + s.Name)
+		case visited:
+			return nil
+		}
+		state[s.Name] = visiting
+		for _, r := range s.Requires {
+			if err := visit(byName[r]); err != nil {
+				return err
+			}
+		}
+		state[s.Name] = visited
+		p = append(p, s)
+		return nil
+	}
+	for _, s := range steps {
+		if err := visit(s); err != nil {
+			return nil, err
+		}
+	}
+
+	p = append(p, &Step{
+		Name: FinalStepName,
+		Run: func(ctx context.Context, req *neco.UpdateRequest) error {
+			return nil
+		},
+	})
+	return p, nil
+}
+
+// FinalStep returns the step number of the final step.
+func (p Pipeline) FinalStep() int {
+	return len(p)
+}
+
+// Step returns the step of the given number.
+// It returns nil if the number is out of range.
+func (p Pipeline) Step(n int) *Step {
+	if n < 1 || n > len(p) {
+		return nil
+	}
+	return p[n-1]
+}
+
+// Name returns the name of the step of the given number.
+func (p Pipeline) Name(n int) string {
+	s := p.Step(n)
+	if s == nil {
+		return ""
+	}
+	return s.Name
+}
@@ -0,0 +1,70 @@
+package worker
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/cybozu-go/neco"
+)
+
+func nopRun(ctx context.Context, req *neco.UpdateRequest) error {
+	return nil
+}
+
+func pipelineNames(p Pipeline) []string {
+	names := make([]string, len(p))
+	for i, s := range p {
+		names[i] = s.Name
+	}
+	return names
+}
+
+func TestNewPipeline(t *testing.T) {
+	p, err := NewPipeline([]*Step{
+		{Name: "a", Run: nopRun},
+		{Name: "b", Requires: []string{"c"}, Run: nopRun},
+		{Name: "c", Requires: []string{"a"}, Run: nopRun},
+		{Name: "d", Run: nopRun},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"a", "c", "b", "d", FinalStepName}
+	if names := pipelineNames(p); !reflect.DeepEqual(names, expected) {
+		t.Error("unexpected order:", names)
+	}
+	if p.FinalStep() != 5 {
+		t.Error("unexpected final step:", p.FinalStep())
+	}
+	if p.Name(2) != "c" || p.Name(0) != "" || p.Name(6) != "" {
+		t.Error("unexpected name")
+	}
+
+	invalid := map[string][]*Step{
+		"no-name":   {{Run: nopRun}},
+		"no-run":    {{Name: "a"}},
+		"duplicate": {{Name: "a", Run: nopRun}, {Name: "a", Run: nopRun}},
+		"reserved":  {{Name: FinalStepName, Run: nopRun}},
+		"unknown":   {{Name: "a", Requires: []string{"x"}, Run: nopRun}},
+		"circular": {
+			{Name: "a", Requires: []string{"b"}, Run: nopRun},
+			{Name: "b", Requires: []string{"a"}, Run: nopRun},
+		},
+	}
+	for name, steps := range invalid {
+		if _, err := NewPipeline(steps); err == nil {
+			t.Error("should fail:", name)
+		}
+	}
+}
+
+func TestOperatorSteps(t *testing.T) {
+	p, err := DefaultPipeline()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.FinalStep() != 18 {
+		t.Error("unexpected number of steps:", p.FinalStep())
+	}
+}
@@ -58,7 +58,7 @@ func (o *operator) replaceVaultFiles(ctx context.Context, lrns []int) (bool, err
 		return false, err
 	}
 
-	r1, err := replaceFile(neco.ServiceFile(neco.VaultService), buf.Bytes(), 0644)
+	r1, err := o.replaceFile(neco.ServiceFile(neco.VaultService), buf.Bytes(), 0644)
 	if err != nil {
 		return false, err
 	}
@@ -69,7 +69,7 @@ func (o *operator) replaceVaultFiles(ctx context.Context, lrns []int) (bool, err
 		return false, err
 	}
 
-	r2, err := replaceFile(neco.VaultConfFile, buf.Bytes(), 0644)
+	r2, err := o.replaceFile(neco.VaultConfFile, buf.Bytes(), 0644)
 	if err != nil {
 		return false, err
 	}
@@ -5,9 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"sort"
+	"time"
 
 	"github.com/cybozu-go/log"
 	"github.com/cybozu-go/neco"
+	"github.com/cybozu-go/neco/metrics"
 	"github.com/cybozu-go/neco/storage"
 	clientv3 "go.etcd.io/etcd/client/v3"
 )
@@ -22,10 +24,11 @@ type Worker struct {
 	operator Operator
 
 	// internal states
-	req     *neco.UpdateRequest
-	step    int
-	barrier Barrier
-	bch     chan struct{}
+	req      *neco.UpdateRequest
+	pipeline Pipeline
+	step     int
+	statuses map[int]*neco.UpdateStatus
+	bch      chan struct{}
 }
 
 // NewWorker returns a *Worker.
@@ -46,7 +49,8 @@ func NewWorker(ec *clientv3.Client, op Operator, version string, mylrn int) *Wor
 //
 // Run works as follows:
 //
-//  1. Check the current request.  If the request is not found, go to 5.
+//  1. Check the current request.  If the request is not found, or if this
+//     boot server is not a target of the current rollout stage, go to 5.
 //
 //  2. If locally installed neco package is older than the requested version,
 //     neco-worker updates the package, then exits to be restarted by systemd.
@@ -81,7 +85,7 @@ func (w *Worker) Run(ctx context.Context) error {
 			continue
 		}
 
-		if !req.IsMember(w.mylrn) {
+		if !req.IsTarget(w.mylrn) {
 			req, modRev, err = w.storage.WaitRequest(ctx, rev)
 			rev = modRev
 			continue
@@ -110,7 +114,7 @@ func (w *Worker) Run(ctx context.Context) error {
 			rev = modRev
 			continue
 		}
-		if neco.UpdateCompleted(req.Version, req.Servers, stMap) {
+		if neco.UpdateCompleted(req.Version, req.Targets(), stMap) {
 			log.Info("previous update was completed successfully", nil)
 			req, modRev, err = w.storage.WaitRequest(ctx, rev)
 			rev = modRev
@@ -135,22 +139,37 @@ func (w *Worker) Run(ctx context.Context) error {
 }
 
 func (w *Worker) update(ctx context.Context, modRev int64) error {
-	status := neco.UpdateStatus{
-		Version: w.req.Version,
-		Step:    1,
-		Cond:    neco.CondRunning,
-	}
-	err := w.storage.PutStatus(ctx, w.mylrn, status)
+	w.pipeline = w.operator.Pipeline()
+	w.statuses = make(map[int]*neco.UpdateStatus)
+	w.step = 1
+	err := w.putStatus(ctx, neco.CondRunning, "")
 	if err != nil {
 		return err
 	}
-	w.step = 1
-	w.barrier = NewBarrier(w.req.Servers)
+	metrics.SetWorkerRequest(w.req.Version)
+	metrics.SetWorkerStep(w.step)
 
 	watcher := storage.NewStatusWatcher(w.handleCurrent, w.handleWorkerStatus, w.registerAbort)
 	return watcher.Watch(ctx, w.storage, modRev)
 }
 
+// putStatus puts the status of this worker for the current step.
+func (w *Worker) putStatus(ctx context.Context, cond neco.UpdateCondition, message string) error {
+	st := &neco.UpdateStatus{
+		Version:  w.req.Version,
+		Step:     w.step,
+		StepName: w.pipeline.Name(w.step),
+		Cond:     cond,
+		Message:  message,
+	}
+	err := w.storage.PutStatus(ctx, w.mylrn, *st)
+	if err != nil {
+		return err
+	}
+	w.statuses[w.mylrn] = st
+	return nil
+}
+
 func (w *Worker) handleCurrent(ctx context.Context, req *neco.UpdateRequest) (bool, error) {
 	if req.Stop {
 		log.Warn("request was canceled", map[string]interface{}{
@@ -169,11 +188,11 @@ func (w *Worker) handleCurrent(ctx context.Context, req *neco.UpdateRequest) (bo
 }
 
 func (w *Worker) handleWorkerStatus(ctx context.Context, lrn int, st *neco.UpdateStatus) (bool, error) {
-	if !w.req.IsMember(lrn) {
+	if !w.req.IsTarget(lrn) {
 		log.Warn("ignoring unexpected boot server", map[string]interface{}{
 			"lrn":     lrn,
 			"version": w.req.Version,
-			"servers": w.req.Servers,
+			"targets": w.req.Targets(),
 		})
 		return false, nil
 	}
@@ -183,59 +202,79 @@ func (w *Worker) handleWorkerStatus(ctx context.Context, lrn int, st *neco.Updat
 	if st.Cond == neco.CondAbort {
 		return false, fmt.Errorf("other boot server failed to update: %d", lrn)
 	}
-	if st.Step != w.step {
-		return false, fmt.Errorf("unexpected step in worker status: %d", st.Step)
-	}
-	if st.Cond == neco.CondComplete {
-		return false, fmt.Errorf("other boot server reports completion: %d", lrn)
+
+	// The status of this worker is recorded by putStatus.
+	// The events for it may be older than the recorded one.
+	// Boot servers are at most one step apart because every step is synchronized.
+	if lrn != w.mylrn {
+		if st.Step < 1 || st.Step > w.pipeline.FinalStep() || st.Step < w.step-1 || st.Step > w.step+1 {
+			return false, fmt.Errorf("unexpected step in worker status: %d", st.Step)
+		}
+		if st.Cond == neco.CondComplete {
+			return false, fmt.Errorf("other boot server reports completion: %d", lrn)
+		}
+		w.statuses[lrn] = st
 	}
 
-	if w.barrier.Check(lrn) {
+	return w.advance(ctx)
+}
+
+// advance runs steps as far as possible.
+// Each step is run when all boot servers reach the step.
+func (w *Worker) advance(ctx context.Context) (bool, error) {
+	for {
+		if !w.arrived() {
+			return false, nil
+		}
 		select {
 		case w.bch <- struct{}{}:
 		default:
 		}
-		return w.runStep(ctx)
+
+		done, err := w.runStep(ctx)
+		if err != nil || done {
+			return done, err
+		}
 	}
+}
 
-	return false, nil
+// arrived returns true if all target boot servers reach the current step.
+func (w *Worker) arrived() bool {
+	for _, lrn := range w.req.Targets() {
+		st, ok := w.statuses[lrn]
+		if !ok || st.Step != w.step {
+			return false
+		}
+	}
+	return true
 }
 
 func (w *Worker) registerAbort(ctx context.Context, err error) error {
-	status := neco.UpdateStatus{
-		Version: w.req.Version,
-		Step:    w.step,
-		Cond:    neco.CondAbort,
-		Message: err.Error(),
-	}
-	err = w.storage.PutStatus(ctx, w.mylrn, status)
-	if err != nil {
+	metrics.WorkerAbortsTotal.Inc()
+	err2 := w.putStatus(ctx, neco.CondAbort, err.Error())
+	if err2 != nil {
 		log.Warn("failed to update status", map[string]interface{}{
-			log.FnError:      err,
+			log.FnError:      err2,
 			"step":           w.step,
 			"original_error": err,
 		})
 	}
-	return err
+	return err2
 }
 
+// runStep runs the current step.
 func (w *Worker) runStep(ctx context.Context) (bool, error) {
-	err := w.operator.RunStep(ctx, w.req, w.step)
+	err := w.runOne(ctx, w.step)
 
 	if err != nil {
 		log.Error("update failed", map[string]interface{}{
 			"version":   w.req.Version,
 			"step":      w.step,
+			"step_name": w.pipeline.Name(w.step),
 			log.FnError: err,
 		})
-		st := neco.UpdateStatus{
-			Version: w.req.Version,
-			Step:    w.step,
-			Cond:    neco.CondAbort,
-			Message: err.Error(),
-		}
 
-		err2 := w.storage.PutStatus(ctx, w.mylrn, st)
+		err2 := w.putStatus(ctx, neco.CondAbort, err.Error())
 		if err2 != nil {
 			log.Warn("failed to put status", map[string]interface{}{
 				log.FnError: err2.Error(),
@@ -245,27 +284,17 @@ func (w *Worker) runStep(ctx context.Context) (bool, error) {
 		return false, err
 	}
 
-	if w.step != w.operator.FinalStep() {
+	if w.step != w.pipeline.FinalStep() {
 		w.step++
-		w.barrier = NewBarrier(w.req.Servers)
-		st := neco.UpdateStatus{
-			Version: w.req.Version,
-			Step:    w.step,
-			Cond:    neco.CondRunning,
-		}
-		err = w.storage.PutStatus(ctx, w.mylrn, st)
+		metrics.SetWorkerStep(w.step)
+		err = w.putStatus(ctx, neco.CondRunning, "")
 		if err != nil {
 			return false, err
 		}
 		return false, nil
 	}
 
-	st := neco.UpdateStatus{
-		Version: w.req.Version,
-		Step:    w.step,
-		Cond:    neco.CondComplete,
-	}
-	err = w.storage.PutStatus(ctx, w.mylrn, st)
+	err = w.putStatus(ctx, neco.CondComplete, "")
 	if err != nil {
 		return false, err
 	}
@@ -274,9 +303,61 @@ func (w *Worker) runStep(ctx context.Context) (bool, error) {
 		"step":    w.step,
 	})
 
+	// etcd is not updated in the canary stage.
+	if w.req.InCanary() {
+		return true, nil
+	}
+
 	// Restart etcd always.
 	// NOTE: ignore error due to os.Exit() is called in this function.
 	w.operator.RestartEtcd(sort.SearchInts(w.req.Servers, w.mylrn), w.req)
 
 	return true, nil
 }
+
+func (w *Worker) runOne(ctx context.Context, n int) error {
+	s := w.pipeline.Step(n)
+	if w.req.InCanary() && !s.Canary && s.Name != FinalStepName {
+		log.Info("skip step in the canary stage", map[string]interface{}{
+			"version":   w.req.Version,
+			"step":      n,
+			"step_name": s.Name,
+		})
+		return nil
+	}
+	log.Info("run step", map[string]interface{}{
+		"version":   w.req.Version,
+		"step":      n,
+		"step_name": s.Name,
+	})
+	startedAt := time.Now()
+	err := s.Run(ctx, w.req)
+	metrics.ObserveWorkerStep(n, time.Since(startedAt))
+	w.recordHistory(ctx, n, startedAt, err)
+	return err
+}
+
+func (w *Worker) recordHistory(ctx context.Context, step int, startedAt time.Time, stepErr error) {
+	h := &neco.StepHistory{
+		Version:   w.req.Version,
+		LRN:       w.mylrn,
+		Step:      step,
+		StepName:  w.pipeline.Name(step),
+		StartedAt: startedAt.UTC(),
+		EndedAt:   time.Now().UTC(),
+		Cond:      neco.CondComplete,
+	}
+	if stepErr != nil {
+		h.Cond = neco.CondAbort
+		h.Message = stepErr.Error()
+	}
+
+	err := w.storage.PutStepHistory(ctx, h)
+	if err != nil {
+		log.Warn("failed to record step history", map[string]interface{}{
+			log.FnError: err,
+			"version":   w.req.Version,
+			"step":      step,
+		})
+	}
+}
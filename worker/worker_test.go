@@ -18,6 +18,7 @@ var errTest = errors.New("test error")
 type mockOp struct {
 	FailNecoUpdate bool
 	FailAt         int
+	Canary         bool
 
 	NecoUpdated bool
 	Step        int
@@ -33,6 +34,14 @@ func newMock(failNeco bool, failAt int) *mockOp {
 	}
 }
 
+// newMockCanary returns a mock whose steps can run in the canary stage.
+func newMockCanary(failAt int) *mockOp {
+	return &mockOp{
+		FailAt: failAt,
+		Canary: true,
+	}
+}
+
 func expect(necoUpdated bool, step int, req *neco.UpdateRequest) *mockOp {
 	return &mockOp{
 		NecoUpdated: necoUpdated,
@@ -60,17 +69,22 @@ func (op *mockOp) UpdateNeco(ctx context.Context, req *neco.UpdateRequest) error
 	return nil
 }
 
-func (op *mockOp) FinalStep() int {
-	return 2
+func (op *mockOp) Pipeline() Pipeline {
+	return Pipeline{
+		{Name: "step1", Canary: op.Canary, Run: op.runStep(1)},
+		{Name: FinalStepName, Run: op.runStep(2)},
+	}
 }
 
-func (op *mockOp) RunStep(ctx context.Context, req *neco.UpdateRequest, step int) error {
-	op.Step = step
-	op.Req = req
-	if op.FailAt == step {
-		return errTest
+func (op *mockOp) runStep(step int) func(ctx context.Context, req *neco.UpdateRequest) error {
+	return func(ctx context.Context, req *neco.UpdateRequest) error {
+		op.Step = step
+		op.Req = req
+		if op.FailAt == step {
+			return errTest
+		}
+		return nil
 	}
-	return nil
 }
 
 func (op *mockOp) StartServices(ctx context.Context) error {
@@ -142,6 +156,22 @@ var (
 		Servers: []int{0, 1},
 		Stop:    true,
 	}
+	testReqCanary = &neco.UpdateRequest{
+		Version: "1.0.0",
+		Servers: []int{0, 1},
+		Strategy: &neco.RolloutStrategy{
+			Canary: []int{0},
+			Stage:  neco.StageCanary,
+		},
+	}
+	testReqRemaining = &neco.UpdateRequest{
+		Version: "1.0.0",
+		Servers: []int{0, 1},
+		Strategy: &neco.RolloutStrategy{
+			Canary: []int{0},
+			Stage:  neco.StageRemaining,
+		},
+	}
 )
 
 func TestWorker(t *testing.T) {
@@ -218,6 +248,44 @@ func TestWorker(t *testing.T) {
 			Expect: expect(false, 2, testReq),
 			Cond:   neco.CondComplete,
 		},
+		{
+			Name: "canary-successful",
+			Input: []testInput{
+				inputRequest(testReqCanary, false),
+			},
+			Op:     newMock(false, 0),
+			Expect: expect(false, 2, testReqCanary),
+			Cond:   neco.CondComplete,
+		},
+		{
+			Name: "canary-skips-cluster-steps",
+			Input: []testInput{
+				inputRequest(testReqCanary, false),
+			},
+			Op:     newMock(false, 1),
+			Expect: expect(false, 2, testReqCanary),
+			Cond:   neco.CondComplete,
+		},
+		{
+			Name: "canary-runs-canary-steps",
+			Input: []testInput{
+				inputRequest(testReqCanary, false),
+			},
+			Op:     newMockCanary(1),
+			Expect: expect(false, 1, testReqCanary),
+			Error:  true,
+			Cond:   neco.CondAbort,
+		},
+		{
+			Name: "remaining-reruns-all-steps",
+			Input: []testInput{
+				inputRequest(testReqRemaining, false),
+				inputStatus(1, testStatus(1, neco.CondRunning), false),
+			},
+			Op:     newMock(false, 0),
+			Expect: expect(false, 1, testReqRemaining),
+			Cond:   neco.CondRunning,
+		},
 		{
 			Name: "update-successful-then-new-request",
 			Input: []testInput{